@@ -0,0 +1,146 @@
+package goresource
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rockstardevs/goresource/codec"
+	"github.com/rockstardevs/goresource/store"
+)
+
+// GroupParam is the query parameter listing the fields aggregations group by,
+// separated by commas.
+const GroupParam = "group"
+
+// MetricParam is the query parameter listing the metrics aggregations compute,
+// as <op>:<field> or count, separated by commas or repeated. For example
+// metric=count,avg:price.
+const MetricParam = "metric"
+
+// TotalCountHeader is the header HEAD requests for collections report the
+// number of matching entities in.
+const TotalCountHeader = "X-Total-Count"
+
+// CountResult is the response to count requests.
+type CountResult struct {
+	Count int `json:"count"`
+}
+
+// aggregation parses the aggregation described by the query's group and
+// metric parameters.
+func aggregation(query url.Values) (store.Aggregation, error) {
+	var agg store.Aggregation
+	for _, f := range split(query[GroupParam]) {
+		agg.GroupBy = append(agg.GroupBy, f)
+	}
+	for _, m := range split(query[MetricParam]) {
+		parts := strings.SplitN(m, ":", 2)
+		metric := store.Metric{Op: parts[0]}
+		if len(parts) == 2 {
+			metric.Field = parts[1]
+		}
+		agg.Metrics = append(agg.Metrics, metric)
+	}
+	if len(agg.Metrics) == 0 {
+		agg.Metrics = []store.Metric{{Op: store.OpCount}}
+	}
+	if err := agg.Validate(); err != nil {
+		return agg, fmt.Errorf("%w: %s", ErrInvalidQuery, err)
+	}
+	return agg, nil
+}
+
+// split returns the non empty comma separated items in the given values.
+func split(values []string) []string {
+	var items []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// CountEntities returns the number of resource entities matching the query's
+// filters, or the results of its full text query if it has one.
+func (manager DefaultManager) CountEntities(ctx context.Context, query url.Values) (int, error) {
+	if query.Get(SearchParam) != "" {
+		result, err := manager.search(ctx, query)
+		return len(result), err
+	}
+	return store.Count(ctx, manager.Store, manager.Name, filters(query))
+}
+
+// AggregateEntities groups the resource entities matching the query's filters
+// by the fields in its group parameter, computing the metrics in its metric
+// parameter over each group. Without metrics the groups are counted.
+func (manager DefaultManager) AggregateEntities(ctx context.Context, query url.Values) (interface{}, error) {
+	agg, err := aggregation(query)
+	if err != nil {
+		return nil, err
+	}
+	return store.Aggregate(ctx, manager.Store, manager.Name, filters(query), agg)
+}
+
+// Count is the http handler for counting the entities of this resource
+// matching the request's filters.
+func (r Resource) Count(rw http.ResponseWriter, req *http.Request) {
+	c, ok := r.negotiate(rw, req)
+	if !ok {
+		return
+	}
+	if n, ok := r.count(rw, req); ok {
+		codec.Write(c, CountResult{n}, rw)
+	}
+}
+
+// count counts the entities matching the request's filters, setting the total
+// count header. It responds with an error if the count fails.
+func (r Resource) count(rw http.ResponseWriter, req *http.Request) (int, bool) {
+	am, ok := r.manager.(AggregateManager)
+	if !ok {
+		writeError(rw, "Method Not Supported", http.StatusNotImplemented)
+		return 0, false
+	}
+	query := r.scopeQuery(req, req.URL.Query())
+	ctx, span := r.startSpan(req.Context(), "CountEntities", AttrFilterKeys.StringSlice(store.FilterKeys(query)))
+	n, err := am.CountEntities(ctx, query)
+	span.SetAttributes(AttrResultCount.Int(n))
+	endSpan(span, err)
+	if err != nil {
+		writeError(rw, err.Error(), errorStatus(err))
+		return 0, false
+	}
+	rw.Header().Set(TotalCountHeader, fmt.Sprint(n))
+	return n, true
+}
+
+// Aggregate is the http handler for aggregating the entities of this resource
+// matching the request's filters, grouped and measured as given by the group
+// and metric query parameters.
+func (r Resource) Aggregate(rw http.ResponseWriter, req *http.Request) {
+	c, ok := r.negotiate(rw, req)
+	if !ok {
+		return
+	}
+	am, ok := r.manager.(AggregateManager)
+	if !ok {
+		writeError(rw, "Method Not Supported", http.StatusNotImplemented)
+		return
+	}
+	query := r.scopeQuery(req, req.URL.Query())
+	ctx, span := r.startSpan(req.Context(), "AggregateEntities", AttrFilterKeys.StringSlice(store.FilterKeys(query)))
+	resp, err := am.AggregateEntities(ctx, query)
+	span.SetAttributes(resultCount(resp))
+	endSpan(span, err)
+	if err != nil {
+		writeError(rw, err.Error(), errorStatus(err))
+		return
+	}
+	codec.Write(c, resp, rw)
+}
@@ -0,0 +1,105 @@
+package goresource_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"goresource"
+	"goresource/mocks"
+	storepkg "goresource/store"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Aggregation", func() {
+	var (
+		ctrl   *gomock.Controller
+		store  *mocks.MockStore
+		router *mux.Router
+		books  = []map[string]interface{}{
+			{"author": "b", "price": 10.0},
+			{"author": "a", "price": 20.0},
+			{"author": "b", "price": 30.0},
+		}
+	)
+
+	// serve returns the response to the given request.
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStore(ctrl)
+		router = mux.NewRouter()
+		goresource.NewResource(bookManager{goresource.NewDefaultManager("books", store)}, router)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("counts entities matching the filters.", func() {
+		store.EXPECT().ListEntities(gomock.Any(), "books", url.Values{"tag": {"x"}}, gomock.Any()).SetArg(3, books).Return(nil)
+		w := serve("GET", "/books/count?tag=x")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(MatchJSON(`{"count": 3}`))
+		Expect(w.Header().Get(goresource.TotalCountHeader)).To(Equal("3"))
+	})
+
+	It("reports the count in a header for HEAD requests of the collection.", func() {
+		store.EXPECT().ListEntities(gomock.Any(), "books", url.Values{"tag": {"x"}}, gomock.Any()).SetArg(3, books).Return(nil).Times(1)
+		w := serve("HEAD", "/books?tag=x")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get(goresource.TotalCountHeader)).To(Equal("3"))
+		Expect(w.Body.Len()).To(Equal(0))
+	})
+
+	It("computes metrics grouped by the given fields.", func() {
+		store.EXPECT().ListEntities(gomock.Any(), "books", url.Values{"tag": {"x"}}, gomock.Any()).SetArg(3, books).Return(nil)
+		w := serve("GET", "/books/aggregate?tag=x&group=author&metric=count,avg:price&metric=sum:price")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(MatchJSON(`[
+			{"author": "a", "count": 1, "avg_price": 20, "sum_price": 20},
+			{"author": "b", "count": 2, "avg_price": 20, "sum_price": 40}
+		]`))
+	})
+
+	It("counts groups without metrics.", func() {
+		store.EXPECT().ListEntities(gomock.Any(), "books", url.Values{}, gomock.Any()).SetArg(3, books).Return(nil)
+		w := serve("GET", "/books/aggregate?group=author")
+		Expect(w.Body.String()).To(MatchJSON(`[{"author": "a", "count": 1}, {"author": "b", "count": 2}]`))
+	})
+
+	It("rejects invalid metrics.", func() {
+		w := serve("GET", "/books/aggregate?metric=median:price")
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		w = serve("GET", "/books/aggregate?metric=sum")
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("rejects fields naming pipeline expressions.", func() {
+		for _, target := range []string{"/books/aggregate?group=$$ROOT", "/books/aggregate?group=$author",
+			"/books/aggregate?metric=max:$$ROOT", "/books/aggregate?group=a.$b"} {
+			Expect(serve("GET", target).Code).To(Equal(http.StatusBadRequest), target)
+		}
+	})
+
+	Context("given a manager that can't aggregate", func() {
+		It("does not serve counts or aggregates.", func() {
+			manager := mocks.NewMockResourceManager(ctrl)
+			manager.EXPECT().GetName().Return("authors").AnyTimes()
+			manager.EXPECT().GetEntity(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, storepkg.ErrNotFound).Times(2)
+			goresource.NewResource(manager, router)
+			Expect(serve("GET", "/authors/count").Code).To(Equal(http.StatusNotFound))
+			Expect(serve("GET", "/authors/aggregate").Code).To(Equal(http.StatusNotFound))
+		})
+	})
+})
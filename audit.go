@@ -0,0 +1,189 @@
+package goresource
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/rockstardevs/goresource/store"
+)
+
+// Audit actions recorded for entity changes.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditRecord describes a single change made to an entity through a Resource.
+type AuditRecord struct {
+	Time      time.Time              `json:"time" bson:"time"`
+	Resource  string                 `json:"resource" bson:"resource"`
+	Action    string                 `json:"action" bson:"action"`
+	EntityID  string                 `json:"entityId" bson:"entityId"`
+	Principal string                 `json:"principal" bson:"principal"`
+	Before    interface{}            `json:"before,omitempty" bson:"before,omitempty"`
+	After     interface{}            `json:"after,omitempty" bson:"after,omitempty"`
+	Changes   map[string]FieldChange `json:"changes,omitempty" bson:"changes,omitempty"`
+}
+
+// FieldChange holds the old and new value of a single changed field.
+type FieldChange struct {
+	Before interface{} `json:"before" bson:"before"`
+	After  interface{} `json:"after" bson:"after"`
+}
+
+// AuditSink is implemented by destinations for audit records.
+type AuditSink interface {
+	Record(ctx context.Context, record AuditRecord) error
+}
+
+// PrincipalFunc identifies who made the given request.
+type PrincipalFunc func(req *http.Request) string
+
+// BasicAuthPrincipal identifies the principal by the basic auth username.
+func BasicAuthPrincipal(req *http.Request) string {
+	user, _, _ := req.BasicAuth()
+	return user
+}
+
+// StoreAuditSink persists audit records as entities in a store collection.
+type StoreAuditSink struct {
+	Name  string
+	Store store.Store
+}
+
+// NewStoreAuditSink returns an AuditSink writing to the named collection of the given store.
+func NewStoreAuditSink(name string, s store.Store) *StoreAuditSink {
+	return &StoreAuditSink{Name: name, Store: s}
+}
+
+// Record persists the given audit record.
+func (s *StoreAuditSink) Record(ctx context.Context, record AuditRecord) error {
+	result := make(map[string]interface{})
+	return s.Store.CreateEntity(ctx, s.Name, record, &result)
+}
+
+// WriterAuditSink writes audit records as newline delimited json to a writer.
+type WriterAuditSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// NewWriterAuditSink returns an AuditSink writing to the given writer.
+func NewWriterAuditSink(w io.Writer) *WriterAuditSink {
+	return &WriterAuditSink{encoder: json.NewEncoder(w)}
+}
+
+// NewFileAuditSink returns an AuditSink appending to the file at the given path.
+func NewFileAuditSink(path string) (*WriterAuditSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	sink := NewWriterAuditSink(f)
+	sink.closer = f
+	return sink, nil
+}
+
+// Record writes the given audit record as a single line.
+func (s *WriterAuditSink) Record(_ context.Context, record AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.encoder.Encode(record)
+}
+
+// Close closes the underlying file, if this sink owns one.
+func (s *WriterAuditSink) Close() error {
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+// audit records a change to an entity with the configured audit sink, if any.
+// Failures to record are logged rather than failing the request.
+func (r Resource) audit(req *http.Request, action string, id string, before, after interface{}) {
+	if r.auditSink == nil {
+		return
+	}
+	record := AuditRecord{
+		Time:      time.Now().UTC(),
+		Resource:  r.manager.GetName(),
+		Action:    action,
+		EntityID:  id,
+		Principal: r.principal(req),
+		Before:    before,
+		After:     after,
+		Changes:   diff(before, after),
+	}
+	if err := r.auditSink.Record(req.Context(), record); err != nil {
+		r.logger.ErrorContext(req.Context(), "error recording audit", "resource", record.Resource,
+			"action", action, "id", id, "error", err.Error())
+	}
+}
+
+// auditBefore fetches the current state of the entity with the given id, for
+// recording changes to it. Nothing is fetched when auditing is disabled.
+func (r Resource) auditBefore(req *http.Request, id string) interface{} {
+	if r.auditSink == nil {
+		return nil
+	}
+	before, err := r.manager.GetEntity(req.Context(), id, req.URL.Query())
+	if err != nil {
+		return nil
+	}
+	return before
+}
+
+// entityID returns the id of the given manager result.
+func entityID(v interface{}) string {
+	if e, ok := v.(Entity); ok {
+		return e.GetId()
+	}
+	fields := toFields(v)
+	for _, key := range []string{"id", "_id"} {
+		if id, ok := fields[key].(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// diff returns the top level fields that differ between before and after.
+// Both values are compared by their json representation.
+func diff(before, after interface{}) map[string]FieldChange {
+	b, a := toFields(before), toFields(after)
+	changes := make(map[string]FieldChange)
+	for k, v := range b {
+		if w, ok := a[k]; !ok || !reflect.DeepEqual(v, w) {
+			changes[k] = FieldChange{Before: v, After: a[k]}
+		}
+	}
+	for k, w := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = FieldChange{After: w}
+		}
+	}
+	return changes
+}
+
+// toFields converts the given value into a map of its json fields.
+func toFields(v interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if v == nil {
+		return fields
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+	return fields
+}
@@ -0,0 +1,145 @@
+package goresource_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"goresource"
+	"goresource/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resource audit trail", func() {
+	var (
+		ctrl    *gomock.Controller
+		manager *mocks.MockResourceManager
+		router  *mux.Router
+		rw      *httptest.ResponseRecorder
+		buf     *bytes.Buffer
+	)
+
+	records := func() []goresource.AuditRecord {
+		var result []goresource.AuditRecord
+		decoder := json.NewDecoder(buf)
+		for decoder.More() {
+			var record goresource.AuditRecord
+			Expect(decoder.Decode(&record)).To(Succeed())
+			result = append(result, record)
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		manager = mocks.NewMockResourceManager(ctrl)
+		router = mux.NewRouter().PathPrefix("/api").Subrouter()
+		rw = httptest.NewRecorder()
+		buf = &bytes.Buffer{}
+		manager.EXPECT().GetName().AnyTimes().Return("test")
+		goresource.NewResource(manager, router, goresource.WithAuditSink(goresource.NewWriterAuditSink(buf)))
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("records created entities with the principal.", func() {
+		body := ioutil.NopCloser(strings.NewReader("fake-content"))
+		e := &mocks.MockEntity{}
+		req, _ := http.NewRequest("POST", "/api/test", body)
+		req.SetBasicAuth("alice", "secret")
+		manager.EXPECT().ParseJSON(body).Return(e, nil)
+		manager.EXPECT().CreateEntity(gomock.Any(), e, gomock.Any()).Return(map[string]interface{}{"id": "fakeid", "name": "foo"}, nil)
+		router.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		got := records()
+		Expect(len(got)).To(Equal(1))
+		Expect(got[0].Action).To(Equal(goresource.AuditCreate))
+		Expect(got[0].Resource).To(Equal("test"))
+		Expect(got[0].EntityID).To(Equal("fakeid"))
+		Expect(got[0].Principal).To(Equal("alice"))
+		Expect(got[0].Before).To(BeNil())
+		Expect(got[0].Changes).To(HaveKey("name"))
+	})
+
+	It("records updated entities with the changed fields.", func() {
+		body := ioutil.NopCloser(strings.NewReader("fake-content"))
+		e := &mocks.MockEntity{Id: "fakeid"}
+		req, _ := http.NewRequest("PUT", "/api/test/fakeid", body)
+		manager.EXPECT().ParseJSON(body).Return(e, nil)
+		manager.EXPECT().GetEntity(gomock.Any(), "fakeid", gomock.Any()).Return(map[string]interface{}{"id": "fakeid", "name": "foo", "tag": "a"}, nil)
+		manager.EXPECT().UpdateEntity(gomock.Any(), "fakeid", e, gomock.Any()).Return(map[string]interface{}{"id": "fakeid", "name": "bar", "tag": "a"}, nil)
+		router.ServeHTTP(rw, req)
+		got := records()
+		Expect(len(got)).To(Equal(1))
+		Expect(got[0].Action).To(Equal(goresource.AuditUpdate))
+		Expect(got[0].Changes).To(Equal(map[string]goresource.FieldChange{"name": {Before: "foo", After: "bar"}}))
+	})
+
+	It("records deleted entities with their last state.", func() {
+		req, _ := http.NewRequest("DELETE", "/api/test/fakeid", nil)
+		manager.EXPECT().GetEntity(gomock.Any(), "fakeid", gomock.Any()).Return(map[string]interface{}{"id": "fakeid"}, nil)
+		manager.EXPECT().DeleteEntity(gomock.Any(), "fakeid", gomock.Any()).Return(nil)
+		router.ServeHTTP(rw, req)
+		got := records()
+		Expect(len(got)).To(Equal(1))
+		Expect(got[0].Action).To(Equal(goresource.AuditDelete))
+		Expect(got[0].Before).To(Equal(map[string]interface{}{"id": "fakeid"}))
+		Expect(got[0].After).To(BeNil())
+	})
+
+	It("does not record failed changes.", func() {
+		req, _ := http.NewRequest("DELETE", "/api/test/fakeid", nil)
+		manager.EXPECT().GetEntity(gomock.Any(), "fakeid", gomock.Any()).Return(nil, fmt.Errorf("not found"))
+		manager.EXPECT().DeleteEntity(gomock.Any(), "fakeid", gomock.Any()).Return(fmt.Errorf("test error"))
+		router.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusInternalServerError))
+		Expect(records()).To(BeEmpty())
+	})
+})
+
+var _ = Describe("AuditSink", func() {
+	var record = goresource.AuditRecord{Resource: "test", Action: goresource.AuditDelete, EntityID: "fakeid"}
+
+	Describe("StoreAuditSink", func() {
+		It("persists records in the given collection.", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			defer ctrl.Finish()
+			store := mocks.NewMockStore(ctrl)
+			store.EXPECT().CreateEntity(gomock.Any(), "audit", record, gomock.Any()).Return(nil)
+			sink := goresource.NewStoreAuditSink("audit", store)
+			Expect(sink.Record(context.Background(), record)).To(Succeed())
+		})
+	})
+
+	Describe("FileAuditSink", func() {
+		It("appends records to the file.", func() {
+			dir, err := ioutil.TempDir("", "audit")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "audit.log")
+			sink, err := goresource.NewFileAuditSink(path)
+			Expect(err).To(BeNil())
+			Expect(sink.Record(context.Background(), record)).To(Succeed())
+			Expect(sink.Record(context.Background(), record)).To(Succeed())
+			Expect(sink.Close()).To(Succeed())
+			data, err := ioutil.ReadFile(path)
+			Expect(err).To(BeNil())
+			Expect(strings.Count(string(data), "\n")).To(Equal(2))
+			Expect(string(data)).To(ContainSubstring(`"entityId":"fakeid"`))
+		})
+	})
+})
@@ -0,0 +1,217 @@
+package goresource
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rockstardevs/goresource/codec"
+)
+
+// SurrogateKeyHeader lists the keys of the entities in a response, for shared
+// caches that purge by key. Responses to changes list the keys to purge.
+const SurrogateKeyHeader = "Surrogate-Key"
+
+// CachePolicy describes how responses may be cached, as a Cache-Control header.
+type CachePolicy struct {
+	// Public allows shared caches such as CDNs to store responses, otherwise
+	// only the client's own cache may.
+	Public bool
+	// MaxAge is how long responses are fresh for.
+	MaxAge time.Duration
+	// SharedMaxAge, if set, overrides MaxAge for shared caches.
+	SharedMaxAge time.Duration
+	// StaleWhileRevalidate is how long stale responses may be served while
+	// caches revalidate them in the background.
+	StaleWhileRevalidate time.Duration
+	// StaleIfError is how long stale responses may be served when revalidating fails.
+	StaleIfError time.Duration
+	// NoStore forbids storing responses at all, overriding the other fields.
+	NoStore bool
+	// Vary lists the request headers responses vary on, such as Authorization.
+	// Accept is added for managers with more than one format, and for
+	// collections of managers streaming them as NDJSON or CSV.
+	Vary []string
+	// ModifiedField names the entity field holding when it last changed, as
+	// an RFC 3339 time, for the Last-Modified header.
+	ModifiedField string
+}
+
+// IsZero reports whether the policy is unset.
+func (p CachePolicy) IsZero() bool {
+	return !p.Public && !p.NoStore && p.MaxAge == 0 && p.SharedMaxAge == 0 &&
+		p.StaleWhileRevalidate == 0 && p.StaleIfError == 0 && len(p.Vary) == 0 && p.ModifiedField == ""
+}
+
+// CacheControl returns the Cache-Control header value for the policy, empty if unset.
+func (p CachePolicy) CacheControl() string {
+	switch {
+	case p.NoStore:
+		return "no-store"
+	case p.IsZero():
+		return ""
+	}
+	directives := []string{"private"}
+	if p.Public {
+		directives[0] = "public"
+	}
+	directives = append(directives, fmt.Sprintf("max-age=%d", seconds(p.MaxAge)))
+	if p.SharedMaxAge > 0 {
+		directives = append(directives, fmt.Sprintf("s-maxage=%d", seconds(p.SharedMaxAge)))
+	}
+	if p.StaleWhileRevalidate > 0 {
+		directives = append(directives, fmt.Sprintf("stale-while-revalidate=%d", seconds(p.StaleWhileRevalidate)))
+	}
+	if p.StaleIfError > 0 {
+		directives = append(directives, fmt.Sprintf("stale-if-error=%d", seconds(p.StaleIfError)))
+	}
+	return strings.Join(directives, ", ")
+}
+
+func seconds(d time.Duration) int64 {
+	return int64(d / time.Second)
+}
+
+// ItemCachePolicy returns the caching policy of responses with a single entity.
+func (manager DefaultManager) ItemCachePolicy() CachePolicy {
+	return manager.ItemCache
+}
+
+// CollectionCachePolicy returns the caching policy of responses listing entities.
+func (manager DefaultManager) CollectionCachePolicy() CachePolicy {
+	return manager.CollectionCache
+}
+
+// cachePolicy returns the manager's caching policy for an item or collection response.
+func (r Resource) cachePolicy(id string) CachePolicy {
+	cm, ok := r.manager.(CacheManager)
+	switch {
+	case !ok:
+		return CachePolicy{}
+	case id != "":
+		return cm.ItemCachePolicy()
+	}
+	return cm.CollectionCachePolicy()
+}
+
+// setCacheHeaders sets the Cache-Control, Vary and Surrogate-Key headers of a
+// response with the entity with the given id, or of a collection if empty.
+func (r Resource) setCacheHeaders(rw http.ResponseWriter, id string, policy CachePolicy) {
+	if cc := policy.CacheControl(); cc != "" {
+		rw.Header().Set("Cache-Control", cc)
+	}
+	vary := policy.Vary
+	_, streams := r.manager.(StreamManager)
+	if len(r.Formats()) > 1 || (id == "" && streams) {
+		vary = append([]string{"Accept"}, vary...)
+	}
+	if len(vary) > 0 {
+		rw.Header().Set("Vary", strings.Join(vary, ", "))
+	}
+	rw.Header().Set(SurrogateKeyHeader, r.surrogateKeys(id))
+}
+
+// surrogateKeys returns the keys of the collection, and of the entity with the given id if any.
+func (r Resource) surrogateKeys(id string) string {
+	name := r.manager.GetName()
+	if id == "" {
+		return name
+	}
+	return name + " " + name + "/" + id
+}
+
+// writeCacheable writes a response to a get or head request with the
+// manager's caching policy and validators, responding with 304 Not Modified
+// if the client's cached copy is still current. Head responses have the same
+// headers as get responses, without the body.
+func (r Resource) writeCacheable(rw http.ResponseWriter, req *http.Request, c codec.Codec, id string, resp interface{}) {
+	encoded, err := c.Marshal(resp)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	policy := r.cachePolicy(id)
+	r.setCacheHeaders(rw, id, policy)
+	sum := sha256.Sum256(encoded)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	rw.Header().Set("ETag", etag)
+	modified := lastModified(resp, policy.ModifiedField)
+	if !modified.IsZero() {
+		rw.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(req, etag, modified) {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+	rw.Header().Set("Content-Type", c.ContentType())
+	rw.Header().Set("Content-Length", strconv.Itoa(len(encoded)))
+	rw.WriteHeader(http.StatusOK)
+	if req.Method != http.MethodHead {
+		rw.Write(encoded)
+	}
+}
+
+// notModified evaluates the request's If-None-Match header, or its
+// If-Modified-Since header if it has none.
+func notModified(req *http.Request, etag string, modified time.Time) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	return err == nil && !modified.IsZero() && !modified.Truncate(time.Second).After(since)
+}
+
+// lastModified returns the latest time in the given field of the entity or
+// entities of a response, zero if none.
+func lastModified(resp interface{}, field string) time.Time {
+	var latest time.Time
+	if field == "" {
+		return latest
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return latest
+	}
+	var v interface{}
+	json.Unmarshal(data, &v)
+	entities, ok := v.([]interface{})
+	if !ok {
+		entities = []interface{}{v}
+	}
+	for _, e := range entities {
+		fields, _ := e.(map[string]interface{})
+		value, _ := fields[field].(string)
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// setInvalidationHeaders marks the response to a change to the entity with
+// the given id as uncacheable, and hints which cached responses it makes
+// stale: caches invalidate the Content-Location, and shared caches purging by
+// key should purge the listed surrogate keys.
+func (r Resource) setInvalidationHeaders(rw http.ResponseWriter, req *http.Request, id string) {
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set(SurrogateKeyHeader, r.surrogateKeys(id))
+	if id == "" {
+		return
+	}
+	location := strings.TrimSuffix(req.URL.Path, "/")
+	if !strings.HasSuffix(location, "/"+id) {
+		location += "/" + id
+	}
+	rw.Header().Set("Content-Location", location)
+}
@@ -0,0 +1,152 @@
+package goresource_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"goresource"
+	"goresource/codec"
+	"goresource/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CachePolicy", func() {
+	It("renders as a Cache-Control header.", func() {
+		Expect(goresource.CachePolicy{}.CacheControl()).To(BeEmpty())
+		Expect(goresource.CachePolicy{MaxAge: time.Minute}.CacheControl()).To(Equal("private, max-age=60"))
+		Expect(goresource.CachePolicy{Public: true, MaxAge: time.Minute, SharedMaxAge: time.Hour,
+			StaleWhileRevalidate: 30 * time.Second, StaleIfError: time.Hour}.CacheControl()).
+			To(Equal("public, max-age=60, s-maxage=3600, stale-while-revalidate=30, stale-if-error=3600"))
+		Expect(goresource.CachePolicy{Vary: []string{"Authorization"}}.CacheControl()).To(Equal("private, max-age=0"))
+		Expect(goresource.CachePolicy{Public: true, NoStore: true}.CacheControl()).To(Equal("no-store"))
+	})
+})
+
+var _ = Describe("Resource caching", func() {
+	var (
+		ctrl    *gomock.Controller
+		store   *mocks.MockStore
+		manager goresource.DefaultManager
+		router  *mux.Router
+		book    = map[string]interface{}{"id": "b1", "name": "go", "updatedAt": "2024-05-01T10:00:00.5Z"}
+	)
+
+	// serve returns the response to the given request with the given headers.
+	serve := func(method, target string, headers ...string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(`{"name": "go"}`))
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStore(ctrl)
+		router = mux.NewRouter()
+		manager = goresource.NewDefaultManager("books", store)
+		manager.ItemCache = goresource.CachePolicy{Public: true, MaxAge: time.Minute, StaleWhileRevalidate: time.Minute,
+			ModifiedField: "updatedAt"}
+		manager.CollectionCache = goresource.CachePolicy{MaxAge: 10 * time.Second, Vary: []string{"Authorization"}}
+		goresource.NewResource(bookManager{manager}, router)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("applies the item policy with validators to entities.", func() {
+		store.EXPECT().GetEntity(gomock.Any(), "books", "b1", gomock.Any()).SetArg(3, book).Return(nil)
+		w := serve("GET", "/books/b1")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("Cache-Control")).To(Equal("public, max-age=60, stale-while-revalidate=60"))
+		Expect(w.Header().Get("ETag")).To(MatchRegexp(`^"[\w-]+"$`))
+		Expect(w.Header().Get("Last-Modified")).To(Equal("Wed, 01 May 2024 10:00:00 GMT"))
+		Expect(w.Header().Get(goresource.SurrogateKeyHeader)).To(Equal("books books/b1"))
+		Expect(w.Header().Get("Vary")).To(BeEmpty())
+		Expect(w.Body.String()).To(MatchJSON(`{"id": "b1", "name": "go", "updatedAt": "2024-05-01T10:00:00.5Z"}`))
+	})
+
+	It("applies the collection policy to lists.", func() {
+		store.EXPECT().ListEntities(gomock.Any(), "books", gomock.Any(), gomock.Any()).
+			SetArg(3, []map[string]interface{}{book}).Return(nil)
+		w := serve("GET", "/books")
+		Expect(w.Header().Get("Cache-Control")).To(Equal("private, max-age=10"))
+		Expect(w.Header().Get("Vary")).To(Equal("Accept, Authorization"))
+		Expect(w.Header().Get(goresource.SurrogateKeyHeader)).To(Equal("books"))
+		Expect(w.Header().Get("Last-Modified")).To(BeEmpty())
+	})
+
+	It("responds with 304 Not Modified to current cached copies.", func() {
+		store.EXPECT().GetEntity(gomock.Any(), "books", "b1", gomock.Any()).SetArg(3, book).Return(nil).Times(5)
+		etag := serve("GET", "/books/b1").Header().Get("ETag")
+
+		w := serve("GET", "/books/b1", "If-None-Match", `"other", W/`+etag)
+		Expect(w.Code).To(Equal(http.StatusNotModified))
+		Expect(w.Body.Len()).To(BeZero())
+		Expect(w.Header().Get("ETag")).To(Equal(etag))
+		Expect(w.Header().Get("Cache-Control")).NotTo(BeEmpty())
+		Expect(serve("GET", "/books/b1", "If-None-Match", `"other"`).Code).To(Equal(http.StatusOK))
+		Expect(serve("GET", "/books/b1", "If-Modified-Since", "Wed, 01 May 2024 10:00:00 GMT").Code).
+			To(Equal(http.StatusNotModified))
+		Expect(serve("GET", "/books/b1", "If-Modified-Since", "Wed, 01 May 2024 09:59:59 GMT").Code).
+			To(Equal(http.StatusOK))
+	})
+
+	It("responds to head requests with the validators of get requests.", func() {
+		store.EXPECT().GetEntity(gomock.Any(), "books", "b1", gomock.Any()).SetArg(3, book).Return(nil).Times(3)
+		get := serve("GET", "/books/b1")
+		w := serve("HEAD", "/books/b1")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.Len()).To(BeZero())
+		for _, h := range []string{"ETag", "Last-Modified", "Cache-Control", "Content-Length", goresource.SurrogateKeyHeader} {
+			Expect(w.Header().Get(h)).To(Equal(get.Header().Get(h)), h)
+		}
+		Expect(serve("HEAD", "/books/b1", "If-None-Match", get.Header().Get("ETag")).Code).To(Equal(http.StatusNotModified))
+
+		store.EXPECT().ListEntities(gomock.Any(), "books", gomock.Any(), gomock.Any()).
+			SetArg(3, []map[string]interface{}{book}).Return(nil).Times(2)
+		get = serve("GET", "/books")
+		w = serve("HEAD", "/books")
+		Expect(w.Header().Get("ETag")).To(Equal(get.Header().Get("ETag")))
+		Expect(w.Header().Get(goresource.TotalCountHeader)).To(Equal("1"))
+	})
+
+	It("varies on Accept for managers with several formats.", func() {
+		rm := mocks.NewMockResourceManager(ctrl)
+		rm.EXPECT().GetName().AnyTimes().Return("authors")
+		rm.EXPECT().GetEntity(gomock.Any(), "a1", gomock.Any()).Return(map[string]interface{}{"id": "a1"}, nil)
+		goresource.NewResource(formatManager{rm, []string{codec.JSONType, codec.CSVType}}, router)
+		w := serve("GET", "/authors/a1")
+		Expect(w.Header().Get("Vary")).To(Equal("Accept"))
+		Expect(w.Header().Get("Cache-Control")).To(BeEmpty())
+		Expect(w.Header().Get("ETag")).NotTo(BeEmpty())
+	})
+
+	It("hints which responses changes invalidate.", func() {
+		store.EXPECT().CreateEntity(gomock.Any(), "books", gomock.Any(), gomock.Any()).SetArg(3, book).Return(nil)
+		w := serve("POST", "/books")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("Cache-Control")).To(Equal("no-store"))
+		Expect(w.Header().Get("Content-Location")).To(Equal("/books/b1"))
+		Expect(w.Header().Get(goresource.SurrogateKeyHeader)).To(Equal("books books/b1"))
+
+		store.EXPECT().UpdateEntity(gomock.Any(), "books", "b1", gomock.Any(), gomock.Any()).SetArg(4, book).Return(nil)
+		w = serve("PUT", "/books/b1")
+		Expect(w.Header().Get("Content-Location")).To(Equal("/books/b1"))
+
+		store.EXPECT().DeleteEntity(gomock.Any(), "books", "b1").Return(nil)
+		w = serve("DELETE", "/books/b1")
+		Expect(w.Code).To(Equal(http.StatusNoContent))
+		Expect(w.Header().Get(goresource.SurrogateKeyHeader)).To(Equal("books books/b1"))
+	})
+})
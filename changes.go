@@ -0,0 +1,235 @@
+package goresource
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rockstardevs/goresource/store"
+)
+
+// DefaultChangeLogSize is the default number of events a ChangeFeed keeps for resuming.
+const DefaultChangeLogSize = 1000
+
+// DefaultHeartbeat is the default interval between comments sent to idle event streams.
+const DefaultHeartbeat = 15 * time.Second
+
+// changeBuffer is the number of events buffered per subscriber. Subscribers
+// falling further behind are dropped, and resume from the log on reconnecting.
+const changeBuffer = 64
+
+// ErrEventsExpired is returned resuming after an event no longer in the log.
+var ErrEventsExpired = errors.New("events expired")
+
+// ChangeEvent describes a change made to an entity through a Resource.
+type ChangeEvent struct {
+	// ID orders events, starting from 1.
+	ID       uint64    `json:"id"`
+	Time     time.Time `json:"time"`
+	Resource string    `json:"resource"`
+	// Action is one of AuditCreate, AuditUpdate or AuditDelete.
+	Action   string `json:"action"`
+	EntityID string `json:"entityId"`
+	// Entity is the entity after the change, or before it for deletes.
+	Entity interface{} `json:"entity,omitempty"`
+}
+
+// ChangeFeed publishes the changes made through resources to subscribers,
+// keeping a bounded log of recent events for subscribers to resume from.
+// A single feed can be shared by many resources.
+type ChangeFeed struct {
+	// Heartbeat is the interval between comments keeping idle event streams
+	// open, defaults to DefaultHeartbeat.
+	Heartbeat time.Duration
+
+	mu          sync.Mutex
+	log         []ChangeEvent
+	size        int
+	last        uint64
+	subscribers map[*Subscription]bool
+}
+
+// Subscription receives the events published to a ChangeFeed.
+type Subscription struct {
+	// C receives published events. It is closed when the subscription is
+	// closed or falls too far behind.
+	C        <-chan ChangeEvent
+	c        chan ChangeEvent
+	feed     *ChangeFeed
+	resource string
+}
+
+// NewChangeFeed returns a ChangeFeed keeping the given number of events for
+// resuming, DefaultChangeLogSize if not positive.
+func NewChangeFeed(size int) *ChangeFeed {
+	if size <= 0 {
+		size = DefaultChangeLogSize
+	}
+	return &ChangeFeed{size: size, subscribers: make(map[*Subscription]bool)}
+}
+
+// Publish assigns the next id and the current time to the given event, logs
+// it and sends it to subscribers.
+func (f *ChangeFeed) Publish(event ChangeEvent) ChangeEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.last++
+	event.ID = f.last
+	event.Time = time.Now().UTC()
+	if len(f.log) == f.size {
+		f.log = append(f.log[:0], f.log[1:]...)
+	}
+	f.log = append(f.log, event)
+	for sub := range f.subscribers {
+		if sub.resource != "" && sub.resource != event.Resource {
+			continue
+		}
+		select {
+		case sub.c <- event:
+		default:
+			f.remove(sub)
+		}
+	}
+	return event
+}
+
+// Subscribe returns a subscription to the events of the named resource, or of
+// all resources if empty, with the logged events after the given id. It
+// returns ErrEventsExpired, along with the subscription, if events after the
+// id are no longer logged.
+func (f *ChangeFeed) Subscribe(resource string, after uint64) (*Subscription, []ChangeEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sub := f.subscribe(resource)
+	var err error
+	if len(f.log) > 0 && after+1 < f.log[0].ID || after > f.last {
+		err = ErrEventsExpired
+	}
+	var backlog []ChangeEvent
+	for _, e := range f.log {
+		if e.ID > after && (resource == "" || e.Resource == resource) {
+			backlog = append(backlog, e)
+		}
+	}
+	return sub, backlog, err
+}
+
+// Listen returns a subscription to the events of the named resource, or of
+// all resources if empty, published from now on.
+func (f *ChangeFeed) Listen(resource string) *Subscription {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.subscribe(resource)
+}
+
+// subscribe adds a subscriber, with the lock held.
+func (f *ChangeFeed) subscribe(resource string) *Subscription {
+	c := make(chan ChangeEvent, changeBuffer)
+	sub := &Subscription{C: c, c: c, feed: f, resource: resource}
+	f.subscribers[sub] = true
+	return sub
+}
+
+// Close stops the subscription, closing its channel.
+func (s *Subscription) Close() {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+	s.feed.remove(s)
+}
+
+// remove drops the given subscriber, with the lock held.
+func (f *ChangeFeed) remove(sub *Subscription) {
+	if f.subscribers[sub] {
+		delete(f.subscribers, sub)
+		close(sub.c)
+	}
+}
+
+// publish publishes a change to an entity to the configured change feed, if any.
+func (r Resource) publish(action string, id string, entity interface{}) {
+	if r.changes == nil {
+		return
+	}
+	r.changes.Publish(ChangeEvent{Resource: r.manager.GetName(), Action: action, EntityID: id, Entity: entity})
+}
+
+// Events is the http handler streaming changes to this resource's entities
+// as server-sent events, named after the action with the ChangeEvent as json
+// data. Only changes to entities matching the request's filters are sent.
+// Clients resume after the event in the Last-Event-ID header, or the
+// lastEventId query parameter. If the events to resume from are no longer
+// logged a reset event is sent first, after which clients should refetch.
+func (r Resource) Events(rw http.ResponseWriter, req *http.Request) {
+	if r.changes == nil {
+		writeError(rw, "Method Not Supported", http.StatusNotImplemented)
+		return
+	}
+	query := r.scopeQuery(req, req.URL.Query())
+	lastID := req.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = query.Get("lastEventId")
+	}
+	query.Del("lastEventId")
+	var after uint64
+	if lastID != "" {
+		var err error
+		if after, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+			writeError(rw, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+	match, err := store.Matcher(filters(query))
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sub, backlog, err := r.changes.Subscribe(r.manager.GetName(), after)
+	defer sub.Close()
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(http.StatusOK)
+	if err == ErrEventsExpired {
+		fmt.Fprintf(rw, "event: reset\ndata: {}\n\n")
+	}
+	for _, e := range backlog {
+		writeEvent(rw, e, match)
+	}
+	flush(rw)
+	heartbeat := r.changes.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			writeEvent(rw, e, match)
+		case <-ticker.C:
+			fmt.Fprintf(rw, ": ping\n\n")
+		case <-req.Context().Done():
+			return
+		}
+		flush(rw)
+	}
+}
+
+// writeEvent writes the given event if its entity matches the filters.
+func writeEvent(rw http.ResponseWriter, e ChangeEvent, match func(map[string]interface{}) bool) {
+	if !match(toFields(e.Entity)) {
+		return
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(rw, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Action, data)
+}
@@ -0,0 +1,227 @@
+package goresource_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"goresource"
+	"goresource/mocks"
+	storepkg "goresource/store"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ChangeFeed", func() {
+	var feed *goresource.ChangeFeed
+
+	// publish publishes a change to the named resource.
+	publish := func(resource, id string) goresource.ChangeEvent {
+		return feed.Publish(goresource.ChangeEvent{Resource: resource, Action: goresource.AuditCreate, EntityID: id})
+	}
+
+	// ids returns the entity ids of the given events.
+	ids := func(events []goresource.ChangeEvent) []string {
+		var ids []string
+		for _, e := range events {
+			ids = append(ids, e.EntityID)
+		}
+		return ids
+	}
+
+	BeforeEach(func() {
+		feed = goresource.NewChangeFeed(3)
+	})
+
+	It("numbers and timestamps published events.", func() {
+		Expect(publish("books", "a").ID).To(Equal(uint64(1)))
+		e := publish("books", "b")
+		Expect(e.ID).To(Equal(uint64(2)))
+		Expect(e.Time).To(BeTemporally("~", time.Now(), time.Second))
+	})
+
+	It("sends events of the subscribed resource to subscribers.", func() {
+		sub, backlog, err := feed.Subscribe("books", 0)
+		Expect(err).To(BeNil())
+		Expect(backlog).To(BeEmpty())
+		publish("authors", "x")
+		publish("books", "a")
+		Expect((<-sub.C).EntityID).To(Equal("a"))
+		sub.Close()
+		Eventually(sub.C).Should(BeClosed())
+		publish("books", "b")
+	})
+
+	It("resumes from the logged events after the given id.", func() {
+		publish("books", "a")
+		publish("authors", "x")
+		publish("books", "b")
+		_, backlog, err := feed.Subscribe("books", 1)
+		Expect(err).To(BeNil())
+		Expect(ids(backlog)).To(Equal([]string{"b"}))
+		_, backlog, err = feed.Subscribe("", 0)
+		Expect(err).To(BeNil())
+		Expect(ids(backlog)).To(Equal([]string{"a", "x", "b"}))
+	})
+
+	It("reports events that are no longer logged as expired.", func() {
+		for _, id := range []string{"a", "b", "c", "d", "e"} {
+			publish("books", id)
+		}
+		_, backlog, err := feed.Subscribe("books", 1)
+		Expect(err).To(Equal(goresource.ErrEventsExpired))
+		Expect(ids(backlog)).To(Equal([]string{"c", "d", "e"}))
+		_, _, err = feed.Subscribe("books", 2)
+		Expect(err).To(BeNil())
+		_, _, err = feed.Subscribe("books", 9)
+		Expect(err).To(Equal(goresource.ErrEventsExpired))
+	})
+
+	It("listens for events published from now on.", func() {
+		publish("books", "a")
+		sub := feed.Listen("books")
+		publish("books", "b")
+		Expect((<-sub.C).EntityID).To(Equal("b"))
+		sub.Close()
+	})
+
+	It("drops subscribers that fall behind.", func() {
+		sub, _, _ := feed.Subscribe("books", 0)
+		for i := 0; i < 100; i++ {
+			publish("books", "a")
+		}
+		n := 0
+		for range sub.C {
+			n++
+		}
+		Expect(n).To(BeNumerically("<", 100))
+	})
+})
+
+var _ = Describe("Events", func() {
+	var (
+		ctrl   *gomock.Controller
+		store  *mocks.MockStore
+		feed   *goresource.ChangeFeed
+		server *httptest.Server
+		cancel context.CancelFunc
+	)
+
+	// connect opens an event stream with the given Last-Event-ID, if any.
+	connect := func(target, lastID string) (*http.Response, *bufio.Reader) {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+target, nil)
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		return resp, bufio.NewReader(resp.Body)
+	}
+
+	// next reads the next event from the stream, skipping comments.
+	next := func(r *bufio.Reader) map[string]string {
+		event := make(map[string]string)
+		for {
+			line, err := r.ReadString('\n')
+			Expect(err).To(BeNil())
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "" && len(event) > 0:
+				return event
+			case line == "", strings.HasPrefix(line, ":"):
+				continue
+			}
+			parts := strings.SplitN(line, ": ", 2)
+			event[parts[0]] = parts[1]
+		}
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStore(ctrl)
+		feed = goresource.NewChangeFeed(10)
+		feed.Heartbeat = 10 * time.Millisecond
+		router := mux.NewRouter()
+		goresource.NewResource(bookManager{goresource.NewDefaultManager("books", store)}, router, goresource.WithChangeFeed(feed))
+		server = httptest.NewServer(router)
+		cancel = func() {}
+	})
+
+	AfterEach(func() {
+		cancel()
+		server.Close()
+		ctrl.Finish()
+	})
+
+	It("streams changes made through the resource.", func() {
+		resp, events := connect("/books/events", "")
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+		store.EXPECT().CreateEntity(gomock.Any(), "books", gomock.Any(), gomock.Any()).
+			SetArg(3, map[string]interface{}{"id": "b1", "name": "a"}).Return(nil)
+		store.EXPECT().GetEntity(gomock.Any(), "books", "b1", gomock.Any()).
+			SetArg(3, map[string]interface{}{"id": "b1", "name": "a"}).Return(nil)
+		store.EXPECT().DeleteEntity(gomock.Any(), "books", "b1").Return(nil)
+		post, err := http.Post(server.URL+"/books", "application/json", strings.NewReader(`{"name": "a"}`))
+		Expect(err).To(BeNil())
+		Expect(post.StatusCode).To(Equal(http.StatusOK))
+		del, _ := http.NewRequest("DELETE", server.URL+"/books/b1", nil)
+		_, err = http.DefaultClient.Do(del)
+		Expect(err).To(BeNil())
+
+		created := next(events)
+		Expect(created["id"]).To(Equal("1"))
+		Expect(created["event"]).To(Equal("create"))
+		Expect(created["data"]).To(ContainSubstring(`"entity":{"id":"b1","name":"a"}`))
+		deleted := next(events)
+		Expect(deleted["event"]).To(Equal("delete"))
+		Expect(deleted["data"]).To(ContainSubstring(`"entityId":"b1"`))
+	})
+
+	It("sends only changes to entities matching the filters.", func() {
+		_, events := connect("/books/events?name~=^go", "")
+		feed.Publish(goresource.ChangeEvent{Resource: "books", Action: "update", EntityID: "1", Entity: map[string]interface{}{"name": "rust"}})
+		feed.Publish(goresource.ChangeEvent{Resource: "books", Action: "update", EntityID: "2", Entity: map[string]interface{}{"name": "Gophers"}})
+		Expect(next(events)["id"]).To(Equal("2"))
+	})
+
+	It("resumes after the Last-Event-ID.", func() {
+		for _, id := range []string{"1", "2", "3"} {
+			feed.Publish(goresource.ChangeEvent{Resource: "books", Action: "create", EntityID: id})
+		}
+		_, events := connect("/books/events", "1")
+		Expect(next(events)["id"]).To(Equal("2"))
+		Expect(next(events)["id"]).To(Equal("3"))
+		feed.Publish(goresource.ChangeEvent{Resource: "books", Action: "create", EntityID: "4"})
+		Expect(next(events)["id"]).To(Equal("4"))
+	})
+
+	It("sends a reset event if the events to resume from have expired.", func() {
+		_, events := connect("/books/events?lastEventId=7", "")
+		Expect(next(events)["event"]).To(Equal("reset"))
+	})
+
+	It("rejects invalid event ids and filters.", func() {
+		resp, _ := connect("/books/events", "x")
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		resp, _ = connect("/books/events?name~=(", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+
+	It("is not served without a change feed.", func() {
+		router := mux.NewRouter()
+		goresource.NewResource(bookManager{goresource.NewDefaultManager("books", store)}, router)
+		store.EXPECT().GetEntity(gomock.Any(), "books", "events", gomock.Any()).Return(storepkg.ErrNotFound)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/books/events", nil))
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+	})
+})
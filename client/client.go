@@ -0,0 +1,208 @@
+// Package client implements a typed client for apis served by goresource.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client calls a single goresource resource, decoding its entities into T.
+type Client[T any] struct {
+	base    string
+	name    string
+	http    *http.Client
+	header  http.Header
+	retries int
+	backoff time.Duration
+}
+
+// Option configures a Client.
+type Option func(*config)
+
+type config struct {
+	http    *http.Client
+	header  http.Header
+	retries int
+	backoff time.Duration
+}
+
+// WithHTTPClient sends requests with the given http client.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cfg *config) { cfg.http = c }
+}
+
+// WithTimeout limits the time taken by each attempt of a request.
+func WithTimeout(timeout time.Duration) Option {
+	return func(cfg *config) {
+		c := *cfg.http
+		c.Timeout = timeout
+		cfg.http = &c
+	}
+}
+
+// WithRetries retries idempotent requests failing with network errors, 429 or
+// 5xx responses up to the given number of times, waiting backoff before the
+// first retry and doubling the wait for every subsequent one.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(cfg *config) { cfg.retries, cfg.backoff = retries, backoff }
+}
+
+// WithHeader sets a header sent with every request, such as Authorization.
+func WithHeader(key, value string) Option {
+	return func(cfg *config) { cfg.header.Set(key, value) }
+}
+
+// New returns a client for the resource with the given name served under baseURL,
+// for example New[Book]("http://localhost:8080/api", "books").
+func New[T any](baseURL, name string, opts ...Option) *Client[T] {
+	cfg := &config{http: http.DefaultClient, header: make(http.Header)}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return &Client[T]{
+		base:    strings.TrimSuffix(baseURL, "/"),
+		name:    name,
+		http:    cfg.http,
+		header:  cfg.header,
+		retries: cfg.retries,
+		backoff: cfg.backoff,
+	}
+}
+
+// Get fetches the entity with the given id.
+func (c *Client[T]) Get(ctx context.Context, id string) (*T, error) {
+	result := new(T)
+	return result, c.do(ctx, http.MethodGet, c.url(id, nil), nil, result)
+}
+
+// List fetches the entities matching the given filters, see Query. The whole
+// list is returned at once, goresource lists are not paginated.
+func (c *Client[T]) List(ctx context.Context, filters url.Values) ([]T, error) {
+	var result []T
+	return result, c.do(ctx, http.MethodGet, c.url("", filters), nil, &result)
+}
+
+// Create persists a new entity, returning it as stored.
+func (c *Client[T]) Create(ctx context.Context, entity *T) (*T, error) {
+	result := new(T)
+	return result, c.do(ctx, http.MethodPost, c.url("", nil), entity, result)
+}
+
+// Update replaces the entity with the given id, returning it as stored.
+func (c *Client[T]) Update(ctx context.Context, id string, entity *T) (*T, error) {
+	result := new(T)
+	return result, c.do(ctx, http.MethodPut, c.url(id, nil), entity, result)
+}
+
+// Patch changes the given fields of the entity with the given id. Servers
+// without PATCH support respond with 501 Not Implemented, matching
+// ErrNotImplemented.
+func (c *Client[T]) Patch(ctx context.Context, id string, fields map[string]interface{}) (*T, error) {
+	result := new(T)
+	return result, c.do(ctx, http.MethodPatch, c.url(id, nil), fields, result)
+}
+
+// Delete removes the entity with the given id.
+func (c *Client[T]) Delete(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, c.url(id, nil), nil, nil)
+}
+
+// url returns the url of the collection, or of the entity with the given id.
+func (c *Client[T]) url(id string, query url.Values) string {
+	u := c.base + "/" + url.PathEscape(c.name)
+	if id != "" {
+		u += "/" + url.PathEscape(id)
+	}
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// do sends a request with the given body encoded as json, decoding the
+// response into result, retrying idempotent requests if configured to.
+func (c *Client[T]) do(ctx context.Context, method, u string, body, result interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	wait := c.backoff
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, u, data, result)
+		if err == nil || attempt >= c.retries || !idempotent(method) || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// send makes a single attempt of a request.
+func (c *Client[T]) send(ctx context.Context, method, u string, data []byte, result interface{}) error {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		msg, _ := io.ReadAll(resp.Body)
+		return &Error{Method: method, URL: u, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("decoding %s %s: %v", method, u, err)
+	}
+	return nil
+}
+
+// idempotent reports whether requests with the given method are safe to retry.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether a request failing with the given error may succeed
+// if retried. Network errors, including timeouts of a single attempt, are retried.
+func retryable(err error) bool {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError &&
+			e.StatusCode != http.StatusNotImplemented
+	}
+	return true
+}
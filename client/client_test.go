@@ -0,0 +1,211 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"goresource/client"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type book struct {
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name"`
+	Pages int    `json:"pages"`
+}
+
+// request is a request received by the test server.
+type request struct {
+	method, path, query, body string
+	header                    http.Header
+}
+
+var _ = Describe("Client", func() {
+	var (
+		server *httptest.Server
+		// mu guards requests and responses, shared with the server's handlers.
+		mu        sync.Mutex
+		requests  []request
+		responses []func(rw http.ResponseWriter)
+		ctx       = context.Background()
+	)
+
+	// queue adds responses to the next requests.
+	queue := func(rs ...func(rw http.ResponseWriter)) {
+		mu.Lock()
+		defer mu.Unlock()
+		responses = append(responses, rs...)
+	}
+
+	// received returns the requests received so far.
+	received := func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]request{}, requests...)
+	}
+
+	respond := func(status int, body string) func(rw http.ResponseWriter) {
+		return func(rw http.ResponseWriter) {
+			rw.WriteHeader(status)
+			io.WriteString(rw, body)
+		}
+	}
+
+	BeforeEach(func() {
+		requests, responses = nil, nil
+		server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+			mu.Lock()
+			requests = append(requests, request{req.Method, req.URL.Path, req.URL.RawQuery, string(body), req.Header})
+			respond := responses[0]
+			responses = responses[1:]
+			mu.Unlock()
+			respond(rw)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("gets an entity.", func() {
+		queue(respond(http.StatusOK, `{"id":"a b","name":"x","pages":3}`))
+		c := client.New[book](server.URL+"/api/", "books", client.WithHeader("Authorization", "Bearer t"))
+		result, err := c.Get(ctx, "a b")
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(&book{ID: "a b", Name: "x", Pages: 3}))
+		Expect(received()[0].method).To(Equal(http.MethodGet))
+		Expect(received()[0].path).To(Equal("/api/books/a b"))
+		Expect(received()[0].header.Get("Accept")).To(Equal("application/json"))
+		Expect(received()[0].header.Get("Authorization")).To(Equal("Bearer t"))
+	})
+
+	It("lists entities with filters.", func() {
+		queue(respond(http.StatusOK, `[{"id":"1","name":"x"},{"id":"2","name":"y"}]`))
+		c := client.New[book](server.URL, "books")
+		result, err := c.List(ctx, client.Query{}.Equal("name", "x", "y").Matches("tag", "^go").Values())
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal([]book{{ID: "1", Name: "x"}, {ID: "2", Name: "y"}}))
+		Expect(received()[0].path).To(Equal("/books"))
+		Expect(received()[0].query).To(Equal("name=x&name=y&tag~=%5Ego"))
+	})
+
+	It("creates, updates and deletes entities.", func() {
+		queue(
+			respond(http.StatusOK, `{"id":"1","name":"x"}`),
+			respond(http.StatusOK, `{"id":"1","name":"y"}`),
+			respond(http.StatusNoContent, ""))
+		c := client.New[book](server.URL, "books")
+		created, err := c.Create(ctx, &book{Name: "x"})
+		Expect(err).To(BeNil())
+		Expect(created.ID).To(Equal("1"))
+		updated, err := c.Update(ctx, "1", &book{Name: "y"})
+		Expect(err).To(BeNil())
+		Expect(updated.Name).To(Equal("y"))
+		Expect(c.Delete(ctx, "1")).To(Succeed())
+		Expect(received()[0].method).To(Equal(http.MethodPost))
+		Expect(received()[0].body).To(MatchJSON(`{"name":"x","pages":0}`))
+		Expect(received()[0].header.Get("Content-Type")).To(Equal("application/json"))
+		Expect(received()[1].method).To(Equal(http.MethodPut))
+		Expect(received()[1].path).To(Equal("/books/1"))
+		Expect(received()[2].method).To(Equal(http.MethodDelete))
+	})
+
+	Describe("Patch", func() {
+		It("sends the changed fields.", func() {
+			queue(respond(http.StatusOK, `{"id":"1","name":"x","pages":5}`))
+			result, err := client.New[book](server.URL, "books").Patch(ctx, "1", map[string]interface{}{"pages": 5})
+			Expect(err).To(BeNil())
+			Expect(result.Pages).To(Equal(5))
+			Expect(received()).To(HaveLen(1))
+			Expect(received()[0].method).To(Equal(http.MethodPatch))
+			Expect(received()[0].body).To(MatchJSON(`{"pages":5}`))
+		})
+
+		It("returns 501 responses of servers without PATCH support.", func() {
+			queue(respond(http.StatusNotImplemented, "Method Not Supported\n"))
+			_, err := client.New[book](server.URL, "books").Patch(ctx, "1", map[string]interface{}{"pages": 5})
+			Expect(errors.Is(err, client.ErrNotImplemented)).To(BeTrue())
+			Expect(received()).To(HaveLen(1))
+		})
+	})
+
+	Describe("errors", func() {
+		It("returns error responses as typed errors.", func() {
+			queue(respond(http.StatusBadRequest, "name is required\n"))
+			_, err := client.New[book](server.URL, "books").Create(ctx, &book{})
+			var e *client.Error
+			Expect(errors.As(err, &e)).To(BeTrue())
+			Expect(e.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(e.Message).To(Equal("name is required"))
+			Expect(errors.Is(err, client.ErrBadRequest)).To(BeTrue())
+			Expect(errors.Is(err, client.ErrServer)).To(BeFalse())
+		})
+
+		It("reports undecodable responses.", func() {
+			queue(respond(http.StatusOK, `{bad`))
+			_, err := client.New[book](server.URL, "books").Get(ctx, "1")
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("decoding GET"))
+		})
+	})
+
+	Describe("retries", func() {
+		It("retries idempotent requests failing with server errors.", func() {
+			queue(
+				respond(http.StatusServiceUnavailable, "unavailable"),
+				respond(http.StatusTooManyRequests, "slow down"),
+				respond(http.StatusOK, `{"id":"1"}`))
+			c := client.New[book](server.URL, "books", client.WithRetries(2, time.Millisecond))
+			result, err := c.Get(ctx, "1")
+			Expect(err).To(BeNil())
+			Expect(result.ID).To(Equal("1"))
+			Expect(received()).To(HaveLen(3))
+		})
+
+		It("gives up after the configured number of retries.", func() {
+			queue(respond(http.StatusInternalServerError, "a"), respond(http.StatusInternalServerError, "b"))
+			_, err := client.New[book](server.URL, "books", client.WithRetries(1, time.Millisecond)).Get(ctx, "1")
+			Expect(errors.Is(err, client.ErrServer)).To(BeTrue())
+			Expect(received()).To(HaveLen(2))
+		})
+
+		It("does not retry creates or client errors.", func() {
+			queue(respond(http.StatusInternalServerError, "a"), respond(http.StatusNotAcceptable, "b"))
+			c := client.New[book](server.URL, "books", client.WithRetries(3, time.Millisecond))
+			_, err := c.Create(ctx, &book{})
+			Expect(err).NotTo(BeNil())
+			_, err = c.Get(ctx, "1")
+			Expect(errors.Is(err, client.ErrNotAcceptable)).To(BeTrue())
+			Expect(received()).To(HaveLen(2))
+		})
+	})
+
+	It("times out slow requests.", func() {
+		queue(func(rw http.ResponseWriter) {
+			time.Sleep(50 * time.Millisecond)
+			rw.Write([]byte(`{}`))
+		})
+		_, err := client.New[book](server.URL, "books", client.WithTimeout(5*time.Millisecond)).Get(ctx, "1")
+		var e *client.Error
+		Expect(err).NotTo(BeNil())
+		Expect(errors.As(err, &e)).To(BeFalse())
+	})
+
+	It("retries timed out attempts.", func() {
+		queue(func(rw http.ResponseWriter) {
+			time.Sleep(50 * time.Millisecond)
+		}, respond(http.StatusOK, `{"id":"1"}`))
+		c := client.New[book](server.URL, "books", client.WithTimeout(20*time.Millisecond), client.WithRetries(1, time.Millisecond))
+		result, err := c.Get(ctx, "1")
+		Expect(err).To(BeNil())
+		Expect(result.ID).To(Equal("1"))
+	})
+})
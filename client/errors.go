@@ -0,0 +1,52 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors matched by the Error responses with the corresponding status, for use with errors.Is.
+var (
+	ErrBadRequest           = errors.New("bad request")
+	ErrNotFound             = errors.New("not found")
+	ErrConflict             = errors.New("conflict")
+	ErrNotAcceptable        = errors.New("not acceptable")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrNotImplemented       = errors.New("not implemented")
+	ErrServer               = errors.New("server error")
+)
+
+// Error is an error response from the server.
+type Error struct {
+	Method     string
+	URL        string
+	StatusCode int
+	// Message is the error message sent as the response body.
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, e.Message)
+}
+
+// Is matches the sentinel error for the response status.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrNotAcceptable:
+		return e.StatusCode == http.StatusNotAcceptable
+	case ErrUnsupportedMediaType:
+		return e.StatusCode == http.StatusUnsupportedMediaType
+	case ErrNotImplemented:
+		return e.StatusCode == http.StatusNotImplemented
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
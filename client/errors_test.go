@@ -0,0 +1,33 @@
+package client_test
+
+import (
+	"errors"
+	"net/http"
+
+	"goresource/client"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Error", func() {
+	It("describes the failed request.", func() {
+		err := &client.Error{Method: "GET", URL: "http://host/books/1", StatusCode: 406, Message: "Not Acceptable"}
+		Expect(err.Error()).To(Equal("GET http://host/books/1: 406 Not Acceptable"))
+	})
+
+	It("matches the sentinel error for its status.", func() {
+		for status, target := range map[int]error{
+			http.StatusBadRequest:           client.ErrBadRequest,
+			http.StatusNotFound:             client.ErrNotFound,
+			http.StatusConflict:             client.ErrConflict,
+			http.StatusNotAcceptable:        client.ErrNotAcceptable,
+			http.StatusUnsupportedMediaType: client.ErrUnsupportedMediaType,
+			http.StatusNotImplemented:       client.ErrNotImplemented,
+			http.StatusBadGateway:           client.ErrServer,
+		} {
+			Expect(errors.Is(&client.Error{StatusCode: status}, target)).To(BeTrue())
+		}
+		Expect(errors.Is(&client.Error{StatusCode: http.StatusBadRequest}, client.ErrNotFound)).To(BeFalse())
+	})
+})
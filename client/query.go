@@ -0,0 +1,24 @@
+package client
+
+import "net/url"
+
+// Query builds list filters following goresource's conventions, where a field
+// matches any of its values and a field suffixed with ~ matches a regular expression.
+type Query url.Values
+
+// Equal matches entities whose field equals any of the given values.
+func (q Query) Equal(field string, values ...string) Query {
+	url.Values(q)[field] = append(url.Values(q)[field], values...)
+	return q
+}
+
+// Matches matches entities whose field matches the given case insensitive regular expression.
+func (q Query) Matches(field, pattern string) Query {
+	url.Values(q).Set(field+"~", pattern)
+	return q
+}
+
+// Values returns the filters to pass to List.
+func (q Query) Values() url.Values {
+	return url.Values(q)
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Import paths of generated code.
+const (
+	goresourcePath = "github.com/rockstardevs/goresource"
+	storePath      = "github.com/rockstardevs/goresource/store"
+	mocksPath      = "github.com/rockstardevs/goresource/mocks"
+	muxPath        = "github.com/gorilla/mux"
+	gomockPath     = "github.com/golang/mock/gomock"
+	ginkgoPath     = "github.com/onsi/ginkgo"
+	gomegaPath     = "github.com/onsi/gomega"
+)
+
+// entityFile is the data for generating a single entity's code. Each flag is
+// set when the corresponding declaration is not declared by hand.
+type entityFile struct {
+	*entityInfo
+	Package       string
+	Imports       []string
+	Struct        bool
+	HasId         bool
+	GetId         bool
+	Manager       bool
+	NewManager    bool
+	New           bool
+	ParseJSON     bool
+	NewResource   bool
+	TestIDSetting bool
+}
+
+// generate writes the code for every entity of pkg into dir, returning the
+// paths written.
+func generate(dir string, pkg *pkgInfo, tests bool) ([]string, error) {
+	var written []string
+	for _, e := range pkg.Entities {
+		data, err := newEntityFile(pkg, e)
+		if err != nil {
+			return written, err
+		}
+		base := filepath.Join(dir, snake(e.Name)+"_resource")
+		if err := writeGenerated(base+"_gen.go", entityTemplate, data); err != nil {
+			return written, err
+		}
+		written = append(written, base+"_gen.go")
+		if !tests {
+			continue
+		}
+		data.Imports = []string{"io", "net/http", "net/http/httptest", "strings", "",
+			gomockPath, muxPath, mocksPath}
+		ok, err := writeNew(base+"_test.go", testTemplate, data)
+		if err != nil {
+			return written, err
+		}
+		if ok {
+			written = append(written, base+"_test.go")
+		}
+	}
+	if tests && !pkg.hasSuite && len(pkg.Entities) > 0 {
+		path := filepath.Join(dir, snake(pkg.Name)+"_suite_test.go")
+		ok, err := writeNew(path, suiteTemplate, pkg)
+		if err != nil {
+			return written, err
+		}
+		if ok {
+			written = append(written, path)
+		}
+	}
+	return written, nil
+}
+
+// newEntityFile works out which declarations to generate for the given entity.
+func newEntityFile(pkg *pkgInfo, e *entityInfo) (*entityFile, error) {
+	manager := e.Name + "Manager"
+	data := &entityFile{
+		entityInfo:  e,
+		Package:     pkg.Name,
+		Struct:      len(e.Fields) > 0 && !pkg.declared[e.Name],
+		HasId:       !pkg.declared[e.Name+".HasId"],
+		GetId:       !pkg.declared[e.Name+".GetId"],
+		Manager:     !pkg.declared[manager],
+		NewManager:  !pkg.declared["New"+manager],
+		New:         !pkg.declared[manager+".New"],
+		ParseJSON:   !pkg.declared[manager+".ParseJSON"],
+		NewResource: !pkg.declared["New"+e.Name+"Resource"],
+	}
+	if (data.HasId || data.GetId) && e.IDField == "" {
+		return nil, fmt.Errorf("%s has no string id field, add one or declare HasId and GetId by hand", e.Name)
+	}
+	data.TestIDSetting = e.IDField != ""
+	imports := make(map[string]bool)
+	if data.Struct {
+		for _, path := range pkg.Imports {
+			for _, f := range e.Fields {
+				if strings.Contains(f.Type, filepath.Base(path)+".") {
+					imports[path] = true
+				}
+			}
+		}
+	}
+	if data.ParseJSON {
+		imports["encoding/json"], imports["io"] = true, true
+	}
+	if data.Manager || data.NewManager || data.New || data.ParseJSON || data.NewResource {
+		imports[goresourcePath] = true
+	}
+	if data.NewManager || data.NewResource {
+		imports[storePath] = true
+	}
+	if data.NewResource {
+		imports[muxPath] = true
+	}
+	data.Imports = sortImports(imports)
+	return data, nil
+}
+
+// sortImports returns the given import paths with the standard library first.
+func sortImports(imports map[string]bool) []string {
+	var std, other []string
+	for path := range imports {
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	if len(std) > 0 && len(other) > 0 {
+		std = append(std, "")
+	}
+	return append(std, other...)
+}
+
+// writeGenerated renders the template to path, refusing to overwrite files
+// that weren't generated.
+func writeGenerated(path string, tmpl *template.Template, data interface{}) error {
+	existing, err := os.ReadFile(path)
+	if err == nil && !bytes.HasPrefix(existing, []byte(generatedHeader)) {
+		return fmt.Errorf("%s exists and was not generated, not overwriting it", path)
+	}
+	src, err := render(tmpl, data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, src, 0644)
+}
+
+// writeNew renders the template to path unless the file already exists.
+func writeNew(path string, tmpl *template.Template, data interface{}) (bool, error) {
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	}
+	src, err := render(tmpl, data)
+	if err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(path, src, 0644)
+}
+
+// render executes the template and formats the result as go source.
+func render(tmpl *template.Template, data interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v\n%s", err, buf.Bytes())
+	}
+	return src, nil
+}
+
+// snake converts a type name to a file name, so ISBNRecord becomes isbn_record.
+func snake(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 &&
+			(unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+var funcs = template.FuncMap{"title": func(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}}
+
+var entityTemplate = template.Must(template.New("entity").Funcs(funcs).Parse(generatedHeader + `
+
+package {{.Package}}
+{{with .Imports}}
+import (
+{{- range .}}
+	{{if .}}"{{.}}"{{end}}
+{{- end}}
+)
+{{end}}
+{{- if .Struct}}
+// {{.Name}} is an entity stored in the {{.Collection}} collection.
+type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}} ` + "`" + `json:"{{.JSON}}" bson:"{{.BSON}}"` + "`" + `
+{{- end}}
+}
+{{end}}
+{{- if .HasId}}
+// HasId reports whether this {{.Name}} has an id.
+func (e {{.Name}}) HasId() bool {
+	return e.{{.IDField}} != ""
+}
+{{end}}
+{{- if .GetId}}
+// GetId returns the id of this {{.Name}}.
+func (e {{.Name}}) GetId() string {
+	return e.{{.IDField}}
+}
+{{end}}
+{{- if .Manager}}
+// {{.Name}}Manager is a ResourceManager for {{.Name}} entities.
+type {{.Name}}Manager struct {
+	goresource.DefaultManager
+}
+{{end}}
+{{- if .NewManager}}
+// New{{.Name}}Manager returns a {{.Name}}Manager storing entities in the named collection of the given store.
+func New{{.Name}}Manager(name string, store store.Store) *{{.Name}}Manager {
+	return &{{.Name}}Manager{goresource.NewDefaultManager(name, store)}
+}
+{{end}}
+{{- if .New}}
+// New returns a new empty {{.Name}}.
+func (manager *{{.Name}}Manager) New() goresource.Entity {
+	return &{{.Name}}{}
+}
+{{end}}
+{{- if .ParseJSON}}
+// ParseJSON decodes a {{.Name}} from the given json.
+func (manager *{{.Name}}Manager) ParseJSON(data io.ReadCloser) (goresource.Entity, error) {
+	entity := &{{.Name}}{}
+	if err := json.NewDecoder(data).Decode(entity); err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+{{end}}
+{{- if .NewResource}}
+// New{{.Name}}Resource serves {{.Name}} entities from the {{.Collection}} collection of the given store.
+func New{{.Name}}Resource(router *mux.Router, store store.Store, opts ...goresource.Option) *goresource.Resource {
+	return goresource.NewResource(New{{.Name}}Manager("{{.Collection}}", store), router, opts...)
+}
+{{end}}`))
+
+var testTemplate = template.Must(template.New("test").Parse(`package {{.Package}}
+
+import (
+{{- range .Imports}}
+	{{if .}}"{{.}}"{{end}}
+{{- end}}
+
+	. "` + ginkgoPath + `"
+	. "` + gomegaPath + `"
+)
+
+var _ = Describe("{{.Name}}", func() {
+	var (
+		ctrl    *gomock.Controller
+		store   *mocks.MockStore
+		manager *{{.Name}}Manager
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStore(ctrl)
+		manager = New{{.Name}}Manager("{{.Collection}}", store)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+{{if .TestIDSetting}}
+	It("has an id once set.", func() {
+		Expect({{.Name}}{}.HasId()).To(BeFalse())
+		Expect({{.Name}}{ {{- .IDField}}: "1"}.GetId()).To(Equal("1"))
+	})
+{{end}}
+	It("creates new entities.", func() {
+		Expect(manager.New()).To(Equal(&{{.Name}}{}))
+	})
+
+	It("parses json.", func() {
+		entity, err := manager.ParseJSON(io.NopCloser(strings.NewReader("{}")))
+		Expect(err).To(BeNil())
+		Expect(entity).To(Equal(&{{.Name}}{}))
+	})
+
+	It("serves the resource.", func() {
+		router := mux.NewRouter()
+		New{{.Name}}Resource(router, store)
+		req := httptest.NewRequest(http.MethodGet, "/{{.Collection}}/1", nil)
+		Expect(router.Match(req, &mux.RouteMatch{})).To(BeTrue())
+	})
+})
+`))
+
+var suiteTemplate = template.Must(template.New("suite").Funcs(funcs).Parse(`package {{.Name}}
+
+import (
+	"testing"
+
+	. "` + ginkgoPath + `"
+	. "` + gomegaPath + `"
+)
+
+func Test{{title .Name}}(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "{{.Name}} Suite")
+}
+`))
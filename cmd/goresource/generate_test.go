@@ -0,0 +1,113 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("generate", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "generate")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		Expect(err).To(BeNil())
+		return string(data)
+	}
+
+	It("generates the code and test scaffolding for an entity.", func() {
+		writeFile(dir, "book.go", "package books\n\ntype Book struct {\n\tID string `json:\"id\"`\n}\n")
+		pkg, err := fromSource(dir, []string{"Book"})
+		Expect(err).To(BeNil())
+		written, err := generate(dir, pkg, true)
+		Expect(err).To(BeNil())
+		Expect(written).To(Equal([]string{
+			filepath.Join(dir, "book_resource_gen.go"),
+			filepath.Join(dir, "book_resource_test.go"),
+			filepath.Join(dir, "books_suite_test.go"),
+		}))
+		src := read("book_resource_gen.go")
+		Expect(src).To(HavePrefix(generatedHeader))
+		Expect(src).To(ContainSubstring("func (e Book) HasId() bool {\n\treturn e.ID != \"\"\n}"))
+		Expect(src).To(ContainSubstring("func (manager *BookManager) ParseJSON(data io.ReadCloser) (goresource.Entity, error) {"))
+		Expect(src).To(ContainSubstring(`goresource.NewResource(NewBookManager("books", store), router, opts...)`))
+		Expect(src).To(ContainSubstring("import (\n\t\"encoding/json\"\n\t\"io\"\n\n\t\"github.com/gorilla/mux\""))
+		Expect(read("book_resource_test.go")).To(ContainSubstring(`Expect(Book{ID: "1"}.GetId()).To(Equal("1"))`))
+		Expect(read("books_suite_test.go")).To(ContainSubstring("func TestBooks(t *testing.T) {"))
+		for _, path := range written {
+			_, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+			Expect(err).To(BeNil())
+		}
+	})
+
+	It("regenerates without the declarations written by hand.", func() {
+		writeFile(dir, "book.go", "package books\n\ntype Book struct {\n\tID string `json:\"id\"`\n}\n")
+		pkg, _ := fromSource(dir, []string{"Book"})
+		_, err := generate(dir, pkg, true)
+		Expect(err).To(BeNil())
+		writeFile(dir, "book_resource_test.go", "package books\n// edited\n")
+		writeFile(dir, "parse.go", "package books\n\nimport \"io\"\n\n"+
+			"func (manager *BookManager) ParseJSON(data io.ReadCloser) (goresource.Entity, error) { return nil, nil }\n")
+
+		pkg, err = fromSource(dir, []string{"Book"})
+		Expect(err).To(BeNil())
+		written, err := generate(dir, pkg, true)
+		Expect(err).To(BeNil())
+		Expect(written).To(Equal([]string{filepath.Join(dir, "book_resource_gen.go")}))
+		src := read("book_resource_gen.go")
+		Expect(src).NotTo(ContainSubstring("ParseJSON"))
+		Expect(src).NotTo(ContainSubstring("encoding/json"))
+		Expect(src).To(ContainSubstring("func (manager *BookManager) New() goresource.Entity {"))
+		Expect(read("book_resource_test.go")).To(Equal("package books\n// edited\n"))
+	})
+
+	It("generates struct types declared in a schema.", func() {
+		writeFile(dir, "schema.yaml", "entities:\n  - name: Author\n    fields:\n      - {name: ID, type: string}\n      - {name: Born, type: time.Time}\n")
+		pkg, err := fromSchema(dir, filepath.Join(dir, "schema.yaml"))
+		Expect(err).To(BeNil())
+		_, err = generate(dir, pkg, false)
+		Expect(err).To(BeNil())
+		Expect(read("author_resource_gen.go")).To(ContainSubstring("import (\n\t\"encoding/json\"\n\t\"io\"\n\t\"time\"\n"))
+		Expect(read("author_resource_gen.go")).To(ContainSubstring("type Author struct {\n\tID   string    `json:\"id\" bson:\"_id\"`\n\tBorn time.Time `json:\"born\" bson:\"born\"`\n}"))
+		_, err = os.Stat(filepath.Join(dir, "author_resource_test.go"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("doesn't overwrite files it didn't generate.", func() {
+		writeFile(dir, "book.go", "package books\n\ntype Book struct {\n\tID string `json:\"id\"`\n}\n")
+		writeFile(dir, "book_resource_gen.go", "package books\n")
+		pkg, err := fromSource(dir, []string{"Book"})
+		Expect(err).To(BeNil())
+		_, err = generate(dir, pkg, false)
+		Expect(err).To(MatchError(ContainSubstring("exists and was not generated")))
+		Expect(read("book_resource_gen.go")).To(Equal("package books\n"))
+	})
+
+	It("requires an id field unless the id methods are written by hand.", func() {
+		writeFile(dir, "book.go", "package books\n\ntype Book struct {\n\tName string\n}\n")
+		pkg, err := fromSource(dir, []string{"Book"})
+		Expect(err).To(BeNil())
+		_, err = generate(dir, pkg, false)
+		Expect(err).To(MatchError(ContainSubstring("Book has no string id field")))
+	})
+
+	It("converts type names to file names.", func() {
+		Expect(snake("Book")).To(Equal("book"))
+		Expect(snake("ISBNRecord")).To(Equal("isbn_record"))
+		Expect(snake("BookAuthor")).To(Equal("book_author"))
+	})
+})
@@ -0,0 +1,78 @@
+// Command goresource generates the boilerplate for exposing entity types as
+// goresource resources: the Entity methods, a manager, a constructor for the
+// resource and ginkgo test scaffolding.
+//
+// Entities are read from struct types declared in the package,
+//
+//	goresource -type Book,Author
+//
+// or declared in a schema file, from which the struct types are generated too.
+//
+//	goresource -schema entities.yaml
+//
+// A schema file lists the entities with their fields. Json names default to
+// the field name with a lower case first letter, bson names to the json name
+// and the id field is stored as _id. Packages used by field types other than
+// time are listed under imports.
+//
+//	imports: [github.com/shopspring/decimal]
+//	entities:
+//	  - name: Book
+//	    collection: books
+//	    fields:
+//	      - {name: ID, type: string}
+//	      - {name: Price, type: decimal.Decimal}
+//	      - {name: Published, type: time.Time, json: published_at}
+//
+// For each entity the generated code is written to <entity>_resource_gen.go,
+// which is regenerated from scratch on every run. Methods already declared by
+// hand elsewhere in the package are not generated, so to customise one, say
+// ParseJSON, declare it in another file and regenerate. Test scaffolding is
+// only written when the test files don't exist yet.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	var (
+		dir     = flag.String("dir", ".", "package directory to generate into")
+		types   = flag.String("type", "", "comma separated struct types to generate for")
+		schema  = flag.String("schema", "", "schema file declaring entities to generate")
+		noTests = flag.Bool("notests", false, "don't write test scaffolding")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: goresource [-dir dir] (-type Type[,Type...] | -schema file) [-notests]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var (
+		pkg *pkgInfo
+		err error
+	)
+	switch {
+	case *types != "" && *schema == "":
+		pkg, err = fromSource(*dir, strings.Split(*types, ","))
+	case *schema != "" && *types == "":
+		pkg, err = fromSchema(*dir, *schema)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err == nil {
+		var written []string
+		written, err = generate(*dir, pkg, !*noTests)
+		for _, path := range written {
+			fmt.Println(path)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "goresource: %v\n", err)
+		os.Exit(1)
+	}
+}
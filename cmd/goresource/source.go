@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pkgInfo describes the package being generated into.
+type pkgInfo struct {
+	Name     string
+	Entities []*entityInfo
+	// Imports are the packages field types of generated structs may refer to.
+	Imports []string
+	// declared holds the types and methods declared by hand, keyed by type
+	// name or by "Type.Method".
+	declared map[string]bool
+	// hasSuite is set when a test file already runs the ginkgo specs.
+	hasSuite bool
+}
+
+// entityInfo describes a single entity type.
+type entityInfo struct {
+	Name       string
+	Collection string
+	// IDField is the name of the string field holding the id, if any.
+	IDField string
+	// Fields are set for entities declared in a schema file, whose struct
+	// type is generated.
+	Fields []fieldInfo
+}
+
+// fieldInfo is a struct field of an entity declared in a schema file.
+type fieldInfo struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	JSON string `yaml:"json"`
+	BSON string `yaml:"bson"`
+}
+
+// schemaFile is the format of schema files.
+type schemaFile struct {
+	Imports  []string `yaml:"imports"`
+	Entities []struct {
+		Name       string      `yaml:"name"`
+		Collection string      `yaml:"collection"`
+		Fields     []fieldInfo `yaml:"fields"`
+	} `yaml:"entities"`
+}
+
+// generatedHeader marks files written by this command, which are overwritten.
+const generatedHeader = "// Code generated by goresource; DO NOT EDIT."
+
+// scan parses the non test, hand written go files in dir.
+func scan(dir string) (*pkgInfo, map[string]*ast.StructType, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, nil, err
+	}
+	pkg := &pkgInfo{declared: make(map[string]bool)}
+	structs := make(map[string]*ast.StructType)
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if bytes.HasPrefix(src, []byte(generatedHeader)) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, src, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		if strings.HasSuffix(path, "_test.go") {
+			pkg.hasSuite = pkg.hasSuite || bytes.Contains(src, []byte("RunSpecs("))
+			continue
+		}
+		pkg.Name = f.Name.Name
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						pkg.declared[ts.Name.Name] = true
+						if st, ok := ts.Type.(*ast.StructType); ok {
+							structs[ts.Name.Name] = st
+						}
+					}
+				}
+			case *ast.FuncDecl:
+				if d.Recv != nil && len(d.Recv.List) == 1 {
+					pkg.declared[receiver(d.Recv.List[0].Type)+"."+d.Name.Name] = true
+				} else if d.Recv == nil {
+					pkg.declared[d.Name.Name] = true
+				}
+			}
+		}
+	}
+	return pkg, structs, nil
+}
+
+// receiver returns the type name of a method receiver.
+func receiver(expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// fromSource describes the given struct types declared in the package in dir.
+func fromSource(dir string, types []string) (*pkgInfo, error) {
+	pkg, structs, err := scan(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range types {
+		name = strings.TrimSpace(name)
+		st, ok := structs[name]
+		if !ok {
+			return nil, fmt.Errorf("struct type %s not found in %s", name, dir)
+		}
+		e := &entityInfo{Name: name, Collection: collection(name)}
+		for _, f := range st.Fields.List {
+			var tag reflect.StructTag
+			if f.Tag != nil {
+				tag = reflect.StructTag(strings.Trim(f.Tag.Value, "`"))
+			}
+			ident, ok := f.Type.(*ast.Ident)
+			if !ok || ident.Name != "string" {
+				continue
+			}
+			for _, n := range f.Names {
+				if isID(n.Name, tag) {
+					e.IDField = n.Name
+				}
+			}
+		}
+		pkg.Entities = append(pkg.Entities, e)
+	}
+	return pkg, nil
+}
+
+// fromSchema describes the entities declared in the given schema file, to be
+// generated into the package in dir.
+func fromSchema(dir string, path string) (*pkgInfo, error) {
+	pkg, _, err := scan(dir)
+	if err != nil {
+		return nil, err
+	}
+	if pkg.Name == "" {
+		pkg.Name = filepath.Base(mustAbs(dir))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var schema schemaFile
+	if err := yaml.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	pkg.Imports = append([]string{"time"}, schema.Imports...)
+	for _, s := range schema.Entities {
+		if !token.IsIdentifier(s.Name) {
+			return nil, fmt.Errorf("%s: invalid entity name %q", path, s.Name)
+		}
+		e := &entityInfo{Name: s.Name, Collection: s.Collection}
+		if e.Collection == "" {
+			e.Collection = collection(s.Name)
+		}
+		for _, f := range s.Fields {
+			if !token.IsIdentifier(f.Name) || f.Type == "" {
+				return nil, fmt.Errorf("%s: invalid field %q of %s", path, f.Name, s.Name)
+			}
+			if f.JSON == "" {
+				f.JSON = lowerFirst(f.Name)
+			}
+			if f.BSON == "" {
+				f.BSON = f.JSON
+				if f.JSON == "id" {
+					f.BSON = "_id"
+				}
+			}
+			tag := reflect.StructTag(fmt.Sprintf(`json:"%s" bson:"%s"`, f.JSON, f.BSON))
+			if f.Type == "string" && isID(f.Name, tag) {
+				e.IDField = f.Name
+			}
+			e.Fields = append(e.Fields, f)
+		}
+		pkg.Entities = append(pkg.Entities, e)
+	}
+	return pkg, nil
+}
+
+// isID reports whether the field with the given name and tag holds the entity id.
+func isID(name string, tag reflect.StructTag) bool {
+	jsonName := strings.Split(tag.Get("json"), ",")[0]
+	bsonName := strings.Split(tag.Get("bson"), ",")[0]
+	return jsonName == "id" || bsonName == "_id" || name == "ID" || name == "Id"
+}
+
+// collection returns the default collection name for an entity type.
+func collection(name string) string {
+	return strings.ToLower(name) + "s"
+}
+
+// lowerFirst lower cases the leading upper case letters of name, so ISBN becomes isbn and PageCount pageCount.
+func lowerFirst(name string) string {
+	runes := []rune(name)
+	for i := range runes {
+		if !unicode.IsUpper(runes[i]) {
+			break
+		}
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+func mustAbs(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// writeFile writes a file into dir for a test.
+func writeFile(dir, name, content string) {
+	Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)).To(Succeed())
+}
+
+var _ = Describe("source", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "generate")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("fromSource", func() {
+		It("finds the id field and hand written declarations.", func() {
+			writeFile(dir, "book.go", "package books\n\ntype Book struct {\n\tKey string `json:\"id\"`\n\tName string\n}\n\n"+
+				"func (b *Book) HasId() bool { return b.Key != \"\" }\n\nfunc NewBookResource() {}\n")
+			writeFile(dir, "book_resource_gen.go", generatedHeader+"\n\npackage books\n\nfunc (b Book) GetId() string { return \"\" }\n")
+			writeFile(dir, "books_test.go", "package books\n\nfunc TestBooks() { RunSpecs() }\n")
+			pkg, err := fromSource(dir, []string{"Book"})
+			Expect(err).To(BeNil())
+			Expect(pkg.Name).To(Equal("books"))
+			Expect(pkg.Entities).To(Equal([]*entityInfo{{Name: "Book", Collection: "books", IDField: "Key"}}))
+			Expect(pkg.declared).To(Equal(map[string]bool{"Book": true, "Book.HasId": true, "NewBookResource": true}))
+			Expect(pkg.hasSuite).To(BeTrue())
+		})
+
+		It("fails for missing types.", func() {
+			writeFile(dir, "book.go", "package books\n")
+			_, err := fromSource(dir, []string{"Book"})
+			Expect(err).To(MatchError(ContainSubstring("struct type Book not found")))
+		})
+	})
+
+	Describe("fromSchema", func() {
+		It("describes the declared entities.", func() {
+			writeFile(dir, "schema.yaml", `
+imports: [github.com/shopspring/decimal]
+entities:
+  - name: Book
+    fields:
+      - {name: ID, type: string}
+      - {name: ISBN, type: string}
+      - {name: PageCount, type: int, bson: pages}
+  - name: Author
+    collection: people
+    fields:
+      - {name: Key, type: string, json: id}
+`)
+			pkg, err := fromSchema(dir, filepath.Join(dir, "schema.yaml"))
+			Expect(err).To(BeNil())
+			Expect(pkg.Name).To(Equal(filepath.Base(dir)))
+			Expect(pkg.Imports).To(Equal([]string{"time", "github.com/shopspring/decimal"}))
+			Expect(pkg.Entities).To(Equal([]*entityInfo{
+				{Name: "Book", Collection: "books", IDField: "ID", Fields: []fieldInfo{
+					{Name: "ID", Type: "string", JSON: "id", BSON: "_id"},
+					{Name: "ISBN", Type: "string", JSON: "isbn", BSON: "isbn"},
+					{Name: "PageCount", Type: "int", JSON: "pageCount", BSON: "pages"},
+				}},
+				{Name: "Author", Collection: "people", IDField: "Key", Fields: []fieldInfo{
+					{Name: "Key", Type: "string", JSON: "id", BSON: "_id"},
+				}},
+			}))
+		})
+
+		It("rejects invalid names.", func() {
+			writeFile(dir, "schema.yaml", "entities:\n  - name: a-b\n")
+			_, err := fromSchema(dir, filepath.Join(dir, "schema.yaml"))
+			Expect(err).To(MatchError(ContainSubstring(`invalid entity name "a-b"`)))
+		})
+	})
+})
@@ -0,0 +1,173 @@
+// Package codec implements encoding and decoding of entities in the media
+// types supported by goresource, and content negotiation between them.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Media types of the built in codecs.
+const (
+	JSONType    = "application/json"
+	XMLType     = "application/xml"
+	YAMLType    = "application/yaml"
+	MsgPackType = "application/msgpack"
+	CBORType    = "application/cbor"
+)
+
+// Codec is implemented by encoders/decoders for a single media type.
+type Codec interface {
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Registry holds codecs keyed by media type.
+type Registry struct {
+	codecs map[string]Codec
+}
+
+// NewRegistry returns a registry with the given codecs registered.
+func NewRegistry(codecs ...Codec) *Registry {
+	r := &Registry{codecs: make(map[string]Codec)}
+	for _, c := range codecs {
+		r.Register(c)
+	}
+	return r
+}
+
+// Register adds the given codec for its content type and any additional aliases.
+func (r *Registry) Register(c Codec, aliases ...string) {
+	r.codecs[c.ContentType()] = c
+	for _, alias := range aliases {
+		r.codecs[alias] = c
+	}
+}
+
+// Lookup returns the codec registered for the given media type, ignoring any parameters.
+func (r *Registry) Lookup(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	c, ok := r.codecs[mediaType]
+	return c, ok
+}
+
+// Negotiate picks the codec best matching the given Accept header among the
+// offered media types, which are listed in order of preference. An empty
+// Accept header matches the first offered type.
+func (r *Registry) Negotiate(accept string, offered []string) (Codec, bool) {
+	if strings.TrimSpace(accept) == "" {
+		accept = "*/*"
+	}
+	for _, mediaRange := range parseAccept(accept) {
+		if c, ok := r.codecs[mediaRange]; ok {
+			mediaRange = c.ContentType()
+		}
+		for _, o := range offered {
+			if c, ok := r.codecs[o]; ok && matches(mediaRange, o) {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Default is the registry of the built in codecs.
+var Default = NewRegistry(JSON, XML, YAML, MsgPack, CBOR)
+
+func init() {
+	Default.Register(XML, "text/xml")
+	Default.Register(YAML, "application/x-yaml", "text/yaml")
+	Default.Register(MsgPack, "application/x-msgpack")
+}
+
+// Write encodes data with the given codec and writes it to the response stream.
+func Write(c Codec, data interface{}, rw http.ResponseWriter) {
+	encoded, err := c.Marshal(data)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", c.ContentType())
+	rw.WriteHeader(http.StatusOK)
+	rw.Write(encoded)
+}
+
+// ToJSON decodes data with the given codec and re-encodes it as json, so it can
+// be handed to a ResourceManager's ParseJSON.
+func ToJSON(c Codec, data []byte) ([]byte, error) {
+	var v interface{}
+	if err := c.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// accepted is a single media range from an Accept header.
+type accepted struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept returns the media ranges in the given Accept header, most
+// preferred first. Ranges with a zero quality are dropped.
+func parseAccept(accept string) []string {
+	var ranges []accepted
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, accepted{mediaType, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	result := make([]string, len(ranges))
+	for i, a := range ranges {
+		result[i] = a.mediaType
+	}
+	return result
+}
+
+// matches reports whether the media range accepts the given media type.
+func matches(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+	if strings.HasSuffix(mediaRange, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*"))
+	}
+	return false
+}
+
+// jsonCodec encodes as json.
+type jsonCodec struct{}
+
+// JSON is the application/json codec.
+var JSON Codec = jsonCodec{}
+
+func (jsonCodec) ContentType() string { return JSONType }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	return decoder.Decode(v)
+}
@@ -0,0 +1,98 @@
+package codec_test
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+
+	"goresource/codec"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	var all = []string{codec.JSONType, codec.XMLType, codec.YAMLType, codec.MsgPackType, codec.CBORType}
+
+	Describe("Lookup", func() {
+		It("finds codecs by content type, ignoring parameters.", func() {
+			c, ok := codec.Default.Lookup("application/json; charset=utf-8")
+			Expect(ok).To(BeTrue())
+			Expect(c).To(Equal(codec.JSON))
+		})
+		It("finds codecs by alias.", func() {
+			c, ok := codec.Default.Lookup("application/x-yaml")
+			Expect(ok).To(BeTrue())
+			Expect(c).To(Equal(codec.YAML))
+		})
+		It("does not find unknown media types.", func() {
+			_, ok := codec.Default.Lookup("text/csv")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("Negotiate", func() {
+		It("picks the first offered type given no Accept header.", func() {
+			c, ok := codec.Default.Negotiate("", all)
+			Expect(ok).To(BeTrue())
+			Expect(c).To(Equal(codec.JSON))
+		})
+		It("picks the first offered type given a wildcard.", func() {
+			c, ok := codec.Default.Negotiate("*/*", []string{codec.CBORType, codec.JSONType})
+			Expect(ok).To(BeTrue())
+			Expect(c).To(Equal(codec.CBOR))
+		})
+		It("honours quality values.", func() {
+			c, ok := codec.Default.Negotiate("application/json;q=0.5, application/yaml", all)
+			Expect(ok).To(BeTrue())
+			Expect(c).To(Equal(codec.YAML))
+		})
+		It("matches subtype wildcards.", func() {
+			c, ok := codec.Default.Negotiate("text/html, application/*;q=0.8", []string{codec.MsgPackType})
+			Expect(ok).To(BeTrue())
+			Expect(c).To(Equal(codec.MsgPack))
+		})
+		It("ignores media types not offered.", func() {
+			_, ok := codec.Default.Negotiate("application/xml", []string{codec.JSONType})
+			Expect(ok).To(BeFalse())
+		})
+		It("ignores media types with a zero quality.", func() {
+			_, ok := codec.Default.Negotiate("application/json;q=0", []string{codec.JSONType})
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("Write", func() {
+		var rw *httptest.ResponseRecorder
+
+		BeforeEach(func() {
+			rw = httptest.NewRecorder()
+		})
+
+		It("writes the encoded data with the codec's content type.", func() {
+			codec.Write(codec.YAML, map[string]string{"name": "foo"}, rw)
+			got, _ := ioutil.ReadAll(rw.Body)
+			Expect(string(got)).To(Equal("name: foo\n"))
+			Expect(rw.Code).To(Equal(http.StatusOK))
+			Expect(rw.Header().Get("Content-Type")).To(Equal(codec.YAMLType))
+		})
+		It("responds with an error if encoding fails.", func() {
+			codec.Write(codec.JSON, math.Inf(1), rw)
+			Expect(rw.Code).To(Equal(http.StatusInternalServerError))
+			Expect(rw.Header().Get("Content-Type")).To(Equal("text/plain; charset=utf-8"))
+		})
+	})
+
+	Describe("ToJSON", func() {
+		It("converts the given data to json.", func() {
+			got, err := codec.ToJSON(codec.YAML, []byte("name: foo\ncount: 2\n"))
+			Expect(err).To(BeNil())
+			Expect(string(got)).To(MatchJSON(`{"name":"foo","count":2}`))
+		})
+		It("passes through decoding errors.", func() {
+			_, err := codec.ToJSON(codec.JSON, []byte("{"))
+			Expect(err).ToNot(BeNil())
+		})
+	})
+})
@@ -0,0 +1,259 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats other than json encode the json representation of a value, so field
+// names follow the json struct tags used throughout goresource.
+
+// generic converts the given value into its json representation made of maps,
+// slices and scalars.
+func generic(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&result); err != nil {
+		return nil, err
+	}
+	return numbers(result), nil
+}
+
+// numbers replaces json.Number values with int64 or float64.
+func numbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = numbers(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = numbers(e)
+		}
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	}
+	return v
+}
+
+type yamlCodec struct{}
+
+// YAML is the application/yaml codec.
+var YAML Codec = yamlCodec{}
+
+func (yamlCodec) ContentType() string { return YAMLType }
+
+func (yamlCodec) Marshal(v interface{}) ([]byte, error) {
+	g, err := generic(v)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(g)
+}
+
+func (yamlCodec) Unmarshal(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
+
+type msgpackCodec struct{}
+
+// MsgPack is the application/msgpack codec.
+var MsgPack Codec = msgpackCodec{}
+
+func (msgpackCodec) ContentType() string { return MsgPackType }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	g, err := generic(v)
+	if err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(g)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+type cborCodec struct {
+	decoder cbor.DecMode
+}
+
+// CBOR is the application/cbor codec.
+var CBOR Codec = newCBORCodec()
+
+func newCBORCodec() cborCodec {
+	decoder, err := cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}(nil))}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return cborCodec{decoder: decoder}
+}
+
+func (cborCodec) ContentType() string { return CBORType }
+
+func (cborCodec) Marshal(v interface{}) ([]byte, error) {
+	g, err := generic(v)
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(g)
+}
+
+func (c cborCodec) Unmarshal(data []byte, v interface{}) error {
+	return c.decoder.Unmarshal(data, v)
+}
+
+// xmlCodec encodes entities as elements named after their fields. A list is
+// encoded as an <items> element with an <item> element per entity.
+type xmlCodec struct{}
+
+// XML is the application/xml codec.
+var XML Codec = xmlCodec{}
+
+func (xmlCodec) ContentType() string { return XMLType }
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error) {
+	g, err := generic(v)
+	if err != nil {
+		return nil, err
+	}
+	root := "item"
+	if _, ok := g.([]interface{}); ok {
+		root = "items"
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString(xml.Header)
+	encoder := xml.NewEncoder(buf)
+	if err := encodeXML(encoder, root, g); err != nil {
+		return nil, err
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeXML(encoder *xml.Encoder, name string, v interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := encodeXML(encoder, k, t[k]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, e := range t {
+			if err := encodeXML(encoder, "item", e); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := encoder.EncodeToken(xml.CharData(fmt.Sprint(t))); err != nil {
+			return err
+		}
+	}
+	return encoder.EncodeToken(start.End())
+}
+
+// Unmarshal decodes into structs using encoding/xml. Decoding into an empty
+// interface yields a map per element with child elements and a string per
+// element with only text, repeated child elements become a slice. Xml has no
+// types, so every value is a string: decode into the entity's type, or convert
+// the values to its field types as Resource does for request bodies.
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error {
+	target, ok := v.(*interface{})
+	if !ok {
+		return xml.Unmarshal(data, v)
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			result, err := decodeXML(decoder, start)
+			if err != nil {
+				return err
+			}
+			*target = result
+			return nil
+		}
+	}
+}
+
+func decodeXML(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	var (
+		text     strings.Builder
+		children map[string]interface{}
+	)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			child, err := decodeXML(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+			if children == nil {
+				children = make(map[string]interface{})
+			}
+			name := t.Name.Local
+			if existing, ok := children[name]; ok {
+				if list, ok := existing.([]interface{}); ok {
+					children[name] = append(list, child)
+				} else {
+					children[name] = []interface{}{existing, child}
+				}
+			} else {
+				children[name] = child
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if children == nil {
+				return strings.TrimSpace(text.String()), nil
+			}
+			if start.Name.Local == "items" && len(children) == 1 {
+				if items, ok := children["item"]; ok {
+					if list, ok := items.([]interface{}); ok {
+						return list, nil
+					}
+					return []interface{}{items}, nil
+				}
+			}
+			return children, nil
+		}
+	}
+}
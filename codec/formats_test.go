@@ -0,0 +1,95 @@
+package codec_test
+
+import (
+	"encoding/json"
+
+	"goresource/codec"
+
+	"github.com/onsi/ginkgo/extensions/table"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type book struct {
+	ID    string   `json:"id"`
+	Name  string   `json:"name"`
+	Pages int      `json:"pages"`
+	Tags  []string `json:"tags"`
+}
+
+var _ = Describe("Formats", func() {
+	var (
+		item = book{ID: "fakeid", Name: "foo", Pages: 12, Tags: []string{"a", "b"}}
+		want = `{"id":"fakeid","name":"foo","pages":12,"tags":["a","b"]}`
+	)
+
+	table.DescribeTable("round trips entities through their json representation",
+		func(c codec.Codec) {
+			data, err := c.Marshal(item)
+			Expect(err).To(BeNil())
+			got, err := codec.ToJSON(c, data)
+			Expect(err).To(BeNil())
+			Expect(string(got)).To(MatchJSON(want))
+		},
+		table.Entry("yaml", codec.YAML),
+		table.Entry("msgpack", codec.MsgPack),
+		table.Entry("cbor", codec.CBOR),
+	)
+
+	table.DescribeTable("round trips lists",
+		func(c codec.Codec) {
+			data, err := c.Marshal([]book{item, item})
+			Expect(err).To(BeNil())
+			got, err := codec.ToJSON(c, data)
+			Expect(err).To(BeNil())
+			Expect(string(got)).To(MatchJSON("[" + want + "," + want + "]"))
+		},
+		table.Entry("yaml", codec.YAML),
+		table.Entry("msgpack", codec.MsgPack),
+		table.Entry("cbor", codec.CBOR),
+	)
+
+	Describe("XML", func() {
+		It("encodes entities as elements named after their json fields.", func() {
+			data, err := codec.XML.Marshal(map[string]interface{}{"id": "fakeid", "name": "foo"})
+			Expect(err).To(BeNil())
+			Expect(string(data)).To(HaveSuffix("<item><id>fakeid</id><name>foo</name></item>"))
+		})
+		It("encodes lists as items.", func() {
+			data, err := codec.XML.Marshal([]map[string]string{{"id": "a"}, {"id": "b"}})
+			Expect(err).To(BeNil())
+			Expect(string(data)).To(HaveSuffix("<items><item><id>a</id></item><item><id>b</id></item></items>"))
+		})
+		It("decodes elements into fields, with repeated elements as lists.", func() {
+			got, err := codec.ToJSON(codec.XML, []byte("<item><name>foo</name><tags>a</tags><tags>b</tags></item>"))
+			Expect(err).To(BeNil())
+			Expect(string(got)).To(MatchJSON(`{"name":"foo","tags":["a","b"]}`))
+		})
+		It("decodes items into a list.", func() {
+			got, err := codec.ToJSON(codec.XML, []byte("<items><item><id>a</id></item></items>"))
+			Expect(err).To(BeNil())
+			Expect(string(got)).To(MatchJSON(`[{"id":"a"}]`))
+		})
+		It("decodes into structs using encoding/xml.", func() {
+			var result struct {
+				Name string `xml:"name"`
+			}
+			Expect(codec.XML.Unmarshal([]byte("<item><name>foo</name></item>"), &result)).To(Succeed())
+			Expect(result.Name).To(Equal("foo"))
+		})
+		It("returns an error for invalid documents.", func() {
+			var result interface{}
+			Expect(codec.XML.Unmarshal([]byte("<item><name>"), &result)).ToNot(Succeed())
+		})
+	})
+
+	Describe("JSON", func() {
+		It("encodes with encoding/json.", func() {
+			data, err := codec.JSON.Marshal(item)
+			Expect(err).To(BeNil())
+			expected, _ := json.Marshal(item)
+			Expect(data).To(Equal(expected))
+		})
+	})
+})
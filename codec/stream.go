@@ -0,0 +1,133 @@
+package codec
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Media types of the built in stream codecs.
+const (
+	NDJSONType = "application/x-ndjson"
+	CSVType    = "text/csv"
+)
+
+// StreamCodec is implemented by formats that write entities one at a time.
+type StreamCodec interface {
+	ContentType() string
+	NewWriter(w io.Writer) RowWriter
+}
+
+// RowWriter writes a stream of entities.
+type RowWriter interface {
+	// Write encodes a single entity.
+	Write(v interface{}) error
+	// Flush writes any buffered data to the underlying writer.
+	Flush() error
+}
+
+// Streams are the built in stream codecs, keyed by media type.
+var Streams = map[string]StreamCodec{
+	NDJSONType: NDJSON,
+	CSVType:    CSV,
+}
+
+// NegotiateType picks the media type best matching the given Accept header
+// among the offered media types, which are listed in order of preference.
+func NegotiateType(accept string, offered []string) (string, bool) {
+	for _, mediaRange := range parseAccept(accept) {
+		for _, o := range offered {
+			if matches(mediaRange, o) {
+				return o, true
+			}
+		}
+	}
+	return "", false
+}
+
+type ndjsonCodec struct{}
+
+// NDJSON writes newline delimited json.
+var NDJSON StreamCodec = ndjsonCodec{}
+
+func (ndjsonCodec) ContentType() string { return NDJSONType }
+
+func (ndjsonCodec) NewWriter(w io.Writer) RowWriter {
+	return ndjsonWriter{json.NewEncoder(w)}
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (w ndjsonWriter) Write(v interface{}) error {
+	return w.encoder.Encode(v)
+}
+
+func (w ndjsonWriter) Flush() error {
+	return nil
+}
+
+type csvCodec struct{}
+
+// CSV writes comma separated values. The columns are the sorted fields of the
+// first entity written, since the header is sent before later entities are
+// read: fields missing from the first entity are left out of every row, and
+// its fields missing from later entities are left empty. Nested values are
+// written as json.
+var CSV StreamCodec = csvCodec{}
+
+func (csvCodec) ContentType() string { return CSVType }
+
+func (csvCodec) NewWriter(w io.Writer) RowWriter {
+	return &csvWriter{writer: csv.NewWriter(w)}
+}
+
+type csvWriter struct {
+	writer  *csv.Writer
+	columns []string
+}
+
+func (w *csvWriter) Write(v interface{}) error {
+	g, err := generic(v)
+	if err != nil {
+		return err
+	}
+	fields, ok := g.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("csv: cannot write %T as a row", v)
+	}
+	if w.columns == nil {
+		for k := range fields {
+			w.columns = append(w.columns, k)
+		}
+		sort.Strings(w.columns)
+		if err := w.writer.Write(w.columns); err != nil {
+			return err
+		}
+	}
+	row := make([]string, len(w.columns))
+	for i, k := range w.columns {
+		switch f := fields[k].(type) {
+		case nil:
+		case string:
+			row[i] = f
+		case map[string]interface{}, []interface{}:
+			data, err := json.Marshal(f)
+			if err != nil {
+				return err
+			}
+			row[i] = string(data)
+		default:
+			row[i] = fmt.Sprint(f)
+		}
+	}
+	return w.writer.Write(row)
+}
+
+func (w *csvWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
@@ -0,0 +1,54 @@
+package codec_test
+
+import (
+	"bytes"
+
+	"goresource/codec"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Streams", func() {
+	var buf *bytes.Buffer
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+	})
+
+	Describe("NDJSON", func() {
+		It("writes an entity per line.", func() {
+			w := codec.NDJSON.NewWriter(buf)
+			Expect(w.Write(map[string]string{"name": "a"})).To(Succeed())
+			Expect(w.Write(map[string]string{"name": "b"})).To(Succeed())
+			Expect(w.Flush()).To(Succeed())
+			Expect(buf.String()).To(Equal("{\"name\":\"a\"}\n{\"name\":\"b\"}\n"))
+		})
+	})
+
+	Describe("CSV", func() {
+		It("writes a header followed by a row per entity.", func() {
+			w := codec.CSV.NewWriter(buf)
+			Expect(w.Write(map[string]interface{}{"name": "a, b", "pages": 12, "tags": []string{"x"}})).To(Succeed())
+			Expect(w.Write(map[string]interface{}{"name": "c", "extra": true})).To(Succeed())
+			Expect(w.Flush()).To(Succeed())
+			Expect(buf.String()).To(Equal("name,pages,tags\n\"a, b\",12,\"[\"\"x\"\"]\"\nc,,\n"))
+		})
+		It("returns an error for values that are not entities.", func() {
+			w := codec.CSV.NewWriter(buf)
+			Expect(w.Write("foo")).ToNot(Succeed())
+		})
+	})
+
+	Describe("NegotiateType", func() {
+		It("picks the best matching offered media type.", func() {
+			got, ok := codec.NegotiateType("text/csv, application/json;q=0.5", []string{codec.JSONType, codec.CSVType})
+			Expect(ok).To(BeTrue())
+			Expect(got).To(Equal(codec.CSVType))
+		})
+		It("matches nothing given no Accept header.", func() {
+			_, ok := codec.NegotiateType("", []string{codec.JSONType})
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
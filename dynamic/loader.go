@@ -0,0 +1,170 @@
+package dynamic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rockstardevs/goresource"
+	"github.com/rockstardevs/goresource/store"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Loader registers a Resource for each schema in a schema file, a json object
+// mapping resource names to JSON Schemas, and keeps them in sync with the file.
+// Resources may be added while serving, so the Loader routes requests to them
+// itself and is mounted on a router at its path prefix.
+type Loader struct {
+	path     string
+	prefix   string
+	router   *mux.Router
+	routes   sync.RWMutex
+	store    store.Store
+	opts     []goresource.Option
+	mu       sync.Mutex
+	data     []byte
+	schemas  map[string]json.RawMessage
+	compiled map[string]*jsonschema.Schema
+	// managers and resources hold the managers and resources of every schema
+	// loaded, including those whose schema has since been removed. Both are
+	// added to with routes locked, since ServeHTTP reads managers.
+	managers  map[string]*Manager
+	resources map[string]*goresource.Resource
+}
+
+// NewLoader returns a Loader serving resources for the schemas in the file at
+// path under the given path prefix, storing documents in the given store.
+//
+//	loader := dynamic.NewLoader("schemas.json", "/admin", store)
+//	router.PathPrefix("/admin/").Handler(loader)
+func NewLoader(path string, prefix string, s store.Store, opts ...goresource.Option) *Loader {
+	return &Loader{
+		path:      path,
+		prefix:    prefix,
+		router:    mux.NewRouter().PathPrefix(prefix).Subrouter(),
+		store:     s,
+		opts:      opts,
+		managers:  make(map[string]*Manager),
+		resources: make(map[string]*goresource.Resource),
+	}
+}
+
+// Load reads the schema file, registering resources for new schemas and
+// updating those of existing resources. Resources whose schema was removed
+// reject requests with ErrRemoved and are unregistered, so they are no longer
+// described, until their schema is added back. If any schema is invalid
+// nothing changes.
+func (l *Loader) Load() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(data, l.data) {
+		return nil
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("parsing %s: %v", l.path, err)
+	}
+	compiled := make(map[string]*jsonschema.Schema)
+	for name, schema := range raw {
+		if bytes.Equal(schema, l.schemas[name]) {
+			compiled[name] = l.compiled[name]
+			continue
+		}
+		if compiled[name], err = Compile(name, schema); err != nil {
+			return fmt.Errorf("compiling schema %s: %v", name, err)
+		}
+	}
+	for _, name := range sortedNames(compiled) {
+		if m, ok := l.managers[name]; ok {
+			m.SetSchema(compiled[name])
+			goresource.Register(l.resources[name])
+			continue
+		}
+		m := NewManager(name, l.store, compiled[name])
+		l.routes.Lock()
+		l.resources[name] = goresource.NewResource(m, l.router, l.opts...)
+		l.managers[name] = m
+		l.routes.Unlock()
+	}
+	for name, m := range l.managers {
+		if _, ok := raw[name]; !ok {
+			m.Remove()
+			goresource.Unregister(l.resources[name])
+		}
+	}
+	l.data, l.schemas, l.compiled = data, raw, compiled
+	return nil
+}
+
+// ServeHTTP routes the request to the resource for its path. Every request to
+// a removed resource, including its events, websocket and import routes which
+// don't go through its manager, is responded to with 404 Not Found.
+func (l *Loader) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	var match mux.RouteMatch
+	name, _, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, l.prefix+"/"), "/")
+	l.routes.RLock()
+	ok := l.router.Match(req, &match)
+	m, found := l.managers[name]
+	l.routes.RUnlock()
+	if !ok || match.Handler == nil {
+		http.NotFound(rw, req)
+		return
+	}
+	if found {
+		if _, err := m.state(); err != nil {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+	match.Handler.ServeHTTP(rw, mux.SetURLVars(req, match.Vars))
+}
+
+// Watch reloads the schema file whenever its contents change, checking every
+// interval until the context is done. Failed reloads are logged and leave the
+// resources unchanged.
+func (l *Loader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Load(); err != nil {
+				slog.ErrorContext(ctx, "error reloading schemas", "path", l.path, "error", err.Error())
+			}
+		}
+	}
+}
+
+// Manager returns the manager of the named resource.
+func (l *Loader) Manager(name string) (*Manager, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m, ok := l.managers[name]
+	return m, ok
+}
+
+// sortedNames returns the keys of the given schemas in order, so resources are
+// registered deterministically.
+func sortedNames(schemas map[string]*jsonschema.Schema) []string {
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
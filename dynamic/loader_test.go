@@ -0,0 +1,166 @@
+package dynamic_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goresource"
+	"goresource/dynamic"
+	"goresource/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Loader", func() {
+	var (
+		ctrl   *gomock.Controller
+		store  *mocks.MockStore
+		router *mux.Router
+		dir    string
+		path   string
+		loader *dynamic.Loader
+	)
+
+	write := func(content string) {
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+
+	post := func(url, body string) int {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		router.ServeHTTP(rw, req)
+		return rw.Code
+	}
+
+	get := func(url, accept string) int {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		router.ServeHTTP(rw, req)
+		return rw.Code
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStore(ctrl)
+		store.EXPECT().CreateEntity(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		router = mux.NewRouter()
+		var err error
+		dir, err = os.MkdirTemp("", "dynamic")
+		Expect(err).To(BeNil())
+		path = filepath.Join(dir, "schemas.json")
+		write(`{"books": ` + bookSchema + `, "authors": {"type": "object"}}`)
+		loader = dynamic.NewLoader(path, "/admin", store)
+		router.PathPrefix("/admin/").Handler(loader)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+		os.RemoveAll(dir)
+	})
+
+	It("registers a resource per schema.", func() {
+		Expect(loader.Load()).To(Succeed())
+		Expect(post("/admin/books", `{"name": "a"}`)).To(Equal(http.StatusOK))
+		Expect(post("/admin/books", `{"pages": 0}`)).To(Equal(http.StatusBadRequest))
+		Expect(post("/admin/authors", `{"any": "thing"}`)).To(Equal(http.StatusOK))
+		Expect(post("/admin/missing", `{}`)).To(Equal(http.StatusNotFound))
+		_, ok := loader.Manager("books")
+		Expect(ok).To(BeTrue())
+	})
+
+	It("updates, adds and removes resources on reload.", func() {
+		Expect(loader.Load()).To(Succeed())
+		write(`{"books": {"type": "object"}, "reviews": {"type": "object", "required": ["stars"]}}`)
+		Expect(loader.Load()).To(Succeed())
+		Expect(post("/admin/books", `{"pages": 0}`)).To(Equal(http.StatusOK))
+		Expect(post("/admin/reviews", `{}`)).To(Equal(http.StatusBadRequest))
+		Expect(post("/admin/reviews", `{"stars": 5}`)).To(Equal(http.StatusOK))
+		Expect(post("/admin/authors", `{}`)).To(Equal(http.StatusNotFound))
+
+		write(`{"authors": {"type": "object"}}`)
+		Expect(loader.Load()).To(Succeed())
+		Expect(post("/admin/authors", `{}`)).To(Equal(http.StatusOK))
+	})
+
+	It("responds with not found to every request to removed resources.", func() {
+		Expect(loader.Load()).To(Succeed())
+		write(`{"books": ` + bookSchema + `}`)
+		Expect(loader.Load()).To(Succeed())
+		for _, url := range []string{"/admin/authors", "/admin/authors/1", "/admin/authors/count",
+			"/admin/authors/aggregate?group=name", "/admin/authors?q=a"} {
+			Expect(get(url, "")).To(Equal(http.StatusNotFound), url)
+		}
+		Expect(get("/admin/authors", "application/x-ndjson")).To(Equal(http.StatusNotFound))
+		Expect(post("/admin/authors", `{}`)).To(Equal(http.StatusNotFound))
+		Expect(post("/admin/authors/import", `{"any": "thing"}`)).To(Equal(http.StatusNotFound))
+	})
+
+	It("responds with not found to change feeds of removed resources.", func() {
+		loader = dynamic.NewLoader(path, "/admin", store, goresource.WithChangeFeed(goresource.NewChangeFeed(0)))
+		router = mux.NewRouter()
+		router.PathPrefix("/admin/").Handler(loader)
+		Expect(loader.Load()).To(Succeed())
+		write(`{"books": ` + bookSchema + `}`)
+		Expect(loader.Load()).To(Succeed())
+		Expect(get("/admin/authors/events", "text/event-stream")).To(Equal(http.StatusNotFound))
+		Expect(get("/admin/authors/ws", "")).To(Equal(http.StatusNotFound))
+	})
+
+	It("unregisters removed resources until their schema is added back.", func() {
+		// names returns the names of the registered resources served by the loader.
+		names := func() []string {
+			var result []string
+			for _, r := range goresource.Registered() {
+				if m, ok := loader.Manager(r.Name()); ok && r.Manager() == m {
+					result = append(result, r.Name())
+				}
+			}
+			return result
+		}
+		Expect(loader.Load()).To(Succeed())
+		Expect(names()).To(ConsistOf("authors", "books"))
+		write(`{"books": ` + bookSchema + `}`)
+		Expect(loader.Load()).To(Succeed())
+		Expect(names()).To(ConsistOf("books"))
+		write(`{"books": ` + bookSchema + `, "authors": {"type": "object"}}`)
+		Expect(loader.Load()).To(Succeed())
+		Expect(names()).To(ConsistOf("authors", "books"))
+		Expect(post("/admin/authors", `{}`)).To(Equal(http.StatusOK))
+	})
+
+	It("keeps the current schemas if the file is invalid.", func() {
+		Expect(loader.Load()).To(Succeed())
+		write(`{"books": {"type": 5}}`)
+		Expect(loader.Load()).To(MatchError(ContainSubstring("compiling schema books")))
+		write(`{bad`)
+		Expect(loader.Load()).To(MatchError(ContainSubstring("parsing")))
+		Expect(post("/admin/books", `{"pages": 0}`)).To(Equal(http.StatusBadRequest))
+		Expect(post("/admin/authors", `{}`)).To(Equal(http.StatusOK))
+	})
+
+	It("reloads when the file changes while watching.", func() {
+		Expect(loader.Load()).To(Succeed())
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			loader.Watch(ctx, 5*time.Millisecond)
+			close(done)
+		}()
+		write(`{"books": {"type": "object"}}`)
+		Eventually(func() int { return post("/admin/books", `{"pages": 0}`) }).Should(Equal(http.StatusOK))
+		cancel()
+		Eventually(done).Should(BeClosed())
+	})
+})
@@ -0,0 +1,210 @@
+// Package dynamic serves resources defined at runtime by JSON Schemas, for
+// entities without a go type of their own.
+package dynamic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+
+	"github.com/rockstardevs/goresource"
+	"github.com/rockstardevs/goresource/store"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// ErrRemoved is returned for requests to resources whose schema was removed,
+// responded to with 404 Not Found.
+var ErrRemoved = fmt.Errorf("%w: resource removed", store.ErrNotFound)
+
+// Document is an entity without a go type, holding its json fields.
+type Document map[string]interface{}
+
+// HasId reports whether this document has an id.
+func (d Document) HasId() bool {
+	return d.GetId() != ""
+}
+
+// GetId returns the document's id field, or _id as returned by stores.
+func (d Document) GetId() string {
+	for _, key := range []string{"id", "_id"} {
+		if id, ok := d[key].(string); ok && id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// Manager is a ResourceManager for Documents, validating them against a JSON
+// Schema which may be replaced while serving.
+type Manager struct {
+	goresource.DefaultManager
+
+	mu      sync.RWMutex
+	schema  *jsonschema.Schema
+	removed bool
+}
+
+// NewManager returns a Manager storing documents valid against the given
+// schema in the named collection of the given store.
+func NewManager(name string, s store.Store, schema *jsonschema.Schema) *Manager {
+	return &Manager{DefaultManager: goresource.NewDefaultManager(name, s), schema: schema}
+}
+
+// Compile compiles the given JSON Schema document.
+func Compile(name string, schema []byte) (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	resource := "mem://dynamic/" + url.PathEscape(name) + ".json"
+	if err := compiler.AddResource(resource, bytes.NewReader(schema)); err != nil {
+		return nil, err
+	}
+	return compiler.Compile(resource)
+}
+
+// SetSchema replaces the schema documents are validated against.
+func (m *Manager) SetSchema(schema *jsonschema.Schema) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.schema, m.removed = schema, false
+}
+
+// Remove makes the manager reject every request, for resources whose schema
+// was removed. Routes can't be removed from a router once added.
+func (m *Manager) Remove() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removed = true
+}
+
+// state returns the current schema, or ErrRemoved.
+func (m *Manager) state() (*jsonschema.Schema, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.removed {
+		return nil, ErrRemoved
+	}
+	return m.schema, nil
+}
+
+// New returns a new empty Document.
+func (m *Manager) New() goresource.Entity {
+	return &Document{}
+}
+
+// ParseJSON decodes a Document, validating it against the schema.
+func (m *Manager) ParseJSON(data io.ReadCloser) (goresource.Entity, error) {
+	schema, err := m.state()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := io.ReadAll(data)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	if err := schema.Validate(v); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", m.Name, err)
+	}
+	doc := &Document{}
+	if err := json.Unmarshal(raw, doc); err != nil {
+		return nil, fmt.Errorf("invalid %s: must be an object", m.Name)
+	}
+	return doc, nil
+}
+
+// GetEntity fetches the document with the given id.
+func (m *Manager) GetEntity(ctx context.Context, id string, query url.Values) (interface{}, error) {
+	if _, err := m.state(); err != nil {
+		return nil, err
+	}
+	return m.DefaultManager.GetEntity(ctx, id, query)
+}
+
+// CreateEntity persists a new document.
+func (m *Manager) CreateEntity(ctx context.Context, e goresource.Entity, query url.Values) (interface{}, error) {
+	if _, err := m.state(); err != nil {
+		return nil, err
+	}
+	return m.DefaultManager.CreateEntity(ctx, e, query)
+}
+
+// ListEntities lists the documents matching the given filters.
+func (m *Manager) ListEntities(ctx context.Context, query url.Values) (interface{}, error) {
+	if _, err := m.state(); err != nil {
+		return nil, err
+	}
+	return m.DefaultManager.ListEntities(ctx, query)
+}
+
+// UpdateEntity updates the document with the given id.
+func (m *Manager) UpdateEntity(ctx context.Context, id string, e goresource.Entity, query url.Values) (interface{}, error) {
+	if _, err := m.state(); err != nil {
+		return nil, err
+	}
+	return m.DefaultManager.UpdateEntity(ctx, id, e, query)
+}
+
+// DeleteEntity removes the document with the given id.
+func (m *Manager) DeleteEntity(ctx context.Context, id string, query url.Values) error {
+	if _, err := m.state(); err != nil {
+		return err
+	}
+	return m.DefaultManager.DeleteEntity(ctx, id, query)
+}
+
+// StreamEntities iterates over the documents matching the given filters.
+func (m *Manager) StreamEntities(ctx context.Context, query url.Values) (store.Iterator, error) {
+	if _, err := m.state(); err != nil {
+		return nil, err
+	}
+	return m.DefaultManager.StreamEntities(ctx, query)
+}
+
+// CreateEntities persists the given documents in batch.
+func (m *Manager) CreateEntities(ctx context.Context, entities []goresource.Entity, query url.Values) (map[int]error, error) {
+	if _, err := m.state(); err != nil {
+		return nil, err
+	}
+	return m.DefaultManager.CreateEntities(ctx, entities, query)
+}
+
+// CountEntities counts the documents matching the given filters.
+func (m *Manager) CountEntities(ctx context.Context, query url.Values) (int, error) {
+	if _, err := m.state(); err != nil {
+		return 0, err
+	}
+	return m.DefaultManager.CountEntities(ctx, query)
+}
+
+// AggregateEntities groups the documents matching the given filters.
+func (m *Manager) AggregateEntities(ctx context.Context, query url.Values) (interface{}, error) {
+	if _, err := m.state(); err != nil {
+		return nil, err
+	}
+	return m.DefaultManager.AggregateEntities(ctx, query)
+}
+
+// EnsureIndexes creates the indexes of the documents' collection.
+func (m *Manager) EnsureIndexes(ctx context.Context) error {
+	if _, err := m.state(); err != nil {
+		return err
+	}
+	return m.DefaultManager.EnsureIndexes(ctx)
+}
+
+// WithTransaction runs fn in a store transaction.
+func (m *Manager) WithTransaction(ctx context.Context, fn func(ctx context.Context, tx goresource.DefaultManager) error) error {
+	if _, err := m.state(); err != nil {
+		return err
+	}
+	return m.DefaultManager.WithTransaction(ctx, fn)
+}
@@ -0,0 +1,111 @@
+package dynamic_test
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"goresource/dynamic"
+	"goresource/mocks"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const bookSchema = `{
+	"type": "object",
+	"properties": {
+		"id": {"type": "string"},
+		"name": {"type": "string", "minLength": 1},
+		"pages": {"type": "integer", "minimum": 1}
+	},
+	"required": ["name"],
+	"additionalProperties": false
+}`
+
+func body(s string) io.ReadCloser {
+	return io.NopCloser(strings.NewReader(s))
+}
+
+var _ = Describe("Document", func() {
+	It("has the id or _id field as its id.", func() {
+		Expect(dynamic.Document{}.HasId()).To(BeFalse())
+		Expect(dynamic.Document{"id": 1}.HasId()).To(BeFalse())
+		Expect(dynamic.Document{"id": "a"}.GetId()).To(Equal("a"))
+		Expect(dynamic.Document{"_id": "b"}.GetId()).To(Equal("b"))
+	})
+})
+
+var _ = Describe("Manager", func() {
+	var (
+		ctrl    *gomock.Controller
+		store   *mocks.MockStore
+		manager *dynamic.Manager
+		ctx     = context.Background()
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStore(ctrl)
+		schema, err := dynamic.Compile("books", []byte(bookSchema))
+		Expect(err).To(BeNil())
+		manager = dynamic.NewManager("books", store, schema)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("creates new documents.", func() {
+		Expect(manager.New()).To(Equal(&dynamic.Document{}))
+	})
+
+	Describe("ParseJSON", func() {
+		It("parses valid documents.", func() {
+			doc, err := manager.ParseJSON(body(`{"name": "a", "pages": 3}`))
+			Expect(err).To(BeNil())
+			Expect(doc).To(Equal(&dynamic.Document{"name": "a", "pages": float64(3)}))
+		})
+
+		It("rejects invalid documents.", func() {
+			_, err := manager.ParseJSON(body(`{"pages": 1.5}`))
+			Expect(err).To(MatchError(ContainSubstring("invalid books")))
+			_, err = manager.ParseJSON(body(`{"name": "a", "extra": true}`))
+			Expect(err).To(MatchError(ContainSubstring("additionalProperties")))
+			_, err = manager.ParseJSON(body(`{bad`))
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("validates against a replaced schema.", func() {
+			schema, err := dynamic.Compile("books", []byte(`{"type": "object"}`))
+			Expect(err).To(BeNil())
+			manager.SetSchema(schema)
+			_, err = manager.ParseJSON(body(`{"extra": true}`))
+			Expect(err).To(BeNil())
+		})
+	})
+
+	It("rejects requests once removed.", func() {
+		manager.Remove()
+		_, err := manager.ParseJSON(body(`{"name": "a"}`))
+		Expect(err).To(Equal(dynamic.ErrRemoved))
+		_, err = manager.ListEntities(ctx, nil)
+		Expect(err).To(Equal(dynamic.ErrRemoved))
+		_, err = manager.GetEntity(ctx, "1", nil)
+		Expect(err).To(Equal(dynamic.ErrRemoved))
+		Expect(manager.DeleteEntity(ctx, "1", nil)).To(Equal(dynamic.ErrRemoved))
+	})
+
+	It("delegates to the store.", func() {
+		store.EXPECT().CreateEntity(gomock.Any(), "books", &dynamic.Document{"name": "a"}, gomock.Any()).Return(nil)
+		_, err := manager.CreateEntity(ctx, &dynamic.Document{"name": "a"}, nil)
+		Expect(err).To(BeNil())
+	})
+
+	It("rejects invalid schemas.", func() {
+		_, err := dynamic.Compile("books", []byte(`{"type": 5}`))
+		Expect(err).NotTo(BeNil())
+	})
+})
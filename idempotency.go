@@ -0,0 +1,344 @@
+package goresource
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rockstardevs/goresource/store"
+)
+
+// Headers of idempotent requests.
+const (
+	// IdempotencyKeyHeader holds the key a client chose for a POST or PATCH
+	// request, reused when it retries the request.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed for a repeated key.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// DefaultIdempotencyTTL is how long keys are remembered for by default.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKey is the longest key accepted.
+const maxIdempotencyKey = 255
+
+// IdempotencyRecord records a request made with an idempotency key, and the
+// response to it once handled.
+type IdempotencyRecord struct {
+	ID string `json:"id,omitempty" bson:"_id,omitempty"`
+	// Key scopes the client's key to the resource and principal.
+	Key string `json:"key" bson:"key"`
+	// Fingerprint is a hash of the request's method, uri and body.
+	Fingerprint string    `json:"fingerprint" bson:"fingerprint"`
+	Time        time.Time `json:"time" bson:"time"`
+	// Completed is set once the response is recorded.
+	Completed bool        `json:"completed" bson:"completed"`
+	Status    int         `json:"status,omitempty" bson:"status,omitempty"`
+	Header    http.Header `json:"header,omitempty" bson:"header,omitempty"`
+	Body      []byte      `json:"body,omitempty" bson:"body,omitempty"`
+}
+
+// IdempotencyStore is implemented by stores of idempotency keys.
+type IdempotencyStore interface {
+	// Reserve records the key of a request about to be handled, returning the
+	// record and true. If the key is recorded already, it returns the existing
+	// record and false instead.
+	Reserve(ctx context.Context, record IdempotencyRecord) (IdempotencyRecord, bool, error)
+	// Complete records the response to a request with a reserved key.
+	Complete(ctx context.Context, record IdempotencyRecord) error
+	// Release forgets a reserved key, so the request may be retried.
+	Release(ctx context.Context, record IdempotencyRecord) error
+}
+
+// WithIdempotency remembers the responses to POST and PATCH requests with an
+// Idempotency-Key header in the given store, replaying them when a request is
+// repeated with the same key instead of handling it again. Keys reused for
+// a different request are rejected with 422 Unprocessable Entity, and
+// repeats of a request still being handled with 409 Conflict. Responses with
+// server errors are not remembered, so the request may be retried.
+func WithIdempotency(s IdempotencyStore) Option {
+	return func(r *Resource) {
+		r.idempotency = s
+	}
+}
+
+// idempotent calls the given handler for the request, unless it repeats an
+// earlier request with the same idempotency key.
+func (r Resource) idempotent(rw *statusWriter, req *http.Request, handler http.HandlerFunc) {
+	key := req.Header.Get(IdempotencyKeyHeader)
+	if r.idempotency == nil || key == "" || req.Method != "POST" && req.Method != "PATCH" {
+		handler(rw, req)
+		return
+	}
+	if len(key) > maxIdempotencyKey {
+		writeError(rw, "Idempotency-Key is too long", http.StatusBadRequest)
+		return
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	fp := fingerprint(req, body)
+	record := IdempotencyRecord{
+		Key:         r.Name() + ":" + r.principal(req) + ":" + key,
+		Fingerprint: fp,
+		Time:        time.Now().UTC(),
+	}
+	ctx := req.Context()
+	record, reserved, err := r.idempotency.Reserve(ctx, record)
+	switch {
+	case err != nil:
+		writeError(rw, err.Error(), http.StatusInternalServerError)
+		return
+	case !reserved && record.Fingerprint != fp:
+		writeError(rw, "Idempotency-Key was used for a different request", http.StatusUnprocessableEntity)
+		return
+	case !reserved && !record.Completed:
+		writeError(rw, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+		return
+	case !reserved:
+		for k, v := range record.Header {
+			rw.Header()[k] = v
+		}
+		rw.Header().Set(IdempotentReplayedHeader, "true")
+		rw.WriteHeader(record.Status)
+		rw.Write(record.Body)
+		return
+	}
+	rec := &recordingWriter{statusWriter: rw}
+	completed := false
+	defer func() {
+		if !completed {
+			if err := r.idempotency.Release(context.WithoutCancel(ctx), record); err != nil {
+				r.logger.ErrorContext(ctx, "error releasing idempotency key", "resource", r.Name(), "error", err.Error())
+			}
+		}
+	}()
+	handler(rec, req)
+	if retryable(rw.status) {
+		return
+	}
+	record.Completed = true
+	record.Status = rw.status
+	record.Header = rec.header
+	record.Body = rec.body.Bytes()
+	if err := r.idempotency.Complete(context.WithoutCancel(ctx), record); err != nil {
+		r.logger.ErrorContext(ctx, "error recording idempotent response", "resource", r.Name(), "error", err.Error())
+		return
+	}
+	completed = true
+}
+
+// fingerprint returns a hash identifying the given request and its body.
+func fingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, req.Method+" "+req.URL.RequestURI()+"\n"+req.Header.Get("Content-Type")+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// retryable reports whether a response with the given status should not be
+// replayed, since repeating the request may succeed.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	}
+	return status >= http.StatusInternalServerError
+}
+
+// recordingWriter records the headers and body of a response as it is written.
+type recordingWriter struct {
+	*statusWriter
+	header http.Header
+	body   bytes.Buffer
+}
+
+// WriteHeader records the response headers and passes them through.
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.header == nil {
+		w.header = w.Header().Clone()
+	}
+	w.statusWriter.WriteHeader(status)
+}
+
+// Write records the body and passes it through.
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	if w.header == nil {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(data)
+	return w.statusWriter.Write(data)
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore keeping keys in memory, for
+// servers running a single process.
+type MemoryIdempotencyStore struct {
+	// TTL is how long keys are remembered for, forever if zero.
+	TTL     time.Duration
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+	swept   time.Time
+}
+
+// NewMemoryIdempotencyStore returns an IdempotencyStore remembering keys for the given duration.
+func NewMemoryIdempotencyStore(ttl time.Duration) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{TTL: ttl, records: make(map[string]IdempotencyRecord)}
+}
+
+// Reserve records the given key unless it is recorded already.
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, record IdempotencyRecord) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+	if existing, ok := s.records[record.Key]; ok && !s.expired(existing, now) {
+		return existing, false, nil
+	}
+	s.records[record.Key] = record
+	return record, true, nil
+}
+
+// expired reports whether the given record is older than the TTL.
+func (s *MemoryIdempotencyStore) expired(record IdempotencyRecord, now time.Time) bool {
+	return s.TTL > 0 && now.Sub(record.Time) > s.TTL
+}
+
+// sweep removes expired records, at most once a minute.
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < time.Minute {
+		return
+	}
+	s.swept = now
+	for key, record := range s.records {
+		if s.expired(record, now) {
+			delete(s.records, key)
+		}
+	}
+}
+
+// Complete records the response for the given key.
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.Key] = record
+	return nil
+}
+
+// Release forgets the given key.
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, record.Key)
+	return nil
+}
+
+// StoreIdempotencyStore is an IdempotencyStore keeping keys as entities in a
+// store collection, shared by every process using the store. Keys are
+// reserved atomically on stores enforcing unique indexes, see EnsureIndexes.
+type StoreIdempotencyStore struct {
+	Name  string
+	Store store.Store
+	// TTL is how long keys are remembered for, forever if zero.
+	TTL time.Duration
+}
+
+// NewStoreIdempotencyStore returns an IdempotencyStore keeping keys in the
+// named collection of the given store for the given duration.
+func NewStoreIdempotencyStore(name string, s store.Store, ttl time.Duration) *StoreIdempotencyStore {
+	return &StoreIdempotencyStore{Name: name, Store: s, TTL: ttl}
+}
+
+// EnsureIndexes creates a unique index on keys, and removes them after the
+// TTL on stores supporting it.
+func (s *StoreIdempotencyStore) EnsureIndexes(ctx context.Context) error {
+	indexes := []store.Index{{Key: []string{"key"}, Unique: true}}
+	if s.TTL > 0 {
+		indexes = append(indexes, store.Index{Key: []string{"time"}, ExpireAfter: s.TTL})
+	}
+	return store.EnsureIndexes(ctx, s.Store, s.Name, indexes)
+}
+
+// Reserve records the given key unless it is recorded already.
+func (s *StoreIdempotencyStore) Reserve(ctx context.Context, record IdempotencyRecord) (IdempotencyRecord, bool, error) {
+	existing, found, err := s.find(ctx, record.Key)
+	if err != nil {
+		return record, false, err
+	}
+	if found && s.TTL > 0 && time.Since(existing.Time) > s.TTL {
+		if err := s.Release(ctx, existing); err != nil {
+			return record, false, err
+		}
+		found = false
+	}
+	if found {
+		return existing, false, nil
+	}
+	result := make(map[string]interface{})
+	err = s.Store.CreateEntity(ctx, s.Name, record, &result)
+	if errors.Is(err, store.ErrDuplicate) {
+		existing, found, err = s.find(ctx, record.Key)
+		if err == nil && !found {
+			err = store.ErrNotFound
+		}
+		return existing, false, err
+	}
+	if err != nil {
+		return record, false, err
+	}
+	record.ID = entityID(result)
+	return record, true, nil
+}
+
+// Complete records the response for the given key.
+func (s *StoreIdempotencyStore) Complete(ctx context.Context, record IdempotencyRecord) error {
+	id := record.ID
+	record.ID = ""
+	result := make(map[string]interface{})
+	return s.Store.UpdateEntity(ctx, s.Name, id, record, &result)
+}
+
+// Release forgets the given key.
+func (s *StoreIdempotencyStore) Release(ctx context.Context, record IdempotencyRecord) error {
+	err := s.Store.DeleteEntity(ctx, s.Name, record.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// find fetches the record of the given key, through a map so stored ids of
+// any type decode as strings.
+func (s *StoreIdempotencyStore) find(ctx context.Context, key string) (IdempotencyRecord, bool, error) {
+	var (
+		docs   []map[string]interface{}
+		record IdempotencyRecord
+	)
+	if err := s.Store.ListEntities(ctx, s.Name, url.Values{"key": {key}}, &docs); err != nil {
+		return record, false, err
+	}
+	if len(docs) == 0 {
+		return record, false, nil
+	}
+	fields := toFields(docs[0])
+	if _, ok := fields["id"]; !ok {
+		fields["id"] = fields["_id"]
+	}
+	delete(fields, "_id")
+	data, err := json.Marshal(fields)
+	if err == nil {
+		err = json.Unmarshal(data, &record)
+	}
+	return record, err == nil, err
+}
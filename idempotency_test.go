@@ -0,0 +1,233 @@
+package goresource_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"goresource"
+	"goresource/mocks"
+	storepkg "goresource/store"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resource idempotency keys", func() {
+	var (
+		ctrl    *gomock.Controller
+		manager *mocks.MockResourceManager
+		router  *mux.Router
+		keys    *goresource.MemoryIdempotencyStore
+	)
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/test", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(goresource.IdempotencyKeyHeader, key)
+		}
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		manager = mocks.NewMockResourceManager(ctrl)
+		router = mux.NewRouter().PathPrefix("/api").Subrouter()
+		keys = goresource.NewMemoryIdempotencyStore(time.Hour)
+		manager.EXPECT().GetName().AnyTimes().Return("test")
+		goresource.NewResource(manager, router, goresource.WithIdempotency(keys))
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("replays the response to a repeated request without handling it again.", func() {
+		e := &mocks.MockEntity{}
+		manager.EXPECT().ParseJSON(gomock.Any()).DoAndReturn(func(body io.ReadCloser) (goresource.Entity, error) {
+			data, _ := ioutil.ReadAll(body)
+			Expect(string(data)).To(Equal(`{"name":"foo"}`))
+			return e, nil
+		})
+		manager.EXPECT().CreateEntity(gomock.Any(), e, gomock.Any()).Times(1).Return(map[string]interface{}{"id": "fakeid"}, nil)
+		first := post("key-1", `{"name":"foo"}`)
+		Expect(first.Code).To(Equal(http.StatusOK))
+		Expect(first.Header().Get(goresource.IdempotentReplayedHeader)).To(BeEmpty())
+
+		second := post("key-1", `{"name":"foo"}`)
+		Expect(second.Code).To(Equal(http.StatusOK))
+		Expect(second.Body.String()).To(Equal(first.Body.String()))
+		Expect(second.Header().Get("Content-Location")).To(Equal("/api/test/fakeid"))
+		Expect(second.Header().Get(goresource.IdempotentReplayedHeader)).To(Equal("true"))
+	})
+
+	It("rejects a key reused with a different body.", func() {
+		e := &mocks.MockEntity{}
+		manager.EXPECT().ParseJSON(gomock.Any()).Return(e, nil)
+		manager.EXPECT().CreateEntity(gomock.Any(), e, gomock.Any()).Times(1).Return(map[string]interface{}{"id": "fakeid"}, nil)
+		Expect(post("key-1", `{"name":"foo"}`).Code).To(Equal(http.StatusOK))
+		Expect(post("key-1", `{"name":"bar"}`).Code).To(Equal(http.StatusUnprocessableEntity))
+	})
+
+	It("rejects repeats of a request still being handled.", func() {
+		var (
+			e       = &mocks.MockEntity{}
+			parsing = make(chan struct{})
+			release = make(chan struct{})
+			done    = make(chan int)
+		)
+		manager.EXPECT().ParseJSON(gomock.Any()).DoAndReturn(func(io.ReadCloser) (goresource.Entity, error) {
+			close(parsing)
+			<-release
+			return e, nil
+		})
+		manager.EXPECT().CreateEntity(gomock.Any(), e, gomock.Any()).Times(1).Return(map[string]interface{}{"id": "fakeid"}, nil)
+		go func() {
+			defer GinkgoRecover()
+			done <- post("key-1", "{}").Code
+		}()
+		<-parsing
+		Expect(post("key-1", "{}").Code).To(Equal(http.StatusConflict))
+		close(release)
+		Expect(<-done).To(Equal(http.StatusOK))
+	})
+
+	It("scopes keys to the principal.", func() {
+		e := &mocks.MockEntity{}
+		manager.EXPECT().ParseJSON(gomock.Any()).Times(2).Return(e, nil)
+		manager.EXPECT().CreateEntity(gomock.Any(), e, gomock.Any()).Times(2).Return(map[string]interface{}{"id": "fakeid"}, nil)
+		for _, user := range []string{"alice", "bob"} {
+			rw := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/api/test", strings.NewReader("{}"))
+			req.Header.Set(goresource.IdempotencyKeyHeader, "key-1")
+			req.SetBasicAuth(user, "secret")
+			router.ServeHTTP(rw, req)
+			Expect(rw.Header().Get(goresource.IdempotentReplayedHeader)).To(BeEmpty())
+		}
+	})
+
+	It("forgets keys of requests failing with server errors.", func() {
+		e := &mocks.MockEntity{}
+		manager.EXPECT().ParseJSON(gomock.Any()).Times(2).Return(e, nil)
+		gomock.InOrder(
+			manager.EXPECT().CreateEntity(gomock.Any(), e, gomock.Any()).Return(nil, fmt.Errorf("test error")),
+			manager.EXPECT().CreateEntity(gomock.Any(), e, gomock.Any()).Return(map[string]interface{}{"id": "fakeid"}, nil),
+		)
+		Expect(post("key-1", "{}").Code).To(Equal(http.StatusInternalServerError))
+		Expect(post("key-1", "{}").Code).To(Equal(http.StatusOK))
+	})
+
+	It("replays client errors.", func() {
+		manager.EXPECT().ParseJSON(gomock.Any()).Times(1).Return(nil, fmt.Errorf("bad json"))
+		Expect(post("key-1", "{").Code).To(Equal(http.StatusBadRequest))
+		rw := post("key-1", "{")
+		Expect(rw.Code).To(Equal(http.StatusBadRequest))
+		Expect(rw.Body.String()).To(Equal("bad json\n"))
+	})
+
+	It("handles requests without a key as usual.", func() {
+		e := &mocks.MockEntity{}
+		manager.EXPECT().ParseJSON(gomock.Any()).Times(2).Return(e, nil)
+		manager.EXPECT().CreateEntity(gomock.Any(), e, gomock.Any()).Times(2).Return(map[string]interface{}{"id": "fakeid"}, nil)
+		Expect(post("", "{}").Code).To(Equal(http.StatusOK))
+		Expect(post("", "{}").Code).To(Equal(http.StatusOK))
+	})
+
+	It("handles patch requests with keys too.", func() {
+		e := &mocks.MockEntity{}
+		manager.EXPECT().ParseJSON(gomock.Any()).Return(e, nil)
+		manager.EXPECT().CreateEntity(gomock.Any(), e, gomock.Any()).Return(map[string]interface{}{"id": "fakeid"}, nil)
+		Expect(post("key-1", "{}").Code).To(Equal(http.StatusOK))
+
+		patch := func(key string) *httptest.ResponseRecorder {
+			rw := httptest.NewRecorder()
+			req, _ := http.NewRequest("PATCH", "/api/test", strings.NewReader("{}"))
+			req.Header.Set(goresource.IdempotencyKeyHeader, key)
+			router.ServeHTTP(rw, req)
+			return rw
+		}
+		Expect(patch("key-1").Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(patch("key-2").Code).To(Equal(http.StatusNotImplemented))
+		Expect(patch("key-2").Code).To(Equal(http.StatusNotImplemented))
+	})
+
+	It("ignores keys of requests with other methods.", func() {
+		manager.EXPECT().DeleteEntity(gomock.Any(), "fakeid", gomock.Any()).Times(2).Return(nil)
+		for i := 0; i < 2; i++ {
+			rw := httptest.NewRecorder()
+			req, _ := http.NewRequest("DELETE", "/api/test/fakeid", nil)
+			req.Header.Set(goresource.IdempotencyKeyHeader, "key-1")
+			router.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusNoContent))
+			Expect(rw.Header().Get(goresource.IdempotentReplayedHeader)).To(BeEmpty())
+		}
+	})
+
+	It("rejects overly long keys.", func() {
+		Expect(post(strings.Repeat("k", 256), "{}").Code).To(Equal(http.StatusBadRequest))
+	})
+
+	Describe("MemoryIdempotencyStore", func() {
+		It("forgets keys after the TTL.", func() {
+			keys := goresource.NewMemoryIdempotencyStore(time.Minute)
+			record := goresource.IdempotencyRecord{Key: "k", Time: time.Now().Add(-2 * time.Minute)}
+			_, reserved, _ := keys.Reserve(context.Background(), record)
+			Expect(reserved).To(BeTrue())
+			_, reserved, _ = keys.Reserve(context.Background(), goresource.IdempotencyRecord{Key: "k", Time: time.Now()})
+			Expect(reserved).To(BeTrue())
+			_, reserved, _ = keys.Reserve(context.Background(), goresource.IdempotencyRecord{Key: "k", Time: time.Now()})
+			Expect(reserved).To(BeFalse())
+		})
+	})
+
+	Describe("StoreIdempotencyStore", func() {
+		var store *mocks.MockStore
+
+		BeforeEach(func() {
+			store = mocks.NewMockStore(ctrl)
+		})
+
+		It("reserves new keys.", func() {
+			record := goresource.IdempotencyRecord{Key: "k", Fingerprint: "f", Time: time.Now()}
+			store.EXPECT().ListEntities(gomock.Any(), "keys", gomock.Any(), gomock.Any()).Return(nil)
+			store.EXPECT().CreateEntity(gomock.Any(), "keys", record, gomock.Any()).
+				SetArg(3, map[string]interface{}{"id": "rid", "key": "k"}).Return(nil)
+			got, reserved, err := goresource.NewStoreIdempotencyStore("keys", store, time.Hour).Reserve(context.Background(), record)
+			Expect(err).To(BeNil())
+			Expect(reserved).To(BeTrue())
+			Expect(got.ID).To(Equal("rid"))
+		})
+
+		It("returns the record of a key reserved concurrently.", func() {
+			record := goresource.IdempotencyRecord{Key: "k", Fingerprint: "f", Time: time.Now()}
+			existing := []map[string]interface{}{{"_id": "rid", "key": "k", "fingerprint": "g", "completed": true, "status": 201}}
+			gomock.InOrder(
+				store.EXPECT().ListEntities(gomock.Any(), "keys", gomock.Any(), gomock.Any()).Return(nil),
+				store.EXPECT().CreateEntity(gomock.Any(), "keys", record, gomock.Any()).Return(storepkg.ErrDuplicate),
+				store.EXPECT().ListEntities(gomock.Any(), "keys", gomock.Any(), gomock.Any()).SetArg(3, existing).Return(nil),
+			)
+			got, reserved, err := goresource.NewStoreIdempotencyStore("keys", store, time.Hour).Reserve(context.Background(), record)
+			Expect(err).To(BeNil())
+			Expect(reserved).To(BeFalse())
+			Expect(got).To(Equal(goresource.IdempotencyRecord{ID: "rid", Key: "k", Fingerprint: "g", Completed: true, Status: 201}))
+		})
+
+		It("updates reserved keys with their response.", func() {
+			record := goresource.IdempotencyRecord{ID: "rid", Key: "k", Completed: true, Status: 200, Body: []byte("{}")}
+			stored := record
+			stored.ID = ""
+			store.EXPECT().UpdateEntity(gomock.Any(), "keys", "rid", stored, gomock.Any()).Return(nil)
+			Expect(goresource.NewStoreIdempotencyStore("keys", store, time.Hour).Complete(context.Background(), record)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,339 @@
+package goresource
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rockstardevs/goresource/codec"
+)
+
+// DefaultImportBatchSize is the number of entities inserted per batch by an Importer.
+const DefaultImportBatchSize = 500
+
+// ImportReport summarizes the outcome of an import, with the rows skipped and
+// failed in row order.
+type ImportReport struct {
+	Inserted int         `json:"inserted"`
+	Skipped  []ImportRow `json:"skipped"`
+	Failed   []ImportRow `json:"failed"`
+}
+
+// sortFailed orders the failed rows by row, since rows failing to insert are
+// only reported once their batch is inserted.
+func (report *ImportReport) sortFailed() {
+	sort.SliceStable(report.Failed, func(i, j int) bool { return report.Failed[i].Row < report.Failed[j].Row })
+}
+
+// ImportRow describes a row that was not inserted. Rows are numbered from 1,
+// for csv not counting the header.
+type ImportRow struct {
+	Row    int    `json:"row"`
+	Reason string `json:"reason"`
+}
+
+// Importer loads entities in bulk from NDJSON or CSV. Each record is parsed
+// with the manager's ParseJSON, so it is validated like any other request.
+type Importer struct {
+	Manager ResourceManager
+	// BatchSize is the number of entities inserted at once, defaults to DefaultImportBatchSize.
+	BatchSize int
+	// Mapping maps csv headers to entity fields, unmapped headers are used as is.
+	Mapping map[string]string
+	// Inserted, if set, is called with the stored result of every entity
+	// inserted. Entities are then inserted one at a time, since batch inserts
+	// do not return the stored entities, with ids assigned by the store.
+	Inserted func(result interface{})
+	// Fields are set on every record, overriding the imported values.
+	Fields map[string]interface{}
+}
+
+// pending is a parsed entity waiting to be inserted.
+type pending struct {
+	row    int
+	entity Entity
+}
+
+// ImportNDJSON imports an entity from each line of the given reader.
+func (imp Importer) ImportNDJSON(ctx context.Context, r io.Reader, query url.Values) (*ImportReport, error) {
+	report := &ImportReport{Skipped: []ImportRow{}, Failed: []ImportRow{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var batch []pending
+	for row := 1; scanner.Scan(); row++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			report.Skipped = append(report.Skipped, ImportRow{row, "empty line"})
+			continue
+		}
+		batch = imp.add(ctx, report, batch, row, append([]byte{}, line...), query)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	imp.insert(ctx, report, batch, query)
+	report.sortFailed()
+	return report, nil
+}
+
+// ImportCSV imports an entity from each row of the given reader, after a
+// header row naming the fields.
+func (imp Importer) ImportCSV(ctx context.Context, r io.Reader, query url.Values) (*ImportReport, error) {
+	report := &ImportReport{Skipped: []ImportRow{}, Failed: []ImportRow{}}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading csv header: %s", err)
+	}
+	fields := make([]string, len(header))
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		if f, ok := imp.Mapping[h]; ok {
+			h = f
+		}
+		fields[i] = h
+	}
+	kinds := fieldKinds(imp.Manager.New())
+	var batch []pending
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); !ok {
+				return nil, err
+			}
+			report.Failed = append(report.Failed, ImportRow{row, err.Error()})
+			continue
+		}
+		if len(record) != len(fields) {
+			report.Failed = append(report.Failed, ImportRow{row,
+				fmt.Sprintf("expected %d columns, got %d", len(fields), len(record))})
+			continue
+		}
+		doc, empty := make(map[string]interface{}), true
+		for i, value := range record {
+			if fields[i] == "" || value == "" {
+				continue
+			}
+			empty = false
+			if doc[fields[i]], err = convert(value, kinds[fields[i]]); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			report.Failed = append(report.Failed, ImportRow{row, err.Error()})
+			continue
+		}
+		if empty {
+			report.Skipped = append(report.Skipped, ImportRow{row, "empty row"})
+			continue
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			report.Failed = append(report.Failed, ImportRow{row, err.Error()})
+			continue
+		}
+		batch = imp.add(ctx, report, batch, row, data, query)
+	}
+	imp.insert(ctx, report, batch, query)
+	report.sortFailed()
+	return report, nil
+}
+
+// add parses the given json record and queues it for insertion, inserting
+// the batch once it is full.
+func (imp Importer) add(ctx context.Context, report *ImportReport, batch []pending, row int, data []byte, query url.Values) []pending {
+	var err error
+	if len(imp.Fields) > 0 {
+		data, err = setFields(data, imp.Fields)
+	}
+	var entity Entity
+	if err == nil {
+		entity, err = imp.Manager.ParseJSON(ioutil.NopCloser(bytes.NewReader(data)))
+	}
+	if err != nil {
+		report.Failed = append(report.Failed, ImportRow{row, err.Error()})
+		return batch
+	}
+	batch = append(batch, pending{row, entity})
+	size := imp.BatchSize
+	if size <= 0 {
+		size = DefaultImportBatchSize
+	}
+	if len(batch) >= size {
+		imp.insert(ctx, report, batch, query)
+		return batch[:0]
+	}
+	return batch
+}
+
+// insert persists the given batch, with the manager's CreateEntities if it is
+// a BatchManager and no Inserted callback is set, otherwise one entity at a time.
+func (imp Importer) insert(ctx context.Context, report *ImportReport, batch []pending, query url.Values) {
+	if len(batch) == 0 {
+		return
+	}
+	var (
+		failed  = make(map[int]error)
+		results = make([]interface{}, len(batch))
+	)
+	if bm, ok := imp.Manager.(BatchManager); ok && imp.Inserted == nil {
+		entities := make([]Entity, len(batch))
+		for i, p := range batch {
+			entities[i] = p.entity
+		}
+		var err error
+		if failed, err = bm.CreateEntities(ctx, entities, query); err != nil {
+			failed = make(map[int]error, len(batch))
+			for i := range batch {
+				failed[i] = err
+			}
+		}
+	} else {
+		for i, p := range batch {
+			result, err := imp.Manager.CreateEntity(ctx, p.entity, query)
+			if err != nil {
+				failed[i] = err
+			}
+			results[i] = result
+		}
+	}
+	for i, p := range batch {
+		if err, ok := failed[i]; ok {
+			report.Failed = append(report.Failed, ImportRow{p.row, err.Error()})
+			continue
+		}
+		report.Inserted++
+		if imp.Inserted != nil {
+			imp.Inserted(results[i])
+		}
+	}
+}
+
+// fieldKinds returns the kind of each json field of the given entity's type,
+// or of the type pointer fields point to, used to convert csv values.
+func fieldKinds(e Entity) map[string]reflect.Kind {
+	kinds := make(map[string]reflect.Kind)
+	t := reflect.TypeOf(e)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return kinds
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" || f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		t := f.Type
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		kinds[name] = t.Kind()
+	}
+	return kinds
+}
+
+// convert parses a csv value into the given kind. Values of unknown fields
+// are kept as strings.
+func convert(value string, kind reflect.Kind) (interface{}, error) {
+	switch kind {
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseInt(value, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(value, 64)
+	case reflect.Slice, reflect.Map, reflect.Struct:
+		var v interface{}
+		err := json.Unmarshal([]byte(value), &v)
+		return v, err
+	}
+	return value, nil
+}
+
+// Import is the http handler for bulk imports into this resource. It accepts
+// NDJSON or CSV request bodies, csv headers are mapped to fields with
+// map.<header>=<field> query parameters and the batch size can be set with
+// batch=<size>. It responds with an ImportReport.
+func (r Resource) Import(rw http.ResponseWriter, req *http.Request) {
+	c, ok := r.negotiate(rw, req)
+	if !ok {
+		return
+	}
+	query := req.URL.Query()
+	imp := Importer{Manager: r.manager, Mapping: make(map[string]string)}
+	for k, v := range query {
+		if strings.HasPrefix(k, "map.") {
+			imp.Mapping[strings.TrimPrefix(k, "map.")] = v[0]
+			query.Del(k)
+		}
+	}
+	if batch := query.Get("batch"); batch != "" {
+		size, err := strconv.Atoi(batch)
+		if err != nil || size <= 0 {
+			writeError(rw, "Invalid batch size", http.StatusBadRequest)
+			return
+		}
+		imp.BatchSize = size
+		query.Del("batch")
+	}
+	if id, ok := r.parentID(req); ok {
+		imp.Fields = map[string]interface{}{r.parentField: id}
+	}
+	if r.auditSink != nil || r.changes != nil {
+		imp.Inserted = func(result interface{}) {
+			r.audit(req, AuditCreate, entityID(result), nil, result)
+			r.publish(AuditCreate, entityID(result), result)
+		}
+	}
+	ctx, span := r.startSpan(req.Context(), "Import")
+	var (
+		report *ImportReport
+		err    error
+	)
+	switch contentType(req) {
+	case codec.NDJSONType:
+		report, err = imp.ImportNDJSON(ctx, req.Body, query)
+	case codec.CSVType:
+		report, err = imp.ImportCSV(ctx, req.Body, query)
+	default:
+		endSpan(span, nil)
+		writeError(rw, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+		return
+	}
+	if report != nil {
+		span.SetAttributes(AttrResultCount.Int(report.Inserted))
+	}
+	endSpan(span, err)
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	codec.Write(c, report, rw)
+}
+
+// contentType returns the media type of the request body, without parameters.
+func contentType(req *http.Request) string {
+	return strings.TrimSpace(strings.Split(req.Header.Get("Content-Type"), ";")[0])
+}
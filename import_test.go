@@ -0,0 +1,216 @@
+package goresource_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"goresource"
+	"goresource/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type testBook struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Pages  int      `json:"pages"`
+	Tags   []string `json:"tags"`
+	Rating *float64 `json:"rating,omitempty"`
+}
+
+func (b *testBook) HasId() bool   { return b.ID != "" }
+func (b *testBook) GetId() string { return b.ID }
+
+// batchStore is a store whose batch inserts fail as a whole with err.
+type batchStore struct {
+	*mocks.MockStore
+	err error
+}
+
+func (s *batchStore) CreateEntities(context.Context, string, []interface{}) (map[int]error, error) {
+	return nil, s.err
+}
+
+// bookManager is a DefaultManager for testBooks, requiring a name.
+type bookManager struct {
+	goresource.DefaultManager
+}
+
+func (m bookManager) New() goresource.Entity {
+	return &testBook{}
+}
+
+func (m bookManager) ParseJSON(data io.ReadCloser) (goresource.Entity, error) {
+	book := &testBook{}
+	if err := json.NewDecoder(data).Decode(book); err != nil {
+		return nil, err
+	}
+	if book.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	return book, nil
+}
+
+var _ = Describe("Importer", func() {
+	var (
+		ctrl    *gomock.Controller
+		store   *mocks.MockStore
+		manager bookManager
+		ctx     = context.Background()
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStore(ctrl)
+		manager = bookManager{goresource.NewDefaultManager("books", store)}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	Describe("ImportNDJSON", func() {
+		It("inserts valid lines and reports the others.", func() {
+			store.EXPECT().CreateEntity(gomock.Any(), "books", &testBook{Name: "a"}, gomock.Any()).Return(nil)
+			store.EXPECT().CreateEntity(gomock.Any(), "books", &testBook{Name: "c"}, gomock.Any()).Return(fmt.Errorf("duplicate"))
+			input := "{\"name\":\"a\"}\n\n{\"pages\":1}\n{\"name\":\"c\"}\n{bad\n"
+			report, err := goresource.Importer{Manager: manager}.ImportNDJSON(ctx, strings.NewReader(input), nil)
+			Expect(err).To(BeNil())
+			Expect(report.Inserted).To(Equal(1))
+			Expect(report.Skipped).To(Equal([]goresource.ImportRow{{Row: 2, Reason: "empty line"}}))
+			Expect(len(report.Failed)).To(Equal(3))
+			Expect(report.Failed[0]).To(Equal(goresource.ImportRow{Row: 3, Reason: "name is required"}))
+			Expect(report.Failed[1]).To(Equal(goresource.ImportRow{Row: 4, Reason: "duplicate"}))
+			Expect(report.Failed[2].Row).To(Equal(5))
+		})
+		It("inserts in batches of the given size.", func() {
+			var inserted []string
+			store.EXPECT().CreateEntity(gomock.Any(), "books", gomock.Any(), gomock.Any()).Times(3).
+				DoAndReturn(func(_ context.Context, _ string, data interface{}, result interface{}) error {
+					*result.(*map[string]interface{}) = map[string]interface{}{"name": data.(*testBook).Name}
+					return nil
+				})
+			imp := goresource.Importer{Manager: manager, BatchSize: 2, Inserted: func(result interface{}) {
+				inserted = append(inserted, result.(map[string]interface{})["name"].(string))
+			}}
+			report, err := imp.ImportNDJSON(ctx, strings.NewReader("{\"name\":\"a\"}\n{\"name\":\"b\"}\n{\"name\":\"c\"}\n"), nil)
+			Expect(err).To(BeNil())
+			Expect(report.Inserted).To(Equal(3))
+			Expect(inserted).To(Equal([]string{"a", "b", "c"}))
+		})
+	})
+
+	It("reports every row of a batch failing as a whole.", func() {
+		s := &batchStore{MockStore: store, err: fmt.Errorf("connection lost")}
+		imp := goresource.Importer{Manager: bookManager{goresource.NewDefaultManager("books", s)}}
+		report, err := imp.ImportNDJSON(ctx, strings.NewReader("{\"name\":\"a\"}\n{\"name\":\"b\"}\n"), nil)
+		Expect(err).To(BeNil())
+		Expect(report.Inserted).To(Equal(0))
+		Expect(report.Failed).To(ConsistOf(
+			goresource.ImportRow{Row: 1, Reason: "connection lost"},
+			goresource.ImportRow{Row: 2, Reason: "connection lost"},
+		))
+	})
+
+	Describe("ImportCSV", func() {
+		It("maps headers to fields and converts values by field type.", func() {
+			rating := 4.5
+			store.EXPECT().CreateEntity(gomock.Any(), "books", &testBook{Name: "a", Pages: 12, Tags: []string{"x"}, Rating: &rating},
+				gomock.Any()).Return(nil)
+			input := "Title,pages,tags,rating\na,12,\"[\"\"x\"\"]\",4.5\n,,,\nb,many,,\nc\n"
+			imp := goresource.Importer{Manager: manager, Mapping: map[string]string{"Title": "name"}}
+			report, err := imp.ImportCSV(ctx, strings.NewReader(input), nil)
+			Expect(err).To(BeNil())
+			Expect(report.Inserted).To(Equal(1))
+			Expect(report.Skipped).To(Equal([]goresource.ImportRow{{Row: 2, Reason: "empty row"}}))
+			Expect(len(report.Failed)).To(Equal(2))
+			Expect(report.Failed[0].Row).To(Equal(3))
+			Expect(report.Failed[0].Reason).To(ContainSubstring("invalid syntax"))
+			Expect(report.Failed[1]).To(Equal(goresource.ImportRow{Row: 4, Reason: "expected 4 columns, got 1"}))
+		})
+		It("returns an error without a header.", func() {
+			_, err := goresource.Importer{Manager: manager}.ImportCSV(ctx, strings.NewReader(""), nil)
+			Expect(err).ToNot(BeNil())
+		})
+	})
+})
+
+var _ = Describe("Resource.Import", func() {
+	var (
+		ctrl   *gomock.Controller
+		store  *mocks.MockStore
+		router *mux.Router
+		rw     *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStore(ctrl)
+		router = mux.NewRouter().PathPrefix("/api").Subrouter()
+		rw = httptest.NewRecorder()
+		goresource.NewResource(bookManager{goresource.NewDefaultManager("books", store)}, router)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("imports csv uploads and responds with a report.", func() {
+		store.EXPECT().CreateEntity(gomock.Any(), "books", &testBook{Name: "a"}, gomock.Any()).Return(nil)
+		req, _ := http.NewRequest("POST", "/api/books/import?map.Title=name", strings.NewReader("Title\na\n"))
+		req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+		router.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Body.String()).To(MatchJSON(`{"inserted":1,"skipped":[],"failed":[]}`))
+	})
+
+	It("imports ndjson uploads.", func() {
+		store.EXPECT().CreateEntity(gomock.Any(), "books", &testBook{Name: "a"}, gomock.Any()).Return(nil)
+		req, _ := http.NewRequest("POST", "/api/books/import?batch=10", strings.NewReader("{\"name\":\"a\"}\n"))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		router.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Body.String()).To(MatchJSON(`{"inserted":1,"skipped":[],"failed":[]}`))
+	})
+
+	It("audits imported entities with their stored ids.", func() {
+		var buf bytes.Buffer
+		router = mux.NewRouter().PathPrefix("/api").Subrouter()
+		goresource.NewResource(bookManager{goresource.NewDefaultManager("books", store)}, router,
+			goresource.WithAuditSink(goresource.NewWriterAuditSink(&buf)))
+		store.EXPECT().CreateEntity(gomock.Any(), "books", &testBook{Name: "a"}, gomock.Any()).
+			SetArg(3, map[string]interface{}{"_id": "generated", "name": "a"}).Return(nil)
+		req, _ := http.NewRequest("POST", "/api/books/import", strings.NewReader("{\"name\":\"a\"}\n"))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		router.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		var record goresource.AuditRecord
+		Expect(json.Unmarshal(buf.Bytes(), &record)).To(Succeed())
+		Expect(record.EntityID).To(Equal("generated"))
+		Expect(record.After).To(HaveKeyWithValue("_id", "generated"))
+	})
+
+	It("responds with 415 for other content types.", func() {
+		req, _ := http.NewRequest("POST", "/api/books/import", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusUnsupportedMediaType))
+	})
+
+	It("responds with 400 for an invalid batch size.", func() {
+		req, _ := http.NewRequest("POST", "/api/books/import?batch=none", strings.NewReader(""))
+		req.Header.Set("Content-Type", "text/csv")
+		router.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusBadRequest))
+	})
+})
@@ -0,0 +1,22 @@
+package goresource
+
+import (
+	"context"
+	"fmt"
+)
+
+// EnsureIndexes creates the indexes declared by the managers of the given
+// resources, usually Registered(), stopping at the first error. Call it at
+// startup once the resources are created.
+func EnsureIndexes(ctx context.Context, resources []*Resource) error {
+	for _, r := range resources {
+		im, ok := r.manager.(IndexManager)
+		if !ok {
+			continue
+		}
+		if err := im.EnsureIndexes(ctx); err != nil {
+			return fmt.Errorf("error ensuring indexes of %s: %w", r.Name(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package goresource_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"goresource"
+	"goresource/mocks"
+	storepkg "goresource/store"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// indexStore is a store recording the indexes ensured on it.
+type indexStore struct {
+	*mocks.MockStore
+	ensured map[string][]storepkg.Index
+	err     error
+}
+
+func (s *indexStore) EnsureIndexes(_ context.Context, name string, indexes []storepkg.Index) error {
+	s.ensured[name] = indexes
+	return s.err
+}
+
+var _ = Describe("Indexes", func() {
+	var (
+		ctrl  *gomock.Controller
+		store *indexStore
+		books goresource.DefaultManager
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = &indexStore{MockStore: mocks.NewMockStore(ctrl), ensured: make(map[string][]storepkg.Index)}
+		books = goresource.NewDefaultManager("books", store)
+		books.Indexes = []storepkg.Index{{Key: []string{"isbn"}, Unique: true}, {Key: []string{"$text:title"}}}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("ensures the indexes declared by each resource's manager.", func() {
+		authors := mocks.NewMockResourceManager(ctrl)
+		authors.EXPECT().GetName().Return("authors").AnyTimes()
+		router := mux.NewRouter()
+		resources := []*goresource.Resource{
+			goresource.NewResource(bookManager{books}, router),
+			goresource.NewResource(authors, router),
+		}
+		Expect(goresource.EnsureIndexes(context.Background(), resources)).To(Succeed())
+		Expect(store.ensured).To(Equal(map[string][]storepkg.Index{"books": books.Indexes}))
+	})
+
+	It("stops at the first error.", func() {
+		store.err = fmt.Errorf("test error")
+		r := goresource.NewResource(bookManager{books}, mux.NewRouter())
+		err := goresource.EnsureIndexes(context.Background(), []*goresource.Resource{r})
+		Expect(err).To(MatchError("error ensuring indexes of books: test error"))
+	})
+
+	It("rejects invalid indexes before reaching the store.", func() {
+		books.Indexes = append(books.Indexes, storepkg.Index{})
+		Expect(books.EnsureIndexes(context.Background())).NotTo(Succeed())
+		Expect(store.ensured).To(BeEmpty())
+	})
+
+	It("responds with 409 Conflict to unique index violations.", func() {
+		router := mux.NewRouter()
+		goresource.NewResource(bookManager{books}, router)
+		store.EXPECT().CreateEntity(gomock.Any(), "books", gomock.Any(), gomock.Any()).
+			Return(fmt.Errorf("%w: E11000 duplicate key error", storepkg.ErrDuplicate))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/books", strings.NewReader(`{"name": "a"}`)))
+		Expect(w.Code).To(Equal(http.StatusConflict))
+	})
+})
@@ -0,0 +1,70 @@
+package goresource
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/rockstardevs/goresource/store"
+)
+
+// logRequest writes a structured log entry describing a handled request.
+// Server errors, and errors after the response started, are logged at error
+// level, client errors at warn level and everything else at info level.
+func (r Resource) logRequest(req *http.Request, id string, rw *statusWriter, latency time.Duration) {
+	level := slog.LevelInfo
+	switch {
+	case rw.status >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case rw.status >= http.StatusBadRequest:
+		level = slog.LevelWarn
+	case rw.err != "":
+		level = slog.LevelError
+	}
+	attrs := []slog.Attr{
+		slog.String("resource", r.manager.GetName()),
+		slog.String("method", req.Method),
+		slog.String("id", id),
+		slog.Int("status", rw.status),
+		slog.Duration("latency", latency),
+	}
+	if rw.err != "" {
+		attrs = append(attrs, slog.String("error", rw.err))
+	}
+	r.logger.LogAttrs(req.Context(), level, "request", attrs...)
+}
+
+// errorStatus returns the status code to respond with for the given error.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidQuery):
+		return http.StatusBadRequest
+	case errors.Is(err, store.ErrDuplicate):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// parseStatus returns the http status code for an error parsing a request
+// body, Bad Request unless the resource is not found.
+func parseStatus(err error) int {
+	if errors.Is(err, store.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// writeError responds with the given error message and status code, remembering
+// the message for the request log.
+func writeError(rw http.ResponseWriter, msg string, status int) {
+	switch w := rw.(type) {
+	case *statusWriter:
+		w.err = msg
+	case *recordingWriter:
+		w.err = msg
+	}
+	http.Error(rw, msg, status)
+}
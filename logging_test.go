@@ -0,0 +1,84 @@
+package goresource_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+
+	"goresource"
+	"goresource/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resource request logging", func() {
+	var (
+		ctrl    *gomock.Controller
+		manager *mocks.MockResourceManager
+		router  *mux.Router
+		rw      *httptest.ResponseRecorder
+		buf     *bytes.Buffer
+	)
+
+	entry := func() map[string]interface{} {
+		result := make(map[string]interface{})
+		Expect(json.Unmarshal(buf.Bytes(), &result)).To(Succeed())
+		return result
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		manager = mocks.NewMockResourceManager(ctrl)
+		router = mux.NewRouter().PathPrefix("/api").Subrouter()
+		rw = httptest.NewRecorder()
+		buf = &bytes.Buffer{}
+		manager.EXPECT().GetName().AnyTimes().Return("test")
+		logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		goresource.NewResource(manager, router, goresource.WithLogger(logger))
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("logs successful requests at info level.", func() {
+		req, _ := http.NewRequest("GET", "/api/test/fakeid", nil)
+		manager.EXPECT().GetEntity(gomock.Any(), "fakeid", gomock.Any()).Return("fake-entity", nil)
+		router.ServeHTTP(rw, req)
+		got := entry()
+		Expect(got["level"]).To(Equal("INFO"))
+		Expect(got["msg"]).To(Equal("request"))
+		Expect(got["resource"]).To(Equal("test"))
+		Expect(got["method"]).To(Equal("GET"))
+		Expect(got["id"]).To(Equal("fakeid"))
+		Expect(got["status"]).To(BeEquivalentTo(http.StatusOK))
+		Expect(got).To(HaveKey("latency"))
+		Expect(got).ToNot(HaveKey("error"))
+	})
+
+	It("logs client errors at warn level.", func() {
+		req, _ := http.NewRequest("DELETE", "/api/test", nil)
+		router.ServeHTTP(rw, req)
+		got := entry()
+		Expect(got["level"]).To(Equal("WARN"))
+		Expect(got["status"]).To(BeEquivalentTo(http.StatusBadRequest))
+		Expect(got["error"]).To(Equal("Invalid Id"))
+	})
+
+	It("logs server errors at error level with the error.", func() {
+		req, _ := http.NewRequest("GET", "/api/test", nil)
+		manager.EXPECT().ListEntities(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("test error"))
+		router.ServeHTTP(rw, req)
+		got := entry()
+		Expect(got["level"]).To(Equal("ERROR"))
+		Expect(got["status"]).To(BeEquivalentTo(http.StatusInternalServerError))
+		Expect(got["error"]).To(Equal("test error"))
+	})
+})
@@ -1,6 +1,8 @@
 package goresource
 
 import (
+	"context"
+	"errors"
 	"io"
 	"net/url"
 
@@ -11,25 +13,81 @@ import (
 type ResourceManager interface {
 	GetName() string
 	New() Entity
-	GetEntity(id string, query url.Values) (interface{}, error)
-	CreateEntity(entity Entity, query url.Values) (interface{}, error)
-	ListEntities(query url.Values) (interface{}, error)
-	UpdateEntity(id string, entity Entity, query url.Values) (interface{}, error)
-	DeleteEntity(id string, query url.Values) error
+	GetEntity(ctx context.Context, id string, query url.Values) (interface{}, error)
+	CreateEntity(ctx context.Context, entity Entity, query url.Values) (interface{}, error)
+	ListEntities(ctx context.Context, query url.Values) (interface{}, error)
+	UpdateEntity(ctx context.Context, id string, entity Entity, query url.Values) (interface{}, error)
+	DeleteEntity(ctx context.Context, id string, query url.Values) error
 	ParseJSON(io.ReadCloser) (Entity, error)
 }
 
+// ErrInvalidQuery is wrapped by errors caused by invalid query parameters,
+// which are responded to with 400 Bad Request.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Formatter is implemented by managers that opt into media types other than json.
+type Formatter interface {
+	// Formats returns the supported media types, in order of preference.
+	Formats() []string
+}
+
+// StreamManager is implemented by managers that can iterate over entities
+// without loading them all into memory.
+type StreamManager interface {
+	StreamEntities(ctx context.Context, query url.Values) (store.Iterator, error)
+}
+
+// BatchManager is implemented by managers that can persist many entities at once.
+type BatchManager interface {
+	// CreateEntities persists the given entities, reporting those that failed by index.
+	CreateEntities(ctx context.Context, entities []Entity, query url.Values) (map[int]error, error)
+}
+
+// AggregateManager is implemented by managers that can count and aggregate
+// entities without returning them.
+type AggregateManager interface {
+	// CountEntities returns the number of entities matching the query's filters.
+	CountEntities(ctx context.Context, query url.Values) (int, error)
+	// AggregateEntities returns grouped metrics over the entities matching the query's filters.
+	AggregateEntities(ctx context.Context, query url.Values) (interface{}, error)
+}
+
+// IndexManager is implemented by managers that declare indexes on their entities.
+type IndexManager interface {
+	// EnsureIndexes creates the declared indexes if they don't exist.
+	EnsureIndexes(ctx context.Context) error
+}
+
+// CacheManager is implemented by managers declaring how responses with their
+// entities may be cached by browsers and shared caches.
+type CacheManager interface {
+	// ItemCachePolicy returns the policy for responses with a single entity.
+	ItemCachePolicy() CachePolicy
+	// CollectionCachePolicy returns the policy for responses listing entities.
+	CollectionCachePolicy() CachePolicy
+}
+
 // DefaultManager is a default implementation for ResourceManager.
 // It implements defaults for all methods except New and ParseJSON.
 type DefaultManager struct {
 	// Name is used a prefix for routes as well as the database collection name.
 	Name  string
 	Store store.Store
+	// Relations are the references to other entities that can be expanded.
+	Relations []Relation
+	// MaxExpandDepth limits how deeply relations are expanded, defaults to DefaultMaxExpandDepth.
+	MaxExpandDepth int
+	// Indexes are created on the store by EnsureIndexes.
+	Indexes []store.Index
+	// ItemCache and CollectionCache are the caching policies of responses
+	// with a single entity and listing entities, not cached if unset.
+	ItemCache       CachePolicy
+	CollectionCache CachePolicy
 }
 
 // NewDefaultManager initializes and returns a DefaultManager.
 func NewDefaultManager(name string, store store.Store) DefaultManager {
-	return DefaultManager{name, store}
+	return DefaultManager{Name: name, Store: store}
 }
 
 // GetName returns the name for this DefaultManager.
@@ -38,42 +96,100 @@ func (manager DefaultManager) GetName() string {
 }
 
 // GetEntity fetches a single resource entity with the given id.
-func (manager DefaultManager) GetEntity(id string, _ url.Values) (interface{}, error) {
+// Relations listed in the expand query parameter are embedded.
+func (manager DefaultManager) GetEntity(ctx context.Context, id string, query url.Values) (interface{}, error) {
 	result := make(map[string]interface{})
-	if err := manager.Store.GetEntity(manager.Name, id, &result); err != nil {
+	if err := manager.Store.GetEntity(ctx, manager.Name, id, &result); err != nil {
+		return nil, err
+	}
+	if err := manager.expand(ctx, []map[string]interface{}{result}, query); err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
+// EnsureIndexes creates the manager's indexes on its store, if the store
+// supports indexes.
+func (manager DefaultManager) EnsureIndexes(ctx context.Context) error {
+	return store.EnsureIndexes(ctx, manager.Store, manager.Name, manager.Indexes)
+}
+
+// WithTransaction runs fn in a store transaction, with a copy of the manager
+// using the transaction's store. Managers embedding DefaultManager use it to
+// make the changes of their methods atomic, for example decrementing inventory
+// along with creating an order. It returns store.ErrTransactionsUnsupported
+// if the store has no transactions.
+func (manager DefaultManager) WithTransaction(ctx context.Context, fn func(ctx context.Context, tx DefaultManager) error) error {
+	return store.WithTransaction(ctx, manager.Store, func(ctx context.Context, s store.Store) error {
+		tx := manager
+		tx.Store = s
+		return fn(ctx, tx)
+	})
+}
+
 // CreateEntity persists the given entity.
-func (manager DefaultManager) CreateEntity(e Entity, _ url.Values) (interface{}, error) {
+func (manager DefaultManager) CreateEntity(ctx context.Context, e Entity, _ url.Values) (interface{}, error) {
 	result := make(map[string]interface{})
-	if err := manager.Store.CreateEntity(manager.Name, e, &result); err != nil {
+	if err := manager.Store.CreateEntity(ctx, manager.Name, e, &result); err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
-// ListEntities fetches all resource entities.
-func (manager DefaultManager) ListEntities(query url.Values) (interface{}, error) {
-	result := make([]map[string]interface{}, 0)
-	if err := manager.Store.ListEntities(manager.Name, query, &result); err != nil {
+// CreateEntities persists the given entities in batch.
+func (manager DefaultManager) CreateEntities(ctx context.Context, entities []Entity, _ url.Values) (map[int]error, error) {
+	data := make([]interface{}, len(entities))
+	for i, e := range entities {
+		data[i] = e
+	}
+	return store.CreateEntities(ctx, manager.Store, manager.Name, data)
+}
+
+// ListEntities fetches all resource entities matching the query's filters,
+// or the results of its full text query if it has one.
+// Relations listed in the expand query parameter are embedded.
+func (manager DefaultManager) ListEntities(ctx context.Context, query url.Values) (interface{}, error) {
+	var (
+		result = make([]map[string]interface{}, 0)
+		err    error
+	)
+	if query.Get(SearchParam) != "" {
+		result, err = manager.search(ctx, query)
+	} else {
+		err = manager.Store.ListEntities(ctx, manager.Name, filters(query), &result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := manager.expand(ctx, result, query); err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
+// StreamEntities returns an iterator over all resource entities matching the
+// query's filters, or over the results of its full text query if it has one.
+func (manager DefaultManager) StreamEntities(ctx context.Context, query url.Values) (store.Iterator, error) {
+	if query.Get(SearchParam) != "" {
+		result, err := manager.search(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		return store.NewSliceIterator(result), nil
+	}
+	return store.Stream(ctx, manager.Store, manager.Name, filters(query))
+}
+
 // UpdateEntity persists changes to the given entity with the given id.
-func (manager DefaultManager) UpdateEntity(id string, e Entity, _ url.Values) (interface{}, error) {
+func (manager DefaultManager) UpdateEntity(ctx context.Context, id string, e Entity, _ url.Values) (interface{}, error) {
 	result := make(map[string]interface{})
-	if err := manager.Store.UpdateEntity(manager.Name, id, e, &result); err != nil {
+	if err := manager.Store.UpdateEntity(ctx, manager.Name, id, e, &result); err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
 // DeleteEntity removes a single entity with the given id.
-func (manager DefaultManager) DeleteEntity(id string, _ url.Values) error {
-	return manager.Store.DeleteEntity(manager.Name, id)
+func (manager DefaultManager) DeleteEntity(ctx context.Context, id string, _ url.Values) error {
+	return manager.Store.DeleteEntity(ctx, manager.Name, id)
 }
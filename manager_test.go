@@ -1,11 +1,13 @@
 package goresource_test
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 
 	"goresource"
 	"goresource/mocks"
+	storepkg "goresource/store"
 
 	"github.com/golang/mock/gomock"
 
@@ -13,6 +15,16 @@ import (
 	. "github.com/onsi/gomega"
 )
 
+// txStore is a store running transactions on a separate store.
+type txStore struct {
+	*mocks.MockStore
+	tx storepkg.Store
+}
+
+func (s *txStore) WithTransaction(ctx context.Context, fn storepkg.TxFunc) error {
+	return fn(ctx, s.tx)
+}
+
 var _ = Describe("DefaultManager", func() {
 	var (
 		ctrl    *gomock.Controller
@@ -39,15 +51,15 @@ var _ = Describe("DefaultManager", func() {
 	Describe(".GetEntity", func() {
 		It("returns the fetched entity from the store.", func() {
 			want := map[string]interface{}{"bar": "baz"}
-			store.EXPECT().GetEntity("test", "foo", gomock.Any()).Times(1).SetArg(2, want).Return(nil)
-			got, err := manager.GetEntity("foo", nil)
+			store.EXPECT().GetEntity(gomock.Any(), "test", "foo", gomock.Any()).Times(1).SetArg(3, want).Return(nil)
+			got, err := manager.GetEntity(context.Background(), "foo", nil)
 			Expect(err).To(BeNil())
 			Expect(got.(map[string]interface{})["bar"]).To(Equal("baz"))
 		})
 		It("passes through any errors from the store.", func() {
 			e := fmt.Errorf("test error")
-			store.EXPECT().GetEntity("test", "foo", gomock.Any()).Times(1).Return(e)
-			got, err := manager.GetEntity("foo", nil)
+			store.EXPECT().GetEntity(gomock.Any(), "test", "foo", gomock.Any()).Times(1).Return(e)
+			got, err := manager.GetEntity(context.Background(), "foo", nil)
 			Expect(err).ToNot(BeNil())
 			Expect(err.Error()).To(Equal("test error"))
 			Expect(got).To(BeNil())
@@ -58,25 +70,36 @@ var _ = Describe("DefaultManager", func() {
 		It("creates a database entity and returns the created entity.", func() {
 			want := map[string]interface{}{"bar": "baz"}
 			e := &mocks.MockEntity{"fakeid"}
-			store.EXPECT().CreateEntity("test", e, gomock.Any()).Times(1).SetArg(2, want).Return(nil)
-			got, err := manager.CreateEntity(e, nil)
+			store.EXPECT().CreateEntity(gomock.Any(), "test", e, gomock.Any()).Times(1).SetArg(3, want).Return(nil)
+			got, err := manager.CreateEntity(context.Background(), e, nil)
 			Expect(err).To(BeNil())
 			Expect(got.(map[string]interface{})["bar"]).To(Equal("baz"))
 		})
 		It("passes through any errors from the store.", func() {
 			e := fmt.Errorf("test error")
-			store.EXPECT().CreateEntity("test", nil, gomock.Any()).Times(1).Return(e)
-			got, err := manager.CreateEntity(nil, nil)
+			store.EXPECT().CreateEntity(gomock.Any(), "test", nil, gomock.Any()).Times(1).Return(e)
+			got, err := manager.CreateEntity(context.Background(), nil, nil)
 			Expect(err).ToNot(BeNil())
 			Expect(err.Error()).To(Equal("test error"))
 			Expect(got).To(BeNil())
 		})
 	})
+	Describe(".CreateEntities", func() {
+		It("creates the entities, reporting failures by index.", func() {
+			e1, e2 := &mocks.MockEntity{"a"}, &mocks.MockEntity{"b"}
+			store.EXPECT().CreateEntity(gomock.Any(), "test", e1, gomock.Any()).Times(1).Return(nil)
+			store.EXPECT().CreateEntity(gomock.Any(), "test", e2, gomock.Any()).Times(1).Return(fmt.Errorf("test error"))
+			failed, err := manager.CreateEntities(context.Background(), []goresource.Entity{e1, e2}, nil)
+			Expect(err).To(BeNil())
+			Expect(len(failed)).To(Equal(1))
+			Expect(failed[1].Error()).To(Equal("test error"))
+		})
+	})
 	Describe(".ListEntities", func() {
 		It("returns the fetched entities from the store.", func() {
 			want := []map[string]interface{}{{"item1": "value1"}, {"item2": "value2"}}
-			store.EXPECT().ListEntities("test", url.Values{"field": []string{"value"}}, gomock.Any()).Times(1).SetArg(2, want).Return(nil)
-			result, err := manager.ListEntities(url.Values{"field": []string{"value"}})
+			store.EXPECT().ListEntities(gomock.Any(), "test", url.Values{"field": []string{"value"}}, gomock.Any()).Times(1).SetArg(3, want).Return(nil)
+			result, err := manager.ListEntities(context.Background(), url.Values{"field": []string{"value"}})
 			Expect(err).To(BeNil())
 			got, ok := result.([]map[string]interface{})
 			Expect(ok).To(BeTrue())
@@ -86,8 +109,8 @@ var _ = Describe("DefaultManager", func() {
 		})
 		It("returns the fetched entities with filters from the store.", func() {
 			want := []map[string]interface{}{{"item1": "value1"}, {"item2": "value2"}}
-			store.EXPECT().ListEntities("test", nil, gomock.Any()).Times(1).SetArg(2, want).Return(nil)
-			result, err := manager.ListEntities(nil)
+			store.EXPECT().ListEntities(gomock.Any(), "test", nil, gomock.Any()).Times(1).SetArg(3, want).Return(nil)
+			result, err := manager.ListEntities(context.Background(), nil)
 			Expect(err).To(BeNil())
 			got, ok := result.([]map[string]interface{})
 			Expect(ok).To(BeTrue())
@@ -97,40 +120,79 @@ var _ = Describe("DefaultManager", func() {
 		})
 		It("passes through any errors from the store.", func() {
 			e := fmt.Errorf("test error")
-			store.EXPECT().ListEntities("test", nil, gomock.Any()).Times(1).Return(e)
-			got, err := manager.ListEntities(nil)
+			store.EXPECT().ListEntities(gomock.Any(), "test", nil, gomock.Any()).Times(1).Return(e)
+			got, err := manager.ListEntities(context.Background(), nil)
 			Expect(err.Error()).To(Equal("test error"))
 			Expect(got).To(BeNil())
 		})
 	})
+	Describe(".StreamEntities", func() {
+		It("iterates over the entities from the store.", func() {
+			want := []map[string]interface{}{{"item1": "value1"}, {"item2": "value2"}}
+			store.EXPECT().ListEntities(gomock.Any(), "test", nil, gomock.Any()).Times(1).SetArg(3, want).Return(nil)
+			it, err := manager.StreamEntities(context.Background(), nil)
+			Expect(err).To(BeNil())
+			count := 0
+			for it.Next(&map[string]interface{}{}) {
+				count++
+			}
+			Expect(count).To(Equal(2))
+		})
+		It("passes through any errors from the store.", func() {
+			store.EXPECT().ListEntities(gomock.Any(), "test", nil, gomock.Any()).Times(1).Return(fmt.Errorf("test error"))
+			it, err := manager.StreamEntities(context.Background(), nil)
+			Expect(err.Error()).To(Equal("test error"))
+			Expect(it).To(BeNil())
+		})
+	})
+	Describe(".WithTransaction", func() {
+		It("runs the function with a manager using the transaction's store.", func() {
+			tx := mocks.NewMockStore(ctrl)
+			manager.Store = &txStore{MockStore: store, tx: tx}
+			tx.EXPECT().DeleteEntity(gomock.Any(), "test", "a").Return(nil)
+			err := manager.WithTransaction(context.Background(), func(ctx context.Context, m goresource.DefaultManager) error {
+				Expect(m.GetName()).To(Equal("test"))
+				return m.DeleteEntity(ctx, "a", nil)
+			})
+			Expect(err).To(BeNil())
+		})
+
+		It("is unsupported by stores without transactions.", func() {
+			err := manager.WithTransaction(context.Background(), func(context.Context, goresource.DefaultManager) error {
+				return nil
+			})
+			Expect(err).To(Equal(storepkg.ErrTransactionsUnsupported))
+		})
+	})
+
 	Describe(".UpdateEntity", func() {
 		It("updates the database entity and returns it.", func() {
 			want := map[string]interface{}{"bar": "baz"}
 			e := &mocks.MockEntity{"fakeid"}
-			store.EXPECT().UpdateEntity("test", "fakeid", e, gomock.Any()).Times(1).SetArg(3, want).Return(nil)
-			got, err := manager.UpdateEntity("fakeid", e, nil)
+			store.EXPECT().UpdateEntity(gomock.Any(), "test", "fakeid", e, gomock.Any()).Times(1).SetArg(4, want).Return(nil)
+			got, err := manager.UpdateEntity(context.Background(), "fakeid", e, nil)
 			Expect(err).To(BeNil())
 			Expect(got).To(BeEquivalentTo(want))
 		})
 		It("passes through any errors from the store.", func() {
 			e := &mocks.MockEntity{"fakeid"}
 			er := fmt.Errorf("test error")
-			store.EXPECT().UpdateEntity("test", "fakeid", e, gomock.Any()).Times(1).Return(er)
-			got, err := manager.UpdateEntity("fakeid", e, nil)
+			store.EXPECT().UpdateEntity(gomock.Any(), "test", "fakeid", e, gomock.Any()).Times(1).Return(er)
+			got, err := manager.UpdateEntity(context.Background(), "fakeid", e, nil)
 			Expect(err.Error()).To(Equal("test error"))
 			Expect(got).To(BeNil())
 		})
 	})
 	Describe(".DeleteEntity", func() {
 		It("deletes the corresponding entity from the store.", func() {
-			store.EXPECT().DeleteEntity("test", "bar").Times(1).Return(nil)
-			err := manager.DeleteEntity("bar", nil)
+			store.EXPECT().DeleteEntity(gomock.Any(), "test", "bar").Times(1).Return(nil)
+			err := manager.DeleteEntity(context.Background(), "bar", nil)
 			Expect(err).To(BeNil())
 		})
 		It("passes through any errors from the store.", func() {
 			e := fmt.Errorf("test error")
-			store.EXPECT().DeleteEntity("test", "bar").Times(1).Return(e)
-			err := manager.DeleteEntity("bar", nil)
+			store.EXPECT().DeleteEntity(gomock.Any(), "test", "bar").Times(1).Return(e)
+			err := manager.DeleteEntity(context.Background(), "bar", nil)
 			Expect(err).ToNot(BeNil())
 			Expect(err.Error()).To(Equal("test error"))
 		})
@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	goresource "goresource"
 	io "io"
@@ -36,41 +37,41 @@ func (m *MockResourceManager) EXPECT() *MockResourceManagerMockRecorder {
 }
 
 // CreateEntity mocks base method
-func (m *MockResourceManager) CreateEntity(arg0 goresource.Entity, arg1 url.Values) (interface{}, error) {
-	ret := m.ctrl.Call(m, "CreateEntity", arg0, arg1)
+func (m *MockResourceManager) CreateEntity(arg0 context.Context, arg1 goresource.Entity, arg2 url.Values) (interface{}, error) {
+	ret := m.ctrl.Call(m, "CreateEntity", arg0, arg1, arg2)
 	ret0, _ := ret[0].(interface{})
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateEntity indicates an expected call of CreateEntity
-func (mr *MockResourceManagerMockRecorder) CreateEntity(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEntity", reflect.TypeOf((*MockResourceManager)(nil).CreateEntity), arg0, arg1)
+func (mr *MockResourceManagerMockRecorder) CreateEntity(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEntity", reflect.TypeOf((*MockResourceManager)(nil).CreateEntity), arg0, arg1, arg2)
 }
 
 // DeleteEntity mocks base method
-func (m *MockResourceManager) DeleteEntity(arg0 string, arg1 url.Values) error {
-	ret := m.ctrl.Call(m, "DeleteEntity", arg0, arg1)
+func (m *MockResourceManager) DeleteEntity(arg0 context.Context, arg1 string, arg2 url.Values) error {
+	ret := m.ctrl.Call(m, "DeleteEntity", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteEntity indicates an expected call of DeleteEntity
-func (mr *MockResourceManagerMockRecorder) DeleteEntity(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEntity", reflect.TypeOf((*MockResourceManager)(nil).DeleteEntity), arg0, arg1)
+func (mr *MockResourceManagerMockRecorder) DeleteEntity(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEntity", reflect.TypeOf((*MockResourceManager)(nil).DeleteEntity), arg0, arg1, arg2)
 }
 
 // GetEntity mocks base method
-func (m *MockResourceManager) GetEntity(arg0 string, arg1 url.Values) (interface{}, error) {
-	ret := m.ctrl.Call(m, "GetEntity", arg0, arg1)
+func (m *MockResourceManager) GetEntity(arg0 context.Context, arg1 string, arg2 url.Values) (interface{}, error) {
+	ret := m.ctrl.Call(m, "GetEntity", arg0, arg1, arg2)
 	ret0, _ := ret[0].(interface{})
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetEntity indicates an expected call of GetEntity
-func (mr *MockResourceManagerMockRecorder) GetEntity(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEntity", reflect.TypeOf((*MockResourceManager)(nil).GetEntity), arg0, arg1)
+func (mr *MockResourceManagerMockRecorder) GetEntity(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEntity", reflect.TypeOf((*MockResourceManager)(nil).GetEntity), arg0, arg1, arg2)
 }
 
 // GetName mocks base method
@@ -86,16 +87,16 @@ func (mr *MockResourceManagerMockRecorder) GetName() *gomock.Call {
 }
 
 // ListEntities mocks base method
-func (m *MockResourceManager) ListEntities(arg0 url.Values) (interface{}, error) {
-	ret := m.ctrl.Call(m, "ListEntities", arg0)
+func (m *MockResourceManager) ListEntities(arg0 context.Context, arg1 url.Values) (interface{}, error) {
+	ret := m.ctrl.Call(m, "ListEntities", arg0, arg1)
 	ret0, _ := ret[0].(interface{})
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ListEntities indicates an expected call of ListEntities
-func (mr *MockResourceManagerMockRecorder) ListEntities(arg0 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntities", reflect.TypeOf((*MockResourceManager)(nil).ListEntities), arg0)
+func (mr *MockResourceManagerMockRecorder) ListEntities(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEntities", reflect.TypeOf((*MockResourceManager)(nil).ListEntities), arg0, arg1)
 }
 
 // New mocks base method
@@ -124,14 +125,14 @@ func (mr *MockResourceManagerMockRecorder) ParseJSON(arg0 interface{}) *gomock.C
 }
 
 // UpdateEntity mocks base method
-func (m *MockResourceManager) UpdateEntity(arg0 string, arg1 goresource.Entity, arg2 url.Values) (interface{}, error) {
-	ret := m.ctrl.Call(m, "UpdateEntity", arg0, arg1, arg2)
+func (m *MockResourceManager) UpdateEntity(arg0 context.Context, arg1 string, arg2 goresource.Entity, arg3 url.Values) (interface{}, error) {
+	ret := m.ctrl.Call(m, "UpdateEntity", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(interface{})
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // UpdateEntity indicates an expected call of UpdateEntity
-func (mr *MockResourceManagerMockRecorder) UpdateEntity(arg0, arg1, arg2 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEntity", reflect.TypeOf((*MockResourceManager)(nil).UpdateEntity), arg0, arg1, arg2)
+func (mr *MockResourceManagerMockRecorder) UpdateEntity(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEntity", reflect.TypeOf((*MockResourceManager)(nil).UpdateEntity), arg0, arg1, arg2, arg3)
 }
@@ -0,0 +1,125 @@
+package goresource
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+
+	"github.com/rockstardevs/goresource/codec"
+)
+
+// Formats returns the media types supported by the manager, in order of preference.
+func (r Resource) Formats() []string {
+	if f, ok := r.manager.(Formatter); ok {
+		return f.Formats()
+	}
+	return []string{codec.JSONType}
+}
+
+// negotiate picks the codec for the response from the request's Accept header.
+// It responds with 406 Not Acceptable if none of the manager's formats match.
+func (r Resource) negotiate(rw http.ResponseWriter, req *http.Request) (codec.Codec, bool) {
+	c, ok := r.codecs.Negotiate(req.Header.Get("Accept"), r.Formats())
+	if !ok {
+		writeError(rw, "Not Acceptable", http.StatusNotAcceptable)
+	}
+	return c, ok
+}
+
+// requestBody returns the request body as json for the manager's ParseJSON,
+// decoding it with the codec for the request's Content-Type. Bodies without a
+// Content-Type are assumed to be json. Xml has no types, so the text of xml
+// bodies is converted to the types of the fields of the manager's entities. It responds with 415 Unsupported Media
+// Type if the manager does not support the Content-Type.
+func (r Resource) requestBody(rw http.ResponseWriter, req *http.Request) (io.ReadCloser, bool) {
+	contentType := req.Header.Get("Content-Type")
+	if contentType == "" {
+		return req.Body, true
+	}
+	c, ok := r.codecs.Lookup(contentType)
+	if ok {
+		ok = false
+		for _, f := range r.Formats() {
+			if f == c.ContentType() {
+				ok = true
+			}
+		}
+	}
+	if !ok {
+		writeError(rw, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+	if c.ContentType() == codec.JSONType {
+		return req.Body, true
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	if err == nil {
+		data, err = r.toJSON(c, data)
+	}
+	if err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), true
+}
+
+// toJSON decodes data with the given codec and re-encodes it as json, typing
+// the values of xml bodies.
+func (r Resource) toJSON(c codec.Codec, data []byte) ([]byte, error) {
+	if c.ContentType() != codec.XMLType {
+		return codec.ToJSON(c, data)
+	}
+	var v interface{}
+	if err := c.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	v, err := xmlTyped(v, fieldKinds(r.manager.New()))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// xmlTyped converts the text of the top level fields of a decoded xml entity,
+// or of each entity of a list, to the given kinds of the entity's fields.
+// Empty text of fields other than strings is null, and a single element of a
+// slice field is a slice of one. Fields of other kinds are left as decoded.
+func xmlTyped(v interface{}, kinds map[string]reflect.Kind) (interface{}, error) {
+	switch t := v.(type) {
+	case []interface{}:
+		for i, e := range t {
+			var err error
+			if t[i], err = xmlTyped(e, kinds); err != nil {
+				return nil, err
+			}
+		}
+	case map[string]interface{}:
+		for field, value := range t {
+			kind := kinds[field]
+			if kind == reflect.Slice {
+				if _, ok := value.([]interface{}); !ok {
+					t[field] = []interface{}{value}
+				}
+				continue
+			}
+			text, ok := value.(string)
+			switch {
+			case !ok || kind == reflect.Invalid || kind == reflect.String:
+			case text == "":
+				t[field] = nil
+			case kind == reflect.Map || kind == reflect.Struct:
+			default:
+				converted, err := convert(text, kind)
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s: %v", field, err)
+				}
+				t[field] = converted
+			}
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,143 @@
+package goresource_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"goresource"
+	"goresource/codec"
+	"goresource/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// formatManager is a ResourceManager that opts into additional formats.
+type formatManager struct {
+	*mocks.MockResourceManager
+	formats []string
+}
+
+func (m formatManager) Formats() []string {
+	return m.formats
+}
+
+var _ = Describe("Resource content negotiation", func() {
+	var (
+		ctrl    *gomock.Controller
+		manager *mocks.MockResourceManager
+		router  *mux.Router
+		rw      *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		manager = mocks.NewMockResourceManager(ctrl)
+		router = mux.NewRouter().PathPrefix("/api").Subrouter()
+		rw = httptest.NewRecorder()
+		manager.EXPECT().GetName().AnyTimes().Return("test")
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	Context("with a manager supporting only json", func() {
+		BeforeEach(func() {
+			goresource.NewResource(manager, router)
+		})
+
+		It("responds with 406 if json is not acceptable.", func() {
+			req, _ := http.NewRequest("GET", "/api/test/fakeid", nil)
+			req.Header.Set("Accept", "application/xml")
+			router.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusNotAcceptable))
+			Expect(rw.Body.String()).To(Equal("Not Acceptable\n"))
+		})
+		It("responds with 415 for other content types.", func() {
+			req, _ := http.NewRequest("POST", "/api/test", strings.NewReader("name: foo"))
+			req.Header.Set("Content-Type", "application/yaml")
+			router.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusUnsupportedMediaType))
+			Expect(rw.Body.String()).To(Equal("Unsupported Media Type\n"))
+		})
+		It("responds with 415 for unknown content types.", func() {
+			req, _ := http.NewRequest("POST", "/api/test", strings.NewReader("a,b"))
+			req.Header.Set("Content-Type", "text/csv")
+			router.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusUnsupportedMediaType))
+		})
+	})
+
+	Context("with a manager opting into other formats", func() {
+		BeforeEach(func() {
+			goresource.NewResource(formatManager{manager, []string{codec.JSONType, codec.YAMLType, codec.XMLType}}, router)
+		})
+
+		It("encodes responses in the negotiated format.", func() {
+			req, _ := http.NewRequest("GET", "/api/test/fakeid", nil)
+			req.Header.Set("Accept", "application/yaml")
+			manager.EXPECT().GetEntity(gomock.Any(), "fakeid", gomock.Any()).Return(map[string]string{"name": "foo"}, nil)
+			router.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusOK))
+			Expect(rw.Header().Get("Content-Type")).To(Equal(codec.YAMLType))
+			Expect(rw.Body.String()).To(Equal("name: foo\n"))
+		})
+		It("sets the negotiated content type for head requests.", func() {
+			req, _ := http.NewRequest("HEAD", "/api/test/fakeid", nil)
+			req.Header.Set("Accept", "text/xml")
+			manager.EXPECT().GetEntity(gomock.Any(), "fakeid", gomock.Any()).Return(map[string]string{"name": "foo"}, nil)
+			router.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusOK))
+			Expect(rw.Header().Get("Content-Type")).To(Equal(codec.XMLType))
+		})
+		It("decodes request bodies as json for ParseJSON.", func() {
+			e := &mocks.MockEntity{}
+			req, _ := http.NewRequest("POST", "/api/test", strings.NewReader("name: foo\n"))
+			req.Header.Set("Content-Type", "application/yaml")
+			manager.EXPECT().ParseJSON(gomock.Any()).DoAndReturn(func(body interface{}) (goresource.Entity, error) {
+				data, _ := ioutil.ReadAll(body.(interface{ Read([]byte) (int, error) }))
+				Expect(string(data)).To(MatchJSON(`{"name":"foo"}`))
+				return e, nil
+			})
+			manager.EXPECT().CreateEntity(gomock.Any(), e, gomock.Any()).Return(map[string]string{"name": "foo"}, nil)
+			router.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusOK))
+			Expect(rw.Header().Get("Content-Type")).To(Equal(codec.JSONType))
+		})
+		It("types xml bodies like the manager's entities.", func() {
+			e := &mocks.MockEntity{}
+			req, _ := http.NewRequest("POST", "/api/test", strings.NewReader(
+				"<item><name>foo</name><pages>12</pages><tags>go</tags><id></id><extra>1</extra></item>"))
+			req.Header.Set("Content-Type", "application/xml")
+			manager.EXPECT().New().Return(&testBook{})
+			manager.EXPECT().ParseJSON(gomock.Any()).DoAndReturn(func(body interface{}) (goresource.Entity, error) {
+				data, _ := ioutil.ReadAll(body.(interface{ Read([]byte) (int, error) }))
+				Expect(string(data)).To(MatchJSON(`{"name":"foo","pages":12,"tags":["go"],"id":"","extra":"1"}`))
+				return e, nil
+			})
+			manager.EXPECT().CreateEntity(gomock.Any(), e, gomock.Any()).Return(map[string]string{"name": "foo"}, nil)
+			router.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusOK))
+		})
+		It("responds with 400 for xml values not of the field's type.", func() {
+			req, _ := http.NewRequest("POST", "/api/test", strings.NewReader("<item><pages>many</pages></item>"))
+			req.Header.Set("Content-Type", "application/xml")
+			manager.EXPECT().New().Return(&testBook{})
+			router.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusBadRequest))
+			Expect(rw.Body.String()).To(ContainSubstring("invalid pages"))
+		})
+		It("responds with 400 for undecodable bodies.", func() {
+			req, _ := http.NewRequest("POST", "/api/test", strings.NewReader("<item>"))
+			req.Header.Set("Content-Type", "application/xml")
+			router.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
@@ -0,0 +1,141 @@
+package goresource
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+	"github.com/rockstardevs/goresource/store"
+)
+
+// WithParent mounts the resource beneath the given parent resource, so for a
+// books resource with parent authors and field "authorId" it is served at
+// /authors/{authorId}/books and /authors/{authorId}/books/{id}. The field holds
+// the parent's id in child entities: lists are filtered by it, it is set on
+// created and updated entities, and entities with another parent are not
+// found. Requests respond 404 Not Found if the parent doesn't exist. The
+// resource must be created with the same router as its parent.
+func WithParent(parent *Resource, field string) Option {
+	return func(r *Resource) {
+		r.parent, r.parentField = parent, field
+	}
+}
+
+// parentID returns the id of the parent entity in the request path, if nested.
+func (r Resource) parentID(req *http.Request) (string, bool) {
+	if r.parent == nil {
+		return "", false
+	}
+	return mux.Vars(req)[r.parentField], true
+}
+
+// checkParents verifies, from the outermost, every ancestor in the request path
+// exists and is the parent of the next, responding 404 Not Found otherwise.
+func (r Resource) checkParents(rw http.ResponseWriter, req *http.Request) bool {
+	var (
+		vars     = mux.Vars(req)
+		children []*Resource
+	)
+	for child := &r; child.parent != nil; child = child.parent {
+		children = append([]*Resource{child}, children...)
+	}
+	for _, child := range children {
+		parent := child.parent
+		id := vars[child.parentField]
+		ctx, span := r.startSpan(req.Context(), "GetParent", AttrEntityID.String(id))
+		entity, err := parent.manager.GetEntity(ctx, id, nil)
+		endSpan(span, err)
+		if err == nil && parent.parent != nil && fieldValue(entity, parent.parentField) != vars[parent.parentField] {
+			err = store.ErrNotFound
+		}
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				err = fmt.Errorf("%s %s %w", parent.manager.GetName(), id, err)
+			}
+			writeError(rw, err.Error(), errorStatus(err))
+			return false
+		}
+	}
+	return true
+}
+
+// scopeQuery filters the given query to the children of the parent in the request path.
+func (r Resource) scopeQuery(req *http.Request, query url.Values) url.Values {
+	if id, ok := r.parentID(req); ok {
+		query.Set(r.parentField, id)
+	}
+	return query
+}
+
+// owned reports whether the given entity is a child of the parent in the
+// request path, always true for resources that aren't nested.
+func (r Resource) owned(req *http.Request, entity interface{}) bool {
+	id, ok := r.parentID(req)
+	return !ok || fieldValue(entity, r.parentField) == id
+}
+
+// checkOwned fetches the entity with the given id, responding 404 Not Found if
+// it isn't a child of the parent in the request path.
+func (r Resource) checkOwned(rw http.ResponseWriter, req *http.Request, id string) bool {
+	if r.parent == nil {
+		return true
+	}
+	entity, err := r.manager.GetEntity(req.Context(), id, nil)
+	if err == nil && !r.owned(req, entity) {
+		err = store.ErrNotFound
+	}
+	if err != nil {
+		writeError(rw, err.Error(), errorStatus(err))
+		return false
+	}
+	return true
+}
+
+// stampBody sets the parent field of a json request body to the id of the
+// parent in the request path.
+func (r Resource) stampBody(req *http.Request, body io.ReadCloser) (io.ReadCloser, error) {
+	id, ok := r.parentID(req)
+	if !ok {
+		return body, nil
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = setFields(data, map[string]interface{}{r.parentField: id}); err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// setFields sets the given fields of a json object.
+func setFields(data []byte, fields map[string]interface{}) ([]byte, error) {
+	var doc map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil || doc == nil {
+		return nil, fmt.Errorf("invalid json object")
+	}
+	for k, v := range fields {
+		doc[k] = v
+	}
+	return json.Marshal(doc)
+}
+
+// fieldValue returns the given json field of an entity as a string.
+func fieldValue(entity interface{}, field string) string {
+	switch v := toFields(entity)[field].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,154 @@
+package goresource_test
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"goresource"
+	"goresource/mocks"
+	"goresource/store"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Nested resources", func() {
+	var (
+		ctrl       *gomock.Controller
+		publishers *mocks.MockResourceManager
+		authors    *mocks.MockResourceManager
+		books      *mocks.MockResourceManager
+		router     *mux.Router
+		rw         *httptest.ResponseRecorder
+	)
+
+	serve := func(method, path, body string) {
+		router.ServeHTTP(rw, httptest.NewRequest(method, path, strings.NewReader(body)))
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		publishers = mocks.NewMockResourceManager(ctrl)
+		authors = mocks.NewMockResourceManager(ctrl)
+		books = mocks.NewMockResourceManager(ctrl)
+		publishers.EXPECT().GetName().Return("publishers").AnyTimes()
+		authors.EXPECT().GetName().Return("authors").AnyTimes()
+		books.EXPECT().GetName().Return("books").AnyTimes()
+		router = mux.NewRouter().PathPrefix("/api").Subrouter()
+		rw = httptest.NewRecorder()
+		p := goresource.NewResource(publishers, router)
+		a := goresource.NewResource(authors, router, goresource.WithParent(p, "publisherId"))
+		goresource.NewResource(books, router, goresource.WithParent(a, "authorId"))
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	// parents expects the publisher and author in the request path to be fetched.
+	parents := func() {
+		publishers.EXPECT().GetEntity(gomock.Any(), "p1", nil).Return(map[string]interface{}{"id": "p1"}, nil)
+		authors.EXPECT().GetEntity(gomock.Any(), "a1", nil).Return(map[string]interface{}{"id": "a1", "publisherId": "p1"}, nil)
+	}
+
+	It("serves children beneath their parent.", func() {
+		authors.EXPECT().GetEntity(gomock.Any(), "p1", nil).Times(0)
+		publishers.EXPECT().GetEntity(gomock.Any(), "p1", nil).Return(map[string]interface{}{"id": "p1"}, nil)
+		authors.EXPECT().ListEntities(gomock.Any(), gomock.Any()).Return([]interface{}{}, nil)
+		serve("GET", "/api/publishers/p1/authors", "")
+		Expect(rw.Code).To(Equal(http.StatusOK))
+	})
+
+	It("filters lists by the parent.", func() {
+		parents()
+		books.EXPECT().ListEntities(gomock.Any(), map[string][]string{"authorId": {"a1"}, "name": {"x"}}).Return([]interface{}{"book"}, nil)
+		serve("GET", "/api/publishers/p1/authors/a1/books?name=x&authorId=a2", "")
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Body.String()).To(Equal(`["book"]`))
+	})
+
+	It("responds not found for missing parents.", func() {
+		publishers.EXPECT().GetEntity(gomock.Any(), "p1", nil).Return(map[string]interface{}{"id": "p1"}, nil)
+		authors.EXPECT().GetEntity(gomock.Any(), "a1", nil).Return(nil, store.ErrNotFound)
+		serve("GET", "/api/publishers/p1/authors/a1/books", "")
+		Expect(rw.Code).To(Equal(http.StatusNotFound))
+		Expect(rw.Body.String()).To(Equal("authors a1 not found\n"))
+	})
+
+	It("responds not found for parents of another grandparent.", func() {
+		publishers.EXPECT().GetEntity(gomock.Any(), "p2", nil).Return(map[string]interface{}{"id": "p2"}, nil)
+		authors.EXPECT().GetEntity(gomock.Any(), "a1", nil).Return(map[string]interface{}{"id": "a1", "publisherId": "p1"}, nil)
+		serve("GET", "/api/publishers/p2/authors/a1/books/b1", "")
+		Expect(rw.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("responds with an error if fetching a parent fails.", func() {
+		publishers.EXPECT().GetEntity(gomock.Any(), "p1", nil).Return(nil, fmt.Errorf("Test Error"))
+		serve("GET", "/api/publishers/p1/authors/a1/books", "")
+		Expect(rw.Code).To(Equal(http.StatusInternalServerError))
+	})
+
+	It("responds not found for children of another parent.", func() {
+		parents()
+		books.EXPECT().GetEntity(gomock.Any(), "b1", gomock.Any()).Return(map[string]interface{}{"id": "b1", "authorId": "a2"}, nil)
+		serve("GET", "/api/publishers/p1/authors/a1/books/b1", "")
+		Expect(rw.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("sets the parent on created entities.", func() {
+		parents()
+		e := &mocks.MockEntity{}
+		books.EXPECT().ParseJSON(gomock.Any()).DoAndReturn(func(body io.ReadCloser) (goresource.Entity, error) {
+			data, _ := ioutil.ReadAll(body)
+			Expect(data).To(MatchJSON(`{"name": "x", "pages": 12345678901234567, "authorId": "a1"}`))
+			return e, nil
+		})
+		books.EXPECT().CreateEntity(gomock.Any(), e, gomock.Any()).Return("created", nil)
+		serve("POST", "/api/publishers/p1/authors/a1/books", `{"name": "x", "pages": 12345678901234567, "authorId": "a2"}`)
+		Expect(rw.Code).To(Equal(http.StatusOK))
+	})
+
+	It("rejects bodies that aren't objects.", func() {
+		parents()
+		serve("POST", "/api/publishers/p1/authors/a1/books", `[]`)
+		Expect(rw.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("only updates children of the parent.", func() {
+		parents()
+		books.EXPECT().ParseJSON(gomock.Any()).Return(&mocks.MockEntity{}, nil)
+		books.EXPECT().GetEntity(gomock.Any(), "b1", nil).Return(map[string]interface{}{"id": "b1", "authorId": "a2"}, nil)
+		serve("PUT", "/api/publishers/p1/authors/a1/books/b1", `{}`)
+		Expect(rw.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("only deletes children of the parent.", func() {
+		parents()
+		books.EXPECT().GetEntity(gomock.Any(), "b1", nil).Return(map[string]interface{}{"id": "b1", "authorId": "a1"}, nil)
+		books.EXPECT().DeleteEntity(gomock.Any(), "b1", gomock.Any()).Return(nil)
+		serve("DELETE", "/api/publishers/p1/authors/a1/books/b1", "")
+		Expect(rw.Code).To(Equal(http.StatusNoContent))
+	})
+
+	It("sets the parent on imported entities.", func() {
+		parents()
+		books.EXPECT().ParseJSON(gomock.Any()).DoAndReturn(func(body io.ReadCloser) (goresource.Entity, error) {
+			data, _ := ioutil.ReadAll(body)
+			Expect(data).To(MatchJSON(`{"name": "x", "authorId": "a1"}`))
+			return &mocks.MockEntity{}, nil
+		})
+		books.EXPECT().CreateEntity(gomock.Any(), gomock.Any(), gomock.Any()).Return("created", nil)
+		req := httptest.NewRequest("POST", "/api/publishers/p1/authors/a1/books/import", strings.NewReader(`{"name": "x"}`))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		router.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Body.String()).To(ContainSubstring(`"inserted":1`))
+	})
+})
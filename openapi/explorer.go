@@ -0,0 +1,31 @@
+package openapi
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed explorer
+var explorerFiles embed.FS
+
+var explorerPage = template.Must(template.ParseFS(explorerFiles, "explorer/index.html"))
+
+// Explorer serves a self contained page for browsing the resources described by
+// the document at specURL and sending requests to them. Its assets are
+// referenced relative to the page, so mount it under a path prefix, for example
+//
+//	router.PathPrefix("/explorer/").Handler(http.StripPrefix("/explorer", openapi.Explorer("/openapi.json")))
+func Explorer(specURL string) http.Handler {
+	assets, _ := fs.Sub(explorerFiles, "explorer")
+	files := http.FileServer(http.FS(assets))
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" && req.URL.Path != "" && req.URL.Path != "/index.html" {
+			files.ServeHTTP(rw, req)
+			return
+		}
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		explorerPage.Execute(rw, struct{ Spec string }{specURL})
+	})
+}
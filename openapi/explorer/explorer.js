@@ -0,0 +1,191 @@
+// Renders the resources described by an OpenAPI document, with forms for
+// sending requests to them. The document url is set on the body by the server.
+(function () {
+  "use strict";
+
+  var spec;
+
+  function el(tag, attrs, children) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (k) {
+      if (k === "text") {
+        node.textContent = attrs[k];
+      } else {
+        node.setAttribute(k, attrs[k]);
+      }
+    });
+    (children || []).forEach(function (c) { node.appendChild(c); });
+    return node;
+  }
+
+  function resolve(schema) {
+    if (schema && schema.$ref) {
+      return spec.components.schemas[schema.$ref.split("/").pop()] || {};
+    }
+    return schema || {};
+  }
+
+  function typeName(schema) {
+    if (schema.$ref) {
+      return schema.$ref.split("/").pop();
+    }
+    var type = Array.isArray(schema.type) ? schema.type.join(" | ") : (schema.type || "any");
+    if (schema.items) {
+      return type + " of " + typeName(schema.items);
+    }
+    return schema.format ? type + " (" + schema.format + ")" : type;
+  }
+
+  // resources groups the documented paths by their tag, one per resource.
+  function resources() {
+    var result = {};
+    Object.keys(spec.paths).sort().forEach(function (path) {
+      var item = spec.paths[path];
+      ["get", "head", "post", "put", "delete"].forEach(function (method) {
+        var op = item[method];
+        if (!op) {
+          return;
+        }
+        var name = (op.tags || ["other"])[0];
+        result[name] = result[name] || [];
+        result[name].push({ path: path, method: method, op: op, params: (item.parameters || []).concat(op.parameters || []) });
+      });
+    });
+    return result;
+  }
+
+  function fieldsTable(operations) {
+    var list = operations.filter(function (o) { return o.op.operationId && /\.get$/.test(o.op.operationId); })[0];
+    if (!list) {
+      return el("p", { "class": "hint", text: "No fields documented." });
+    }
+    var content = list.op.responses["200"].content || {};
+    var media = content["application/json"] || content[Object.keys(content)[0]] || {};
+    var schema = resolve(media.schema);
+    var rows = Object.keys(schema.properties || {}).map(function (name) {
+      return el("tr", {}, [el("td", {}, [el("code", { text: name })]), el("td", { text: typeName(schema.properties[name]) })]);
+    });
+    return el("table", {}, [el("tr", {}, [el("th", { text: "Field" }), el("th", { text: "Type" })])].concat(rows));
+  }
+
+  function operationForm(o) {
+    var inputs = o.params.map(function (p) {
+      var input = el("input", { name: p.name, "data-in": p.in, placeholder: p.in === "path" ? "required" : "" });
+      return el("label", { title: p.description || "" }, [el("span", {}, [el("code", { text: p.name })]), input]);
+    });
+    var body = o.op.requestBody;
+    var types = body ? Object.keys(body.content) : [];
+    var contentType = el("select", { name: "content-type" }, types.map(function (t) { return el("option", { text: t }); }));
+    var accept = el("select", { name: "accept" }, acceptTypes(o).map(function (t) { return el("option", { text: t }); }));
+    var fields = inputs.concat([el("label", {}, [el("span", { text: "Accept" }), accept])]);
+    if (body) {
+      fields.push(el("label", {}, [el("span", { text: "Content-Type" }), contentType]));
+      fields.push(el("textarea", { name: "body", placeholder: "Request body" }));
+    }
+    var output = el("div");
+    var form = el("form", {}, fields.concat([el("button", { type: "submit", text: "Send" }), output]));
+    form.addEventListener("submit", function (e) {
+      e.preventDefault();
+      send(o, form, output);
+    });
+    return form;
+  }
+
+  function acceptTypes(o) {
+    var types = {};
+    Object.keys(o.op.responses).forEach(function (status) {
+      Object.keys(o.op.responses[status].content || {}).forEach(function (t) { types[t] = true; });
+    });
+    var result = Object.keys(types);
+    return result.length ? result : ["*/*"];
+  }
+
+  function send(o, form, output) {
+    var path = o.path, query = [];
+    Array.prototype.forEach.call(form.querySelectorAll("input"), function (input) {
+      if (input.getAttribute("data-in") === "path") {
+        path = path.replace("{" + input.name + "}", encodeURIComponent(input.value));
+      } else if (input.value !== "") {
+        input.value.split(",").forEach(function (v) {
+          query.push(encodeURIComponent(input.name) + "=" + encodeURIComponent(v.trim()));
+        });
+      }
+    });
+    var server = (spec.servers || [])[0];
+    var url = (server ? server.url.replace(/\/$/, "") : "") + path + (query.length ? "?" + query.join("&") : "");
+    var init = { method: o.method.toUpperCase(), headers: { Accept: form.elements.accept.value } };
+    if (form.elements.body) {
+      init.headers["Content-Type"] = form.elements["content-type"].value;
+      init.body = form.elements.body.value;
+    }
+    output.textContent = "";
+    output.appendChild(el("p", { "class": "status", text: init.method + " " + url }));
+    fetch(url, init).then(function (resp) {
+      return resp.text().then(function (text) {
+        output.appendChild(el("p", { "class": resp.ok ? "status" : "status error", text: resp.status + " " + resp.statusText }));
+        output.appendChild(el("pre", { text: pretty(text, resp.headers.get("Content-Type")) }));
+      });
+    }).catch(function (err) {
+      output.appendChild(el("p", { "class": "error", text: err.message }));
+    });
+  }
+
+  function pretty(text, contentType) {
+    if (contentType && contentType.indexOf("application/json") === 0) {
+      try {
+        return JSON.stringify(JSON.parse(text), null, 2);
+      } catch (e) {
+        return text;
+      }
+    }
+    return text;
+  }
+
+  function show(name, operations, link) {
+    Array.prototype.forEach.call(document.querySelectorAll("nav a"), function (a) { a.className = ""; });
+    link.className = "selected";
+    var main = document.getElementById("resource");
+    main.textContent = "";
+    main.appendChild(el("h2", { text: name }));
+    main.appendChild(el("h3", { text: "Fields" }));
+    main.appendChild(fieldsTable(operations));
+    main.appendChild(el("h3", { text: "Operations" }));
+    operations.forEach(function (o) {
+      main.appendChild(el("details", { "class": "operation" }, [
+        el("summary", {}, [
+          el("span", { "class": "method " + o.method, text: o.method.toUpperCase() }),
+          el("code", { text: o.path }),
+          el("span", { "class": "hint", text: " " + (o.op.summary || "") })
+        ]),
+        operationForm(o)
+      ]));
+    });
+  }
+
+  function render() {
+    document.getElementById("title").textContent = spec.info.title || "API Explorer";
+    document.getElementById("version").textContent = spec.info.version || "";
+    var nav = document.getElementById("resources");
+    var all = resources();
+    Object.keys(all).sort().forEach(function (name) {
+      var link = el("a", { href: "#" + name, text: name });
+      link.addEventListener("click", function () { show(name, all[name], link); });
+      nav.appendChild(link);
+      if (location.hash === "#" + name) {
+        show(name, all[name], link);
+      }
+    });
+  }
+
+  fetch(document.body.getAttribute("data-spec")).then(function (resp) {
+    if (!resp.ok) {
+      throw new Error("fetching spec: " + resp.status + " " + resp.statusText);
+    }
+    return resp.json();
+  }).then(function (doc) {
+    spec = doc;
+    render();
+  }).catch(function (err) {
+    document.getElementById("resource").appendChild(el("p", { "class": "error", text: err.message }));
+  });
+})();
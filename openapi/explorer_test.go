@@ -0,0 +1,41 @@
+package openapi_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"goresource/openapi"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Explorer", func() {
+	var handler http.Handler
+
+	BeforeEach(func() {
+		handler = http.StripPrefix("/explorer", openapi.Explorer("/openapi.json?v=\"1\""))
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, path, nil))
+		return rw
+	}
+
+	It("serves the page with the spec url.", func() {
+		rw := get("/explorer/")
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Header().Get("Content-Type")).To(Equal("text/html; charset=utf-8"))
+		Expect(rw.Body.String()).To(ContainSubstring(`data-spec="/openapi.json?v=&#34;1&#34;"`))
+		Expect(rw.Body.String()).To(ContainSubstring(`<script src="explorer.js">`))
+	})
+
+	It("serves embedded assets.", func() {
+		rw := get("/explorer/explorer.js")
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Body.String()).To(ContainSubstring("data-spec"))
+		Expect(get("/explorer/explorer.css").Code).To(Equal(http.StatusOK))
+		Expect(get("/explorer/missing.js").Code).To(Equal(http.StatusNotFound))
+	})
+})
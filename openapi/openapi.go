@@ -0,0 +1,377 @@
+// Package openapi generates OpenAPI 3.1 documents describing goresource resources.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/rockstardevs/goresource"
+	"github.com/rockstardevs/goresource/codec"
+	"github.com/rockstardevs/goresource/util"
+)
+
+// Version is the OpenAPI version of generated documents.
+const Version = "3.1.0"
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+// Info describes the api.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server is a server the api is served from.
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem holds the operations available on a single path.
+type PathItem struct {
+	Get        *Operation  `json:"get,omitempty"`
+	Put        *Operation  `json:"put,omitempty"`
+	Post       *Operation  `json:"post,omitempty"`
+	Delete     *Operation  `json:"delete,omitempty"`
+	Head       *Operation  `json:"head,omitempty"`
+	Parameters []Parameter `json:"parameters,omitempty"`
+}
+
+// Operation describes a single api operation on a path.
+type Operation struct {
+	Tags        []string             `json:"tags,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	OperationID string               `json:"operationId,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter describes a single operation parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+// RequestBody describes an operation's request body.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// MediaType describes a body in a single media type.
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Response describes a single response of an operation.
+type Response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+	Headers     map[string]*Header   `json:"headers,omitempty"`
+}
+
+// Header describes a response header.
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+// Components holds reusable schemas and responses.
+type Components struct {
+	Schemas   map[string]*Schema   `json:"schemas,omitempty"`
+	Responses map[string]*Response `json:"responses,omitempty"`
+}
+
+// errorResponses are the error responses resources may respond with, all with
+// the error message as a plain text body.
+var errorResponses = map[string]string{
+	"400": "Invalid request, such as a malformed body or missing id.",
+	"404": "No entity with the given id exists.",
+	"406": "None of the acceptable media types are supported.",
+	"409": "The entity violates a unique index.",
+	"415": "The request body's media type is not supported.",
+	"429": "Too many requests, retry after the seconds in the Retry-After header.",
+	"500": "The request failed.",
+}
+
+// Build returns a document describing the given resources.
+func Build(info Info, resources []*goresource.Resource) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			Schemas:   make(map[string]*Schema),
+			Responses: make(map[string]*Response),
+		},
+	}
+	for status, description := range errorResponses {
+		doc.Components.Responses[status] = &Response{
+			Description: description,
+			Content:     map[string]MediaType{"text/plain": {Schema: &Schema{Type: "string"}}},
+		}
+	}
+	s := &schemas{components: doc.Components.Schemas}
+	for _, r := range resources {
+		describe(doc, s, r)
+	}
+	return doc
+}
+
+// describe adds the paths served by the given resource to doc.
+func describe(doc *Document, s *schemas, r *goresource.Resource) {
+	var (
+		name     = r.Name()
+		tags     = []string{name}
+		typ      = reflect.TypeOf(r.Manager().New())
+		entity   = s.SchemaOf(typ)
+		list     = &Schema{Type: "array", Items: entity}
+		formats  = r.Formats()
+		idParam  = Parameter{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}}
+		parents  = pathParameters(r.Path())
+		filters  = filterParameters(typ)
+		itemResp = content(formats, entity)
+		listResp = content(formats, list)
+	)
+	if _, ok := r.Manager().(goresource.StreamManager); ok {
+		listResp[codec.NDJSONType] = MediaType{Schema: entity}
+		listResp[codec.CSVType] = MediaType{Schema: &Schema{Type: "string"}}
+	}
+	body := &RequestBody{Required: true, Content: content(formats, entity)}
+	doc.Paths[r.Path()] = &PathItem{
+		Parameters: parents,
+		Get: &Operation{
+			Tags: tags, OperationID: name + ".list", Summary: "Lists " + name + " matching the given filters.",
+			Parameters: filters,
+			Responses:  responses("200", "The matching entities.", listResp, "406", "500"),
+		},
+		Head: &Operation{
+			Tags: tags, OperationID: name + ".headList", Summary: "Checks listing " + name + ".",
+			Parameters: filters,
+			Responses:  responses("200", "The entities can be listed.", nil, "406", "500"),
+		},
+		Post: &Operation{
+			Tags: tags, OperationID: name + ".create",
+			Summary:     "Creates an entity, or updates it if the body has an id.",
+			RequestBody: body,
+			Responses:   responses("200", "The created or updated entity.", itemResp, "400", "406", "409", "415", "500"),
+		},
+		Put: &Operation{
+			Tags: tags, OperationID: name + ".put",
+			Summary:     "Creates an entity, or updates it if the body has an id.",
+			RequestBody: body,
+			Responses:   responses("200", "The created or updated entity.", itemResp, "400", "406", "409", "415", "500"),
+		},
+	}
+	doc.Paths[r.Path()+"/{id}"] = &PathItem{
+		Parameters: append(append([]Parameter{}, parents...), idParam),
+		Get: &Operation{
+			Tags: tags, OperationID: name + ".get", Summary: "Fetches the entity with the given id.",
+			Responses: responses("200", "The entity.", itemResp, "404", "406", "500"),
+		},
+		Head: &Operation{
+			Tags: tags, OperationID: name + ".head", Summary: "Checks the entity with the given id exists.",
+			Responses: responses("200", "The entity exists.", nil, "404", "406", "500"),
+		},
+		Put: &Operation{
+			Tags: tags, OperationID: name + ".update", Summary: "Updates the entity with the given id.",
+			RequestBody: body,
+			Responses:   responses("200", "The updated entity.", itemResp, "400", "404", "406", "409", "415", "500"),
+		},
+		Delete: &Operation{
+			Tags: tags, OperationID: name + ".delete", Summary: "Deletes the entity with the given id.",
+			Responses: responses("204", "The entity was deleted.", nil, "400", "404", "500"),
+		},
+	}
+	doc.Paths[r.Path()+"/import"] = &PathItem{
+		Parameters: parents,
+		Post: &Operation{
+			Tags: tags, OperationID: name + ".import", Summary: "Imports " + name + " in bulk.",
+			Parameters: []Parameter{
+				{Name: "batch", In: "query", Description: "Number of entities inserted at once.", Schema: &Schema{Type: "integer"}},
+			},
+			RequestBody: &RequestBody{Required: true, Content: map[string]MediaType{
+				codec.NDJSONType: {Schema: entity},
+				codec.CSVType:    {Schema: &Schema{Type: "string", Description: "A header row naming the fields, map.<header>=<field> query parameters rename headers."}},
+			}},
+			Responses: responses("200", "A report of the imported rows.",
+				content(formats, s.SchemaOf(reflect.TypeOf(goresource.ImportReport{}))), "400", "406", "415"),
+		},
+	}
+	notModified := &Response{Description: "The cached copy with the ETag in If-None-Match, or modified before If-Modified-Since, is current."}
+	doc.Paths[r.Path()].Get.Responses["304"] = notModified
+	doc.Paths[r.Path()+"/{id}"].Get.Responses["304"] = notModified
+	if r.IdempotencyStore() != nil {
+		describeIdempotency(doc.Paths[r.Path()].Post, doc.Paths[r.Path()+"/import"].Post)
+	}
+	if _, ok := r.Manager().(goresource.AggregateManager); ok {
+		describeAggregates(doc, s, r, parents, filters)
+	}
+	if _, ok := r.RateLimits(); ok {
+		describeRateLimits(doc, r)
+	}
+	if r.ChangeFeed() != nil {
+		doc.Paths[r.Path()+"/events"] = &PathItem{
+			Parameters: parents,
+			Get: &Operation{
+				Tags: tags, OperationID: name + ".events",
+				Summary: "Streams changes to " + name + " matching the given filters as server-sent events.",
+				Parameters: append([]Parameter{
+					{Name: "Last-Event-ID", In: "header", Description: "Resumes after the event with the given id.",
+						Schema: &Schema{Type: "integer"}},
+					{Name: "lastEventId", In: "query", Description: "Resumes after the event with the given id.",
+						Schema: &Schema{Type: "integer"}},
+				}, filters...),
+				Responses: responses("200", "Events named create, update or delete with a change event as data, or reset if resuming failed.",
+					map[string]MediaType{"text/event-stream": {Schema: s.SchemaOf(reflect.TypeOf(goresource.ChangeEvent{}))}}, "400", "500"),
+			},
+		}
+		doc.Paths[r.Path()+"/ws"] = &PathItem{
+			Parameters: parents,
+			Get: &Operation{
+				Tags: tags, OperationID: name + ".ws",
+				Summary: "Upgrades to a websocket exchanging subscription messages for changes to " + name + ".",
+				Responses: responses("101", "Switching to the websocket protocol.",
+					map[string]MediaType{"application/json": {Schema: s.SchemaOf(reflect.TypeOf(goresource.SubscriptionMessage{}))}}, "400"),
+			},
+		}
+	}
+}
+
+// describeIdempotency adds the Idempotency-Key header and its responses to
+// the given operations.
+func describeIdempotency(ops ...*Operation) {
+	key := Parameter{Name: goresource.IdempotencyKeyHeader, In: "header",
+		Description: "Replays the response to an earlier request with the same key instead of repeating it.",
+		Schema:      &Schema{Type: "string"}}
+	for _, op := range ops {
+		op.Parameters = append(op.Parameters, key)
+		op.Responses["409"] = &Response{Description: "A request with the same Idempotency-Key is in progress, or the entity violates a unique index."}
+		op.Responses["422"] = &Response{Description: "The Idempotency-Key was used for a different request."}
+	}
+}
+
+// describeRateLimits adds the 429 response to every operation of the given
+// rate limited resource.
+func describeRateLimits(doc *Document, r *goresource.Resource) {
+	for path, item := range doc.Paths {
+		if path != r.Path() && !strings.HasPrefix(path, r.Path()+"/") {
+			continue
+		}
+		for _, op := range []*Operation{item.Get, item.Head, item.Post, item.Put, item.Delete} {
+			if op != nil {
+				op.Responses["429"] = &Response{Ref: "#/components/responses/429"}
+			}
+		}
+	}
+}
+
+// describeAggregates adds the count and aggregate paths of resources with an
+// AggregateManager to the given document.
+func describeAggregates(doc *Document, s *schemas, r *goresource.Resource, parents, filters []Parameter) {
+	var (
+		name    = r.Name()
+		tags    = []string{name}
+		formats = r.Formats()
+		total   = map[string]*Header{goresource.TotalCountHeader: {
+			Description: "The number of matching entities.", Schema: &Schema{Type: "integer"}}}
+		count = responses("200", "The number of matching entities.",
+			content(formats, s.SchemaOf(reflect.TypeOf(goresource.CountResult{}))), "400", "406", "500")
+		rows = &Schema{Type: "array", Items: &Schema{Type: "object",
+			Description: "The values of the group fields and each metric, named <op>_<field> or count."}}
+	)
+	count["200"].Headers = total
+	doc.Paths[r.Path()].Head.Responses["200"].Headers = total
+	doc.Paths[r.Path()+"/count"] = &PathItem{
+		Parameters: parents,
+		Get: &Operation{
+			Tags: tags, OperationID: name + ".count", Summary: "Counts " + name + " matching the given filters.",
+			Parameters: filters,
+			Responses:  count,
+		},
+	}
+	doc.Paths[r.Path()+"/aggregate"] = &PathItem{
+		Parameters: parents,
+		Get: &Operation{
+			Tags: tags, OperationID: name + ".aggregate",
+			Summary: "Computes metrics over groups of " + name + " matching the given filters.",
+			Parameters: append([]Parameter{
+				{Name: goresource.GroupParam, In: "query", Description: "Fields to group by, separated by commas.",
+					Schema: &Schema{Type: "string"}},
+				{Name: goresource.MetricParam, In: "query",
+					Description: "Metrics to compute as <op>:<field>, with op one of sum, avg, min or max, or count. Defaults to count.",
+					Schema:      &Schema{Type: "array", Items: &Schema{Type: "string"}}},
+			}, filters...),
+			Responses: responses("200", "A row per group.", content(formats, rows), "400", "406", "500"),
+		},
+	}
+}
+
+// pathParameters returns the variables in the given path template, the ids of
+// the parents of nested resources.
+func pathParameters(path string) []Parameter {
+	var params []Parameter
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			name := strings.SplitN(part[1:len(part)-1], ":", 2)[0]
+			params = append(params, Parameter{Name: name, In: "path", Required: true,
+				Description: "Id of the parent entity.", Schema: &Schema{Type: "string"}})
+		}
+	}
+	return params
+}
+
+// filterParameters returns the list filters supported for the given entity type.
+func filterParameters(typ reflect.Type) []Parameter {
+	var params []Parameter
+	for _, f := range Fields(typ) {
+		params = append(params,
+			Parameter{Name: f, In: "query", Description: "Matches " + f + " equal to the value, or to any of the values if repeated.",
+				Schema: &Schema{Type: "array", Items: &Schema{Type: "string"}}},
+			Parameter{Name: f + "~", In: "query", Description: "Matches " + f + " against a case insensitive regular expression.",
+				Schema: &Schema{Type: "string"}})
+	}
+	return params
+}
+
+// content returns the given schema in each of the given media types.
+func content(formats []string, schema *Schema) map[string]MediaType {
+	result := make(map[string]MediaType)
+	for _, f := range formats {
+		result[f] = MediaType{Schema: schema}
+	}
+	return result
+}
+
+// responses returns a successful response with the given status and content,
+// and references to the given error responses.
+func responses(status, description string, body map[string]MediaType, errors ...string) map[string]*Response {
+	result := map[string]*Response{status: {Description: description, Content: body}}
+	for _, e := range errors {
+		result[e] = &Response{Ref: "#/components/responses/" + e}
+	}
+	return result
+}
+
+// Handler serves a document describing every registered resource as json.
+// Mount it at any path, for example router.Handle("/openapi.json", openapi.Handler(info)).
+func Handler(info Info, servers ...Server) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		doc := Build(info, goresource.Registered())
+		doc.Servers = servers
+		util.WriteJSON(doc, rw)
+	})
+}
@@ -0,0 +1,230 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"time"
+
+	"goresource"
+	"goresource/codec"
+	"goresource/mocks"
+	"goresource/openapi"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type book struct {
+	ID    string  `json:"id"`
+	Title string  `json:"title"`
+	Pages int     `json:"pages"`
+	Price float64 `json:"price"`
+}
+
+func (b *book) HasId() bool   { return b.ID != "" }
+func (b *book) GetId() string { return b.ID }
+
+// bookSchema is the component name of book's schema.
+var bookSchema = openapi.SchemaName(reflect.TypeOf(book{}))
+
+// entityManager is a DefaultManager creating entities of a fixed type.
+type entityManager struct {
+	goresource.DefaultManager
+	entity goresource.Entity
+}
+
+func (m entityManager) New() goresource.Entity {
+	return m.entity
+}
+
+func (m entityManager) ParseJSON(data io.ReadCloser) (goresource.Entity, error) {
+	return m.entity, json.NewDecoder(data).Decode(m.entity)
+}
+
+// build returns a document for a single resource of the given entity.
+func build(entity goresource.Entity) *openapi.Document {
+	m := entityManager{goresource.NewDefaultManager("authors", nil), entity}
+	r := goresource.NewResource(m, mux.NewRouter().PathPrefix("/api").Subrouter())
+	return openapi.Build(openapi.Info{Title: "test", Version: "1"}, []*goresource.Resource{r})
+}
+
+var _ = Describe("OpenAPI", func() {
+	var (
+		ctrl    *gomock.Controller
+		manager *mocks.MockResourceManager
+		router  *mux.Router
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		manager = mocks.NewMockResourceManager(ctrl)
+		manager.EXPECT().GetName().Return("books").AnyTimes()
+		manager.EXPECT().New().Return(&book{}).AnyTimes()
+		router = mux.NewRouter()
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	Describe("Build", func() {
+		It("describes the paths served by each resource.", func() {
+			r := goresource.NewResource(manager, router.PathPrefix("/v1").Subrouter())
+			doc := openapi.Build(openapi.Info{Title: "test", Version: "1"}, []*goresource.Resource{r})
+			Expect(doc.OpenAPI).To(Equal("3.1.0"))
+			Expect(doc.Info).To(Equal(openapi.Info{Title: "test", Version: "1"}))
+			Expect(doc.Paths).To(HaveLen(3))
+
+			list := doc.Paths["/v1/books"]
+			Expect(list).NotTo(BeNil())
+			Expect(list.Get.OperationID).To(Equal("books.list"))
+			Expect(list.Get.Responses["200"].Content).To(Equal(map[string]openapi.MediaType{
+				codec.JSONType: {Schema: &openapi.Schema{Type: "array", Items: &openapi.Schema{Ref: "#/components/schemas/" + bookSchema}}},
+			}))
+			Expect(list.Get.Responses["500"]).To(Equal(&openapi.Response{Ref: "#/components/responses/500"}))
+			Expect(list.Get.Responses).To(HaveKey("304"))
+			Expect(list.Post.RequestBody.Content).To(HaveKey(codec.JSONType))
+			Expect(list.Delete).To(BeNil())
+
+			item := doc.Paths["/v1/books/{id}"]
+			Expect(item.Parameters).To(HaveLen(1))
+			Expect(item.Parameters[0].In).To(Equal("path"))
+			Expect(item.Delete.Responses).To(HaveKey("204"))
+			Expect(item.Get.Responses).To(HaveKey("304"))
+			Expect(item.Post).To(BeNil())
+			Expect(item.Get.Responses).To(HaveKey("404"))
+			Expect(item.Delete.Responses).To(HaveKey("404"))
+			Expect(item.Put.Responses).To(HaveKey("409"))
+			Expect(list.Post.Responses).To(HaveKey("409"))
+			Expect(list.Get.Responses).NotTo(HaveKey("404"))
+
+			imports := doc.Paths["/v1/books/import"]
+			Expect(imports.Post.RequestBody.Content).To(HaveKey(codec.CSVType))
+			Expect(imports.Post.RequestBody.Content).To(HaveKey(codec.NDJSONType))
+			Expect(doc.Components.Schemas).To(HaveKey(openapi.SchemaName(reflect.TypeOf(goresource.ImportReport{}))))
+			Expect(doc.Components.Responses).To(HaveKey("415"))
+		})
+
+		It("documents entities of managers returning nil with the empty schema.", func() {
+			doc := build(nil)
+			Expect(doc.Paths["/api/authors/{id}"].Get.Responses["200"].Content[codec.JSONType].Schema).To(Equal(&openapi.Schema{}))
+			Expect(doc.Paths["/api/authors"].Get.Parameters).To(BeEmpty())
+		})
+
+		It("documents a filter per field.", func() {
+			r := goresource.NewResource(manager, router)
+			doc := openapi.Build(openapi.Info{}, []*goresource.Resource{r})
+			var names []string
+			for _, p := range doc.Paths["/books"].Get.Parameters {
+				Expect(p.In).To(Equal("query"))
+				names = append(names, p.Name)
+			}
+			Expect(names).To(Equal([]string{"id", "id~", "title", "title~", "pages", "pages~", "price", "price~"}))
+		})
+
+		It("documents streamed list formats for stream managers.", func() {
+			r := goresource.NewResource(manager, router)
+			doc := openapi.Build(openapi.Info{}, []*goresource.Resource{r})
+			Expect(doc.Paths["/books"].Get.Responses["200"].Content).NotTo(HaveKey(codec.NDJSONType))
+
+			doc = build(&book{})
+			Expect(doc.Paths["/api/authors"].Get.Responses["200"].Content).To(HaveKey(codec.NDJSONType))
+			Expect(doc.Paths["/api/authors"].Get.Responses["200"].Content).To(HaveKey(codec.CSVType))
+		})
+
+		It("documents the events and websocket paths of resources with a change feed.", func() {
+			r := goresource.NewResource(manager, router)
+			doc := openapi.Build(openapi.Info{}, []*goresource.Resource{r})
+			Expect(doc.Paths).NotTo(HaveKey("/books/events"))
+			Expect(doc.Paths).NotTo(HaveKey("/books/ws"))
+
+			r = goresource.NewResource(manager, mux.NewRouter(), goresource.WithChangeFeed(goresource.NewChangeFeed(0)))
+			doc = openapi.Build(openapi.Info{}, []*goresource.Resource{r})
+			events := doc.Paths["/books/events"]
+			Expect(events.Get.OperationID).To(Equal("books.events"))
+			Expect(events.Get.Responses["200"].Content).To(HaveKey("text/event-stream"))
+			Expect(doc.Paths["/books/ws"].Get.Responses).To(HaveKey("101"))
+		})
+
+		It("documents the Idempotency-Key header of idempotent resources.", func() {
+			r := goresource.NewResource(manager, router)
+			doc := openapi.Build(openapi.Info{}, []*goresource.Resource{r})
+			Expect(doc.Paths["/books"].Post.Parameters).To(BeEmpty())
+			Expect(doc.Paths["/books"].Post.Responses).NotTo(HaveKey("422"))
+
+			r = goresource.NewResource(manager, mux.NewRouter(), goresource.WithIdempotency(goresource.NewMemoryIdempotencyStore(0)))
+			doc = openapi.Build(openapi.Info{}, []*goresource.Resource{r})
+			for _, op := range []*openapi.Operation{doc.Paths["/books"].Post, doc.Paths["/books/import"].Post} {
+				Expect(op.Parameters[len(op.Parameters)-1].Name).To(Equal(goresource.IdempotencyKeyHeader))
+				Expect(op.Parameters[len(op.Parameters)-1].In).To(Equal("header"))
+				Expect(op.Responses).To(HaveKey("409"))
+				Expect(op.Responses).To(HaveKey("422"))
+			}
+		})
+
+		It("documents the 429 response of rate limited resources.", func() {
+			r := goresource.NewResource(manager, router)
+			doc := openapi.Build(openapi.Info{}, []*goresource.Resource{r})
+			Expect(doc.Paths["/books"].Get.Responses).NotTo(HaveKey("429"))
+
+			r = goresource.NewResource(manager, mux.NewRouter(), goresource.WithRateLimit(goresource.NewMemoryRateLimiter(),
+				goresource.RateLimitPolicy{Limit: goresource.RateLimit{Requests: 1, Period: time.Second}}))
+			doc = openapi.Build(openapi.Info{}, []*goresource.Resource{r})
+			Expect(doc.Paths["/books"].Get.Responses).To(HaveKey("429"))
+			Expect(doc.Paths["/books/{id}"].Delete.Responses).To(HaveKey("429"))
+			Expect(doc.Paths["/books/import"].Post.Responses).To(HaveKey("429"))
+			Expect(doc.Components.Responses).To(HaveKey("429"))
+		})
+
+		It("documents count and aggregate paths for aggregate managers.", func() {
+			r := goresource.NewResource(manager, router)
+			doc := openapi.Build(openapi.Info{}, []*goresource.Resource{r})
+			Expect(doc.Paths).NotTo(HaveKey("/books/count"))
+			Expect(doc.Paths["/books"].Head.Responses["200"].Headers).To(BeEmpty())
+
+			doc = build(&book{})
+			count := doc.Paths["/api/authors/count"]
+			Expect(count.Get.OperationID).To(Equal("authors.count"))
+			Expect(count.Get.Responses["200"].Headers).To(HaveKey(goresource.TotalCountHeader))
+			Expect(doc.Paths["/api/authors"].Head.Responses["200"].Headers).To(HaveKey(goresource.TotalCountHeader))
+			Expect(doc.Components.Schemas).To(HaveKey(openapi.SchemaName(reflect.TypeOf(goresource.CountResult{}))))
+			aggregate := doc.Paths["/api/authors/aggregate"]
+			Expect(aggregate.Get.Parameters[0].Name).To(Equal(goresource.GroupParam))
+			Expect(aggregate.Get.Parameters[1].Name).To(Equal(goresource.MetricParam))
+		})
+	})
+
+	It("documents the parent ids of nested resources.", func() {
+		parent := goresource.NewResource(manager, router)
+		m := entityManager{goresource.NewDefaultManager("reviews", nil), &book{}}
+		child := goresource.NewResource(m, router, goresource.WithParent(parent, "bookId"))
+		doc := openapi.Build(openapi.Info{}, []*goresource.Resource{child})
+		item := doc.Paths["/books/{bookId}/reviews/{id}"]
+		Expect(item).NotTo(BeNil())
+		Expect(item.Parameters).To(HaveLen(2))
+		Expect(item.Parameters[0].Name).To(Equal("bookId"))
+		Expect(item.Parameters[1].Name).To(Equal("id"))
+		Expect(doc.Paths["/books/{bookId}/reviews"].Parameters).To(HaveLen(1))
+	})
+
+	Describe("Handler", func() {
+		It("serves a document describing the registered resources.", func() {
+			goresource.NewResource(manager, router.PathPrefix("/handler").Subrouter())
+			rw := httptest.NewRecorder()
+			openapi.Handler(openapi.Info{Title: "test"}, openapi.Server{URL: "http://example.com"}).
+				ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+			Expect(rw.Code).To(Equal(http.StatusOK))
+			doc := &openapi.Document{}
+			Expect(json.Unmarshal(rw.Body.Bytes(), doc)).To(Succeed())
+			Expect(doc.Info.Title).To(Equal("test"))
+			Expect(doc.Servers).To(Equal([]openapi.Server{{URL: "http://example.com"}}))
+			Expect(doc.Paths).To(HaveKey("/handler/books"))
+		})
+	})
+})
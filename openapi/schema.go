@@ -0,0 +1,160 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is an OpenAPI 3.1 (JSON Schema 2020-12) schema object.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemas derives schemas from go types, collecting named struct types as
+// reusable components.
+type schemas struct {
+	components map[string]*Schema
+}
+
+// SchemaOf returns the schema for the given type. Named struct types are added
+// to components and referenced. A nil type, such as that of a nil interface,
+// has the empty schema.
+func (s *schemas) SchemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := s.SchemaOf(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		if typ, ok := schema.Type.(string); ok {
+			schema.Type = []string{typ, "null"}
+		}
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.SchemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.SchemaOf(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name := SchemaName(t)
+		if _, ok := s.components[name]; !ok {
+			// Register before recursing, so self references terminate.
+			s.components[name] = &Schema{}
+			*s.components[name] = *s.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+// SchemaName returns the component name of the given named type, qualified by
+// its package path so types of the same name in different packages don't
+// collide. Characters component names don't allow are replaced with _.
+func SchemaName(t reflect.Type) string {
+	name := t.Name()
+	if t.PkgPath() != "" {
+		name = strings.ReplaceAll(t.PkgPath(), "/", ".") + "." + name
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// object returns the schema for a struct, with a property per json field.
+func (s *schemas) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, f := range fields(t) {
+		schema.Properties[f.name] = s.SchemaOf(f.typ)
+	}
+	return schema
+}
+
+// field is a json field of a struct.
+type field struct {
+	name string
+	typ  reflect.Type
+}
+
+// fields returns the json fields of the given struct type in declaration
+// order, including those of embedded structs.
+func fields(t reflect.Type) []field {
+	var result []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		// Like encoding/json, promote the fields of embedded structs even if
+		// the embedded type itself is unexported.
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			result = append(result, fields(f.Type)...)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		result = append(result, field{name, f.Type})
+	}
+	return result
+}
+
+// Fields returns the json field names of the given type in declaration order,
+// if it is a struct or a pointer to one.
+func Fields(t reflect.Type) []string {
+	if t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var names []string
+	for _, f := range fields(t) {
+		names = append(names, f.name)
+	}
+	return names
+}
@@ -0,0 +1,66 @@
+package openapi_test
+
+import (
+	"reflect"
+	"time"
+
+	"goresource/openapi"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type base struct {
+	ID string `json:"id"`
+}
+
+type author struct {
+	base
+	Name    string    `json:"name"`
+	Born    time.Time `json:"born"`
+	Website *string   `json:"website,omitempty"`
+	Ignored string    `json:"-"`
+	hidden  string
+	Books   []book `json:"books"`
+}
+
+func (a *author) HasId() bool   { return a.ID != "" }
+func (a *author) GetId() string { return a.ID }
+
+var _ = Describe("Schema", func() {
+	Describe("Fields", func() {
+		It("lists json fields in declaration order, flattening embedded structs.", func() {
+			Expect(openapi.Fields(reflect.TypeOf(&author{}))).To(Equal([]string{"id", "name", "born", "website", "books"}))
+		})
+
+		It("returns nothing for non struct types.", func() {
+			Expect(openapi.Fields(reflect.TypeOf(map[string]string{}))).To(BeNil())
+			Expect(openapi.Fields(nil)).To(BeNil())
+		})
+	})
+
+	Describe("SchemaName", func() {
+		It("qualifies type names by their package path.", func() {
+			Expect(openapi.SchemaName(reflect.TypeOf(time.Time{}))).To(Equal("time.Time"))
+			Expect(openapi.SchemaName(reflect.TypeOf(openapi.Schema{}))).To(HaveSuffix(".goresource.openapi.Schema"))
+		})
+	})
+
+	Describe("Build", func() {
+		It("derives component schemas from entity types.", func() {
+			doc := openapi.Build(openapi.Info{}, nil)
+			Expect(doc.Components.Schemas).To(BeEmpty())
+			doc = build(&author{})
+			schema := doc.Components.Schemas[openapi.SchemaName(reflect.TypeOf(author{}))]
+			Expect(schema).NotTo(BeNil())
+			Expect(schema.Type).To(Equal("object"))
+			Expect(schema.Properties).To(HaveLen(5))
+			Expect(schema.Properties["id"]).To(Equal(&openapi.Schema{Type: "string"}))
+			Expect(schema.Properties["born"]).To(Equal(&openapi.Schema{Type: "string", Format: "date-time"}))
+			Expect(schema.Properties["website"]).To(Equal(&openapi.Schema{Type: []string{"string", "null"}}))
+			Expect(schema.Properties["books"]).To(Equal(&openapi.Schema{
+				Type: "array", Items: &openapi.Schema{Ref: "#/components/schemas/" + bookSchema}}))
+			Expect(doc.Components.Schemas[bookSchema].Properties["pages"]).To(Equal(&openapi.Schema{Type: "integer", Format: "int32"}))
+		})
+	})
+})
@@ -0,0 +1,106 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/rockstardevs/goresource/store"
+)
+
+// ChannelPublisher publishes events to an in-process channel.
+type ChannelPublisher struct {
+	// C receives published events.
+	C <-chan store.OutboxEvent
+	c chan store.OutboxEvent
+}
+
+// NewChannelPublisher returns a ChannelPublisher buffering the given number of events.
+func NewChannelPublisher(size int) *ChannelPublisher {
+	c := make(chan store.OutboxEvent, size)
+	return &ChannelPublisher{C: c, c: c}
+}
+
+// Publish sends the event, blocking until it is received or buffered.
+func (p *ChannelPublisher) Publish(ctx context.Context, event store.OutboxEvent) error {
+	select {
+	case p.c <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WriterPublisher publishes events as newline delimited json to a writer.
+type WriterPublisher struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	file    *os.File
+}
+
+// NewWriterPublisher returns a Publisher writing to the given writer.
+func NewWriterPublisher(w io.Writer) *WriterPublisher {
+	return &WriterPublisher{encoder: json.NewEncoder(w)}
+}
+
+// NewFilePublisher returns a Publisher appending to the file at the given
+// path, syncing it after each event.
+func NewFilePublisher(path string) (*WriterPublisher, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	p := NewWriterPublisher(f)
+	p.file = f
+	return p, nil
+}
+
+// Publish writes the event as a single line.
+func (p *WriterPublisher) Publish(_ context.Context, event store.OutboxEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.encoder.Encode(event); err != nil {
+		return err
+	}
+	if p.file != nil {
+		return p.file.Sync()
+	}
+	return nil
+}
+
+// Close closes the underlying file, if this publisher owns one.
+func (p *WriterPublisher) Close() error {
+	if p.file != nil {
+		return p.file.Close()
+	}
+	return nil
+}
+
+// NATSConn publishes messages to subjects. *nats.Conn from
+// github.com/nats-io/nats.go implements it.
+type NATSConn interface {
+	Publish(subject string, data []byte) error
+}
+
+// NATSPublisher publishes events as json to the subject
+// <Subject>.<collection>.<action>, for example events.books.create.
+type NATSPublisher struct {
+	Conn    NATSConn
+	Subject string
+}
+
+// NewNATSPublisher returns a Publisher publishing to subjects under the given prefix.
+func NewNATSPublisher(conn NATSConn, subject string) *NATSPublisher {
+	return &NATSPublisher{Conn: conn, Subject: subject}
+}
+
+// Publish publishes the event to its subject.
+func (p *NATSPublisher) Publish(_ context.Context, event store.OutboxEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.Conn.Publish(p.Subject+"."+event.Collection+"."+event.Action, data)
+}
@@ -0,0 +1,81 @@
+package outbox_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"goresource/outbox"
+	"goresource/store"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// natsConn records the messages published to it.
+type natsConn struct {
+	subjects []string
+	data     [][]byte
+}
+
+func (c *natsConn) Publish(subject string, data []byte) error {
+	c.subjects = append(c.subjects, subject)
+	c.data = append(c.data, data)
+	return nil
+}
+
+var _ = Describe("Publishers", func() {
+	var (
+		ctx   = context.Background()
+		event = store.OutboxEvent{ID: "e1", Collection: "books", Action: store.OutboxCreate, EntityID: "b1"}
+	)
+
+	It("publish events to a channel until the context is done.", func() {
+		p := outbox.NewChannelPublisher(1)
+		Expect(p.Publish(ctx, event)).To(Succeed())
+		Expect((<-p.C).ID).To(Equal("e1"))
+		Expect(p.Publish(ctx, event)).To(Succeed())
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		Expect(p.Publish(ctx, event)).To(Equal(context.Canceled))
+	})
+
+	It("write events as lines of json.", func() {
+		var buf bytes.Buffer
+		p := outbox.NewWriterPublisher(&buf)
+		Expect(p.Publish(ctx, event)).To(Succeed())
+		Expect(p.Publish(ctx, event)).To(Succeed())
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		Expect(lines).To(HaveLen(2))
+		var got store.OutboxEvent
+		Expect(json.Unmarshal([]byte(lines[0]), &got)).To(Succeed())
+		Expect(got.ID).To(Equal("e1"))
+	})
+
+	It("append events to a file.", func() {
+		dir, err := os.MkdirTemp("", "outbox")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "events.jsonl")
+		for i := 0; i < 2; i++ {
+			p, err := outbox.NewFilePublisher(path)
+			Expect(err).To(BeNil())
+			Expect(p.Publish(ctx, event)).To(Succeed())
+			Expect(p.Close()).To(Succeed())
+		}
+		data, err := os.ReadFile(path)
+		Expect(err).To(BeNil())
+		Expect(strings.Count(string(data), "\n")).To(Equal(2))
+	})
+
+	It("publish events to NATS subjects by collection and action.", func() {
+		conn := &natsConn{}
+		p := outbox.NewNATSPublisher(conn, "events")
+		Expect(p.Publish(ctx, event)).To(Succeed())
+		Expect(conn.subjects).To(Equal([]string{"events.books.create"}))
+		Expect(string(conn.data[0])).To(ContainSubstring(`"id":"e1"`))
+	})
+})
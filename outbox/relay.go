@@ -0,0 +1,140 @@
+// Package outbox publishes the events written by a store.OutboxStore to a
+// Publisher, such as an in-process channel, a file or a NATS connection.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/rockstardevs/goresource/store"
+)
+
+// Defaults used by NewRelay.
+const (
+	DefaultBatchSize    = 100
+	DefaultPollInterval = time.Second
+)
+
+// Publisher publishes outbox events to consumers.
+type Publisher interface {
+	Publish(ctx context.Context, event store.OutboxEvent) error
+}
+
+// Relay publishes pending outbox events in the order they were written,
+// marking each published once its Publisher succeeds. An event is published
+// again if the relay stops before marking it, so consumers should deduplicate
+// events by id. Only one relay should process an outbox.
+type Relay struct {
+	Store store.Store
+	// Outbox names the collection events are read from.
+	Outbox    string
+	Publisher Publisher
+	// BatchSize is the maximum number of events published by each call to Process.
+	BatchSize int
+	// PollInterval is the interval between checks for pending events by Run.
+	PollInterval time.Duration
+	// DeletePublished deletes published events, instead of marking them published.
+	DeletePublished bool
+	Logger          *slog.Logger
+}
+
+// NewRelay returns a Relay publishing the events in the named collection of
+// the given store to the given publisher.
+func NewRelay(s store.Store, outbox string, p Publisher) *Relay {
+	return &Relay{
+		Store:        s,
+		Outbox:       outbox,
+		Publisher:    p,
+		BatchSize:    DefaultBatchSize,
+		PollInterval: DefaultPollInterval,
+		Logger:       slog.Default(),
+	}
+}
+
+// Process publishes up to BatchSize pending events, oldest first, returning
+// the number published. It stops at the first event failing to publish, to
+// keep events in order.
+func (r *Relay) Process(ctx context.Context) (int, error) {
+	events, err := r.pending(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i, event := range events {
+		if err := r.Publisher.Publish(ctx, event); err != nil {
+			return i, err
+		}
+		if err := r.done(ctx, event); err != nil {
+			return i, err
+		}
+	}
+	return len(events), nil
+}
+
+// pending returns the oldest pending events, up to BatchSize, served by the
+// status and time index on stores that can sort and limit queries.
+func (r *Relay) pending(ctx context.Context) ([]store.OutboxEvent, error) {
+	filters := url.Values{"status": {store.OutboxPending}}
+	docs, err := store.Find(ctx, r.Store, r.Outbox, filters, store.FindOptions{Sort: []string{"time"}, Limit: r.BatchSize})
+	if err != nil {
+		return nil, err
+	}
+	events := make([]store.OutboxEvent, len(docs))
+	for i, doc := range docs {
+		if _, ok := doc["id"]; !ok {
+			doc["id"] = doc["_id"]
+		}
+		delete(doc, "_id")
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &events[i]); err != nil {
+			return nil, err
+		}
+		events[i].Status = ""
+	}
+	return events, nil
+}
+
+// done marks the given event published, or deletes it.
+func (r *Relay) done(ctx context.Context, event store.OutboxEvent) error {
+	if r.DeletePublished {
+		return r.Store.DeleteEntity(ctx, r.Outbox, event.ID)
+	}
+	id := event.ID
+	event.ID = ""
+	event.Status = store.OutboxPublished
+	result := make(map[string]interface{})
+	return r.Store.UpdateEntity(ctx, r.Outbox, id, event, &result)
+}
+
+// Run publishes pending events every PollInterval until the context is done,
+// first indexing the outbox by status and time on stores with indexes.
+func (r *Relay) Run(ctx context.Context) error {
+	index := store.Index{Key: []string{"status", "time"}}
+	if err := store.EnsureIndexes(ctx, r.Store, r.Outbox, []store.Index{index}); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// Keep publishing while full batches are pending.
+			for {
+				n, err := r.Process(ctx)
+				if err != nil && ctx.Err() == nil {
+					r.Logger.ErrorContext(ctx, "error publishing outbox events", "outbox", r.Outbox, "error", err.Error())
+				}
+				if err != nil || n == 0 || n < r.BatchSize {
+					break
+				}
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"goresource/mocks"
+	"goresource/outbox"
+	"goresource/store"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// failingPublisher fails to publish the event with the given id.
+type failingPublisher struct {
+	*outbox.ChannelPublisher
+	fail string
+}
+
+func (p failingPublisher) Publish(ctx context.Context, event store.OutboxEvent) error {
+	if event.ID == p.fail {
+		return errors.New("test error")
+	}
+	return p.ChannelPublisher.Publish(ctx, event)
+}
+
+// finder is a store sorting and limiting queries with the given func.
+type finder struct {
+	*mocks.MockStore
+	find func(filters url.Values, opts store.FindOptions) []map[string]interface{}
+}
+
+func (f finder) FindEntities(_ context.Context, _ string, filters url.Values, opts store.FindOptions, result interface{}) error {
+	*result.(*[]map[string]interface{}) = f.find(filters, opts)
+	return nil
+}
+
+var _ = Describe("Relay", func() {
+	var (
+		ctrl      *gomock.Controller
+		backend   *mocks.MockStore
+		publisher *outbox.ChannelPublisher
+		relay     *outbox.Relay
+		ctx       = context.Background()
+		now       = time.Now().UTC()
+	)
+
+	// pending expects the pending events to be listed.
+	pending := func(docs ...map[string]interface{}) {
+		backend.EXPECT().ListEntities(gomock.Any(), "outbox", gomock.Any(), gomock.Any()).
+			Do(func(_ context.Context, _ string, filters map[string][]string, _ interface{}) {
+				Expect(filters["status"]).To(Equal([]string{store.OutboxPending}))
+			}).SetArg(3, docs).Return(nil)
+	}
+
+	// doc returns a stored event with the given id, written the given time ago.
+	doc := func(id string, ago time.Duration) map[string]interface{} {
+		return map[string]interface{}{"_id": id, "time": now.Add(-ago), "collection": "books",
+			"action": store.OutboxCreate, "entityId": "b" + id, "status": store.OutboxPending}
+	}
+
+	// received returns the ids of the published events.
+	received := func() []string {
+		var ids []string
+		for len(publisher.C) > 0 {
+			ids = append(ids, (<-publisher.C).ID)
+		}
+		return ids
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		backend = mocks.NewMockStore(ctrl)
+		publisher = outbox.NewChannelPublisher(10)
+		relay = outbox.NewRelay(backend, "outbox", publisher)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("publishes pending events in order and marks them published.", func() {
+		pending(doc("2", time.Second), doc("1", time.Minute))
+		var marked []string
+		backend.EXPECT().UpdateEntity(gomock.Any(), "outbox", gomock.Any(), gomock.Any(), gomock.Any()).
+			Do(func(_ context.Context, _ string, id string, data interface{}, _ interface{}) {
+				event := data.(store.OutboxEvent)
+				Expect(event.ID).To(BeEmpty())
+				Expect(event.Status).To(Equal(store.OutboxPublished))
+				marked = append(marked, id)
+			}).Return(nil).Times(2)
+		n, err := relay.Process(ctx)
+		Expect(err).To(BeNil())
+		Expect(n).To(Equal(2))
+		Expect(marked).To(Equal([]string{"1", "2"}))
+		Expect(received()).To(Equal([]string{"1", "2"}))
+	})
+
+	It("publishes events with their ids and without their status.", func() {
+		pending(doc("1", 0))
+		relay.DeletePublished = true
+		backend.EXPECT().DeleteEntity(gomock.Any(), "outbox", "1").Return(nil)
+		relay.Process(ctx)
+		event := <-publisher.C
+		Expect(event.ID).To(Equal("1"))
+		Expect(event.EntityID).To(Equal("b1"))
+		Expect(event.Status).To(BeEmpty())
+	})
+
+	It("stops at the first event failing to publish.", func() {
+		relay.Publisher = failingPublisher{ChannelPublisher: publisher, fail: "2"}
+		pending(doc("1", 3*time.Second), doc("2", 2*time.Second), doc("3", time.Second))
+		backend.EXPECT().UpdateEntity(gomock.Any(), "outbox", "1", gomock.Any(), gomock.Any()).Return(nil)
+		n, err := relay.Process(ctx)
+		Expect(err).To(MatchError("test error"))
+		Expect(n).To(Equal(1))
+		Expect(received()).To(Equal([]string{"1"}))
+	})
+
+	It("queries the oldest batch of pending events from stores that can sort.", func() {
+		relay.BatchSize = 1
+		var queried store.FindOptions
+		relay.Store = finder{MockStore: backend, find: func(filters url.Values, opts store.FindOptions) []map[string]interface{} {
+			Expect(filters).To(Equal(url.Values{"status": {store.OutboxPending}}))
+			queried = opts
+			return []map[string]interface{}{doc("1", time.Second)}
+		}}
+		backend.EXPECT().UpdateEntity(gomock.Any(), "outbox", "1", gomock.Any(), gomock.Any()).Return(nil)
+		n, err := relay.Process(ctx)
+		Expect(err).To(BeNil())
+		Expect(n).To(Equal(1))
+		Expect(queried).To(Equal(store.FindOptions{Sort: []string{"time"}, Limit: 1}))
+	})
+
+	It("publishes at most a batch of events at a time.", func() {
+		relay.BatchSize = 1
+		pending(doc("1", time.Second), doc("2", 0))
+		backend.EXPECT().UpdateEntity(gomock.Any(), "outbox", "1", gomock.Any(), gomock.Any()).Return(nil)
+		n, _ := relay.Process(ctx)
+		Expect(n).To(Equal(1))
+	})
+
+	It("publishes events until the context is done.", func() {
+		relay.PollInterval = 10 * time.Millisecond
+		backend.EXPECT().ListEntities(gomock.Any(), "outbox", gomock.Any(), gomock.Any()).
+			SetArg(3, []map[string]interface{}{doc("1", 0)}).Return(nil)
+		backend.EXPECT().UpdateEntity(gomock.Any(), "outbox", "1", gomock.Any(), gomock.Any()).Return(nil)
+		backend.EXPECT().ListEntities(gomock.Any(), "outbox", gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan error)
+		go func() { done <- relay.Run(ctx) }()
+		Eventually(publisher.C).Should(Receive())
+		cancel()
+		Eventually(done).Should(Receive(Equal(context.Canceled)))
+	})
+})
@@ -0,0 +1,264 @@
+package goresource
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers of rate limited responses.
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	// RateLimitResetHeader holds the seconds until the client's bucket is full again.
+	RateLimitResetHeader = "RateLimit-Reset"
+	// RateLimitPolicyHeader describes the limit as <burst>;w=<seconds>.
+	RateLimitPolicyHeader = "RateLimit-Policy"
+)
+
+// RateLimit is a token bucket holding up to Burst requests, refilled at
+// Requests per Period.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	// Burst is the size of the bucket, Requests if zero.
+	Burst int
+}
+
+// IsZero reports whether the limit is unset, allowing every request.
+func (l RateLimit) IsZero() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+// capacity returns the size of the bucket.
+func (l RateLimit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Requests)
+}
+
+// rate returns the tokens added to the bucket per second.
+func (l RateLimit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// RateLimitResult is the outcome of taking a request from a bucket.
+type RateLimitResult struct {
+	Allowed bool
+	// Remaining is the number of requests left in the bucket.
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until a request is allowed, if it was not.
+	RetryAfter time.Duration
+}
+
+// RateLimiter is implemented by token bucket stores. Servers running several
+// processes share limits through a limiter backed by a shared store.
+type RateLimiter interface {
+	// Allow takes a request from the bucket with the given key and limit.
+	Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// RateLimitKeyFunc returns the client a request's limit applies to.
+type RateLimitKeyFunc func(req *http.Request) string
+
+// IPKey identifies clients by their ip address. Servers behind proxies should
+// set the request's RemoteAddr from the forwarded address.
+func IPKey(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// HeaderKey identifies clients by the value of the given header, such as an
+// api key, or by ip address if unset.
+func HeaderKey(name string) RateLimitKeyFunc {
+	return func(req *http.Request) string {
+		if v := req.Header.Get(name); v != "" {
+			return name + "=" + v
+		}
+		return IPKey(req)
+	}
+}
+
+// PrincipalKey identifies clients by the given principal, or by ip address
+// if anonymous. The principal must be authenticated, otherwise clients get a
+// new bucket for every principal they claim.
+func PrincipalKey(principal PrincipalFunc) RateLimitKeyFunc {
+	return func(req *http.Request) string {
+		if p := principal(req); p != "" {
+			return "principal=" + p
+		}
+		return IPKey(req)
+	}
+}
+
+// RateLimitPolicy configures the rate limits of a resource.
+type RateLimitPolicy struct {
+	// Limit applies to requests with methods not in Methods.
+	Limit RateLimit
+	// Methods limits requests with the given http methods separately.
+	Methods map[string]RateLimit
+	// Quota, if set, limits all requests of a client over a longer window,
+	// such as 10000 requests a day, in addition to the limits above.
+	Quota RateLimit
+	// Key identifies the client. It defaults to the principal set with
+	// WithPrincipal, which must authenticate requests, or to the ip address
+	// otherwise. The default BasicAuthPrincipal is not used, since it trusts
+	// any username without checking the password.
+	Key RateLimitKeyFunc
+}
+
+// WithRateLimit limits the rate of requests of each client to the resource
+// with the given policy, taking requests from buckets in the given limiter.
+// Requests over the limit are rejected with 429 Too Many Requests and a
+// Retry-After header, and every response reports the client's limit in
+// RateLimit headers. Requests are allowed if the limiter fails.
+func WithRateLimit(limiter RateLimiter, policy RateLimitPolicy) Option {
+	return func(r *Resource) {
+		r.limiter = limiter
+		r.rateLimits = policy
+	}
+}
+
+// rateLimitKey returns the client the request's limits apply to.
+func (r Resource) rateLimitKey(req *http.Request) string {
+	switch {
+	case r.rateLimits.Key != nil:
+		return r.rateLimits.Key(req)
+	case r.authenticates:
+		return PrincipalKey(r.principal)(req)
+	}
+	return IPKey(req)
+}
+
+// allow takes the request from its client's bucket for its method, then from
+// its quota, responding with 429 Too Many Requests and returning false if
+// either is empty. The headers report the bucket with the fewest requests left.
+func (r Resource) allow(rw http.ResponseWriter, req *http.Request) bool {
+	if r.limiter == nil {
+		return true
+	}
+	limit, scope := r.rateLimits.Limit, "*"
+	if l, ok := r.rateLimits.Methods[req.Method]; ok {
+		limit, scope = l, req.Method
+	}
+	var (
+		client   = r.rateLimitKey(req)
+		policies []string
+		reported *RateLimitResult
+		capacity int
+	)
+	ctx, span := r.startSpan(req.Context(), "RateLimit")
+	defer span.End()
+	for _, check := range []struct {
+		limit RateLimit
+		scope string
+	}{{limit, scope}, {r.rateLimits.Quota, "quota"}} {
+		if check.limit.IsZero() {
+			continue
+		}
+		result, err := r.limiter.Allow(ctx, r.Name()+":"+check.scope+":"+client, check.limit)
+		if err != nil {
+			r.logger.ErrorContext(ctx, "error checking rate limit", "resource", r.Name(), "error", err.Error())
+			continue
+		}
+		policies = append(policies, fmt.Sprintf("%d;w=%d", int(check.limit.capacity()), ceilSeconds(check.limit.Period)))
+		if reported == nil || !result.Allowed || (reported.Allowed && result.Remaining < reported.Remaining) {
+			reported, capacity = &result, int(check.limit.capacity())
+		}
+		if !result.Allowed {
+			break
+		}
+	}
+	if reported == nil {
+		return true
+	}
+	h := rw.Header()
+	h.Set(RateLimitLimitHeader, strconv.Itoa(capacity))
+	h.Set(RateLimitRemainingHeader, strconv.Itoa(reported.Remaining))
+	h.Set(RateLimitResetHeader, strconv.FormatInt(ceilSeconds(reported.Reset), 10))
+	h.Set(RateLimitPolicyHeader, strings.Join(policies, ", "))
+	if !reported.Allowed {
+		h.Set("Retry-After", strconv.FormatInt(ceilSeconds(reported.RetryAfter), 10))
+		writeError(rw, "Too Many Requests", http.StatusTooManyRequests)
+	}
+	return reported.Allowed
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// MemoryRateLimiter is a RateLimiter keeping buckets in memory, for servers
+// running a single process.
+type MemoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// bucket holds the tokens left at the time it was last updated.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// NewMemoryRateLimiter returns an empty MemoryRateLimiter.
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{buckets: make(map[string]*bucket)}
+}
+
+// Allow takes a request from the bucket with the given key and limit.
+func (l *MemoryRateLimiter) Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.sweep(now)
+	capacity, rate := limit.capacity(), limit.rate()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+	result := RateLimitResult{Allowed: b.tokens >= 1}
+	if result.Allowed {
+		b.tokens--
+	} else {
+		result.RetryAfter = fromSeconds((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = fromSeconds((capacity - b.tokens) / rate)
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+// sweep removes full buckets, which are the same as new ones, at most once a minute.
+func (l *MemoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+	for key, b := range l.buckets {
+		if !b.full.After(now) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func fromSeconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,189 @@
+package goresource_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"goresource"
+	"goresource/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// failingLimiter is a RateLimiter whose backend is down.
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, goresource.RateLimit) (goresource.RateLimitResult, error) {
+	return goresource.RateLimitResult{}, fmt.Errorf("test error")
+}
+
+var _ = Describe("Resource rate limits", func() {
+	var (
+		ctrl    *gomock.Controller
+		manager *mocks.MockResourceManager
+		router  *mux.Router
+		policy  goresource.RateLimitPolicy
+	)
+
+	request := func(method, path, remoteAddr, user string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.RemoteAddr = remoteAddr
+		if user != "" {
+			req.SetBasicAuth(user, "secret")
+		}
+		router.ServeHTTP(rw, req)
+		return rw
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		manager = mocks.NewMockResourceManager(ctrl)
+		router = mux.NewRouter().PathPrefix("/api").Subrouter()
+		manager.EXPECT().GetName().AnyTimes().Return("test")
+		manager.EXPECT().ListEntities(gomock.Any(), gomock.Any()).AnyTimes().Return([]map[string]interface{}{}, nil)
+		manager.EXPECT().DeleteEntity(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+		policy = goresource.RateLimitPolicy{
+			Limit:   goresource.RateLimit{Requests: 10, Period: time.Minute},
+			Methods: map[string]goresource.RateLimit{"GET": {Requests: 2, Period: time.Minute}},
+		}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("rejects requests over the limit with Retry-After.", func() {
+		goresource.NewResource(manager, router, goresource.WithRateLimit(goresource.NewMemoryRateLimiter(), policy))
+		rw := request("GET", "/api/test", "10.0.0.1:1234", "")
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Header().Get(goresource.RateLimitLimitHeader)).To(Equal("2"))
+		Expect(rw.Header().Get(goresource.RateLimitRemainingHeader)).To(Equal("1"))
+		Expect(rw.Header().Get(goresource.RateLimitResetHeader)).To(Equal("30"))
+		Expect(rw.Header().Get(goresource.RateLimitPolicyHeader)).To(Equal("2;w=60"))
+		Expect(request("GET", "/api/test", "10.0.0.1:1234", "").Code).To(Equal(http.StatusOK))
+
+		rw = request("GET", "/api/test", "10.0.0.1:5678", "")
+		Expect(rw.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(rw.Header().Get("Retry-After")).To(Equal("30"))
+		Expect(rw.Header().Get(goresource.RateLimitRemainingHeader)).To(Equal("0"))
+	})
+
+	It("limits methods separately.", func() {
+		goresource.NewResource(manager, router, goresource.WithRateLimit(goresource.NewMemoryRateLimiter(), policy))
+		request("GET", "/api/test", "10.0.0.1:1234", "")
+		request("GET", "/api/test", "10.0.0.1:1234", "")
+		rw := request("DELETE", "/api/test/fakeid", "10.0.0.1:1234", "")
+		Expect(rw.Code).To(Equal(http.StatusNoContent))
+		Expect(rw.Header().Get(goresource.RateLimitLimitHeader)).To(Equal("10"))
+		Expect(rw.Header().Get(goresource.RateLimitRemainingHeader)).To(Equal("9"))
+	})
+
+	It("keys limits by ip address by default, ignoring claimed usernames.", func() {
+		goresource.NewResource(manager, router, goresource.WithRateLimit(goresource.NewMemoryRateLimiter(), policy))
+		request("GET", "/api/test", "10.0.0.1:1234", "alice")
+		request("GET", "/api/test", "10.0.0.1:1234", "bob")
+		Expect(request("GET", "/api/test", "10.0.0.1:1234", "mallory").Code).To(Equal(http.StatusTooManyRequests))
+		Expect(request("GET", "/api/test", "10.0.0.2:1234", "alice").Code).To(Equal(http.StatusOK))
+	})
+
+	It("keys limits by the principal set with WithPrincipal, or by ip address if anonymous.", func() {
+		principal := func(req *http.Request) string {
+			user, _, _ := req.BasicAuth()
+			return user
+		}
+		goresource.NewResource(manager, router, goresource.WithPrincipal(principal),
+			goresource.WithRateLimit(goresource.NewMemoryRateLimiter(), policy))
+		request("GET", "/api/test", "10.0.0.1:1234", "alice")
+		request("GET", "/api/test", "10.0.0.1:1234", "alice")
+		Expect(request("GET", "/api/test", "10.0.0.2:1234", "alice").Code).To(Equal(http.StatusTooManyRequests))
+		Expect(request("GET", "/api/test", "10.0.0.1:1234", "bob").Code).To(Equal(http.StatusOK))
+		Expect(request("GET", "/api/test", "10.0.0.1:1234", "").Code).To(Equal(http.StatusOK))
+	})
+
+	It("limits all requests of a client by the quota.", func() {
+		policy.Quota = goresource.RateLimit{Requests: 3, Period: 24 * time.Hour}
+		goresource.NewResource(manager, router, goresource.WithRateLimit(goresource.NewMemoryRateLimiter(), policy))
+		rw := request("GET", "/api/test", "10.0.0.1:1234", "")
+		Expect(rw.Header().Get(goresource.RateLimitPolicyHeader)).To(Equal("2;w=60, 3;w=86400"))
+		Expect(rw.Header().Get(goresource.RateLimitLimitHeader)).To(Equal("2"))
+		Expect(rw.Header().Get(goresource.RateLimitRemainingHeader)).To(Equal("1"))
+		rw = request("DELETE", "/api/test/fakeid", "10.0.0.1:1234", "")
+		Expect(rw.Code).To(Equal(http.StatusNoContent))
+		Expect(rw.Header().Get(goresource.RateLimitLimitHeader)).To(Equal("3"))
+		Expect(rw.Header().Get(goresource.RateLimitRemainingHeader)).To(Equal("1"))
+		request("DELETE", "/api/test/fakeid", "10.0.0.1:1234", "")
+		rw = request("DELETE", "/api/test/fakeid", "10.0.0.1:1234", "")
+		Expect(rw.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(rw.Header().Get(goresource.RateLimitLimitHeader)).To(Equal("3"))
+		Expect(rw.Header().Get("Retry-After")).To(Equal("28800"))
+	})
+
+	It("keys limits by the given key function.", func() {
+		policy.Key = goresource.HeaderKey("X-Api-Key")
+		goresource.NewResource(manager, router, goresource.WithRateLimit(goresource.NewMemoryRateLimiter(), policy))
+		for i := 0; i < 2; i++ {
+			rw := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/test", nil)
+			req.Header.Set("X-Api-Key", "key-1")
+			req.RemoteAddr = fmt.Sprintf("10.0.0.%d:1234", i)
+			router.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusOK))
+		}
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/test", nil)
+		req.Header.Set("X-Api-Key", "key-1")
+		router.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusTooManyRequests))
+	})
+
+	It("allows requests if the limiter fails.", func() {
+		goresource.NewResource(manager, router, goresource.WithRateLimit(failingLimiter{}, policy))
+		for i := 0; i < 3; i++ {
+			Expect(request("GET", "/api/test", "10.0.0.1:1234", "").Code).To(Equal(http.StatusOK))
+		}
+	})
+
+	It("does not limit resources without a limiter.", func() {
+		goresource.NewResource(manager, router)
+		rw := request("GET", "/api/test", "10.0.0.1:1234", "")
+		Expect(rw.Header().Get(goresource.RateLimitLimitHeader)).To(BeEmpty())
+	})
+
+	Describe("MemoryRateLimiter", func() {
+		It("refills buckets over time.", func() {
+			limiter := goresource.NewMemoryRateLimiter()
+			limit := goresource.RateLimit{Requests: 1, Period: 50 * time.Millisecond, Burst: 2}
+			for i := 0; i < 2; i++ {
+				result, err := limiter.Allow(context.Background(), "k", limit)
+				Expect(err).To(BeNil())
+				Expect(result.Allowed).To(BeTrue())
+			}
+			result, _ := limiter.Allow(context.Background(), "k", limit)
+			Expect(result.Allowed).To(BeFalse())
+			Expect(result.RetryAfter).To(BeNumerically(">", 0))
+			Expect(result.RetryAfter).To(BeNumerically("<=", 50*time.Millisecond))
+			time.Sleep(result.RetryAfter + 5*time.Millisecond)
+			result, _ = limiter.Allow(context.Background(), "k", limit)
+			Expect(result.Allowed).To(BeTrue())
+		})
+
+		It("keeps a bucket per key.", func() {
+			limiter := goresource.NewMemoryRateLimiter()
+			limit := goresource.RateLimit{Requests: 1, Period: time.Hour}
+			result, _ := limiter.Allow(context.Background(), "a", limit)
+			Expect(result.Allowed).To(BeTrue())
+			result, _ = limiter.Allow(context.Background(), "a", limit)
+			Expect(result.Allowed).To(BeFalse())
+			result, _ = limiter.Allow(context.Background(), "b", limit)
+			Expect(result.Allowed).To(BeTrue())
+		})
+	})
+})
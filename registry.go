@@ -0,0 +1,44 @@
+package goresource
+
+import "sync"
+
+// registry tracks every Resource created with NewResource.
+var registry struct {
+	sync.Mutex
+	resources []*Resource
+}
+
+// Register adds the given resource to the registry, unless it is registered
+// already. NewResource registers every resource it creates, Register adds back
+// resources removed with Unregister.
+func Register(r *Resource) {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, registered := range registry.resources {
+		if registered == r {
+			return
+		}
+	}
+	registry.resources = append(registry.resources, r)
+}
+
+// Unregister removes the given resource from the registry, for resources no
+// longer served, such as dynamic resources whose schema was removed, so they
+// aren't described or indexed.
+func Unregister(r *Resource) {
+	registry.Lock()
+	defer registry.Unlock()
+	for i, registered := range registry.resources {
+		if registered == r {
+			registry.resources = append(registry.resources[:i:i], registry.resources[i+1:]...)
+			return
+		}
+	}
+}
+
+// Registered returns every registered Resource, in order of registration.
+func Registered() []*Resource {
+	registry.Lock()
+	defer registry.Unlock()
+	return append([]*Resource{}, registry.resources...)
+}
@@ -0,0 +1,55 @@
+package goresource_test
+
+import (
+	"goresource"
+	"goresource/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	var (
+		ctrl    *gomock.Controller
+		manager *mocks.MockResourceManager
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		manager = mocks.NewMockResourceManager(ctrl)
+		manager.EXPECT().GetName().Return("registered").AnyTimes()
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("registers resources in order of creation.", func() {
+		before := goresource.Registered()
+		first := goresource.NewResource(manager, mux.NewRouter().PathPrefix("/a").Subrouter())
+		second := goresource.NewResource(manager, mux.NewRouter())
+		registered := goresource.Registered()
+		Expect(registered).To(HaveLen(len(before) + 2))
+		Expect(registered[len(before)]).To(BeIdenticalTo(first))
+		Expect(registered[len(before)+1]).To(BeIdenticalTo(second))
+		Expect(first.Path()).To(Equal("/a/registered"))
+		Expect(second.Path()).To(Equal("/registered"))
+		Expect(first.Name()).To(Equal("registered"))
+		Expect(first.Manager()).To(Equal(manager))
+	})
+
+	It("unregisters resources and registers them back once.", func() {
+		r := goresource.NewResource(manager, mux.NewRouter())
+		before := goresource.Registered()
+		goresource.Unregister(r)
+		Expect(goresource.Registered()).To(HaveLen(len(before) - 1))
+		Expect(goresource.Registered()).NotTo(ContainElement(BeIdenticalTo(r)))
+		goresource.Register(r)
+		goresource.Register(r)
+		Expect(goresource.Registered()).To(HaveLen(len(before)))
+		Expect(goresource.Registered()[len(before)-1]).To(BeIdenticalTo(r))
+	})
+})
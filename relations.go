@@ -0,0 +1,198 @@
+package goresource
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/rockstardevs/goresource/store"
+)
+
+// ExpandParam is the query parameter listing the relations to embed, separated
+// by commas. Relations of embedded entities are expanded with dotted paths,
+// for example expand=author,author.publisher.
+const ExpandParam = "expand"
+
+// DefaultMaxExpandDepth is the default limit on the number of relations in an expand path.
+const DefaultMaxExpandDepth = 3
+
+// Relation declares that entities reference entities of another manager by id.
+type Relation struct {
+	// Name is the name of the relation in expand paths, and the field the
+	// referenced entities are embedded as.
+	Name string
+	// Field holds the id of the referenced entity, or a list of ids.
+	Field string
+	// Target is the manager of the referenced entities.
+	Target *DefaultManager
+}
+
+// params are the query parameters that aren't store filters.
+var params = map[string]bool{
+	ExpandParam: true, SearchParam: true, HighlightParam: true, GroupParam: true, MetricParam: true,
+}
+
+// filters returns the query without parameters that aren't store filters.
+func filters(query url.Values) url.Values {
+	clean := true
+	for k := range params {
+		if _, ok := query[k]; ok {
+			clean = false
+		}
+	}
+	if clean {
+		return query
+	}
+	result := make(url.Values, len(query))
+	for k, v := range query {
+		if !params[k] {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// expand embeds the relations listed in the query's expand parameter into the given entities.
+func (manager DefaultManager) expand(ctx context.Context, entities []map[string]interface{}, query url.Values) error {
+	var paths []string
+	for _, v := range query[ExpandParam] {
+		for _, path := range strings.Split(v, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				paths = append(paths, path)
+			}
+		}
+	}
+	if len(paths) == 0 || len(entities) == 0 {
+		return nil
+	}
+	max := manager.MaxExpandDepth
+	if max <= 0 {
+		max = DefaultMaxExpandDepth
+	}
+	for _, path := range paths {
+		if depth := strings.Count(path, ".") + 1; depth > max {
+			return fmt.Errorf("%w: %s expands more than %d relations deep", ErrInvalidQuery, path, max)
+		}
+	}
+	return manager.embed(ctx, entities, paths)
+}
+
+// embed loads the entities referenced by the first relation of each path in a
+// single batch per relation, expanding the rest of the path on them.
+func (manager DefaultManager) embed(ctx context.Context, entities []map[string]interface{}, paths []string) error {
+	nested := make(map[string][]string)
+	for _, path := range paths {
+		parts := strings.SplitN(path, ".", 2)
+		if _, ok := nested[parts[0]]; !ok {
+			nested[parts[0]] = nil
+		}
+		if len(parts) == 2 {
+			nested[parts[0]] = append(nested[parts[0]], parts[1])
+		}
+	}
+	names := make([]string, 0, len(nested))
+	for name := range nested {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rel, ok := manager.relation(name)
+		if !ok {
+			return fmt.Errorf("%w: %s has no relation %s", ErrInvalidQuery, manager.Name, name)
+		}
+		var ids []string
+		seen := make(map[string]bool)
+		for _, e := range entities {
+			for _, id := range references(e[rel.Field]) {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		loaded, err := store.GetEntities(ctx, rel.Target.Store, rel.Target.Name, ids)
+		if err != nil {
+			return err
+		}
+		if len(nested[name]) > 0 {
+			if err := rel.Target.embed(ctx, loaded, nested[name]); err != nil {
+				return err
+			}
+		}
+		byID := make(map[string]map[string]interface{}, len(loaded))
+		for _, l := range loaded {
+			for _, key := range []string{"_id", "id"} {
+				if id := idString(l[key]); id != "" {
+					byID[id] = l
+					break
+				}
+			}
+		}
+		for _, e := range entities {
+			switch e[rel.Field].(type) {
+			case nil:
+			case []interface{}:
+				embedded := []interface{}{}
+				for _, id := range references(e[rel.Field]) {
+					if l, ok := byID[id]; ok {
+						embedded = append(embedded, l)
+					}
+				}
+				e[rel.Name] = embedded
+			default:
+				if l, ok := byID[idString(e[rel.Field])]; ok {
+					e[rel.Name] = l
+				} else {
+					e[rel.Name] = nil
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// relation returns the manager's relation with the given name.
+func (manager DefaultManager) relation(name string) (Relation, bool) {
+	for _, rel := range manager.Relations {
+		if rel.Name == name {
+			return rel, true
+		}
+	}
+	return Relation{}, false
+}
+
+// references returns the ids held by a reference field.
+func references(v interface{}) []string {
+	var ids []string
+	if list, ok := v.([]interface{}); ok {
+		for _, item := range list {
+			if id := idString(item); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+	if id := idString(v); id != "" {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// idString returns an id as a string, object ids as their hex representation.
+func idString(v interface{}) string {
+	switch id := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return id
+	case interface{ Hex() string }:
+		return id.Hex()
+	default:
+		return fmt.Sprint(id)
+	}
+}
@@ -0,0 +1,119 @@
+package goresource_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"goresource"
+	"goresource/mocks"
+	storepkg "goresource/store"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Relations", func() {
+	var (
+		ctrl       *gomock.Controller
+		store      *mocks.MockStore
+		books      goresource.DefaultManager
+		authors    goresource.DefaultManager
+		publishers goresource.DefaultManager
+		ctx        = context.Background()
+	)
+
+	// get expects the entity with the given id to be fetched from the named collection.
+	get := func(name, id string, entity map[string]interface{}) {
+		store.EXPECT().GetEntity(gomock.Any(), name, id, gomock.Any()).SetArg(3, entity).Return(nil)
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStore(ctrl)
+		publishers = goresource.NewDefaultManager("publishers", store)
+		authors = goresource.NewDefaultManager("authors", store)
+		authors.Relations = []goresource.Relation{{Name: "publisher", Field: "publisherId", Target: &publishers}}
+		books = goresource.NewDefaultManager("books", store)
+		books.Relations = []goresource.Relation{
+			{Name: "author", Field: "authorId", Target: &authors},
+			{Name: "reviewers", Field: "reviewerIds", Target: &authors},
+		}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("embeds referenced entities in lists, loading each once.", func() {
+		list := []map[string]interface{}{
+			{"_id": "b1", "authorId": "a1"},
+			{"_id": "b2", "authorId": "a1"},
+			{"_id": "b3", "authorId": "a2"},
+			{"_id": "b4"},
+		}
+		store.EXPECT().ListEntities(gomock.Any(), "books", url.Values{"tag": {"x"}}, gomock.Any()).SetArg(3, list).Return(nil)
+		get("authors", "a1", map[string]interface{}{"_id": "a1", "name": "one"})
+		get("authors", "a2", map[string]interface{}{"_id": "a2", "name": "two"})
+		result, err := books.ListEntities(ctx, url.Values{"tag": {"x"}, "expand": {"author"}})
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal([]map[string]interface{}{
+			{"_id": "b1", "authorId": "a1", "author": map[string]interface{}{"_id": "a1", "name": "one"}},
+			{"_id": "b2", "authorId": "a1", "author": map[string]interface{}{"_id": "a1", "name": "one"}},
+			{"_id": "b3", "authorId": "a2", "author": map[string]interface{}{"_id": "a2", "name": "two"}},
+			{"_id": "b4"},
+		}))
+	})
+
+	It("embeds lists of references and nested relations.", func() {
+		get("books", "b1", map[string]interface{}{"_id": "b1", "authorId": "a1", "reviewerIds": []interface{}{"a2", "missing"}})
+		get("authors", "a1", map[string]interface{}{"_id": "a1", "publisherId": "p1"})
+		get("publishers", "p1", map[string]interface{}{"_id": "p1"})
+		get("authors", "a2", map[string]interface{}{"_id": "a2"})
+		store.EXPECT().GetEntity(gomock.Any(), "authors", "missing", gomock.Any()).Return(storepkg.ErrNotFound)
+		result, err := books.GetEntity(ctx, "b1", url.Values{"expand": {"author.publisher", "reviewers"}})
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(map[string]interface{}{
+			"_id": "b1", "authorId": "a1", "reviewerIds": []interface{}{"a2", "missing"},
+			"author": map[string]interface{}{
+				"_id": "a1", "publisherId": "p1", "publisher": map[string]interface{}{"_id": "p1"},
+			},
+			"reviewers": []interface{}{map[string]interface{}{"_id": "a2"}},
+		}))
+	})
+
+	It("returns errors loading referenced entities.", func() {
+		get("books", "b1", map[string]interface{}{"_id": "b1", "authorId": "a1"})
+		store.EXPECT().GetEntity(gomock.Any(), "authors", "a1", gomock.Any()).Return(errors.New("Test Error"))
+		_, err := books.GetEntity(ctx, "b1", url.Values{"expand": {"author"}})
+		Expect(err).To(MatchError("Test Error"))
+	})
+
+	It("rejects unknown relations and paths that are too deep.", func() {
+		get("books", "b1", map[string]interface{}{"_id": "b1"})
+		_, err := books.GetEntity(ctx, "b1", url.Values{"expand": {"editor"}})
+		Expect(errors.Is(err, goresource.ErrInvalidQuery)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("books has no relation editor"))
+
+		books.MaxExpandDepth = 1
+		get("books", "b1", map[string]interface{}{"_id": "b1"})
+		_, err = books.GetEntity(ctx, "b1", url.Values{"expand": {"author.publisher"}})
+		Expect(errors.Is(err, goresource.ErrInvalidQuery)).To(BeTrue())
+	})
+
+	It("responds bad request to invalid expansions.", func() {
+		router := mux.NewRouter()
+		goresource.NewResource(bookManager{books}, router)
+		get("books", "b1", map[string]interface{}{"_id": "b1"})
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/books/b1?expand=editor", nil))
+		Expect(rw.Code).To(Equal(http.StatusBadRequest))
+		Expect(rw.Body.String()).To(Equal(fmt.Sprintf("%s: books has no relation editor\n", goresource.ErrInvalidQuery)))
+	})
+})
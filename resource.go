@@ -3,10 +3,15 @@ package goresource
 
 import (
 	"fmt"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/rockstardevs/goresource/codec"
+	"github.com/rockstardevs/goresource/store"
 	"github.com/rockstardevs/goresource/util"
+	"go.opentelemetry.io/otel/codes"
 )
 
 // Entity is an interface implemented by entities we store in the database.
@@ -21,21 +26,138 @@ type Entity interface {
 // are delegated to the corresponding ResourceManager. This decouples request
 // handing and persistence from specific entity types.
 type Resource struct {
-	manager ResourceManager
+	manager       ResourceManager
+	path          string
+	base          string
+	parent        *Resource
+	parentField   string
+	logger        *slog.Logger
+	auditSink     AuditSink
+	principal     PrincipalFunc
+	authenticates bool
+	codecs        *codec.Registry
+	changes       *ChangeFeed
+	authorize     SubscriptionAuthorizer
+	idempotency   IdempotencyStore
+	limiter       RateLimiter
+	rateLimits    RateLimitPolicy
+}
+
+// Option configures optional behaviour of a Resource.
+type Option func(*Resource)
+
+// WithLogger sets the logger used for request logs, defaults to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *Resource) {
+		r.logger = logger
+	}
+}
+
+// WithAuditSink records an audit trail of entity changes to the given sink.
+func WithAuditSink(sink AuditSink) Option {
+	return func(r *Resource) {
+		r.auditSink = sink
+	}
+}
+
+// WithPrincipal sets how the principal making a request is identified,
+// defaults to BasicAuthPrincipal.
+func WithPrincipal(principal PrincipalFunc) Option {
+	return func(r *Resource) {
+		r.principal = principal
+		r.authenticates = true
+	}
+}
+
+// WithCodecs sets the codecs available for content negotiation, defaults to codec.Default.
+func WithCodecs(codecs *codec.Registry) Option {
+	return func(r *Resource) {
+		r.codecs = codecs
+	}
+}
+
+// WithChangeFeed publishes changes to entities to the given feed, and serves
+// them as server-sent events at /{name}/events and to websocket subscriptions
+// at /{name}/ws.
+func WithChangeFeed(feed *ChangeFeed) Option {
+	return func(r *Resource) {
+		r.changes = feed
+	}
 }
 
 // NewResource instantiates a Resource and binds routes to the given mux router,
 // to serve the api end points specific to this resource.
-func NewResource(m ResourceManager, router *mux.Router) *Resource {
-	r := &Resource{manager: m}
-	router.Handle(fmt.Sprintf("/%s", m.GetName()), r)
-	router.Handle(fmt.Sprintf("/%s/{id}", m.GetName()), r)
+func NewResource(m ResourceManager, router *mux.Router, opts ...Option) *Resource {
+	r := &Resource{manager: m, logger: slog.Default(), principal: BasicAuthPrincipal, codecs: codec.Default}
+	for _, opt := range opts {
+		opt(r)
+	}
+	name := m.GetName()
+	r.base = "/" + name
+	if r.parent != nil {
+		r.base = fmt.Sprintf("%s/{%s}/%s", r.parent.base, r.parentField, name)
+	}
+	router.Handle(r.base+"/import", r.handle(r.Import)).Methods("POST")
+	if _, ok := m.(AggregateManager); ok {
+		router.Handle(r.base+"/count", r.handle(r.Count)).Methods("GET")
+		router.Handle(r.base+"/aggregate", r.handle(r.Aggregate)).Methods("GET")
+	}
+	if r.changes != nil {
+		router.Handle(r.base+"/events", r.handle(r.Events)).Methods("GET")
+		router.Handle(r.base+"/ws", r.handle(r.WebSocket)).Methods("GET")
+	}
+	r.path, _ = router.Handle(r.base, r).GetPathTemplate()
+	router.Handle(r.base+"/{id}", r)
+	Register(r)
 	return r
 }
 
+// Name returns the name of this resource.
+func (r Resource) Name() string {
+	return r.manager.GetName()
+}
+
+// Path returns the path this resource's collection is served at.
+func (r Resource) Path() string {
+	return r.path
+}
+
+// Manager returns the ResourceManager for this resource.
+func (r Resource) Manager() ResourceManager {
+	return r.manager
+}
+
+// ChangeFeed returns the feed changes to this resource are published to, if any.
+func (r Resource) ChangeFeed() *ChangeFeed {
+	return r.changes
+}
+
+// IdempotencyStore returns the store idempotency keys are recorded in, if any.
+func (r Resource) IdempotencyStore() IdempotencyStore {
+	return r.idempotency
+}
+
+// RateLimits returns the resource's rate limit policy, and false if it has none.
+func (r Resource) RateLimits() (RateLimitPolicy, bool) {
+	return r.rateLimits, r.limiter != nil
+}
+
+// handle returns a http.Handler for the given handler, traced and logged like
+// requests to ServeHTTP.
+func (r Resource) handle(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.serve(w, req, handler)
+	})
+}
+
 // ServeHTTP is the main http handler that handles all api request for this resource.
 // It delegates based on HTTP Method to other methods of this resource.
-func (r Resource) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+func (r Resource) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.serve(w, req, r.route)
+}
+
+// route delegates the given request to a handler based on its HTTP Method.
+func (r Resource) route(rw http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case "GET":
 		r.Get(rw, req)
@@ -54,22 +176,50 @@ func (r Resource) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// serve calls the given handler, tracing the request and logging its outcome.
+func (r Resource) serve(w http.ResponseWriter, req *http.Request, handler http.HandlerFunc) {
+	start := time.Now()
+	id := mux.Vars(req)["id"]
+	ctx, span := r.startRequestSpan(req, id)
+	rw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	req = req.WithContext(ctx)
+	defer func() {
+		span.SetAttributes(AttrHTTPStatus.Int(rw.status))
+		if rw.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rw.status))
+		}
+		span.End()
+		r.logRequest(req, id, rw, time.Since(start))
+	}()
+	if r.allow(rw, req) && r.checkParents(rw, req) {
+		r.idempotent(rw, req, handler)
+	}
+}
+
 // get is the common code between get and head requests.
 func (r Resource) get(rw http.ResponseWriter, req *http.Request) interface{} {
 	var (
-		query = req.URL.Query()
+		query = r.scopeQuery(req, req.URL.Query())
 		vars  = mux.Vars(req)
 		resp  interface{}
 		err   error
 	)
 	id := vars["id"]
 	if id != "" {
-		resp, err = r.manager.GetEntity(id, query)
+		ctx, span := r.startSpan(req.Context(), "GetEntity", AttrEntityID.String(id))
+		resp, err = r.manager.GetEntity(ctx, id, query)
+		if err == nil && !r.owned(req, resp) {
+			err = store.ErrNotFound
+		}
+		endSpan(span, err)
 	} else {
-		resp, err = r.manager.ListEntities(query)
+		ctx, span := r.startSpan(req.Context(), "ListEntities", AttrFilterKeys.StringSlice(store.FilterKeys(query)))
+		resp, err = r.manager.ListEntities(ctx, query)
+		span.SetAttributes(resultCount(resp))
+		endSpan(span, err)
 	}
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		writeError(rw, err.Error(), errorStatus(err))
 		return nil
 	}
 	return resp
@@ -77,18 +227,38 @@ func (r Resource) get(rw http.ResponseWriter, req *http.Request) interface{} {
 
 // Get is the delegate http handler for get requests for this resource.
 func (r Resource) Get(rw http.ResponseWriter, req *http.Request) {
+	if sc, ok := r.streamCodec(req); ok {
+		r.stream(rw, req, sc)
+		return
+	}
+	c, ok := r.negotiate(rw, req)
+	if !ok {
+		return
+	}
 	resp := r.get(rw, req)
 	if resp != nil {
-		util.WriteJSON(resp, rw)
+		r.writeCacheable(rw, req, c, mux.Vars(req)["id"], resp)
 	}
 }
 
-// Head is the delegate http handler for head requests for this resource.
+// Head is the delegate http handler for head requests for this resource,
+// responding with the headers of the get response, including its validators.
+// Collections of managers that can count report the number of entities
+// listed in the X-Total-Count header, so both come from a single query.
 func (r Resource) Head(rw http.ResponseWriter, req *http.Request) {
-	if r.get(rw, req) != nil {
-		rw.Header().Set("Content-Type", "application/json")
-		rw.WriteHeader(http.StatusOK)
+	c, ok := r.negotiate(rw, req)
+	if !ok {
+		return
 	}
+	id := mux.Vars(req)["id"]
+	resp := r.get(rw, req)
+	if resp == nil {
+		return
+	}
+	if _, ok := r.manager.(AggregateManager); ok && id == "" {
+		rw.Header().Set(TotalCountHeader, fmt.Sprint(util.Count(resp)))
+	}
+	r.writeCacheable(rw, req, c, id, resp)
 }
 
 // PostOrPut is the common code between put and post requests.
@@ -103,8 +273,23 @@ func (r Resource) PostOrPut(rw http.ResponseWriter, req *http.Request) {
 		resp   interface{}
 		err    error
 	)
-	if entity, err = r.manager.ParseJSON(req.Body); err != nil {
-		http.Error(rw, err.Error(), http.StatusBadRequest)
+	c, ok := r.negotiate(rw, req)
+	if !ok {
+		return
+	}
+	body, ok := r.requestBody(rw, req)
+	if !ok {
+		return
+	}
+	if body, err = r.stampBody(req, body); err != nil {
+		writeError(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, span := r.startSpan(req.Context(), "ParseJSON")
+	entity, err = r.manager.ParseJSON(body)
+	endSpan(span, err)
+	if err != nil {
+		writeError(rw, err.Error(), parseStatus(err))
 		return
 	}
 	id := vars["id"]
@@ -112,15 +297,32 @@ func (r Resource) PostOrPut(rw http.ResponseWriter, req *http.Request) {
 		id = entity.GetId()
 	}
 	if id != "" {
-		resp, err = r.manager.UpdateEntity(id, entity, query)
+		if !r.checkOwned(rw, req, id) {
+			return
+		}
+		before := r.auditBefore(req, id)
+		ctx, span := r.startSpan(req.Context(), "UpdateEntity", AttrEntityID.String(id))
+		resp, err = r.manager.UpdateEntity(ctx, id, entity, query)
+		endSpan(span, err)
+		if err == nil {
+			r.audit(req, AuditUpdate, id, before, resp)
+			r.publish(AuditUpdate, id, resp)
+		}
 	} else {
-		resp, err = r.manager.CreateEntity(entity, query)
+		ctx, span := r.startSpan(req.Context(), "CreateEntity")
+		resp, err = r.manager.CreateEntity(ctx, entity, query)
+		endSpan(span, err)
+		if err == nil {
+			r.audit(req, AuditCreate, entityID(resp), nil, resp)
+			r.publish(AuditCreate, entityID(resp), resp)
+		}
 	}
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		writeError(rw, err.Error(), errorStatus(err))
 		return
 	}
-	util.WriteJSON(resp, rw)
+	r.setInvalidationHeaders(rw, req, entityID(resp))
+	codec.Write(c, resp, rw)
 }
 
 // Delete is the delegate http handler for delete requests for this resource.
@@ -132,24 +334,38 @@ func (r Resource) Delete(rw http.ResponseWriter, req *http.Request) {
 	)
 	id := vars["id"]
 	if id == "" {
-		http.Error(rw, "Invalid Id", http.StatusBadRequest)
+		writeError(rw, "Invalid Id", http.StatusBadRequest)
 		return
 	}
-	if err = r.manager.DeleteEntity(id, query); err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+	if !r.checkOwned(rw, req, id) {
+		return
+	}
+	before := r.auditBefore(req, id)
+	if before == nil && r.changes != nil {
+		// Delete events carry the deleted entity, to match subscribers' filters.
+		before, _ = r.manager.GetEntity(req.Context(), id, req.URL.Query())
+	}
+	ctx, span := r.startSpan(req.Context(), "DeleteEntity", AttrEntityID.String(id))
+	err = r.manager.DeleteEntity(ctx, id, query)
+	endSpan(span, err)
+	if err != nil {
+		writeError(rw, err.Error(), errorStatus(err))
 		return
 	}
+	r.audit(req, AuditDelete, id, before, nil)
+	r.publish(AuditDelete, id, before)
+	r.setInvalidationHeaders(rw, req, id)
 	rw.WriteHeader(http.StatusNoContent) // Status 204 OK
 }
 
 // Patch is the delegate http handler for patch requests for this resource.
 func (r Resource) Patch(rw http.ResponseWriter, req *http.Request) {
 	// TODO: Implement this.
-	http.Error(rw, "Method Not Supported", http.StatusNotImplemented)
+	writeError(rw, "Method Not Supported", http.StatusNotImplemented)
 }
 
 // UnsupportedMethod is the delegate http handler for unknown requests types.
 // This is the catch-all when request method is not known.
 func (r Resource) UnsupportedMethod(rw http.ResponseWriter, req *http.Request) {
-	http.Error(rw, "Method Not Supported", http.StatusNotImplemented)
+	writeError(rw, "Method Not Supported", http.StatusNotImplemented)
 }
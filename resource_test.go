@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"goresource"
 	"goresource/mocks"
+	"goresource/store"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -36,8 +37,14 @@ var _ = Describe("Resource", func() {
 
 	Context("at initialization", func() {
 		It("sets up routes correctly", func() {
-			manager.EXPECT().GetName().Times(2).Return("foo")
+			manager.EXPECT().GetName().Times(1).Return("foo")
 			r = goresource.NewResource(manager, router)
+			var match mux.RouteMatch
+			for _, route := range []struct{ method, path string }{
+				{"GET", "/foo"}, {"GET", "/foo/fakeid"}, {"POST", "/foo/import"}} {
+				req, _ := http.NewRequest(route.method, route.path, nil)
+				Expect(router.Match(req, &match)).To(BeTrue())
+			}
 		})
 	})
 })
@@ -67,7 +74,7 @@ var _ = Describe("Resource.Get", func() {
 	Context("given a valid id", func() {
 		It("responds with the corresponding entity.", func() {
 			req, _ := http.NewRequest("GET", "/api/test/fakeid", nil)
-			manager.EXPECT().GetEntity("fakeid", req.URL.Query()).Return("fake-entity", nil)
+			manager.EXPECT().GetEntity(gomock.Any(), "fakeid", req.URL.Query()).Return("fake-entity", nil)
 			router.ServeHTTP(rw, req)
 			Expect(rw.Code).To(Equal(http.StatusOK))
 			Expect(rw.Header().Get("Content-Type")).To(Equal("application/json"))
@@ -75,17 +82,24 @@ var _ = Describe("Resource.Get", func() {
 		})
 		It("responds with an error, if one occurs.", func() {
 			req, _ := http.NewRequest("GET", "/api/test/fakeid", nil)
-			manager.EXPECT().GetEntity("fakeid", req.URL.Query()).Return(nil, fmt.Errorf("Test Error"))
+			manager.EXPECT().GetEntity(gomock.Any(), "fakeid", req.URL.Query()).Return(nil, fmt.Errorf("Test Error"))
 			router.ServeHTTP(rw, req)
 			Expect(rw.Code).To(Equal(http.StatusInternalServerError))
 			Expect(rw.Header().Get("Content-Type")).To(Equal("text/plain; charset=utf-8"))
 			Expect(rw.Body.String()).To(Equal("Test Error\n"))
 		})
+		It("responds with not found, if the entity doesn't exist.", func() {
+			req, _ := http.NewRequest("GET", "/api/test/fakeid", nil)
+			manager.EXPECT().GetEntity(gomock.Any(), "fakeid", req.URL.Query()).Return(nil, store.ErrNotFound)
+			router.ServeHTTP(rw, req)
+			Expect(rw.Code).To(Equal(http.StatusNotFound))
+			Expect(rw.Body.String()).To(Equal("not found\n"))
+		})
 	})
 	Context("not given an id", func() {
 		It("responds with all entities.", func() {
 			req, _ := http.NewRequest("GET", "/api/test", nil)
-			manager.EXPECT().ListEntities(req.URL.Query()).Return([]interface{}{"entities"}, nil)
+			manager.EXPECT().ListEntities(gomock.Any(), req.URL.Query()).Return([]interface{}{"entities"}, nil)
 			router.ServeHTTP(rw, req)
 			Expect(rw.Code).To(Equal(http.StatusOK))
 			Expect(rw.Header().Get("Content-Type")).To(Equal("application/json"))
@@ -93,7 +107,7 @@ var _ = Describe("Resource.Get", func() {
 		})
 		It("responds with an error, if one occurs.", func() {
 			req, _ := http.NewRequest("GET", "/api/test", nil)
-			manager.EXPECT().ListEntities(req.URL.Query()).Return(nil, fmt.Errorf("Test Error"))
+			manager.EXPECT().ListEntities(gomock.Any(), req.URL.Query()).Return(nil, fmt.Errorf("Test Error"))
 			router.ServeHTTP(rw, req)
 			Expect(rw.Code).To(Equal(http.StatusInternalServerError))
 			Expect(rw.Header().Get("Content-Type")).To(Equal("text/plain; charset=utf-8"))
@@ -127,7 +141,7 @@ var _ = Describe("Resource.Head", func() {
 	Context("given a valid id", func() {
 		It("responds with the correct headers.", func() {
 			req, _ := http.NewRequest("HEAD", "/api/test/fakeid", nil)
-			manager.EXPECT().GetEntity("fakeid", req.URL.Query()).Return("fake-entity", nil)
+			manager.EXPECT().GetEntity(gomock.Any(), "fakeid", req.URL.Query()).Return("fake-entity", nil)
 			router.ServeHTTP(rw, req)
 			Expect(rw.Code).To(Equal(http.StatusOK))
 			Expect(rw.Header().Get("Content-Type")).To(Equal("application/json"))
@@ -135,7 +149,7 @@ var _ = Describe("Resource.Head", func() {
 		})
 		It("responds with an error, if one occurs.", func() {
 			req, _ := http.NewRequest("HEAD", "/api/test/fakeid", nil)
-			manager.EXPECT().GetEntity("fakeid", req.URL.Query()).Return(nil, fmt.Errorf("Test Error"))
+			manager.EXPECT().GetEntity(gomock.Any(), "fakeid", req.URL.Query()).Return(nil, fmt.Errorf("Test Error"))
 			router.ServeHTTP(rw, req)
 			Expect(rw.Code).To(Equal(http.StatusInternalServerError))
 			Expect(rw.Header().Get("Content-Type")).To(Equal("text/plain; charset=utf-8"))
@@ -145,7 +159,7 @@ var _ = Describe("Resource.Head", func() {
 	Context("not given an id", func() {
 		It("responds with all entities.", func() {
 			req, _ := http.NewRequest("HEAD", "/api/test", nil)
-			manager.EXPECT().ListEntities(req.URL.Query()).Return([]interface{}{"entities"}, nil)
+			manager.EXPECT().ListEntities(gomock.Any(), req.URL.Query()).Return([]interface{}{"entities"}, nil)
 			router.ServeHTTP(rw, req)
 			Expect(rw.Code).To(Equal(http.StatusOK))
 			Expect(rw.Header().Get("Content-Type")).To(Equal("application/json"))
@@ -153,7 +167,7 @@ var _ = Describe("Resource.Head", func() {
 		})
 		It("responds with an error, if one occurs.", func() {
 			req, _ := http.NewRequest("HEAD", "/api/test", nil)
-			manager.EXPECT().ListEntities(req.URL.Query()).Return(nil, fmt.Errorf("Test Error"))
+			manager.EXPECT().ListEntities(gomock.Any(), req.URL.Query()).Return(nil, fmt.Errorf("Test Error"))
 			router.ServeHTTP(rw, req)
 			Expect(rw.Code).To(Equal(http.StatusInternalServerError))
 			Expect(rw.Header().Get("Content-Type")).To(Equal("text/plain; charset=utf-8"))
@@ -190,7 +204,7 @@ var _ = Describe("Resource.PostOrPut", func() {
 			e := &mocks.MockEntity{"fake-entity"}
 			req, _ := http.NewRequest("PUT", "/api/test/fakeid", body)
 			manager.EXPECT().ParseJSON(body).Return(e, nil)
-			manager.EXPECT().UpdateEntity("fakeid", e, req.URL.Query()).Return("fake-entity", nil)
+			manager.EXPECT().UpdateEntity(gomock.Any(), "fakeid", e, req.URL.Query()).Return("fake-entity", nil)
 			router.ServeHTTP(rw, req)
 			Expect(rw.Code).To(Equal(http.StatusOK))
 			Expect(rw.Header().Get("Content-Type")).To(Equal("application/json"))
@@ -201,7 +215,7 @@ var _ = Describe("Resource.PostOrPut", func() {
 			e := &mocks.MockEntity{"fakeid"}
 			req, _ := http.NewRequest("POST", "/api/test", body)
 			manager.EXPECT().ParseJSON(body).Return(e, nil)
-			manager.EXPECT().UpdateEntity("fakeid", e, req.URL.Query()).Return("fake-entity", nil)
+			manager.EXPECT().UpdateEntity(gomock.Any(), "fakeid", e, req.URL.Query()).Return("fake-entity", nil)
 			router.ServeHTTP(rw, req)
 			Expect(rw.Code).To(Equal(http.StatusOK))
 			Expect(rw.Header().Get("Content-Type")).To(Equal("application/json"))
@@ -212,7 +226,7 @@ var _ = Describe("Resource.PostOrPut", func() {
 			e := &mocks.MockEntity{}
 			req, _ := http.NewRequest("POST", "/api/test", body)
 			manager.EXPECT().ParseJSON(body).Return(e, nil)
-			manager.EXPECT().CreateEntity(e, req.URL.Query()).Return("fake-entity", nil)
+			manager.EXPECT().CreateEntity(gomock.Any(), e, req.URL.Query()).Return("fake-entity", nil)
 			router.ServeHTTP(rw, req)
 			Expect(rw.Code).To(Equal(http.StatusOK))
 			Expect(rw.Header().Get("Content-Type")).To(Equal("application/json"))
@@ -223,7 +237,7 @@ var _ = Describe("Resource.PostOrPut", func() {
 			e := &mocks.MockEntity{}
 			req, _ := http.NewRequest("PUT", "/api/test", body)
 			manager.EXPECT().ParseJSON(body).Return(e, nil)
-			manager.EXPECT().CreateEntity(e, req.URL.Query()).Return("fake-entity", nil)
+			manager.EXPECT().CreateEntity(gomock.Any(), e, req.URL.Query()).Return("fake-entity", nil)
 			router.ServeHTTP(rw, req)
 			Expect(rw.Code).To(Equal(http.StatusOK))
 			Expect(rw.Header().Get("Content-Type")).To(Equal("application/json"))
@@ -253,7 +267,7 @@ var _ = Describe("Resource.PostOrPut", func() {
 			e := &mocks.MockEntity{"fake-entity"}
 			req, _ := http.NewRequest("PUT", "/api/test/fakeid", body)
 			manager.EXPECT().ParseJSON(body).Return(e, nil)
-			manager.EXPECT().UpdateEntity("fakeid", e, req.URL.Query()).Return(nil, fmt.Errorf("test error"))
+			manager.EXPECT().UpdateEntity(gomock.Any(), "fakeid", e, req.URL.Query()).Return(nil, fmt.Errorf("test error"))
 			router.ServeHTTP(rw, req)
 			Expect(rw.Code).To(Equal(http.StatusInternalServerError))
 			Expect(rw.Header().Get("Content-Type")).To(Equal("text/plain; charset=utf-8"))
@@ -264,7 +278,7 @@ var _ = Describe("Resource.PostOrPut", func() {
 			e := &mocks.MockEntity{"fakeid"}
 			req, _ := http.NewRequest("POST", "/api/test", body)
 			manager.EXPECT().ParseJSON(body).Return(e, nil)
-			manager.EXPECT().UpdateEntity("fakeid", e, req.URL.Query()).Return(nil, fmt.Errorf("test error"))
+			manager.EXPECT().UpdateEntity(gomock.Any(), "fakeid", e, req.URL.Query()).Return(nil, fmt.Errorf("test error"))
 			router.ServeHTTP(rw, req)
 			Expect(rw.Code).To(Equal(http.StatusInternalServerError))
 			Expect(rw.Header().Get("Content-Type")).To(Equal("text/plain; charset=utf-8"))
@@ -298,14 +312,14 @@ var _ = Describe("Resource.Delete", func() {
 	Context("given a valid id", func() {
 		It("deletes the corresponding entity.", func() {
 			req, _ := http.NewRequest("DELETE", "/api/test/fakeid", nil)
-			manager.EXPECT().DeleteEntity("fakeid", req.URL.Query()).Return(nil)
+			manager.EXPECT().DeleteEntity(gomock.Any(), "fakeid", req.URL.Query()).Return(nil)
 			router.ServeHTTP(rw, req)
 			Expect(rw.Code).To(Equal(http.StatusNoContent))
 			Expect(rw.Body.String()).To(Equal(""))
 		})
 		It("responds with an error, if one occurs.", func() {
 			req, _ := http.NewRequest("DELETE", "/api/test/fakeid", nil)
-			manager.EXPECT().DeleteEntity("fakeid", req.URL.Query()).Return(fmt.Errorf("Test Error"))
+			manager.EXPECT().DeleteEntity(gomock.Any(), "fakeid", req.URL.Query()).Return(fmt.Errorf("Test Error"))
 			router.ServeHTTP(rw, req)
 			Expect(rw.Code).To(Equal(http.StatusInternalServerError))
 			Expect(rw.Header().Get("Content-Type")).To(Equal("text/plain; charset=utf-8"))
@@ -0,0 +1,100 @@
+package goresource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/rockstardevs/goresource/store"
+)
+
+// SearchParam is the query parameter holding a full text query. Listing with
+// it returns the entities matching the query, most relevant first, each with
+// its relevance in store.ScoreField.
+const SearchParam = "q"
+
+// HighlightParam is the query parameter asking for the words matching a full
+// text query to be highlighted, with highlight=true.
+const HighlightParam = "highlight"
+
+// HighlightField is the field holding the highlighted text of each search
+// result, by field name. Matching words are wrapped in <em> tags and the text
+// is html escaped.
+const HighlightField = "_highlights"
+
+// search fetches the entities matching the query's full text query, most
+// relevant first.
+func (manager DefaultManager) search(ctx context.Context, query url.Values) ([]map[string]interface{}, error) {
+	result, err := store.Search(ctx, manager.Store, manager.Name, query.Get(SearchParam), filters(query))
+	if errors.Is(err, store.ErrSearchUnsupported) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidQuery, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if query.Get(HighlightParam) == "true" {
+		words := store.Tokenize(query.Get(SearchParam))
+		for _, e := range result {
+			e[HighlightField] = highlights(e, words)
+		}
+	}
+	return result, nil
+}
+
+// highlights returns the top level string fields of the given entity that
+// contain any of the given words, with the words highlighted.
+func highlights(entity map[string]interface{}, words []string) map[string]string {
+	match := make(map[string]bool, len(words))
+	for _, w := range words {
+		match[w] = true
+	}
+	result := make(map[string]string)
+	for field, v := range entity {
+		if text, ok := v.(string); ok && !strings.HasPrefix(field, "_") {
+			if h, ok := highlight(text, match); ok {
+				result[field] = h
+			}
+		}
+	}
+	return result
+}
+
+// highlight html escapes the given text, wrapping the matching words in <em>
+// tags. It reports whether any word matched.
+func highlight(text string, match map[string]bool) (string, bool) {
+	var (
+		b       strings.Builder
+		start   = -1
+		matched bool
+	)
+	word := func(end int) {
+		w := text[start:end]
+		if match[strings.ToLower(w)] {
+			matched = true
+			b.WriteString("<em>" + html.EscapeString(w) + "</em>")
+		} else {
+			b.WriteString(html.EscapeString(w))
+		}
+		start = -1
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			word(i)
+		}
+		b.WriteString(html.EscapeString(string(r)))
+	}
+	if start >= 0 {
+		word(len(text))
+	}
+	return b.String(), matched
+}
@@ -0,0 +1,93 @@
+package goresource_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"goresource"
+	"goresource/mocks"
+	storepkg "goresource/store"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Search", func() {
+	var (
+		ctrl    *gomock.Controller
+		store   *mocks.MockStore
+		manager goresource.DefaultManager
+		ctx     = context.Background()
+		doc     = map[string]interface{}{"_id": "1", "title": "Go <fast>", "body": "Nothing here.", "_hidden": "go"}
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStore(ctrl)
+		store.EXPECT().ListEntities(gomock.Any(), "books", url.Values{}, gomock.Any()).
+			SetArg(3, []map[string]interface{}{doc}).Return(nil)
+		indexed := storepkg.NewIndexedStore(store, nil)
+		Expect(indexed.Rebuild(ctx, "books")).To(Succeed())
+		manager = goresource.NewDefaultManager("books", indexed)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	// fetch expects the indexed document to be fetched.
+	fetch := func() {
+		found := make(map[string]interface{})
+		for k, v := range doc {
+			found[k] = v
+		}
+		store.EXPECT().GetEntity(gomock.Any(), "books", "1", gomock.Any()).SetArg(3, found).Return(nil)
+	}
+
+	It("lists search results with their score for queries with q.", func() {
+		fetch()
+		result, err := manager.ListEntities(ctx, url.Values{"q": {"go"}})
+		Expect(err).To(BeNil())
+		list := result.([]map[string]interface{})
+		Expect(len(list)).To(Equal(1))
+		Expect(list[0]).To(HaveKey(storepkg.ScoreField))
+		Expect(list[0]).NotTo(HaveKey(goresource.HighlightField))
+	})
+
+	It("highlights matching words in escaped text.", func() {
+		fetch()
+		result, err := manager.ListEntities(ctx, url.Values{"q": {"go"}, "highlight": {"true"}})
+		Expect(err).To(BeNil())
+		list := result.([]map[string]interface{})
+		Expect(list[0][goresource.HighlightField]).To(Equal(map[string]string{"title": "<em>Go</em> &lt;fast&gt;"}))
+	})
+
+	It("streams search results.", func() {
+		fetch()
+		it, err := manager.StreamEntities(ctx, url.Values{"q": {"go"}})
+		Expect(err).To(BeNil())
+		result := make(map[string]interface{})
+		Expect(it.Next(&result)).To(BeTrue())
+		Expect(result["_id"]).To(Equal("1"))
+		Expect(it.Next(&result)).To(BeFalse())
+		Expect(it.Close()).To(Succeed())
+	})
+
+	It("rejects queries with q for stores without search.", func() {
+		plain := goresource.NewDefaultManager("books", store)
+		_, err := plain.ListEntities(ctx, url.Values{"q": {"go"}})
+		Expect(errors.Is(err, goresource.ErrInvalidQuery)).To(BeTrue())
+
+		router := mux.NewRouter()
+		goresource.NewResource(bookManager{plain}, router)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/books?q=go", nil))
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+	})
+})
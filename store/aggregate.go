@@ -0,0 +1,287 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Aggregation operators supported by Metric.
+const (
+	OpCount = "count"
+	OpSum   = "sum"
+	OpAvg   = "avg"
+	OpMin   = "min"
+	OpMax   = "max"
+)
+
+// Aggregation groups entities by the values of some fields and computes
+// metrics over each group. Without GroupBy all entities form a single group.
+type Aggregation struct {
+	GroupBy []string
+	Metrics []Metric
+}
+
+// Metric is an aggregate of a field over a group of entities. Sum and avg
+// ignore values that aren't numbers.
+type Metric struct {
+	// Op is one of OpCount, OpSum, OpAvg, OpMin or OpMax.
+	Op string
+	// Field is the aggregated field, unused by OpCount.
+	Field string
+}
+
+// Name returns the field the metric is returned in, for example avg_price.
+func (m Metric) Name() string {
+	if m.Op == OpCount {
+		return OpCount
+	}
+	return m.Op + "_" + strings.Replace(m.Field, ".", "_", -1)
+}
+
+// Validate checks that the aggregation has known operators and fields to
+// aggregate. Field names containing $ are rejected, since pipelines would
+// read them as expressions such as $$ROOT, exposing whole entities.
+func (a Aggregation) Validate() error {
+	for _, f := range a.GroupBy {
+		if err := checkField(f); err != nil {
+			return err
+		}
+	}
+	for _, m := range a.Metrics {
+		switch m.Op {
+		case OpCount:
+		case OpSum, OpAvg, OpMin, OpMax:
+			if m.Field == "" {
+				return fmt.Errorf("%s needs a field", m.Op)
+			}
+			if err := checkField(m.Field); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown aggregation %q", m.Op)
+		}
+	}
+	return nil
+}
+
+// checkField rejects empty field names and those containing $.
+func checkField(f string) error {
+	if f == "" || strings.Contains(f, "$") {
+		return fmt.Errorf("invalid field %q", f)
+	}
+	return nil
+}
+
+// Counter is implemented by stores that can count entities without fetching them.
+type Counter interface {
+	// CountEntities returns the number of entities matching the given filters.
+	CountEntities(ctx context.Context, name string, filters url.Values) (int, error)
+}
+
+// Count returns the number of entities matching the given filters, counted by
+// the store if it supports it, otherwise by listing them.
+func Count(ctx context.Context, s Store, name string, filters url.Values) (int, error) {
+	if counter, ok := s.(Counter); ok {
+		return counter.CountEntities(ctx, name, filters)
+	}
+	var result []map[string]interface{}
+	if err := s.ListEntities(ctx, name, filters, &result); err != nil {
+		return 0, err
+	}
+	return len(result), nil
+}
+
+// Aggregator is implemented by stores that can aggregate entities natively.
+type Aggregator interface {
+	// Aggregate computes the given aggregation over the entities matching the
+	// given filters into result, a pointer to a slice with a row per group.
+	// Rows hold the group's values of the GroupBy fields and each metric by
+	// its Name, ordered by the group values.
+	Aggregate(ctx context.Context, name string, filters url.Values, agg Aggregation, result interface{}) error
+}
+
+// Aggregate computes the given aggregation over the entities matching the
+// given filters, by the store if it supports it, otherwise over the listed
+// entities in memory.
+func Aggregate(ctx context.Context, s Store, name string, filters url.Values, agg Aggregation) ([]map[string]interface{}, error) {
+	if err := agg.Validate(); err != nil {
+		return nil, err
+	}
+	result := make([]map[string]interface{}, 0)
+	if aggregator, ok := s.(Aggregator); ok {
+		if err := aggregator.Aggregate(ctx, name, filters, agg, &result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	var entities []map[string]interface{}
+	if err := s.ListEntities(ctx, name, filters, &entities); err != nil {
+		return nil, err
+	}
+	return aggregate(entities, agg), nil
+}
+
+// group accumulates the metrics of a group of entities.
+type group struct {
+	row    map[string]interface{}
+	sums   []float64
+	counts []int
+}
+
+// aggregate computes the given aggregation over the given entities.
+func aggregate(entities []map[string]interface{}, agg Aggregation) []map[string]interface{} {
+	groups := make(map[string]*group)
+	var keys []string
+	for _, e := range entities {
+		values := make([]interface{}, len(agg.GroupBy))
+		for i, f := range agg.GroupBy {
+			values[i] = lookup(e, f)
+		}
+		data, _ := json.Marshal(values)
+		g, ok := groups[string(data)]
+		if !ok {
+			g = &group{row: make(map[string]interface{}), sums: make([]float64, len(agg.Metrics)), counts: make([]int, len(agg.Metrics))}
+			for i, f := range agg.GroupBy {
+				g.row[f] = values[i]
+			}
+			groups[string(data)] = g
+			keys = append(keys, string(data))
+		}
+		for i, m := range agg.Metrics {
+			g.add(i, m, lookup(e, m.Field))
+		}
+	}
+	rows := make([]map[string]interface{}, 0, len(keys))
+	for _, k := range keys {
+		g := groups[k]
+		for i, m := range agg.Metrics {
+			switch m.Op {
+			case OpCount:
+				g.row[m.Name()] = g.counts[i]
+			case OpSum:
+				g.row[m.Name()] = g.sums[i]
+			case OpAvg:
+				if g.counts[i] > 0 {
+					g.row[m.Name()] = g.sums[i] / float64(g.counts[i])
+				} else {
+					g.row[m.Name()] = nil
+				}
+			default:
+				if _, ok := g.row[m.Name()]; !ok {
+					g.row[m.Name()] = nil
+				}
+			}
+		}
+		rows = append(rows, g.row)
+	}
+	SortGroups(rows, agg.GroupBy)
+	return rows
+}
+
+// add accumulates the value of the i-th metric of an entity of the group.
+func (g *group) add(i int, m Metric, value interface{}) {
+	switch m.Op {
+	case OpCount:
+		g.counts[i]++
+	case OpSum, OpAvg:
+		if n, ok := number(value); ok {
+			g.sums[i] += n
+			g.counts[i]++
+		}
+	case OpMin, OpMax:
+		if value == nil {
+			return
+		}
+		current, ok := g.row[m.Name()]
+		c := compare(value, current)
+		if !ok || current == nil || (m.Op == OpMin && c < 0) || (m.Op == OpMax && c > 0) {
+			g.row[m.Name()] = value
+		}
+	}
+}
+
+// SortGroups orders aggregation rows by the values of the given fields.
+func SortGroups(rows []map[string]interface{}, fields []string) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, f := range fields {
+			if c := compare(rows[i][f], rows[j][f]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+// lookup returns the value of a field of the given entity, following dotted paths.
+func lookup(entity map[string]interface{}, field string) interface{} {
+	var value interface{} = entity
+	for _, part := range strings.Split(field, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[part]
+	}
+	return value
+}
+
+// number converts numeric values to float64.
+func number(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// compare orders values, nil first, then numbers, then anything else by its
+// string form. Times are compared as times.
+func compare(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+	if x, ok := a.(time.Time); ok {
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y)
+		}
+	}
+	x, xok := number(a)
+	y, yok := number(b)
+	switch {
+	case xok && yok:
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case xok:
+		return -1
+	case yok:
+		return 1
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
@@ -0,0 +1,99 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"goresource/mocks"
+	"goresource/store"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Aggregate", func() {
+	var (
+		ctrl    *gomock.Controller
+		backend *mocks.MockStore
+		ctx     = context.Background()
+		filters = url.Values{"genre": {"tech"}}
+		books   = []map[string]interface{}{
+			{"author": "b", "price": 10.0, "meta": map[string]interface{}{"year": 2001}},
+			{"author": "a", "price": 20.0, "meta": map[string]interface{}{"year": 2003}},
+			{"author": "b", "price": 30.0, "meta": map[string]interface{}{"year": 1999}},
+			{"author": "a", "price": "free"},
+			{"price": 5.0},
+		}
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		backend = mocks.NewMockStore(ctrl)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("computes metrics over groups in memory for stores without aggregation.", func() {
+		backend.EXPECT().ListEntities(gomock.Any(), "books", filters, gomock.Any()).SetArg(3, books).Return(nil)
+		rows, err := store.Aggregate(ctx, backend, "books", filters, store.Aggregation{
+			GroupBy: []string{"author"},
+			Metrics: []store.Metric{
+				{Op: store.OpCount}, {Op: store.OpSum, Field: "price"}, {Op: store.OpAvg, Field: "price"},
+				{Op: store.OpMin, Field: "meta.year"}, {Op: store.OpMax, Field: "meta.year"},
+			},
+		})
+		Expect(err).To(BeNil())
+		Expect(rows).To(Equal([]map[string]interface{}{
+			{"author": nil, "count": 1, "sum_price": 5.0, "avg_price": 5.0, "min_meta_year": nil, "max_meta_year": nil},
+			{"author": "a", "count": 2, "sum_price": 20.0, "avg_price": 20.0, "min_meta_year": 2003, "max_meta_year": 2003},
+			{"author": "b", "count": 2, "sum_price": 40.0, "avg_price": 20.0, "min_meta_year": 1999, "max_meta_year": 2001},
+		}))
+	})
+
+	It("aggregates all entities as one group without group fields.", func() {
+		backend.EXPECT().ListEntities(gomock.Any(), "books", filters, gomock.Any()).SetArg(3, books).Return(nil)
+		rows, err := store.Aggregate(ctx, backend, "books", filters, store.Aggregation{
+			Metrics: []store.Metric{{Op: store.OpMax, Field: "price"}},
+		})
+		Expect(err).To(BeNil())
+		Expect(rows).To(Equal([]map[string]interface{}{{"max_price": "free"}}))
+	})
+
+	It("rejects unknown operators and metrics without a field.", func() {
+		_, err := store.Aggregate(ctx, backend, "books", nil, store.Aggregation{Metrics: []store.Metric{{Op: "median", Field: "price"}}})
+		Expect(err).To(MatchError(`unknown aggregation "median"`))
+		_, err = store.Aggregate(ctx, backend, "books", nil, store.Aggregation{Metrics: []store.Metric{{Op: store.OpSum}}})
+		Expect(err).To(MatchError("sum needs a field"))
+	})
+
+	It("rejects fields containing $.", func() {
+		_, err := store.Aggregate(ctx, backend, "books", nil, store.Aggregation{GroupBy: []string{"$ROOT"}})
+		Expect(err).To(MatchError(`invalid field "$ROOT"`))
+		_, err = store.Aggregate(ctx, backend, "books", nil, store.Aggregation{Metrics: []store.Metric{{Op: store.OpMax, Field: "a.$b"}}})
+		Expect(err).To(MatchError(`invalid field "a.$b"`))
+	})
+
+	It("passes through errors listing entities.", func() {
+		backend.EXPECT().ListEntities(gomock.Any(), "books", filters, gomock.Any()).Return(fmt.Errorf("test error"))
+		_, err := store.Aggregate(ctx, backend, "books", filters, store.Aggregation{Metrics: []store.Metric{{Op: store.OpCount}}})
+		Expect(err).To(MatchError("test error"))
+	})
+})
+
+var _ = Describe("Count", func() {
+	It("counts listed entities for stores that can't count.", func() {
+		ctrl := gomock.NewController(GinkgoT())
+		defer ctrl.Finish()
+		backend := mocks.NewMockStore(ctrl)
+		backend.EXPECT().ListEntities(gomock.Any(), "books", url.Values{"a": {"b"}}, gomock.Any()).
+			SetArg(3, []map[string]interface{}{{}, {}}).Return(nil)
+		n, err := store.Count(context.Background(), backend, "books", url.Values{"a": {"b"}})
+		Expect(err).To(BeNil())
+		Expect(n).To(Equal(2))
+	})
+})
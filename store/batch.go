@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// BatchCreator is implemented by stores that can persist many entities in a
+// single operation.
+type BatchCreator interface {
+	// CreateEntities persists the given entities. Entities that could not be
+	// persisted are reported by their index, an error is returned only if the
+	// batch as a whole failed.
+	CreateEntities(ctx context.Context, name string, data []interface{}) (map[int]error, error)
+}
+
+// CreateEntities persists the given entities in batch if the store supports
+// it, otherwise one at a time.
+func CreateEntities(ctx context.Context, s Store, name string, data []interface{}) (map[int]error, error) {
+	if creator, ok := s.(BatchCreator); ok {
+		return creator.CreateEntities(ctx, name, data)
+	}
+	failed := make(map[int]error)
+	for i, d := range data {
+		result := make(map[string]interface{})
+		if err := s.CreateEntity(ctx, name, d, &result); err != nil {
+			failed[i] = err
+		}
+	}
+	return failed, nil
+}
+
+// BatchGetter is implemented by stores that can fetch many entities by id in a
+// single operation.
+type BatchGetter interface {
+	// GetEntities fetches the entities with the given ids into result, a
+	// pointer to a slice. Ids without an entity are skipped.
+	GetEntities(ctx context.Context, name string, ids []string, result interface{}) error
+}
+
+// GetEntities fetches the entities with the given ids in batch if the store
+// supports it, otherwise one at a time. Ids without an entity are skipped.
+func GetEntities(ctx context.Context, s Store, name string, ids []string) ([]map[string]interface{}, error) {
+	result := make([]map[string]interface{}, 0, len(ids))
+	if getter, ok := s.(BatchGetter); ok {
+		if err := getter.GetEntities(ctx, name, ids, &result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	for _, id := range ids {
+		entity := make(map[string]interface{})
+		if err := s.GetEntity(ctx, name, id, &entity); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		result = append(result, entity)
+	}
+	return result, nil
+}
+
+// assign stores the given entities in result, a pointer to a slice, round
+// tripping through json for slices of other types.
+func assign(entities []map[string]interface{}, result interface{}) error {
+	if r, ok := result.(*[]map[string]interface{}); ok {
+		*r = entities
+		return nil
+	}
+	data, err := json.Marshal(entities)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
@@ -0,0 +1,93 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+
+	"goresource/mocks"
+	"goresource/store"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CreateEntities", func() {
+	var (
+		ctrl    *gomock.Controller
+		backend *mocks.MockStore
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		backend = mocks.NewMockStore(ctrl)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	Context("given a store that cannot create in batch", func() {
+		It("creates entities one at a time, reporting failures by index.", func() {
+			backend.EXPECT().CreateEntity(gomock.Any(), "books", "a", gomock.Any()).Return(nil)
+			backend.EXPECT().CreateEntity(gomock.Any(), "books", "b", gomock.Any()).Return(fmt.Errorf("test error"))
+			backend.EXPECT().CreateEntity(gomock.Any(), "books", "c", gomock.Any()).Return(nil)
+			failed, err := store.CreateEntities(context.Background(), backend, "books", []interface{}{"a", "b", "c"})
+			Expect(err).To(BeNil())
+			Expect(len(failed)).To(Equal(1))
+			Expect(failed[1].Error()).To(Equal("test error"))
+		})
+	})
+
+	Context("given a store that can create in batch", func() {
+		It("delegates to the store.", func() {
+			backend.EXPECT().CreateEntity(gomock.Any(), "books", "a", gomock.Any()).Return(nil)
+			s := store.NewTracedStore(backend)
+			failed, err := store.CreateEntities(context.Background(), s, "books", []interface{}{"a"})
+			Expect(err).To(BeNil())
+			Expect(failed).To(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("GetEntities", func() {
+	var (
+		ctrl    *gomock.Controller
+		backend *mocks.MockStore
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		backend = mocks.NewMockStore(ctrl)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	Context("given a store that cannot get in batch", func() {
+		It("gets entities one at a time, skipping missing ones.", func() {
+			backend.EXPECT().GetEntity(gomock.Any(), "books", "a", gomock.Any()).SetArg(3, map[string]interface{}{"id": "a"}).Return(nil)
+			backend.EXPECT().GetEntity(gomock.Any(), "books", "b", gomock.Any()).Return(store.ErrNotFound)
+			result, err := store.GetEntities(context.Background(), backend, "books", []string{"a", "b"})
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal([]map[string]interface{}{{"id": "a"}}))
+		})
+
+		It("returns other errors.", func() {
+			backend.EXPECT().GetEntity(gomock.Any(), "books", "a", gomock.Any()).Return(fmt.Errorf("test error"))
+			_, err := store.GetEntities(context.Background(), backend, "books", []string{"a"})
+			Expect(err).To(MatchError("test error"))
+		})
+	})
+
+	Context("given a store that can get in batch", func() {
+		It("delegates to the store.", func() {
+			backend.EXPECT().GetEntity(gomock.Any(), "books", "a", gomock.Any()).SetArg(3, map[string]interface{}{"id": "a"}).Return(nil)
+			result, err := store.GetEntities(context.Background(), store.NewTracedStore(backend), "books", []string{"a"})
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal([]map[string]interface{}{{"id": "a"}}))
+		})
+	})
+})
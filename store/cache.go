@@ -0,0 +1,370 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCacheTTL is the default time entities are cached for.
+const DefaultCacheTTL = time.Minute
+
+// Cache is a key value cache with expiring entries, shared by CachedStores.
+type Cache interface {
+	// Get returns the value cached for the key, if any.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set caches the value for the key, for the given time if positive.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the given keys.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// CacheStats counts the lookups of a CachedStore.
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	// Errors counts failed cache operations, which fall back to the store.
+	Errors uint64 `json:"errors"`
+}
+
+// CachedStore is a Store caching the results of GetEntity and ListEntities,
+// keyed by id and by filters. Changes made through it invalidate the cached
+// entity and every cached list of its collection, including results of reads
+// racing with them. Changes made by other
+// writers are seen once cached results expire. Results are cached as json, so
+// they decode like json into results.
+type CachedStore struct {
+	Forwarder
+	Cache Cache
+	// TTL is the time results are cached for, defaults to DefaultCacheTTL.
+	TTL time.Duration
+	// Prefix is prepended to cache keys, for stores sharing a cache.
+	Prefix string
+
+	hits, misses, errors uint64
+}
+
+// NewCachedStore wraps the given store, caching results in the given cache.
+func NewCachedStore(s Store, cache Cache) *CachedStore {
+	return &CachedStore{Forwarder: Forwarder{s}, Cache: cache, TTL: DefaultCacheTTL, Prefix: "store"}
+}
+
+// Stats returns the number of cache hits, misses and errors so far.
+func (s *CachedStore) Stats() CacheStats {
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&s.hits),
+		Misses: atomic.LoadUint64(&s.misses),
+		Errors: atomic.LoadUint64(&s.errors),
+	}
+}
+
+// entityKey returns the key of the entity with the given id, in its current
+// version. Entities read from the store before a write but cached after it
+// are cached under an earlier version, so they are no longer found.
+func (s *CachedStore) entityKey(ctx context.Context, name, id string) (string, error) {
+	version, err := s.generation(ctx, s.versionKey(name, id))
+	if err != nil {
+		return "", err
+	}
+	return s.Prefix + ":" + name + ":entity:" + id + ":" + string(version), nil
+}
+
+// versionKey returns the key of the version of the entity with the given id,
+// a random token removed by every write to it.
+func (s *CachedStore) versionKey(name, id string) string {
+	return s.Prefix + ":" + name + ":version:" + id
+}
+
+// generationKey returns the key of the collection's generation, a random
+// token changed by every write so earlier cached lists are no longer found.
+func (s *CachedStore) generationKey(name string) string {
+	return s.Prefix + ":" + name + ":generation"
+}
+
+// listKey returns the key of the list of entities matching the given filters,
+// in the current generation of the collection.
+func (s *CachedStore) listKey(ctx context.Context, name string, filters url.Values) (string, error) {
+	gen, err := s.generation(ctx, s.generationKey(name))
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(filters.Encode()))
+	return s.Prefix + ":" + name + ":list:" + string(gen) + ":" + hex.EncodeToString(sum[:]), nil
+}
+
+// generation returns the token cached for the given key, starting a new
+// generation if there is none.
+func (s *CachedStore) generation(ctx context.Context, key string) ([]byte, error) {
+	gen, ok, err := s.Cache.Get(ctx, key)
+	if err != nil || ok {
+		return gen, err
+	}
+	return s.newGeneration(ctx, key)
+}
+
+// newGeneration caches a new random token for the given key.
+func (s *CachedStore) newGeneration(ctx context.Context, key string) ([]byte, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	gen := []byte(hex.EncodeToString(b))
+	return gen, s.Cache.Set(ctx, key, gen, 0)
+}
+
+// cached decodes the value cached for the key into result, reporting whether it was found.
+func (s *CachedStore) cached(ctx context.Context, key string, result interface{}) bool {
+	data, ok, err := s.Cache.Get(ctx, key)
+	if err == nil && ok {
+		err = json.Unmarshal(data, result)
+	}
+	switch {
+	case err != nil:
+		atomic.AddUint64(&s.errors, 1)
+	case ok:
+		atomic.AddUint64(&s.hits, 1)
+		return true
+	}
+	atomic.AddUint64(&s.misses, 1)
+	return false
+}
+
+// cache caches the given result for the key.
+func (s *CachedStore) cache(ctx context.Context, key string, result interface{}) {
+	data, err := json.Marshal(result)
+	if err == nil {
+		err = s.Cache.Set(ctx, key, data, s.TTL)
+	}
+	if err != nil {
+		atomic.AddUint64(&s.errors, 1)
+	}
+}
+
+// invalidate removes the versions of the entities with the given ids and
+// starts a new generation of the collection's cached lists. Failures are
+// counted, the change itself succeeded.
+func (s *CachedStore) invalidate(ctx context.Context, name string, ids ...string) {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.versionKey(name, id)
+	}
+	var err error
+	if len(keys) > 0 {
+		err = s.Cache.Delete(ctx, keys...)
+	}
+	if _, genErr := s.newGeneration(ctx, s.generationKey(name)); err == nil {
+		err = genErr
+	}
+	if err != nil {
+		atomic.AddUint64(&s.errors, 1)
+	}
+}
+
+// GetEntity fetches the entity with the given id from the cache, or from the
+// store caching it.
+func (s *CachedStore) GetEntity(ctx context.Context, name string, id string, result interface{}) error {
+	key, err := s.entityKey(ctx, name, id)
+	if err != nil {
+		atomic.AddUint64(&s.errors, 1)
+		return s.Store.GetEntity(ctx, name, id, result)
+	}
+	if s.cached(ctx, key, result) {
+		return nil
+	}
+	if err := s.Store.GetEntity(ctx, name, id, result); err != nil {
+		return err
+	}
+	s.cache(ctx, key, result)
+	return nil
+}
+
+// ListEntities fetches the entities matching the given filters from the
+// cache, or from the store caching them.
+func (s *CachedStore) ListEntities(ctx context.Context, name string, filters url.Values, result interface{}) error {
+	key, err := s.listKey(ctx, name, filters)
+	if err != nil {
+		atomic.AddUint64(&s.errors, 1)
+		return s.Store.ListEntities(ctx, name, filters, result)
+	}
+	if s.cached(ctx, key, result) {
+		return nil
+	}
+	if err := s.Store.ListEntities(ctx, name, filters, result); err != nil {
+		return err
+	}
+	s.cache(ctx, key, result)
+	return nil
+}
+
+// CreateEntity persists a new entity, invalidating the collection's cached lists.
+func (s *CachedStore) CreateEntity(ctx context.Context, name string, data interface{}, result interface{}) error {
+	err := s.Store.CreateEntity(ctx, name, data, result)
+	s.invalidate(ctx, name)
+	return err
+}
+
+// CreateEntities persists entities in batch, invalidating the collection's
+// cached lists even if it fails, since part of the batch may be persisted.
+func (s *CachedStore) CreateEntities(ctx context.Context, name string, data []interface{}) (map[int]error, error) {
+	failed, err := CreateEntities(ctx, s.Store, name, data)
+	s.invalidate(ctx, name)
+	return failed, err
+}
+
+// UpdateEntity persists changes to an entity, invalidating it and the
+// collection's cached lists.
+func (s *CachedStore) UpdateEntity(ctx context.Context, name string, id string, data interface{}, result interface{}) error {
+	err := s.Store.UpdateEntity(ctx, name, id, data, result)
+	s.invalidate(ctx, name, id)
+	return err
+}
+
+// DeleteEntity removes an entity, invalidating it and the collection's cached lists.
+func (s *CachedStore) DeleteEntity(ctx context.Context, name string, id string) error {
+	err := s.Store.DeleteEntity(ctx, name, id)
+	s.invalidate(ctx, name, id)
+	return err
+}
+
+// WithTransaction runs fn in a transaction of the wrapped store, bypassing
+// the cache so it sees its own changes, and invalidates what it changed once
+// it's done.
+func (s *CachedStore) WithTransaction(ctx context.Context, fn TxFunc) error {
+	changed := &cacheTx{changes: make(map[string][]string)}
+	err := WithTransaction(ctx, s.Store, func(ctx context.Context, tx Store) error {
+		changed.Store = tx
+		return fn(ctx, changed)
+	})
+	for name, ids := range changed.changes {
+		s.invalidate(ctx, name, ids...)
+	}
+	return err
+}
+
+// cacheTx is a store in a transaction recording the entities changed in it.
+type cacheTx struct {
+	Store
+	mu      sync.Mutex
+	changes map[string][]string
+}
+
+func (tx *cacheTx) changed(name string, ids ...string) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.changes[name] = append(tx.changes[name], ids...)
+}
+
+// WithTransaction runs fn in the same transaction.
+func (tx *cacheTx) WithTransaction(ctx context.Context, fn TxFunc) error {
+	return fn(ctx, tx)
+}
+
+func (tx *cacheTx) CreateEntity(ctx context.Context, name string, data interface{}, result interface{}) error {
+	tx.changed(name)
+	return tx.Store.CreateEntity(ctx, name, data, result)
+}
+
+func (tx *cacheTx) UpdateEntity(ctx context.Context, name string, id string, data interface{}, result interface{}) error {
+	tx.changed(name, id)
+	return tx.Store.UpdateEntity(ctx, name, id, data, result)
+}
+
+func (tx *cacheTx) DeleteEntity(ctx context.Context, name string, id string) error {
+	tx.changed(name, id)
+	return tx.Store.DeleteEntity(ctx, name, id)
+}
+
+// LRUCache is an in-process Cache holding a bounded number of entries,
+// evicting the least recently used.
+type LRUCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRUCache returns a cache holding up to size entries, each for at most
+// ttl if positive.
+func NewLRUCache(size int, ttl time.Duration) *LRUCache {
+	return &LRUCache{size: size, ttl: ttl, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// Len returns the number of cached entries, including expired ones not yet evicted.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Get returns the unexpired value cached for the key, marking it recently used.
+func (c *LRUCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*lruEntry)
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		c.remove(el)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(el)
+	return e.value, true, nil
+}
+
+// Set caches the value for the key, evicting the least recently used entry if full.
+func (c *LRUCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	if c.ttl > 0 && (ttl <= 0 || ttl > c.ttl) {
+		ttl = c.ttl
+	}
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*lruEntry)
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(el)
+		return nil
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.size > 0 && c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+// Delete removes the given keys.
+func (c *LRUCache) Delete(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := c.entries[key]; ok {
+			c.remove(el)
+		}
+	}
+	return nil
+}
+
+// remove drops the given entry, with the lock held.
+func (c *LRUCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*lruEntry).key)
+}
@@ -0,0 +1,212 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"goresource/mocks"
+	"goresource/store"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// failingCache is a cache whose operations fail.
+type failingCache struct{}
+
+func (failingCache) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.New("test error")
+}
+
+func (failingCache) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.New("test error")
+}
+
+func (failingCache) Delete(context.Context, ...string) error {
+	return errors.New("test error")
+}
+
+// failingBatchStore is a store whose batch inserts fail part way.
+type failingBatchStore struct {
+	*mocks.MockStore
+}
+
+func (failingBatchStore) CreateEntities(context.Context, string, []interface{}) (map[int]error, error) {
+	return nil, errors.New("test error")
+}
+
+var _ = Describe("CachedStore", func() {
+	var (
+		ctrl    *gomock.Controller
+		backend *mocks.MockStore
+		s       *store.CachedStore
+		ctx     = context.Background()
+		book    = map[string]interface{}{"id": "b1", "name": "go"}
+	)
+
+	// get fetches the book with the given id.
+	get := func(id string) map[string]interface{} {
+		result := make(map[string]interface{})
+		Expect(s.GetEntity(ctx, "books", id, &result)).To(Succeed())
+		return result
+	}
+
+	// list lists the books matching the given filters.
+	list := func(filters url.Values) []map[string]interface{} {
+		var result []map[string]interface{}
+		Expect(s.ListEntities(ctx, "books", filters, &result)).To(Succeed())
+		return result
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		backend = mocks.NewMockStore(ctrl)
+		s = store.NewCachedStore(backend, store.NewLRUCache(100, 0))
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("reads entities through the cache.", func() {
+		backend.EXPECT().GetEntity(gomock.Any(), "books", "b1", gomock.Any()).SetArg(3, book).Return(nil).Times(1)
+		Expect(get("b1")).To(Equal(book))
+		Expect(get("b1")).To(Equal(book))
+		Expect(s.Stats()).To(Equal(store.CacheStats{Hits: 1, Misses: 1}))
+	})
+
+	It("doesn't cache missing entities.", func() {
+		backend.EXPECT().GetEntity(gomock.Any(), "books", "x", gomock.Any()).Return(store.ErrNotFound).Times(2)
+		for i := 0; i < 2; i++ {
+			Expect(s.GetEntity(ctx, "books", "x", &map[string]interface{}{})).To(Equal(store.ErrNotFound))
+		}
+	})
+
+	It("caches lists by their filters.", func() {
+		tech := []map[string]interface{}{book}
+		backend.EXPECT().ListEntities(gomock.Any(), "books", url.Values{"genre": {"tech"}}, gomock.Any()).
+			SetArg(3, tech).Return(nil).Times(1)
+		backend.EXPECT().ListEntities(gomock.Any(), "books", url.Values{"genre": {"fiction"}}, gomock.Any()).
+			SetArg(3, []map[string]interface{}{}).Return(nil).Times(1)
+		Expect(list(url.Values{"genre": {"tech"}})).To(Equal(tech))
+		Expect(list(url.Values{"genre": {"fiction"}})).To(BeEmpty())
+		Expect(list(url.Values{"genre": {"tech"}})).To(Equal(tech))
+		Expect(list(url.Values{"genre": {"fiction"}})).To(BeEmpty())
+		Expect(s.Stats().Hits).To(Equal(uint64(2)))
+	})
+
+	It("invalidates lists on create and entities on update and delete.", func() {
+		backend.EXPECT().GetEntity(gomock.Any(), "books", "b1", gomock.Any()).SetArg(3, book).Return(nil).Times(3)
+		backend.EXPECT().ListEntities(gomock.Any(), "books", gomock.Any(), gomock.Any()).Return(nil).Times(4)
+		backend.EXPECT().CreateEntity(gomock.Any(), "books", gomock.Any(), gomock.Any()).Return(nil)
+		backend.EXPECT().UpdateEntity(gomock.Any(), "books", "b1", gomock.Any(), gomock.Any()).Return(nil)
+		backend.EXPECT().DeleteEntity(gomock.Any(), "books", "b1").Return(nil)
+
+		get("b1")
+		list(nil)
+		Expect(s.CreateEntity(ctx, "books", book, &map[string]interface{}{})).To(Succeed())
+		get("b1")
+		list(nil)
+		Expect(s.UpdateEntity(ctx, "books", "b1", book, &map[string]interface{}{})).To(Succeed())
+		get("b1")
+		list(nil)
+		Expect(s.DeleteEntity(ctx, "books", "b1")).To(Succeed())
+		get("b1")
+		list(nil)
+	})
+
+	It("doesn't cache entities read before a concurrent write.", func() {
+		updated := map[string]interface{}{"id": "b1", "name": "rust"}
+		gomock.InOrder(
+			backend.EXPECT().GetEntity(gomock.Any(), "books", "b1", gomock.Any()).
+				DoAndReturn(func(_ context.Context, _, _ string, result interface{}) error {
+					Expect(s.UpdateEntity(ctx, "books", "b1", updated, &map[string]interface{}{})).To(Succeed())
+					*result.(*map[string]interface{}) = book
+					return nil
+				}),
+			backend.EXPECT().GetEntity(gomock.Any(), "books", "b1", gomock.Any()).SetArg(3, updated).Return(nil),
+		)
+		backend.EXPECT().UpdateEntity(gomock.Any(), "books", "b1", updated, gomock.Any()).Return(nil)
+		Expect(get("b1")).To(Equal(book))
+		Expect(get("b1")).To(Equal(updated))
+		Expect(get("b1")).To(Equal(updated))
+	})
+
+	It("invalidates lists after failed batch inserts.", func() {
+		s = store.NewCachedStore(failingBatchStore{backend}, store.NewLRUCache(100, 0))
+		backend.EXPECT().ListEntities(gomock.Any(), "books", gomock.Any(), gomock.Any()).Return(nil).Times(2)
+		list(nil)
+		_, err := s.CreateEntities(ctx, "books", []interface{}{book, book})
+		Expect(err).NotTo(BeNil())
+		list(nil)
+	})
+
+	It("invalidates the changes of transactions once they're done.", func() {
+		tx := mocks.NewMockStore(ctrl)
+		s = store.NewCachedStore(&txStore{MockStore: backend, tx: tx}, store.NewLRUCache(100, 0))
+		backend.EXPECT().GetEntity(gomock.Any(), "books", "b1", gomock.Any()).SetArg(3, book).Return(nil).Times(2)
+		tx.EXPECT().GetEntity(gomock.Any(), "books", "b1", gomock.Any()).SetArg(3, book).Return(nil)
+		tx.EXPECT().DeleteEntity(gomock.Any(), "books", "b1").Return(nil)
+		get("b1")
+		err := store.WithTransaction(ctx, s, func(ctx context.Context, t store.Store) error {
+			Expect(t.GetEntity(ctx, "books", "b1", &map[string]interface{}{})).To(Succeed())
+			return t.DeleteEntity(ctx, "books", "b1")
+		})
+		Expect(err).To(BeNil())
+		get("b1")
+	})
+
+	It("falls back to the store when the cache fails.", func() {
+		s = store.NewCachedStore(backend, failingCache{})
+		backend.EXPECT().GetEntity(gomock.Any(), "books", "b1", gomock.Any()).SetArg(3, book).Return(nil)
+		backend.EXPECT().ListEntities(gomock.Any(), "books", gomock.Any(), gomock.Any()).Return(nil)
+		backend.EXPECT().DeleteEntity(gomock.Any(), "books", "b1").Return(nil)
+		Expect(get("b1")).To(Equal(book))
+		list(nil)
+		Expect(s.DeleteEntity(ctx, "books", "b1")).To(Succeed())
+		Expect(s.Stats().Errors).To(Equal(uint64(3)))
+	})
+})
+
+var _ = Describe("LRUCache", func() {
+	var ctx = context.Background()
+
+	It("evicts the least recently used entries.", func() {
+		c := store.NewLRUCache(2, 0)
+		c.Set(ctx, "a", []byte("1"), 0)
+		c.Set(ctx, "b", []byte("2"), 0)
+		c.Get(ctx, "a")
+		c.Set(ctx, "c", []byte("3"), 0)
+		Expect(c.Len()).To(Equal(2))
+		_, ok, _ := c.Get(ctx, "b")
+		Expect(ok).To(BeFalse())
+		value, ok, _ := c.Get(ctx, "a")
+		Expect(ok).To(BeTrue())
+		Expect(value).To(Equal([]byte("1")))
+	})
+
+	It("expires entries after their ttl, bounded by the cache's.", func() {
+		c := store.NewLRUCache(10, 20*time.Millisecond)
+		c.Set(ctx, "short", []byte("1"), time.Millisecond)
+		c.Set(ctx, "long", []byte("2"), time.Hour)
+		time.Sleep(5 * time.Millisecond)
+		_, ok, _ := c.Get(ctx, "short")
+		Expect(ok).To(BeFalse())
+		_, ok, _ = c.Get(ctx, "long")
+		Expect(ok).To(BeTrue())
+		time.Sleep(20 * time.Millisecond)
+		_, ok, _ = c.Get(ctx, "long")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("deletes entries.", func() {
+		c := store.NewLRUCache(10, 0)
+		c.Set(ctx, "a", []byte("1"), 0)
+		Expect(c.Delete(ctx, "a", "b")).To(Succeed())
+		Expect(c.Len()).To(Equal(0))
+	})
+})
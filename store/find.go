@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// FindOptions orders and limits the entities fetched by Find.
+type FindOptions struct {
+	// Sort lists the fields to order entities by, descending if prefixed with "-".
+	Sort []string
+	// Limit is the maximum number of entities fetched, all if zero.
+	Limit int
+}
+
+// Finder is implemented by stores that can order and limit query results
+// natively, serving them from an index on the filtered and sorted fields.
+type Finder interface {
+	// FindEntities fetches the entities matching the given filters into
+	// result, a pointer to a slice, ordered and limited as given.
+	FindEntities(ctx context.Context, name string, filters url.Values, opts FindOptions, result interface{}) error
+}
+
+// Find fetches the entities matching the given filters, ordered and limited
+// by the store if it supports it, otherwise by listing them all and sorting
+// them in memory.
+func Find(ctx context.Context, s Store, name string, filters url.Values, opts FindOptions) ([]map[string]interface{}, error) {
+	result := make([]map[string]interface{}, 0)
+	if finder, ok := s.(Finder); ok {
+		if err := finder.FindEntities(ctx, name, filters, opts, &result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	if err := s.ListEntities(ctx, name, filters, &result); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		for _, f := range opts.Sort {
+			desc := strings.HasPrefix(f, "-")
+			f = strings.TrimPrefix(f, "-")
+			if c := compare(lookup(result[i], f), lookup(result[j], f)); c != 0 {
+				return (c < 0) != desc
+			}
+		}
+		return false
+	})
+	if opts.Limit > 0 && len(result) > opts.Limit {
+		result = result[:opts.Limit]
+	}
+	return result, nil
+}
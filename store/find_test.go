@@ -0,0 +1,79 @@
+package store_test
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"goresource/mocks"
+	"goresource/store"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// findStore is a store that can sort and limit queries.
+type findStore struct {
+	*mocks.MockStore
+	opts store.FindOptions
+}
+
+func (s *findStore) FindEntities(_ context.Context, _ string, _ url.Values, opts store.FindOptions, result interface{}) error {
+	s.opts = opts
+	*result.(*[]map[string]interface{}) = []map[string]interface{}{{"id": "b1"}}
+	return nil
+}
+
+var _ = Describe("Find", func() {
+	var (
+		ctrl    *gomock.Controller
+		backend *mocks.MockStore
+		ctx     = context.Background()
+		now     = time.Now().UTC()
+		books   = []map[string]interface{}{
+			{"id": "b1", "genre": "tech", "time": now},
+			{"id": "b2", "genre": "fiction", "time": now.Add(-time.Minute)},
+			{"id": "b3", "genre": "tech", "time": now.Add(-time.Hour)},
+		}
+	)
+
+	// ids returns the ids of the given entities.
+	ids := func(entities []map[string]interface{}) []string {
+		var ids []string
+		for _, e := range entities {
+			ids = append(ids, e["id"].(string))
+		}
+		return ids
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		backend = mocks.NewMockStore(ctrl)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("sorts and limits the listed entities of stores without it.", func() {
+		backend.EXPECT().ListEntities(gomock.Any(), "books", url.Values{"a": {"b"}}, gomock.Any()).SetArg(3, books).Return(nil).Times(2)
+		found, err := store.Find(ctx, backend, "books", url.Values{"a": {"b"}}, store.FindOptions{Sort: []string{"time"}, Limit: 2})
+		Expect(err).To(BeNil())
+		Expect(ids(found)).To(Equal([]string{"b3", "b2"}))
+
+		found, err = store.Find(ctx, backend, "books", url.Values{"a": {"b"}}, store.FindOptions{Sort: []string{"-genre", "time"}})
+		Expect(err).To(BeNil())
+		Expect(ids(found)).To(Equal([]string{"b3", "b1", "b2"}))
+	})
+
+	It("sorts and limits queries by stores with it.", func() {
+		s := &findStore{MockStore: backend}
+		opts := store.FindOptions{Sort: []string{"-time"}, Limit: 1}
+		found, err := store.Find(ctx, s, "books", nil, opts)
+		Expect(err).To(BeNil())
+		Expect(found).To(Equal([]map[string]interface{}{{"id": "b1"}}))
+		Expect(s.opts).To(Equal(opts))
+	})
+})
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"net/url"
+)
+
+// Forwarder is embedded by stores wrapping another, forwarding the optional
+// store interfaces to the wrapped store. Those it doesn't implement fall back
+// to the same helpers used for any store, such as Count and Stream. Search
+// has no fallback, so it isn't forwarded: the Search helper finds a wrapped
+// Searcher through Unwrap instead.
+type Forwarder struct {
+	Store
+}
+
+// Unwrap returns the wrapped store.
+func (f Forwarder) Unwrap() Store {
+	return f.Store
+}
+
+// GetEntities forwards to the wrapped store.
+func (f Forwarder) GetEntities(ctx context.Context, name string, ids []string, result interface{}) error {
+	found, err := GetEntities(ctx, f.Store, name, ids)
+	if err != nil {
+		return err
+	}
+	return assign(found, result)
+}
+
+// StreamEntities forwards to the wrapped store.
+func (f Forwarder) StreamEntities(ctx context.Context, name string, filters url.Values) (Iterator, error) {
+	return Stream(ctx, f.Store, name, filters)
+}
+
+// FindEntities forwards to the wrapped store.
+func (f Forwarder) FindEntities(ctx context.Context, name string, filters url.Values, opts FindOptions, result interface{}) error {
+	found, err := Find(ctx, f.Store, name, filters, opts)
+	if err != nil {
+		return err
+	}
+	return assign(found, result)
+}
+
+// CountEntities forwards to the wrapped store.
+func (f Forwarder) CountEntities(ctx context.Context, name string, filters url.Values) (int, error) {
+	return Count(ctx, f.Store, name, filters)
+}
+
+// Aggregate forwards to the wrapped store.
+func (f Forwarder) Aggregate(ctx context.Context, name string, filters url.Values, agg Aggregation, result interface{}) error {
+	rows, err := Aggregate(ctx, f.Store, name, filters, agg)
+	if err != nil {
+		return err
+	}
+	return assign(rows, result)
+}
+
+// EnsureIndexes forwards to the wrapped store.
+func (f Forwarder) EnsureIndexes(ctx context.Context, name string, indexes []Index) error {
+	return EnsureIndexes(ctx, f.Store, name, indexes)
+}
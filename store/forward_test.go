@@ -0,0 +1,65 @@
+package store_test
+
+import (
+	"context"
+	"net/url"
+
+	"goresource/mocks"
+	"goresource/store"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Forwarder", func() {
+	var (
+		ctrl    *gomock.Controller
+		backend *mocks.MockStore
+		ctx     = context.Background()
+		books   = []map[string]interface{}{{"id": "b1"}, {"id": "b2"}}
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		backend = mocks.NewMockStore(ctrl)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("falls back to the wrapped store's basic operations.", func() {
+		backend.EXPECT().ListEntities(gomock.Any(), "books", url.Values{"genre": {"tech"}}, gomock.Any()).
+			SetArg(3, books).Return(nil)
+		for _, s := range []store.Store{store.NewCachedStore(backend, store.NewLRUCache(10, 0)), store.NewOutboxStore(backend, "outbox"),
+			store.NewIndexedStore(backend, nil)} {
+			_, ok := s.(store.Counter)
+			Expect(ok).To(BeTrue())
+		}
+		n, err := store.Forwarder{Store: backend}.CountEntities(ctx, "books", url.Values{"genre": {"tech"}})
+		Expect(err).To(BeNil())
+		Expect(n).To(Equal(2))
+	})
+
+	It("searches wrapped stores only if they search.", func() {
+		for _, s := range []store.Store{store.Forwarder{Store: backend}, store.NewTracedStore(backend),
+			store.NewCachedStore(backend, store.NewLRUCache(10, 0))} {
+			_, ok := s.(store.Searcher)
+			Expect(ok).To(BeFalse())
+			_, err := store.Search(ctx, s, "books", "go", nil)
+			Expect(err).To(Equal(store.ErrSearchUnsupported))
+		}
+
+		backend.EXPECT().ListEntities(gomock.Any(), "books", url.Values{}, gomock.Any()).
+			SetArg(3, []map[string]interface{}{{"id": "b1", "title": "go"}}).Return(nil)
+		backend.EXPECT().GetEntity(gomock.Any(), "books", "b1", gomock.Any()).
+			SetArg(3, map[string]interface{}{"id": "b1", "title": "go"}).Return(nil)
+		indexed := store.NewIndexedStore(backend, nil)
+		Expect(indexed.Rebuild(ctx, "books")).To(Succeed())
+		result, err := store.Search(ctx, store.NewOutboxStore(indexed, "outbox"), "books", "go", nil)
+		Expect(err).To(BeNil())
+		Expect(result).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,400 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// IndexedStore adds full text search to stores without it, maintaining an in
+// memory inverted index of the entities created, updated and deleted through
+// it. Entities already in the store are indexed with Rebuild. Other optional
+// store interfaces are forwarded to the wrapped store.
+type IndexedStore struct {
+	Forwarder
+	fields  map[string][]string
+	mu      sync.RWMutex
+	indexes map[string]*invertedIndex
+}
+
+// invertedIndex maps the words of a collection's entities to the ids of the
+// entities containing them and how often, and each entity to its distinct
+// words so it is removed from their postings only.
+type invertedIndex struct {
+	postings map[string]map[string]int
+	lengths  map[string]int
+	terms    map[string][]string
+}
+
+func newInvertedIndex() *invertedIndex {
+	return &invertedIndex{
+		postings: make(map[string]map[string]int),
+		lengths:  make(map[string]int),
+		terms:    make(map[string][]string),
+	}
+}
+
+// NewIndexedStore returns an IndexedStore wrapping the given store. The fields
+// indexed for each collection are given by name, or by text indexes passed to
+// EnsureIndexes. All string fields are indexed for collections not listed.
+func NewIndexedStore(s Store, fields map[string][]string) *IndexedStore {
+	if fields == nil {
+		fields = make(map[string][]string)
+	}
+	return &IndexedStore{Forwarder: Forwarder{s}, fields: fields, indexes: make(map[string]*invertedIndex)}
+}
+
+// Rebuild indexes all entities of the named collection, replacing its index.
+func (s *IndexedStore) Rebuild(ctx context.Context, name string) error {
+	var entities []map[string]interface{}
+	if err := s.Store.ListEntities(ctx, name, url.Values{}, &entities); err != nil {
+		return err
+	}
+	index := newInvertedIndex()
+	for _, e := range entities {
+		if id := docID(e); id != "" {
+			index.add(id, s.words(name, e))
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.indexes[name] = index
+	return nil
+}
+
+// CreateEntity persists a new entity and indexes it.
+func (s *IndexedStore) CreateEntity(ctx context.Context, name string, data interface{}, result interface{}) error {
+	if err := s.Store.CreateEntity(ctx, name, data, result); err != nil {
+		return err
+	}
+	return s.reindex(name, result)
+}
+
+// UpdateEntity updates the entity with the given id and reindexes it.
+func (s *IndexedStore) UpdateEntity(ctx context.Context, name string, id string, data interface{}, result interface{}) error {
+	if err := s.Store.UpdateEntity(ctx, name, id, data, result); err != nil {
+		return err
+	}
+	return s.reindex(name, result)
+}
+
+// DeleteEntity deletes the entity with the given id and removes it from the index.
+func (s *IndexedStore) DeleteEntity(ctx context.Context, name string, id string) error {
+	if err := s.Store.DeleteEntity(ctx, name, id); err != nil {
+		return err
+	}
+	s.unindex(name, id)
+	return nil
+}
+
+// unindex removes the entity with the given id from the named collection's index.
+func (s *IndexedStore) unindex(name, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index, ok := s.indexes[name]; ok {
+		index.remove(id)
+	}
+}
+
+// WithTransaction runs fn in a transaction of the wrapped store and, once it
+// commits, reindexes the entities changed in it as stored, so changes rolled
+// back are never indexed.
+func (s *IndexedStore) WithTransaction(ctx context.Context, fn TxFunc) error {
+	changed := &indexTx{changes: make(map[string][]string)}
+	err := WithTransaction(ctx, s.Store, func(ctx context.Context, tx Store) error {
+		changed.Forwarder = Forwarder{tx}
+		return fn(ctx, changed)
+	})
+	if err != nil {
+		return err
+	}
+	for name, ids := range changed.changes {
+		for _, id := range ids {
+			doc := make(map[string]interface{})
+			err := s.Store.GetEntity(ctx, name, id, &doc)
+			switch {
+			case errors.Is(err, ErrNotFound):
+				s.unindex(name, id)
+			case err != nil:
+				return err
+			default:
+				if err := s.reindex(name, doc); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// indexTx is a store in a transaction recording the ids of the entities
+// changed in it.
+type indexTx struct {
+	Forwarder
+	mu      sync.Mutex
+	changes map[string][]string
+}
+
+func (tx *indexTx) changed(name string, ids ...string) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.changes[name] = append(tx.changes[name], ids...)
+}
+
+// WithTransaction runs fn in the same transaction.
+func (tx *indexTx) WithTransaction(ctx context.Context, fn TxFunc) error {
+	return fn(ctx, tx)
+}
+
+func (tx *indexTx) CreateEntity(ctx context.Context, name string, data interface{}, result interface{}) error {
+	if err := tx.Store.CreateEntity(ctx, name, data, result); err != nil {
+		return err
+	}
+	doc, err := toDoc(result)
+	if err != nil {
+		return err
+	}
+	tx.changed(name, docID(doc))
+	return nil
+}
+
+func (tx *indexTx) UpdateEntity(ctx context.Context, name string, id string, data interface{}, result interface{}) error {
+	tx.changed(name, id)
+	return tx.Store.UpdateEntity(ctx, name, id, data, result)
+}
+
+func (tx *indexTx) DeleteEntity(ctx context.Context, name string, id string) error {
+	tx.changed(name, id)
+	return tx.Store.DeleteEntity(ctx, name, id)
+}
+
+// Search fetches the entities containing any word of the given text query and
+// matching the given filters, ranked by tf-idf.
+func (s *IndexedStore) Search(ctx context.Context, name string, query string, filters url.Values, result interface{}) error {
+	match, err := Matcher(filters)
+	if err != nil {
+		return err
+	}
+	scores := s.score(name, Tokenize(query))
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	entities, err := GetEntities(ctx, s.Store, name, ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]map[string]interface{}, len(entities))
+	for _, e := range entities {
+		byID[docID(e)] = e
+	}
+	ranked := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		if e, ok := byID[id]; ok && match(e) {
+			e[ScoreField] = scores[id]
+			ranked = append(ranked, e)
+		}
+	}
+	return assign(ranked, result)
+}
+
+// score returns the tf-idf score of each entity of the named collection
+// containing any of the given words.
+func (s *IndexedStore) score(name string, words []string) map[string]float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	scores := make(map[string]float64)
+	index, ok := s.indexes[name]
+	if !ok {
+		return scores
+	}
+	total := float64(len(index.lengths))
+	seen := make(map[string]bool)
+	for _, w := range words {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		postings := index.postings[w]
+		idf := math.Log(1 + total/float64(len(postings)))
+		for id, tf := range postings {
+			scores[id] += float64(tf) / float64(index.lengths[id]) * idf
+		}
+	}
+	return scores
+}
+
+// reindex replaces the indexed words of the given stored entity.
+func (s *IndexedStore) reindex(name string, result interface{}) error {
+	doc, err := toDoc(result)
+	if err != nil {
+		return err
+	}
+	id := docID(doc)
+	if id == "" {
+		return nil
+	}
+	words := s.words(name, doc)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index, ok := s.indexes[name]
+	if !ok {
+		index = newInvertedIndex()
+		s.indexes[name] = index
+	}
+	index.remove(id)
+	index.add(id, words)
+	return nil
+}
+
+// EnsureIndexes indexes the fields of the given text indexes in memory,
+// rebuilding the collection's index, and creates the indexes on the wrapped
+// store if it supports them.
+func (s *IndexedStore) EnsureIndexes(ctx context.Context, name string, indexes []Index) error {
+	if err := EnsureIndexes(ctx, s.Store, name, indexes); err != nil {
+		return err
+	}
+	var fields []string
+	for _, i := range indexes {
+		fields = append(fields, i.TextFields()...)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	s.fields[name] = fields
+	s.mu.Unlock()
+	return s.Rebuild(ctx, name)
+}
+
+// words returns the words of the indexed fields of the given entity.
+func (s *IndexedStore) words(name string, doc map[string]interface{}) []string {
+	s.mu.RLock()
+	fields, ok := s.fields[name]
+	s.mu.RUnlock()
+	if !ok {
+		for f := range doc {
+			fields = append(fields, f)
+		}
+	}
+	var words []string
+	for _, f := range fields {
+		if text, ok := doc[f].(string); ok {
+			words = append(words, Tokenize(text)...)
+		}
+	}
+	return words
+}
+
+func (index *invertedIndex) add(id string, words []string) {
+	if len(words) == 0 {
+		return
+	}
+	for _, w := range words {
+		if index.postings[w] == nil {
+			index.postings[w] = make(map[string]int)
+		}
+		if index.postings[w][id] == 0 {
+			index.terms[id] = append(index.terms[id], w)
+		}
+		index.postings[w][id]++
+	}
+	index.lengths[id] = len(words)
+}
+
+func (index *invertedIndex) remove(id string) {
+	for _, w := range index.terms[id] {
+		postings := index.postings[w]
+		delete(postings, id)
+		if len(postings) == 0 {
+			delete(index.postings, w)
+		}
+	}
+	delete(index.terms, id)
+	delete(index.lengths, id)
+}
+
+// Matcher returns a function reporting whether an entity matches the given
+// filters, with the semantics of MongoStore's ListEntities, or an error for
+// invalid regular expressions.
+func Matcher(filters url.Values) (func(map[string]interface{}) bool, error) {
+	patterns := make(map[string]*regexp.Regexp)
+	for k, v := range filters {
+		if strings.HasSuffix(k, "~") {
+			re, err := regexp.Compile("(?im)" + v[0])
+			if err != nil {
+				return nil, err
+			}
+			patterns[k] = re
+		}
+	}
+	return func(doc map[string]interface{}) bool {
+		for k, v := range filters {
+			if re, ok := patterns[k]; ok {
+				if !re.MatchString(fmt.Sprint(doc[strings.TrimSuffix(k, "~")])) {
+					return false
+				}
+				continue
+			}
+			found := false
+			for _, value := range v {
+				if fmt.Sprint(doc[k]) == value {
+					found = true
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// toDoc converts a stored entity to a map, round tripping through json for
+// other types.
+func toDoc(entity interface{}) (map[string]interface{}, error) {
+	switch e := entity.(type) {
+	case map[string]interface{}:
+		return e, nil
+	case *map[string]interface{}:
+		return *e, nil
+	}
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	doc := make(map[string]interface{})
+	err = json.Unmarshal(data, &doc)
+	return doc, err
+}
+
+// docID returns the id of the given entity, from its id or _id field.
+func docID(doc map[string]interface{}) string {
+	for _, f := range []string{"_id", "id"} {
+		switch id := doc[f].(type) {
+		case nil:
+			continue
+		case fmt.Stringer:
+			if h, ok := id.(interface{ Hex() string }); ok {
+				return h.Hex()
+			}
+			return id.String()
+		default:
+			return fmt.Sprint(id)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,167 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"goresource/mocks"
+	"goresource/store"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IndexedStore", func() {
+	var (
+		ctrl    *gomock.Controller
+		backend *mocks.MockStore
+		s       *store.IndexedStore
+		ctx     = context.Background()
+		docs    = map[string]map[string]interface{}{
+			"1": {"_id": "1", "title": "Go in Action", "body": "Learning go, the go way.", "genre": "tech"},
+			"2": {"_id": "2", "title": "The Go Programming Language", "body": "A book about programs.", "genre": "tech"},
+			"3": {"_id": "3", "title": "Cooking", "body": "Recipes to go.", "genre": "food"},
+			"4": {"_id": "4", "title": "Gardening", "body": "Plants.", "genre": "home"},
+		}
+	)
+
+	// get expects the entities with the given ids to be fetched.
+	get := func(ids ...string) {
+		for _, id := range ids {
+			doc := make(map[string]interface{})
+			for k, v := range docs[id] {
+				doc[k] = v
+			}
+			backend.EXPECT().GetEntity(gomock.Any(), "books", id, gomock.Any()).SetArg(3, doc).Return(nil)
+		}
+	}
+
+	// ids returns the ids of the given results, in order.
+	ids := func(result []map[string]interface{}) []string {
+		var ids []string
+		for _, e := range result {
+			ids = append(ids, e["_id"].(string))
+		}
+		return ids
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		backend = mocks.NewMockStore(ctrl)
+		s = store.NewIndexedStore(backend, map[string][]string{"books": {"title", "body"}})
+		all := []map[string]interface{}{docs["1"], docs["2"], docs["3"], docs["4"]}
+		backend.EXPECT().ListEntities(gomock.Any(), "books", url.Values{}, gomock.Any()).SetArg(3, all).Return(nil)
+		Expect(s.Rebuild(ctx, "books")).To(Succeed())
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("ranks entities matching any word by relevance.", func() {
+		get("1", "2", "3")
+		result, err := store.Search(ctx, s, "books", "Go", nil)
+		Expect(err).To(BeNil())
+		Expect(ids(result)).To(Equal([]string{"1", "3", "2"}))
+		Expect(result[0][store.ScoreField]).To(BeNumerically(">", result[1][store.ScoreField].(float64)))
+	})
+
+	It("only indexes the listed fields.", func() {
+		result, err := store.Search(ctx, s, "books", "tech", nil)
+		Expect(err).To(BeNil())
+		Expect(result).To(BeEmpty())
+	})
+
+	It("applies filters to the results.", func() {
+		get("1", "2", "3")
+		result, err := store.Search(ctx, s, "books", "go", url.Values{"genre": {"tech"}, "title~": {"^the"}})
+		Expect(err).To(BeNil())
+		Expect(ids(result)).To(Equal([]string{"2"}))
+	})
+
+	It("rejects invalid regex filters.", func() {
+		_, err := store.Search(ctx, s, "books", "go", url.Values{"title~": {"("}})
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("indexes created and updated entities.", func() {
+		created := map[string]interface{}{"_id": "5", "title": "Gophers"}
+		backend.EXPECT().CreateEntity(gomock.Any(), "books", gomock.Any(), gomock.Any()).SetArg(3, created).Return(nil)
+		result := make(map[string]interface{})
+		Expect(s.CreateEntity(ctx, "books", created, &result)).To(Succeed())
+		backend.EXPECT().GetEntity(gomock.Any(), "books", "5", gomock.Any()).SetArg(3, created).Return(nil)
+		found, err := store.Search(ctx, s, "books", "gophers", nil)
+		Expect(err).To(BeNil())
+		Expect(ids(found)).To(Equal([]string{"5"}))
+
+		updated := map[string]interface{}{"_id": "5", "title": "Rabbits"}
+		backend.EXPECT().UpdateEntity(gomock.Any(), "books", "5", gomock.Any(), gomock.Any()).SetArg(4, updated).Return(nil)
+		Expect(s.UpdateEntity(ctx, "books", "5", updated, &result)).To(Succeed())
+		found, err = store.Search(ctx, s, "books", "gophers", nil)
+		Expect(err).To(BeNil())
+		Expect(found).To(BeEmpty())
+	})
+
+	It("removes deleted entities from the index.", func() {
+		backend.EXPECT().DeleteEntity(gomock.Any(), "books", "4").Return(nil)
+		Expect(s.DeleteEntity(ctx, "books", "4")).To(Succeed())
+		result, err := store.Search(ctx, s, "books", "plants", nil)
+		Expect(err).To(BeNil())
+		Expect(result).To(BeEmpty())
+	})
+
+	It("indexes the changes of transactions once they commit.", func() {
+		tx := mocks.NewMockStore(ctrl)
+		s = store.NewIndexedStore(&txStore{MockStore: backend, tx: tx}, map[string][]string{"books": {"title", "body"}})
+		created := map[string]interface{}{"_id": "5", "title": "Gophers"}
+		tx.EXPECT().CreateEntity(gomock.Any(), "books", gomock.Any(), gomock.Any()).SetArg(3, created).Return(nil).Times(2)
+		tx.EXPECT().DeleteEntity(gomock.Any(), "books", "4").Return(nil)
+		err := store.WithTransaction(ctx, s, func(ctx context.Context, t store.Store) error {
+			Expect(t.CreateEntity(ctx, "books", created, &map[string]interface{}{})).To(Succeed())
+			return errors.New("rolled back")
+		})
+		Expect(err).To(MatchError("rolled back"))
+		found, err := store.Search(ctx, s, "books", "gophers", nil)
+		Expect(err).To(BeNil())
+		Expect(found).To(BeEmpty())
+
+		backend.EXPECT().ListEntities(gomock.Any(), "books", url.Values{}, gomock.Any()).
+			SetArg(3, []map[string]interface{}{docs["4"]}).Return(nil)
+		Expect(s.Rebuild(ctx, "books")).To(Succeed())
+		backend.EXPECT().GetEntity(gomock.Any(), "books", "5", gomock.Any()).SetArg(3, created).Return(nil).Times(2)
+		backend.EXPECT().GetEntity(gomock.Any(), "books", "4", gomock.Any()).Return(store.ErrNotFound)
+		err = store.WithTransaction(ctx, s, func(ctx context.Context, t store.Store) error {
+			Expect(t.CreateEntity(ctx, "books", created, &map[string]interface{}{})).To(Succeed())
+			return t.DeleteEntity(ctx, "books", "4")
+		})
+		Expect(err).To(BeNil())
+		found, err = store.Search(ctx, s, "books", "gophers plants", nil)
+		Expect(err).To(BeNil())
+		Expect(ids(found)).To(Equal([]string{"5"}))
+	})
+
+	It("skips indexed entities that no longer exist.", func() {
+		backend.EXPECT().GetEntity(gomock.Any(), "books", "4", gomock.Any()).Return(store.ErrNotFound)
+		result, err := store.Search(ctx, s, "books", "plants", nil)
+		Expect(err).To(BeNil())
+		Expect(result).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Search", func() {
+	It("is unsupported by stores without full text search.", func() {
+		ctrl := gomock.NewController(GinkgoT())
+		defer ctrl.Finish()
+		_, err := store.Search(context.Background(), mocks.NewMockStore(ctrl), "books", "go", nil)
+		Expect(err).To(Equal(store.ErrSearchUnsupported))
+	})
+})
+
+var _ = Describe("Tokenize", func() {
+	It("splits text into lower case words.", func() {
+		Expect(store.Tokenize("Hello, World! It's 2024.")).To(Equal([]string{"hello", "world", "it", "s", "2024"}))
+	})
+})
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrDuplicate is wrapped by errors for entities violating a unique index.
+var ErrDuplicate = errors.New("duplicate key")
+
+// Index declares an index over fields of a collection.
+type Index struct {
+	// Key lists the indexed fields. Fields prefixed with "-" are indexed in
+	// descending order and fields prefixed with "$text:" are full text indexed.
+	Key []string
+	// Unique rejects entities with the same values as another entity for Key
+	// with ErrDuplicate.
+	Unique bool
+	// ExpireAfter, if set, removes entities once the time in the single Key
+	// field is older than it.
+	ExpireAfter time.Duration
+	// Name optionally names the index, stores name it after the key otherwise.
+	Name string
+}
+
+// TextFields returns the full text indexed fields of the index.
+func (i Index) TextFields() []string {
+	var fields []string
+	for _, k := range i.Key {
+		if strings.HasPrefix(k, "$text:") {
+			fields = append(fields, strings.TrimPrefix(k, "$text:"))
+		}
+	}
+	return fields
+}
+
+// Validate checks that the index has a key, and a single field for TTL indexes.
+func (i Index) Validate() error {
+	switch {
+	case len(i.Key) == 0:
+		return errors.New("index needs a key")
+	case i.ExpireAfter < 0:
+		return errors.New("index expiry can't be negative")
+	case i.ExpireAfter > 0 && len(i.Key) != 1:
+		return fmt.Errorf("ttl index on %v needs a single field", i.Key)
+	}
+	return nil
+}
+
+// Indexer is implemented by stores that can create indexes.
+type Indexer interface {
+	// EnsureIndexes creates the given indexes on the named collection if they
+	// don't exist.
+	EnsureIndexes(ctx context.Context, name string, indexes []Index) error
+}
+
+// EnsureIndexes validates the given indexes and creates them on the named
+// collection if the store supports indexes. Stores without indexes ignore them.
+func EnsureIndexes(ctx context.Context, s Store, name string, indexes []Index) error {
+	for _, i := range indexes {
+		if err := i.Validate(); err != nil {
+			return err
+		}
+	}
+	if indexer, ok := s.(Indexer); ok {
+		return indexer.EnsureIndexes(ctx, name, indexes)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package store_test
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"goresource/mocks"
+	"goresource/store"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Index", func() {
+	It("validates its key and expiry.", func() {
+		Expect(store.Index{Key: []string{"a", "-b"}, Unique: true}.Validate()).To(Succeed())
+		Expect(store.Index{Key: []string{"created"}, ExpireAfter: time.Hour}.Validate()).To(Succeed())
+		Expect(store.Index{}.Validate()).To(MatchError("index needs a key"))
+		Expect(store.Index{Key: []string{"a"}, ExpireAfter: -time.Hour}.Validate()).NotTo(Succeed())
+		Expect(store.Index{Key: []string{"a", "b"}, ExpireAfter: time.Hour}.Validate()).NotTo(Succeed())
+	})
+
+	It("lists its text fields.", func() {
+		Expect(store.Index{Key: []string{"$text:title", "genre", "$text:body"}}.TextFields()).To(Equal([]string{"title", "body"}))
+	})
+})
+
+var _ = Describe("EnsureIndexes", func() {
+	var (
+		ctrl    *gomock.Controller
+		backend *mocks.MockStore
+		ctx     = context.Background()
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		backend = mocks.NewMockStore(ctrl)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("ignores indexes for stores without them.", func() {
+		Expect(store.EnsureIndexes(ctx, backend, "books", []store.Index{{Key: []string{"a"}}})).To(Succeed())
+	})
+
+	It("rejects invalid indexes.", func() {
+		Expect(store.EnsureIndexes(ctx, backend, "books", []store.Index{{Key: []string{"a"}}, {}})).NotTo(Succeed())
+	})
+
+	It("indexes the fields of text indexes in an IndexedStore.", func() {
+		s := store.NewIndexedStore(backend, nil)
+		backend.EXPECT().ListEntities(gomock.Any(), "books", url.Values{}, gomock.Any()).
+			SetArg(3, []map[string]interface{}{{"_id": "1", "title": "gophers", "genre": "tech"}}).Return(nil)
+		Expect(s.EnsureIndexes(ctx, "books", []store.Index{{Key: []string{"$text:title"}}, {Key: []string{"genre"}}})).To(Succeed())
+		result, err := store.Search(ctx, s, "books", "tech", nil)
+		Expect(err).To(BeNil())
+		Expect(result).To(BeEmpty())
+		backend.EXPECT().GetEntity(gomock.Any(), "books", "1", gomock.Any()).
+			SetArg(3, map[string]interface{}{"_id": "1", "title": "gophers"}).Return(nil)
+		result, err = store.Search(ctx, s, "books", "gophers", nil)
+		Expect(err).To(BeNil())
+		Expect(result).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,255 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrTransactionConflict is returned by transactions that kept conflicting
+// with concurrent changes to the collections they used.
+var ErrTransactionConflict = errors.New("transaction conflict")
+
+// MaxTransactionAttempts bounds how many times MemoryStore runs a transaction
+// conflicting with concurrent changes.
+const MaxTransactionAttempts = 10
+
+// MemoryStore is a Store keeping entities in memory as json documents, for
+// tests and single process deployments. Entities are identified by their id
+// field, new entities without one are given an object id. Transactions are
+// isolated from each other, a transaction committing after a collection it
+// used was changed is run again.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	collections map[string]*memCollection
+	// base is the store a transaction's store was started from, with the
+	// versions of its collections the transaction used and whether it
+	// changed them.
+	base    *MemoryStore
+	read    map[string]int
+	written map[string]bool
+}
+
+// memCollection holds the documents of a collection by id, in the order they
+// were created, and a version incremented by every change.
+type memCollection struct {
+	version int
+	seq     int
+	docs    map[string]memDoc
+}
+
+type memDoc struct {
+	seq  int
+	data []byte
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{collections: make(map[string]*memCollection)}
+}
+
+// collection returns the named collection, creating it if missing. In
+// transactions, collections are copied from the base store when first used.
+// It must be called with mu locked.
+func (s *MemoryStore) collection(name string) *memCollection {
+	if c, ok := s.collections[name]; ok {
+		return c
+	}
+	c := &memCollection{docs: make(map[string]memDoc)}
+	if s.base != nil {
+		s.base.mu.RLock()
+		if b, ok := s.base.collections[name]; ok {
+			c.version, c.seq = b.version, b.seq
+			for id, doc := range b.docs {
+				c.docs[id] = doc
+			}
+		}
+		s.base.mu.RUnlock()
+		s.read[name] = c.version
+	}
+	s.collections[name] = c
+	return c
+}
+
+// changed records a change to the named collection.
+func (s *MemoryStore) changed(name string, c *memCollection) {
+	c.version++
+	if s.base != nil {
+		s.written[name] = true
+	}
+}
+
+// GetEntity fetches the entity with the given id.
+func (s *MemoryStore) GetEntity(_ context.Context, name string, id string, result interface{}) error {
+	s.mu.Lock()
+	doc, ok := s.collection(name).docs[id]
+	s.mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+	return json.Unmarshal(doc.data, result)
+}
+
+// CreateEntity persists a new entity with the given data, rejecting ids that
+// already exist with ErrDuplicate.
+func (s *MemoryStore) CreateEntity(_ context.Context, name string, data interface{}, result interface{}) error {
+	doc, err := toDoc(data)
+	if err != nil {
+		return err
+	}
+	id, doc := entityID(doc)
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	c := s.collection(name)
+	if _, ok := c.docs[id]; ok {
+		s.mu.Unlock()
+		return ErrDuplicate
+	}
+	c.seq++
+	c.docs[id] = memDoc{seq: c.seq, data: encoded}
+	s.changed(name, c)
+	s.mu.Unlock()
+	return json.Unmarshal(encoded, result)
+}
+
+// ListEntities fetches the entities matching the given filters, in the order
+// they were created.
+func (s *MemoryStore) ListEntities(_ context.Context, name string, filters url.Values, result interface{}) error {
+	match, err := Matcher(filters)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	docs := make([]memDoc, 0, len(s.collection(name).docs))
+	for _, doc := range s.collection(name).docs {
+		docs = append(docs, doc)
+	}
+	s.mu.Unlock()
+	sort.Slice(docs, func(i, j int) bool { return docs[i].seq < docs[j].seq })
+	entities := make([]map[string]interface{}, 0, len(docs))
+	for _, doc := range docs {
+		entity := make(map[string]interface{})
+		if err := json.Unmarshal(doc.data, &entity); err != nil {
+			return err
+		}
+		if match(entity) {
+			entities = append(entities, entity)
+		}
+	}
+	return assign(entities, result)
+}
+
+// UpdateEntity replaces the entity with the given id with the given data.
+func (s *MemoryStore) UpdateEntity(_ context.Context, name string, id string, data interface{}, result interface{}) error {
+	doc, err := toDoc(data)
+	if err != nil {
+		return err
+	}
+	doc = withID(doc, id)
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	c := s.collection(name)
+	existing, ok := c.docs[id]
+	if ok {
+		c.docs[id] = memDoc{seq: existing.seq, data: encoded}
+		s.changed(name, c)
+	}
+	s.mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+	return json.Unmarshal(encoded, result)
+}
+
+// DeleteEntity removes the entity with the given id.
+func (s *MemoryStore) DeleteEntity(_ context.Context, name string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.collection(name)
+	if _, ok := c.docs[id]; !ok {
+		return ErrNotFound
+	}
+	delete(c.docs, id)
+	s.changed(name, c)
+	return nil
+}
+
+// WithTransaction runs fn on a copy of the collections it uses, replacing them
+// in the store if fn returns nil. If a collection used was changed meanwhile,
+// fn is run again, up to MaxTransactionAttempts times, after which
+// ErrTransactionConflict is returned.
+func (s *MemoryStore) WithTransaction(ctx context.Context, fn TxFunc) error {
+	if s.base != nil {
+		return fn(ctx, s)
+	}
+	for attempt := 0; attempt < MaxTransactionAttempts; attempt++ {
+		tx := &MemoryStore{
+			collections: make(map[string]*memCollection),
+			base:        s,
+			read:        make(map[string]int),
+			written:     make(map[string]bool),
+		}
+		if err := fn(ctx, tx); err != nil {
+			return err
+		}
+		if s.commit(tx) {
+			return nil
+		}
+	}
+	return ErrTransactionConflict
+}
+
+// commit replaces the collections changed by the given transaction, unless
+// any collection it used was changed since, and reports whether it did.
+func (s *MemoryStore) commit(tx *MemoryStore) bool {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, version := range tx.read {
+		if c, ok := s.collections[name]; ok && c.version != version || !ok && version != 0 {
+			return false
+		}
+	}
+	for name := range tx.written {
+		c := tx.collections[name]
+		c.version = tx.read[name] + 1
+		s.collections[name] = c
+	}
+	return true
+}
+
+// Close does nothing, the entities are kept until the store is garbage collected.
+func (s *MemoryStore) Close() {}
+
+// entityID returns the id of the given document and a copy of it with the id
+// in its id field, giving it a new object id if it has none.
+func entityID(doc map[string]interface{}) (string, map[string]interface{}) {
+	id := docID(doc)
+	if id == "" {
+		id = primitive.NewObjectID().Hex()
+	}
+	return id, withID(doc, id)
+}
+
+// withID returns a copy of the given document with the given id in its id field.
+func withID(doc map[string]interface{}, id string) map[string]interface{} {
+	copied := make(map[string]interface{}, len(doc)+1)
+	for k, v := range doc {
+		copied[k] = v
+	}
+	delete(copied, "_id")
+	copied["id"] = id
+	return copied
+}
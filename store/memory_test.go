@@ -0,0 +1,157 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+
+	"goresource/store"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type memItem struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	Tag  string `json:"tag"`
+}
+
+// itStoresEntities describes the behaviour shared by stores of json documents
+// with transactions, on stores returned by newStore.
+func itStoresEntities(newStore func() store.Store) {
+	var (
+		s   store.Store
+		ctx = context.Background()
+	)
+
+	BeforeEach(func() {
+		s = newStore()
+		for _, name := range []string{"foo", "bar", "baz"} {
+			var result memItem
+			Expect(s.CreateEntity(ctx, "items", memItem{Name: name, Tag: name[:2]}, &result)).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		s.Close()
+	})
+
+	It("creates, gets, updates and deletes entities.", func() {
+		var created memItem
+		Expect(s.CreateEntity(ctx, "items", map[string]interface{}{"id": "i1", "name": "qux"}, &created)).To(Succeed())
+		Expect(created).To(Equal(memItem{ID: "i1", Name: "qux"}))
+		Expect(s.CreateEntity(ctx, "items", memItem{ID: "i1"}, &created)).To(Equal(store.ErrDuplicate))
+
+		var got map[string]interface{}
+		Expect(s.GetEntity(ctx, "items", "i1", &got)).To(Succeed())
+		Expect(got).To(Equal(map[string]interface{}{"id": "i1", "name": "qux"}))
+
+		var updated memItem
+		Expect(s.UpdateEntity(ctx, "items", "i1", memItem{Name: "quux", Tag: "qu"}, &updated)).To(Succeed())
+		Expect(updated).To(Equal(memItem{ID: "i1", Name: "quux", Tag: "qu"}))
+		Expect(s.UpdateEntity(ctx, "items", "i1", memItem{Name: "quux", Tag: "qu"}, &updated)).To(Succeed())
+		Expect(s.UpdateEntity(ctx, "items", "missing", memItem{}, &updated)).To(Equal(store.ErrNotFound))
+
+		Expect(s.DeleteEntity(ctx, "items", "i1")).To(Succeed())
+		Expect(s.GetEntity(ctx, "items", "i1", &got)).To(Equal(store.ErrNotFound))
+		Expect(s.DeleteEntity(ctx, "items", "i1")).To(Equal(store.ErrNotFound))
+	})
+
+	It("lists entities matching filters in order.", func() {
+		var items []memItem
+		Expect(s.ListEntities(ctx, "items", url.Values{}, &items)).To(Succeed())
+		Expect(items).To(HaveLen(3))
+		Expect(items[0].Name).To(Equal("foo"))
+		Expect(items[0].ID).To(MatchRegexp(`^[0-9a-f]{24}$`))
+
+		Expect(s.ListEntities(ctx, "items", url.Values{"tag": {"ba"}}, &items)).To(Succeed())
+		Expect(items).To(HaveLen(2))
+		Expect(s.ListEntities(ctx, "items", url.Values{"name~": {"^f"}}, &items)).To(Succeed())
+		Expect(items).To(HaveLen(1))
+	})
+
+	It("commits transactions.", func() {
+		var created memItem
+		err := store.WithTransaction(ctx, s, func(ctx context.Context, tx store.Store) error {
+			if err := tx.CreateEntity(ctx, "items", memItem{ID: "i1", Name: "qux"}, &created); err != nil {
+				return err
+			}
+			return tx.(store.Transactor).WithTransaction(ctx, func(ctx context.Context, tx store.Store) error {
+				var got memItem
+				return tx.GetEntity(ctx, "items", "i1", &got)
+			})
+		})
+		Expect(err).To(Succeed())
+		var got memItem
+		Expect(s.GetEntity(ctx, "items", "i1", &got)).To(Succeed())
+		Expect(got.Name).To(Equal("qux"))
+	})
+
+	It("rolls back transactions failing.", func() {
+		var items []memItem
+		Expect(s.ListEntities(ctx, "items", url.Values{}, &items)).To(Succeed())
+		failed := errors.New("failed")
+		err := store.WithTransaction(ctx, s, func(ctx context.Context, tx store.Store) error {
+			var created memItem
+			Expect(tx.CreateEntity(ctx, "items", memItem{ID: "i1", Name: "qux"}, &created)).To(Succeed())
+			Expect(tx.DeleteEntity(ctx, "items", items[0].ID)).To(Succeed())
+			return failed
+		})
+		Expect(err).To(Equal(failed))
+		var got memItem
+		Expect(s.GetEntity(ctx, "items", "i1", &got)).To(Equal(store.ErrNotFound))
+		Expect(s.GetEntity(ctx, "items", items[0].ID, &got)).To(Succeed())
+	})
+}
+
+var _ = Describe("MemoryStore", func() {
+	itStoresEntities(func() store.Store { return store.NewMemoryStore() })
+
+	It("isolates transactions, running them again on conflicts.", func() {
+		ctx := context.Background()
+		s := store.NewMemoryStore()
+		var created memItem
+		Expect(s.CreateEntity(ctx, "counters", map[string]interface{}{"id": "c1", "n": 0}, &created)).To(Succeed())
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				err := s.WithTransaction(ctx, func(ctx context.Context, tx store.Store) error {
+					counter := make(map[string]interface{})
+					if err := tx.GetEntity(ctx, "counters", "c1", &counter); err != nil {
+						return err
+					}
+					counter["n"] = counter["n"].(float64) + 1
+					return tx.UpdateEntity(ctx, "counters", "c1", counter, &counter)
+				})
+				Expect(err).To(Succeed())
+			}()
+		}
+		wg.Wait()
+		counter := make(map[string]interface{})
+		Expect(s.GetEntity(ctx, "counters", "c1", &counter)).To(Succeed())
+		Expect(counter["n"]).To(Equal(5.0))
+	})
+
+	It("gives up on transactions that keep conflicting.", func() {
+		ctx := context.Background()
+		s := store.NewMemoryStore()
+		attempts := 0
+		err := s.WithTransaction(ctx, func(ctx context.Context, tx store.Store) error {
+			attempts++
+			var items []memItem
+			if err := tx.ListEntities(ctx, "items", nil, &items); err != nil {
+				return err
+			}
+			var created memItem
+			return s.CreateEntity(ctx, "items", memItem{}, &created)
+		})
+		Expect(err).To(Equal(store.ErrTransactionConflict))
+		Expect(attempts).To(Equal(store.MaxTransactionAttempts))
+	})
+})
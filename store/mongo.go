@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strings"
@@ -37,8 +38,9 @@ func NewMongoStore(addr string, database string, timeout time.Duration) (Store,
 	}, nil
 }
 
-// ListEntities queries and returns all entities matching the given filters.
-func (s *MongoStore) ListEntities(name string, filters url.Values, result interface{}) error {
+// search builds a mongo query from the given filters. Keys ending in "~" match
+// by regex, keys with multiple values match any of them.
+func search(filters url.Values) bson.M {
 	search := bson.M{}
 	for k, v := range filters {
 		if strings.HasSuffix(k, "~") {
@@ -51,31 +53,137 @@ func (s *MongoStore) ListEntities(name string, filters url.Values, result interf
 			}
 		}
 	}
-	err := s.db.C(name).Find(search).All(result)
+	return search
+}
+
+// ListEntities queries and returns all entities matching the given filters.
+func (s *MongoStore) ListEntities(_ context.Context, name string, filters url.Values, result interface{}) error {
+	err := s.db.C(name).Find(search(filters)).All(result)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// ListEntities fetches a specific entity with the given id.
-func (s *MongoStore) GetEntity(name string, id string, result interface{}) error {
-	if !bson.IsObjectIdHex(id) {
-		return fmt.Errorf("invalid object id %s.", id)
+// FindEntities fetches the entities matching the given filters, ordered and limited as given.
+func (s *MongoStore) FindEntities(_ context.Context, name string, filters url.Values, opts FindOptions, result interface{}) error {
+	query := s.db.C(name).Find(search(filters))
+	if len(opts.Sort) > 0 {
+		query = query.Sort(opts.Sort...)
 	}
-	entityId := bson.ObjectIdHex(id)
-	err := s.db.C(name).FindId(entityId).One(result)
-	if err != nil {
+	if opts.Limit > 0 {
+		query = query.Limit(opts.Limit)
+	}
+	return query.All(result)
+}
+
+// StreamEntities returns a cursor over all entities matching the given filters.
+func (s *MongoStore) StreamEntities(_ context.Context, name string, filters url.Values) (Iterator, error) {
+	return s.db.C(name).Find(search(filters)).Iter(), nil
+}
+
+// CountEntities returns the number of entities matching the given filters.
+func (s *MongoStore) CountEntities(_ context.Context, name string, filters url.Values) (int, error) {
+	return s.db.C(name).Find(search(filters)).Count()
+}
+
+// Aggregate computes the given aggregation over the entities matching the
+// given filters with an aggregation pipeline.
+func (s *MongoStore) Aggregate(_ context.Context, name string, filters url.Values, agg Aggregation, result interface{}) error {
+	if err := agg.Validate(); err != nil {
 		return err
 	}
+	// Group fields are keyed by position, since keys can't hold dotted paths.
+	id := bson.M{}
+	for i, f := range agg.GroupBy {
+		id[fmt.Sprintf("g%d", i)] = "$" + f
+	}
+	group := bson.M{"_id": id}
+	for _, m := range agg.Metrics {
+		if m.Op == OpCount {
+			group[m.Name()] = bson.M{"$sum": 1}
+		} else {
+			group[m.Name()] = bson.M{"$" + m.Op: "$" + m.Field}
+		}
+	}
+	var rows []bson.M
+	pipeline := []bson.M{{"$match": search(filters)}, {"$group": group}}
+	if err := s.db.C(name).Pipe(pipeline).All(&rows); err != nil {
+		return err
+	}
+	entities := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		key, _ := row["_id"].(bson.M)
+		entity := make(map[string]interface{})
+		for j, f := range agg.GroupBy {
+			entity[f] = key[fmt.Sprintf("g%d", j)]
+		}
+		for _, m := range agg.Metrics {
+			entity[m.Name()] = row[m.Name()]
+		}
+		entities[i] = entity
+	}
+	SortGroups(entities, agg.GroupBy)
+	return assign(entities, result)
+}
+
+// Search fetches the entities matching the given text query and filters, most
+// relevant first. It requires a text index on the collection, see EnsureTextIndex.
+func (s *MongoStore) Search(_ context.Context, name string, query string, filters url.Values, result interface{}) error {
+	q := search(filters)
+	q["$text"] = bson.M{"$search": query}
+	return s.db.C(name).Find(q).
+		Select(bson.M{ScoreField: bson.M{"$meta": "textScore"}}).
+		Sort("$textScore:" + ScoreField).
+		All(result)
+}
+
+// EnsureTextIndex creates the text index searched by Search over the given
+// fields of the named collection, if it doesn't exist.
+func (s *MongoStore) EnsureTextIndex(name string, fields ...string) error {
+	key := make([]string, len(fields))
+	for i, f := range fields {
+		key[i] = "$text:" + f
+	}
+	return s.EnsureIndexes(context.Background(), name, []Index{{Key: key}})
+}
+
+// EnsureIndexes creates the given indexes on the named collection if they
+// don't exist, in the background so as to not block other operations.
+func (s *MongoStore) EnsureIndexes(_ context.Context, name string, indexes []Index) error {
+	for _, i := range indexes {
+		index := mgo.Index{Key: i.Key, Unique: i.Unique, ExpireAfter: i.ExpireAfter, Name: i.Name, Background: true}
+		if err := s.db.C(name).EnsureIndex(index); err != nil {
+			return fmt.Errorf("error creating index %v on %s: %w", i.Key, name, err)
+		}
+	}
+	return nil
+}
+
+// ListEntities fetches a specific entity with the given id.
+func (s *MongoStore) GetEntity(_ context.Context, name string, id string, result interface{}) error {
+	err := s.db.C(name).FindId(objectIdHex(id)).One(result)
+	if err != nil {
+		return notFound(err)
+	}
 	return nil
 }
 
+// GetEntities fetches the entities with the given ids with a single query.
+// Ids that are not valid object ids are matched as strings.
+func (s *MongoStore) GetEntities(_ context.Context, name string, ids []string, result interface{}) error {
+	in := make([]interface{}, len(ids))
+	for i, id := range ids {
+		in[i] = objectIdHex(id)
+	}
+	return s.db.C(name).Find(bson.M{"_id": bson.M{"$in": in}}).All(result)
+}
+
 // CreateEntity persists a new entity with the given data.
-func (s *MongoStore) CreateEntity(name string, data interface{}, result interface{}) error {
+func (s *MongoStore) CreateEntity(_ context.Context, name string, data interface{}, result interface{}) error {
 	err := s.db.C(name).Insert(data)
 	if err != nil {
-		return err
+		return duplicate(err)
 	}
 	if err = s.db.C(name).Find(data).One(result); err != nil {
 		return err
@@ -83,12 +191,34 @@ func (s *MongoStore) CreateEntity(name string, data interface{}, result interfac
 	return nil
 }
 
+// CreateEntities persists the given entities with a single unordered bulk
+// insert, so a failing entity does not prevent the others from being inserted.
+func (s *MongoStore) CreateEntities(_ context.Context, name string, data []interface{}) (map[int]error, error) {
+	failed := make(map[int]error)
+	if len(data) == 0 {
+		return failed, nil
+	}
+	bulk := s.db.C(name).Bulk()
+	bulk.Unordered()
+	bulk.Insert(data...)
+	if _, err := bulk.Run(); err != nil {
+		bulkErr, ok := err.(*mgo.BulkError)
+		if !ok {
+			return nil, err
+		}
+		for _, c := range bulkErr.Cases() {
+			failed[c.Index] = duplicate(c.Err)
+		}
+	}
+	return failed, nil
+}
+
 // UpdateEntity updates a specific entity corresponding the given id, with the given data.
-func (s *MongoStore) UpdateEntity(name string, id string, data interface{}, result interface{}) error {
-	entityId := bson.ObjectIdHex(id)
+func (s *MongoStore) UpdateEntity(_ context.Context, name string, id string, data interface{}, result interface{}) error {
+	entityId := objectIdHex(id)
 	err := s.db.C(name).UpdateId(entityId, data)
 	if err != nil {
-		return err
+		return notFound(duplicate(err))
 	}
 	if err = s.db.C(name).FindId(entityId).One(result); err != nil {
 		return err
@@ -97,8 +227,34 @@ func (s *MongoStore) UpdateEntity(name string, id string, data interface{}, resu
 }
 
 // DeleteEntity removes a specific entity with the given id.
-func (s *MongoStore) DeleteEntity(name string, id string) error {
-	return s.db.C(name).RemoveId(bson.ObjectIdHex(id))
+func (s *MongoStore) DeleteEntity(_ context.Context, name string, id string) error {
+	return notFound(s.db.C(name).RemoveId(objectIdHex(id)))
+}
+
+// objectIdHex returns the given id as an ObjectId if it is one, otherwise as
+// is, like MongoDriverStore, so ids that are not object ids are not found
+// rather than rejected.
+func objectIdHex(id string) interface{} {
+	if bson.IsObjectIdHex(id) {
+		return bson.ObjectIdHex(id)
+	}
+	return id
+}
+
+// notFound converts mgo's not found error into ErrNotFound.
+func notFound(err error) error {
+	if err == mgo.ErrNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+// duplicate wraps unique index violations with ErrDuplicate.
+func duplicate(err error) error {
+	if mgo.IsDup(err) {
+		return fmt.Errorf("%w: %s", ErrDuplicate, err)
+	}
+	return err
 }
 
 // Close tears down the database connection and closes the session.
@@ -1,6 +1,8 @@
 package store_test
 
 import (
+	"context"
+	"errors"
 	"net/url"
 	"time"
 
@@ -70,7 +72,7 @@ var _ = Describe("MongoStore", func() {
 
 		It("passes errors from the store through.", func() {
 			var items []TestItem
-			err := s.ListEntities(testcoll, url.Values{"$a": []string{"test"}}, &items)
+			err := s.ListEntities(context.Background(), testcoll, url.Values{"$a": []string{"test"}}, &items)
 			Expect(err).ToNot(BeNil())
 		})
 
@@ -86,7 +88,7 @@ var _ = Describe("MongoStore", func() {
 			})
 			It("fetches all entities given no filter", func() {
 				var items []TestItem
-				err := s.ListEntities(testcoll, nil, &items)
+				err := s.ListEntities(context.Background(), testcoll, nil, &items)
 				Expect(err).To(BeNil())
 				Expect(len(items)).To(Equal(6))
 				Expect(items[0].Name).To(Equal("item1"))
@@ -98,7 +100,7 @@ var _ = Describe("MongoStore", func() {
 			})
 			It("fetches matching entities given a equals filter", func() {
 				var items []TestItem
-				err := s.ListEntities(testcoll, url.Values{"tag": []string{"imp"}}, &items)
+				err := s.ListEntities(context.Background(), testcoll, url.Values{"tag": []string{"imp"}}, &items)
 				Expect(err).To(BeNil())
 				Expect(len(items)).To(Equal(2))
 				Expect(items[0].Name).To(Equal("item2"))
@@ -106,7 +108,7 @@ var _ = Describe("MongoStore", func() {
 			})
 			It("fetches matching entities given an in filter", func() {
 				var items []TestItem
-				err := s.ListEntities(testcoll, url.Values{"tag": []string{"imp", "new"}}, &items)
+				err := s.ListEntities(context.Background(), testcoll, url.Values{"tag": []string{"imp", "new"}}, &items)
 				Expect(err).To(BeNil())
 				Expect(len(items)).To(Equal(4))
 				Expect(items[0].Name).To(Equal("item2"))
@@ -116,7 +118,7 @@ var _ = Describe("MongoStore", func() {
 			})
 			It("fetches matching entities given a regex filter", func() {
 				var items []TestItem
-				err := s.ListEntities(testcoll, url.Values{"tag~": []string{"^i"}}, &items)
+				err := s.ListEntities(context.Background(), testcoll, url.Values{"tag~": []string{"^i"}}, &items)
 				Expect(err).To(BeNil())
 				Expect(len(items)).To(Equal(3))
 				Expect(items[0].Name).To(Equal("item2"))
@@ -128,19 +130,52 @@ var _ = Describe("MongoStore", func() {
 		Context("if no entities exist in the database.", func() {
 			It("returns an empty slice not given a filter", func() {
 				var items []TestItem
-				err := s.ListEntities(testcoll, nil, &items)
+				err := s.ListEntities(context.Background(), testcoll, nil, &items)
 				Expect(err).To(BeNil())
 				Expect(len(items)).To(Equal(0))
 			})
 			It("returns an empty slice given a filter", func() {
 				var items []TestItem
-				err := s.ListEntities(testcoll, url.Values{"tag": []string{"imp"}}, &items)
+				err := s.ListEntities(context.Background(), testcoll, url.Values{"tag": []string{"imp"}}, &items)
 				Expect(err).To(BeNil())
 				Expect(len(items)).To(Equal(0))
 			})
 		})
 	})
 
+	Describe("StreamEntities", func() {
+		var (
+			s   store.Store
+			err error
+		)
+
+		BeforeEach(func() {
+			s, err = store.NewMongoStore(testdbhost, testdbname, 5*time.Second)
+			database.C(testcoll).Insert(
+				TestItem{Name: "item1", Tag: "imp"},
+				TestItem{Name: "item2", Tag: "new"},
+				TestItem{Name: "item3", Tag: "imp"})
+		})
+
+		AfterEach(func() {
+			s.Close()
+		})
+
+		It("iterates over matching entities.", func() {
+			it, err := s.(store.Streamer).StreamEntities(context.Background(), testcoll, url.Values{"tag": []string{"imp"}})
+			Expect(err).To(BeNil())
+			var (
+				item  TestItem
+				names []string
+			)
+			for it.Next(&item) {
+				names = append(names, item.Name)
+			}
+			Expect(it.Close()).To(BeNil())
+			Expect(names).To(Equal([]string{"item1", "item3"}))
+		})
+	})
+
 	Describe("GetEntity", func() {
 		var (
 			s   store.Store
@@ -162,7 +197,7 @@ var _ = Describe("MongoStore", func() {
 				if err := database.C(testcoll).Insert(source); err != nil {
 					Fail(err.Error())
 				}
-				err := s.GetEntity(testcoll, source.ID.Hex(), &result)
+				err := s.GetEntity(context.Background(), testcoll, source.ID.Hex(), &result)
 				Expect(err).To(BeNil())
 				Expect(result.ID).To(Equal(source.ID))
 				Expect(result.Name).To(Equal(source.Name))
@@ -174,16 +209,19 @@ var _ = Describe("MongoStore", func() {
 			It("returns an error.", func() {
 				var result TestItem
 				source := TestItem{Name: "foo", Tag: "bar", ID: bson.NewObjectId()}
-				err := s.GetEntity(testcoll, source.ID.Hex(), &result)
+				err := s.GetEntity(context.Background(), testcoll, source.ID.Hex(), &result)
 				Expect(err).ToNot(BeNil())
 			})
 		})
 
 		Context("given an invalid id", func() {
-			It("returns an error.", func() {
+			It("returns ErrNotFound.", func() {
 				var result TestItem
-				err := s.GetEntity(testcoll, "invalid-id", &result)
-				Expect(err).ToNot(BeNil())
+				err := s.GetEntity(context.Background(), testcoll, "invalid-id", &result)
+				Expect(err).To(Equal(store.ErrNotFound))
+				Expect(s.UpdateEntity(context.Background(), testcoll, "invalid-id", TestItem{Name: "foo"}, &result)).
+					To(Equal(store.ErrNotFound))
+				Expect(s.DeleteEntity(context.Background(), testcoll, "invalid-id")).To(Equal(store.ErrNotFound))
 			})
 		})
 
@@ -207,7 +245,7 @@ var _ = Describe("MongoStore", func() {
 			It("persists it in the database.", func() {
 				var result TestItem
 				item := TestItem{Name: "foo", Tag: "bar"}
-				err := s.CreateEntity(testcoll, item, &result)
+				err := s.CreateEntity(context.Background(), testcoll, item, &result)
 				Expect(err).To(BeNil())
 				Expect(result.ID.Valid()).To(BeTrue())
 			})
@@ -216,13 +254,139 @@ var _ = Describe("MongoStore", func() {
 		Context("given an invalid entity.", func() {
 			It("returns an error.", func() {
 				var result TestItem
-				err := s.CreateEntity(testcoll, nil, &result)
+				err := s.CreateEntity(context.Background(), testcoll, nil, &result)
 				Expect(err).ToNot(BeNil())
 				Expect(result.ID.Valid()).To(BeFalse())
 			})
 		})
 	})
 
+	Describe("GetEntities", func() {
+		var (
+			s   store.Store
+			err error
+		)
+
+		BeforeEach(func() {
+			s, err = store.NewMongoStore(testdbhost, testdbname, 5*time.Second)
+		})
+
+		AfterEach(func() {
+			s.Close()
+		})
+
+		It("fetches the existing entities with a single query.", func() {
+			var result []TestItem
+			items := []TestItem{{Name: "foo", ID: bson.NewObjectId()}, {Name: "bar", ID: bson.NewObjectId()}}
+			for _, item := range items {
+				if err := database.C(testcoll).Insert(item); err != nil {
+					Fail(err.Error())
+				}
+			}
+			ids := []string{items[0].ID.Hex(), items[1].ID.Hex(), bson.NewObjectId().Hex(), "invalid"}
+			err := s.(store.BatchGetter).GetEntities(context.Background(), testcoll, ids, &result)
+			Expect(err).To(BeNil())
+			Expect(len(result)).To(Equal(2))
+		})
+	})
+
+	Describe("Aggregate", func() {
+		var (
+			s   store.Store
+			err error
+		)
+
+		BeforeEach(func() {
+			s, err = store.NewMongoStore(testdbhost, testdbname, 5*time.Second)
+			for _, item := range []TestItem{{Name: "a", Tag: "x"}, {Name: "b", Tag: "x"}, {Name: "c", Tag: "y"}} {
+				if err := database.C(testcoll).Insert(item); err != nil {
+					Fail(err.Error())
+				}
+			}
+		})
+
+		AfterEach(func() {
+			s.Close()
+		})
+
+		It("counts matching entities.", func() {
+			n, err := s.(store.Counter).CountEntities(context.Background(), testcoll, url.Values{"tag": {"x"}})
+			Expect(err).To(BeNil())
+			Expect(n).To(Equal(2))
+		})
+
+		It("groups entities with an aggregation pipeline.", func() {
+			var result []map[string]interface{}
+			err := s.(store.Aggregator).Aggregate(context.Background(), testcoll, nil, store.Aggregation{
+				GroupBy: []string{"tag"},
+				Metrics: []store.Metric{{Op: store.OpCount}, {Op: store.OpMax, Field: "name"}},
+			}, &result)
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal([]map[string]interface{}{
+				{"tag": "x", "count": 2, "max_name": "b"},
+				{"tag": "y", "count": 1, "max_name": "c"},
+			}))
+		})
+	})
+
+	Describe("EnsureIndexes", func() {
+		var (
+			s   store.Store
+			err error
+		)
+
+		BeforeEach(func() {
+			s, err = store.NewMongoStore(testdbhost, testdbname, 5*time.Second)
+		})
+
+		AfterEach(func() {
+			s.Close()
+		})
+
+		It("creates the indexes, rejecting duplicates of unique ones.", func() {
+			indexes := []store.Index{{Key: []string{"name"}, Unique: true}, {Key: []string{"tag", "-name"}}}
+			Expect(s.(store.Indexer).EnsureIndexes(context.Background(), testcoll, indexes)).To(Succeed())
+			created, err := database.C(testcoll).Indexes()
+			Expect(err).To(BeNil())
+			Expect(len(created)).To(Equal(3))
+
+			result := make(map[string]interface{})
+			Expect(s.CreateEntity(context.Background(), testcoll, TestItem{Name: "foo"}, &result)).To(Succeed())
+			err = s.CreateEntity(context.Background(), testcoll, TestItem{Name: "foo"}, &result)
+			Expect(errors.Is(err, store.ErrDuplicate)).To(BeTrue())
+		})
+	})
+
+	Describe("CreateEntities", func() {
+		var (
+			s   store.Store
+			err error
+		)
+
+		BeforeEach(func() {
+			s, err = store.NewMongoStore(testdbhost, testdbname, 5*time.Second)
+		})
+
+		AfterEach(func() {
+			s.Close()
+		})
+
+		It("persists valid entities and reports failed ones by index.", func() {
+			id := bson.NewObjectId()
+			items := []interface{}{
+				TestItem{ID: id, Name: "foo"},
+				TestItem{ID: id, Name: "bar"},
+				TestItem{Name: "baz"},
+			}
+			failed, err := s.(store.BatchCreator).CreateEntities(context.Background(), testcoll, items)
+			Expect(err).To(BeNil())
+			Expect(len(failed)).To(Equal(1))
+			Expect(failed).To(HaveKey(1))
+			count, _ := database.C(testcoll).Count()
+			Expect(count).To(Equal(2))
+		})
+	})
+
 	Describe("UpdateEntity", func() {
 		var (
 			s   store.Store
@@ -245,7 +409,7 @@ var _ = Describe("MongoStore", func() {
 				if err := database.C(testcoll).Insert(source); err != nil {
 					Fail(err.Error())
 				}
-				err := s.UpdateEntity(testcoll, source.ID.Hex(), changed, &result)
+				err := s.UpdateEntity(context.Background(), testcoll, source.ID.Hex(), changed, &result)
 				Expect(err).To(BeNil())
 				Expect(result.ID.Valid()).To(BeTrue())
 				Expect(result.ID).To(Equal(source.ID))
@@ -259,7 +423,7 @@ var _ = Describe("MongoStore", func() {
 				var result TestItem
 				changed := TestItem{Name: "foo", Tag: "bar"}
 				id := bson.NewObjectId().Hex()
-				err := s.UpdateEntity(testcoll, id, changed, &result)
+				err := s.UpdateEntity(context.Background(), testcoll, id, changed, &result)
 				Expect(err).ToNot(BeNil())
 				Expect(result.ID.Valid()).To(BeFalse())
 			})
@@ -287,7 +451,7 @@ var _ = Describe("MongoStore", func() {
 				if err := database.C(testcoll).Insert(source); err != nil {
 					Fail(err.Error())
 				}
-				err := s.DeleteEntity(testcoll, source.ID.Hex())
+				err := s.DeleteEntity(context.Background(), testcoll, source.ID.Hex())
 				Expect(err).To(BeNil())
 				err = database.C(testcoll).FindId(source.ID).One(&result)
 				Expect(err).ToNot(BeNil())
@@ -297,7 +461,7 @@ var _ = Describe("MongoStore", func() {
 		Context("given a non existent entity.", func() {
 			It("returns an error.", func() {
 				source := TestItem{ID: bson.NewObjectId(), Name: "foo", Tag: ""}
-				err := s.DeleteEntity(testcoll, source.ID.Hex())
+				err := s.DeleteEntity(context.Background(), testcoll, source.ID.Hex())
 				Expect(err).ToNot(BeNil())
 			})
 		})
@@ -0,0 +1,369 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoDriverStore is a store implementation using mongodb as the database,
+// through the official MongoDB Go driver. It supports the same filters as
+// MongoStore.
+type MongoDriverStore struct {
+	client *mongo.Client
+	db     *mongo.Database
+	// session is the session of the transaction the store runs in, if any.
+	session mongo.Session
+}
+
+// NewMongoDriverStore connects to the deployment in the given connection
+// string and returns a store for the named database, or any connection errors.
+// Pooling, read and write concerns and other client settings are configured
+// with the connection string's options, or with the given options which take
+// precedence, for example options.Client().SetMaxPoolSize(50).
+func NewMongoDriverStore(ctx context.Context, uri string, database string, opts ...*options.ClientOptions) (Store, error) {
+	// Decode embedded documents and arrays into the plain types used by the
+	// other stores, rather than the driver's bson.D and bson.A.
+	registry := bson.NewRegistry()
+	registry.RegisterTypeMapEntry(bson.TypeEmbeddedDocument, reflect.TypeOf(map[string]interface{}{}))
+	registry.RegisterTypeMapEntry(bson.TypeArray, reflect.TypeOf([]interface{}{}))
+	opts = append([]*options.ClientOptions{options.Client().ApplyURI(uri).SetRegistry(registry)}, opts...)
+	client, err := mongo.Connect(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err = client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+	return &MongoDriverStore{client: client, db: client.Database(database)}, nil
+}
+
+// driverFilter builds a mongo query from the given filters. Keys ending in "~"
+// match by regex, keys with multiple values match any of them.
+func driverFilter(filters url.Values) bson.M {
+	filter := bson.M{}
+	for k, v := range filters {
+		if strings.HasSuffix(k, "~") {
+			filter[k[0:len(k)-1]] = bson.M{"$regex": v[0], "$options": "im"}
+		} else if len(v) > 1 {
+			filter[k] = bson.M{"$in": v}
+		} else {
+			filter[k] = v[0]
+		}
+	}
+	return filter
+}
+
+// objectID returns the given id as an ObjectID if it is one, otherwise as is.
+func objectID(id string) interface{} {
+	if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+		return oid
+	}
+	return id
+}
+
+// driverError converts the driver's errors into ErrNotFound and ErrDuplicate.
+func driverError(err error) error {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return ErrNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return fmt.Errorf("%w: %s", ErrDuplicate, err)
+	}
+	return err
+}
+
+// ListEntities fetches all entities matching the given filters.
+func (s *MongoDriverStore) ListEntities(ctx context.Context, name string, filters url.Values, result interface{}) error {
+	ctx = s.scope(ctx)
+	cursor, err := s.db.Collection(name).Find(ctx, driverFilter(filters))
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, result)
+}
+
+// FindEntities fetches the entities matching the given filters, ordered and limited as given.
+func (s *MongoDriverStore) FindEntities(ctx context.Context, name string, filters url.Values, opts FindOptions, result interface{}) error {
+	ctx = s.scope(ctx)
+	find := options.Find()
+	if len(opts.Sort) > 0 {
+		sort := make(bson.D, len(opts.Sort))
+		for i, f := range opts.Sort {
+			sort[i] = bson.E{Key: strings.TrimPrefix(f, "-"), Value: 1}
+			if strings.HasPrefix(f, "-") {
+				sort[i].Value = -1
+			}
+		}
+		find.SetSort(sort)
+	}
+	if opts.Limit > 0 {
+		find.SetLimit(int64(opts.Limit))
+	}
+	cursor, err := s.db.Collection(name).Find(ctx, driverFilter(filters), find)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, result)
+}
+
+// StreamEntities returns an iterator over the entities matching the given
+// filters, fetched in batches as it advances.
+func (s *MongoDriverStore) StreamEntities(ctx context.Context, name string, filters url.Values) (Iterator, error) {
+	ctx = s.scope(ctx)
+	cursor, err := s.db.Collection(name).Find(ctx, driverFilter(filters))
+	if err != nil {
+		return nil, err
+	}
+	return &cursorIterator{ctx: ctx, cursor: cursor}, nil
+}
+
+// Search fetches the entities matching the given text query and filters, most
+// relevant first. It requires a text index on the collection.
+func (s *MongoDriverStore) Search(ctx context.Context, name string, query string, filters url.Values, result interface{}) error {
+	ctx = s.scope(ctx)
+	filter := driverFilter(filters)
+	filter["$text"] = bson.M{"$search": query}
+	score := bson.M{ScoreField: bson.M{"$meta": "textScore"}}
+	cursor, err := s.db.Collection(name).Find(ctx, filter, options.Find().SetProjection(score).SetSort(score))
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, result)
+}
+
+// CountEntities returns the number of entities matching the given filters.
+func (s *MongoDriverStore) CountEntities(ctx context.Context, name string, filters url.Values) (int, error) {
+	ctx = s.scope(ctx)
+	n, err := s.db.Collection(name).CountDocuments(ctx, driverFilter(filters))
+	return int(n), err
+}
+
+// Aggregate computes the given aggregation over the entities matching the
+// given filters with an aggregation pipeline.
+func (s *MongoDriverStore) Aggregate(ctx context.Context, name string, filters url.Values, agg Aggregation, result interface{}) error {
+	if err := agg.Validate(); err != nil {
+		return err
+	}
+	ctx = s.scope(ctx)
+	// Group fields are keyed by position, since keys can't hold dotted paths.
+	id := bson.M{}
+	for i, f := range agg.GroupBy {
+		id[fmt.Sprintf("g%d", i)] = "$" + f
+	}
+	group := bson.M{"_id": id}
+	for _, m := range agg.Metrics {
+		if m.Op == OpCount {
+			group[m.Name()] = bson.M{"$sum": 1}
+		} else {
+			group[m.Name()] = bson.M{"$" + m.Op: "$" + m.Field}
+		}
+	}
+	pipeline := []bson.M{{"$match": driverFilter(filters)}, {"$group": group}}
+	cursor, err := s.db.Collection(name).Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	var rows []map[string]interface{}
+	if err = cursor.All(ctx, &rows); err != nil {
+		return err
+	}
+	entities := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		key, _ := row["_id"].(map[string]interface{})
+		entity := make(map[string]interface{})
+		for j, f := range agg.GroupBy {
+			entity[f] = key[fmt.Sprintf("g%d", j)]
+		}
+		for _, m := range agg.Metrics {
+			entity[m.Name()] = row[m.Name()]
+		}
+		entities[i] = entity
+	}
+	SortGroups(entities, agg.GroupBy)
+	return assign(entities, result)
+}
+
+// EnsureIndexes creates the given indexes on the named collection if they don't exist.
+func (s *MongoDriverStore) EnsureIndexes(ctx context.Context, name string, indexes []Index) error {
+	ctx = s.scope(ctx)
+	for _, i := range indexes {
+		keys := bson.D{}
+		for _, k := range i.Key {
+			switch {
+			case strings.HasPrefix(k, "-"):
+				keys = append(keys, bson.E{Key: k[1:], Value: -1})
+			case strings.HasPrefix(k, "$text:"):
+				keys = append(keys, bson.E{Key: strings.TrimPrefix(k, "$text:"), Value: "text"})
+			default:
+				keys = append(keys, bson.E{Key: k, Value: 1})
+			}
+		}
+		opts := options.Index().SetUnique(i.Unique)
+		if i.ExpireAfter > 0 {
+			opts.SetExpireAfterSeconds(int32(i.ExpireAfter / time.Second))
+		}
+		if i.Name != "" {
+			opts.SetName(i.Name)
+		}
+		model := mongo.IndexModel{Keys: keys, Options: opts}
+		if _, err := s.db.Collection(name).Indexes().CreateOne(ctx, model); err != nil {
+			return fmt.Errorf("error creating index %v on %s: %w", i.Key, name, err)
+		}
+	}
+	return nil
+}
+
+// GetEntity fetches the entity with the given id.
+func (s *MongoDriverStore) GetEntity(ctx context.Context, name string, id string, result interface{}) error {
+	ctx = s.scope(ctx)
+	return driverError(s.db.Collection(name).FindOne(ctx, bson.M{"_id": objectID(id)}).Decode(result))
+}
+
+// GetEntities fetches the entities with the given ids with a single query.
+// Ids that are not valid object ids are matched as strings.
+func (s *MongoDriverStore) GetEntities(ctx context.Context, name string, ids []string, result interface{}) error {
+	ctx = s.scope(ctx)
+	in := make([]interface{}, len(ids))
+	for i, id := range ids {
+		in[i] = objectID(id)
+	}
+	cursor, err := s.db.Collection(name).Find(ctx, bson.M{"_id": bson.M{"$in": in}})
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, result)
+}
+
+// CreateEntity persists a new entity and fetches it into result.
+func (s *MongoDriverStore) CreateEntity(ctx context.Context, name string, data interface{}, result interface{}) error {
+	ctx = s.scope(ctx)
+	inserted, err := s.db.Collection(name).InsertOne(ctx, data)
+	if err != nil {
+		return driverError(err)
+	}
+	return s.db.Collection(name).FindOne(ctx, bson.M{"_id": inserted.InsertedID}).Decode(result)
+}
+
+// CreateEntities persists the given entities with a single unordered insert,
+// so valid entities are persisted even if others fail.
+func (s *MongoDriverStore) CreateEntities(ctx context.Context, name string, data []interface{}) (map[int]error, error) {
+	ctx = s.scope(ctx)
+	failed := make(map[int]error)
+	if len(data) == 0 {
+		return failed, nil
+	}
+	_, err := s.db.Collection(name).InsertMany(ctx, data, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, e := range bulkErr.WriteErrors {
+			failed[e.Index] = driverError(e)
+		}
+		return failed, nil
+	}
+	return failed, err
+}
+
+// UpdateEntity replaces the entity with the given id and fetches it into result.
+func (s *MongoDriverStore) UpdateEntity(ctx context.Context, name string, id string, data interface{}, result interface{}) error {
+	ctx = s.scope(ctx)
+	filter := bson.M{"_id": objectID(id)}
+	updated, err := s.db.Collection(name).ReplaceOne(ctx, filter, data)
+	if err != nil {
+		return driverError(err)
+	}
+	if updated.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return s.db.Collection(name).FindOne(ctx, filter).Decode(result)
+}
+
+// DeleteEntity removes the entity with the given id.
+func (s *MongoDriverStore) DeleteEntity(ctx context.Context, name string, id string) error {
+	ctx = s.scope(ctx)
+	deleted, err := s.db.Collection(name).DeleteOne(ctx, bson.M{"_id": objectID(id)})
+	if err != nil {
+		return err
+	}
+	if deleted.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// WithTransaction runs fn in a transaction of a new session, which needs a
+// replica set or sharded cluster. The driver retries fn on transient errors
+// and retries committing on unknown commit results.
+func (s *MongoDriverStore) WithTransaction(ctx context.Context, fn TxFunc) error {
+	if s.session != nil {
+		return fn(ctx, s)
+	}
+	session, err := s.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+	tx := &MongoDriverStore{client: s.client, db: s.db, session: session}
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc, tx)
+	})
+	return err
+}
+
+// scope binds the given context to the store's transaction, if any, so
+// operations join it whatever context they are called with.
+func (s *MongoDriverStore) scope(ctx context.Context) context.Context {
+	if s.session == nil {
+		return ctx
+	}
+	return mongo.NewSessionContext(ctx, s.session)
+}
+
+// Close disconnects from the deployment. Stores of transactions share their
+// connections with the store they were started from, closing them does nothing.
+func (s *MongoDriverStore) Close() {
+	if s.client != nil && s.session == nil {
+		s.client.Disconnect(context.Background())
+	}
+}
+
+// cursorIterator iterates over the results of a query.
+type cursorIterator struct {
+	ctx    context.Context
+	cursor *mongo.Cursor
+	err    error
+}
+
+func (it *cursorIterator) Next(result interface{}) bool {
+	if it.err != nil || !it.cursor.Next(it.ctx) {
+		return false
+	}
+	if it.err = it.cursor.Decode(result); it.err != nil {
+		return false
+	}
+	return true
+}
+
+func (it *cursorIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.cursor.Err()
+}
+
+func (it *cursorIterator) Close() error {
+	if err := it.cursor.Close(it.ctx); err != nil {
+		return err
+	}
+	return it.Err()
+}
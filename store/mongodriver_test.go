@@ -0,0 +1,140 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"goresource/store"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MongoDriverStore", func() {
+	var (
+		ctx        = context.Background()
+		testdburi  = "mongodb://127.0.0.1"
+		testdbname = "goresourcedrivertestdatabase"
+		testcoll   = "testitems"
+	)
+
+	It("rejects invalid connection strings.", func() {
+		s, err := store.NewMongoDriverStore(ctx, "invalid://127.0.0.1", testdbname)
+		Expect(err).NotTo(BeNil())
+		Expect(s).To(BeNil())
+	})
+
+	Context("against a server", func() {
+		var s store.Store
+
+		BeforeEach(func() {
+			var err error
+			s, err = store.NewMongoDriverStore(ctx, testdburi, testdbname, options.Client().SetMaxPoolSize(4))
+			Expect(err).To(BeNil())
+			Expect(s.(store.Indexer).EnsureIndexes(ctx, testcoll, []store.Index{{Key: []string{"name"}, Unique: true}})).To(Succeed())
+			for _, name := range []string{"foo", "bar", "baz"} {
+				result := make(map[string]interface{})
+				Expect(s.CreateEntity(ctx, testcoll, map[string]interface{}{"name": name, "tag": name[:2]}, &result)).To(Succeed())
+			}
+		})
+
+		AfterEach(func() {
+			var items []map[string]interface{}
+			Expect(s.ListEntities(ctx, testcoll, nil, &items)).To(Succeed())
+			for _, item := range items {
+				Expect(s.DeleteEntity(ctx, testcoll, item["_id"].(primitive.ObjectID).Hex())).To(Succeed())
+			}
+			s.Close()
+		})
+
+		It("lists entities with the same filters as MongoStore.", func() {
+			var result []map[string]interface{}
+			Expect(s.ListEntities(ctx, testcoll, url.Values{"name": {"foo", "bar"}}, &result)).To(Succeed())
+			Expect(result).To(HaveLen(2))
+			Expect(s.ListEntities(ctx, testcoll, url.Values{"name~": {"^BA"}}, &result)).To(Succeed())
+			Expect(result).To(HaveLen(2))
+			Expect(s.ListEntities(ctx, testcoll, url.Values{"tag": {"fo"}}, &result)).To(Succeed())
+			Expect(result).To(HaveLen(1))
+		})
+
+		It("finds entities in order, up to a limit.", func() {
+			var result []map[string]interface{}
+			opts := store.FindOptions{Sort: []string{"-name"}, Limit: 2}
+			Expect(s.(store.Finder).FindEntities(ctx, testcoll, url.Values{"tag": {"ba", "fo"}}, opts, &result)).To(Succeed())
+			Expect(result).To(HaveLen(2))
+			Expect(result[0]["name"]).To(Equal("foo"))
+			Expect(result[1]["name"]).To(Equal("baz"))
+		})
+
+		It("gets, updates and deletes entities by id.", func() {
+			var list []map[string]interface{}
+			Expect(s.ListEntities(ctx, testcoll, url.Values{"name": {"foo"}}, &list)).To(Succeed())
+			id := list[0]["_id"].(primitive.ObjectID).Hex()
+			result := make(map[string]interface{})
+			Expect(s.UpdateEntity(ctx, testcoll, id, map[string]interface{}{"name": "qux"}, &result)).To(Succeed())
+			Expect(result["name"]).To(Equal("qux"))
+			Expect(s.GetEntity(ctx, testcoll, id, &result)).To(Succeed())
+			Expect(result["name"]).To(Equal("qux"))
+			Expect(s.DeleteEntity(ctx, testcoll, id)).To(Succeed())
+			Expect(s.GetEntity(ctx, testcoll, id, &result)).To(Equal(store.ErrNotFound))
+			Expect(s.DeleteEntity(ctx, testcoll, id)).To(Equal(store.ErrNotFound))
+			Expect(s.UpdateEntity(ctx, testcoll, id, map[string]interface{}{"name": "x"}, &result)).To(Equal(store.ErrNotFound))
+		})
+
+		It("rejects unique index violations with ErrDuplicate.", func() {
+			result := make(map[string]interface{})
+			err := s.CreateEntity(ctx, testcoll, map[string]interface{}{"name": "foo"}, &result)
+			Expect(errors.Is(err, store.ErrDuplicate)).To(BeTrue())
+			failed, err := s.(store.BatchCreator).CreateEntities(ctx, testcoll, []interface{}{
+				map[string]interface{}{"name": "new"}, map[string]interface{}{"name": "bar"},
+			})
+			Expect(err).To(BeNil())
+			Expect(failed).To(HaveLen(1))
+			Expect(errors.Is(failed[1], store.ErrDuplicate)).To(BeTrue())
+		})
+
+		It("commits transactions and rolls them back on errors.", func() {
+			result := make(map[string]interface{})
+			err := store.WithTransaction(ctx, s, func(ctx context.Context, tx store.Store) error {
+				return tx.CreateEntity(ctx, testcoll, map[string]interface{}{"name": "committed"}, &result)
+			})
+			Expect(err).To(BeNil())
+			err = store.WithTransaction(ctx, s, func(ctx context.Context, tx store.Store) error {
+				if err := tx.CreateEntity(context.Background(), testcoll, map[string]interface{}{"name": "rolled back"}, &result); err != nil {
+					return err
+				}
+				return tx.CreateEntity(ctx, testcoll, map[string]interface{}{"name": "foo"}, &result)
+			})
+			Expect(errors.Is(err, store.ErrDuplicate)).To(BeTrue())
+			var names []map[string]interface{}
+			Expect(s.ListEntities(ctx, testcoll, url.Values{"name": {"committed", "rolled back"}}, &names)).To(Succeed())
+			Expect(names).To(HaveLen(1))
+			Expect(names[0]["name"]).To(Equal("committed"))
+		})
+
+		It("streams, counts and aggregates entities.", func() {
+			it, err := s.(store.Streamer).StreamEntities(ctx, testcoll, url.Values{"tag": {"ba"}})
+			Expect(err).To(BeNil())
+			n, item := 0, make(map[string]interface{})
+			for it.Next(&item) {
+				n++
+			}
+			Expect(it.Close()).To(Succeed())
+			Expect(n).To(Equal(2))
+
+			count, err := s.(store.Counter).CountEntities(ctx, testcoll, url.Values{"tag": {"ba"}})
+			Expect(err).To(BeNil())
+			Expect(count).To(Equal(2))
+
+			var rows []map[string]interface{}
+			err = s.(store.Aggregator).Aggregate(ctx, testcoll, nil, store.Aggregation{
+				GroupBy: []string{"tag"}, Metrics: []store.Metric{{Op: store.OpCount}}}, &rows)
+			Expect(err).To(BeNil())
+			Expect(rows).To(Equal([]map[string]interface{}{{"tag": "ba", "count": int32(2)}, {"tag": "fo", "count": int32(1)}}))
+		})
+	})
+})
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultOutbox is the collection outbox events are written to by default.
+const DefaultOutbox = "outbox"
+
+// Outbox event actions, matching goresource's audit actions.
+const (
+	OutboxCreate = "create"
+	OutboxUpdate = "update"
+	OutboxDelete = "delete"
+)
+
+// Outbox event statuses.
+const (
+	OutboxPending   = "pending"
+	OutboxPublished = "published"
+)
+
+// OutboxEvent records a change to an entity, written along with the change.
+type OutboxEvent struct {
+	// ID is assigned by the store. It is unchanged when an event is published
+	// more than once, for consumers to deduplicate.
+	ID         string                 `json:"id,omitempty" bson:"_id,omitempty"`
+	Time       time.Time              `json:"time" bson:"time"`
+	Collection string                 `json:"collection" bson:"collection"`
+	Action     string                 `json:"action" bson:"action"`
+	EntityID   string                 `json:"entityId" bson:"entityId"`
+	Entity     map[string]interface{} `json:"entity,omitempty" bson:"entity,omitempty"`
+	// Status is OutboxPending until the event is published.
+	Status string `json:"status,omitempty" bson:"status"`
+}
+
+// OutboxStore is a Store writing an OutboxEvent for every entity it creates,
+// updates or deletes, for a relay to publish. Events are written in the same
+// transaction as the change if the wrapped store is a Transactor, so they are
+// written if and only if the change is. Other stores write them right after
+// the change, and lose the event if the process exits in between.
+type OutboxStore struct {
+	Forwarder
+	// Outbox names the collection events are written to.
+	Outbox string
+	// Collections, if set, limits the collections whose changes are recorded.
+	Collections []string
+	// tx is set for stores running in a transaction.
+	tx bool
+}
+
+// NewOutboxStore wraps the given store, writing events to the named collection.
+func NewOutboxStore(s Store, outbox string) *OutboxStore {
+	return &OutboxStore{Forwarder: Forwarder{s}, Outbox: outbox}
+}
+
+// records reports whether changes to the named collection are recorded.
+func (s *OutboxStore) records(name string) bool {
+	if name == s.Outbox {
+		return false
+	}
+	if len(s.Collections) == 0 {
+		return true
+	}
+	for _, c := range s.Collections {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// atomically runs fn in a transaction if the wrapped store has them.
+func (s *OutboxStore) atomically(ctx context.Context, fn TxFunc) error {
+	if !s.tx {
+		if err := WithTransaction(ctx, s.Store, fn); !errors.Is(err, ErrTransactionsUnsupported) {
+			return err
+		}
+	}
+	return fn(ctx, s.Store)
+}
+
+// record writes an event for a change to the given entity.
+func (s *OutboxStore) record(ctx context.Context, tx Store, name, action, id string, entity map[string]interface{}) error {
+	event := OutboxEvent{
+		Time:       time.Now().UTC(),
+		Collection: name,
+		Action:     action,
+		EntityID:   id,
+		Entity:     entity,
+		Status:     OutboxPending,
+	}
+	result := make(map[string]interface{})
+	return tx.CreateEntity(ctx, s.Outbox, event, &result)
+}
+
+// CreateEntity persists a new entity along with its create event.
+func (s *OutboxStore) CreateEntity(ctx context.Context, name string, data interface{}, result interface{}) error {
+	if !s.records(name) {
+		return s.Store.CreateEntity(ctx, name, data, result)
+	}
+	return s.atomically(ctx, func(ctx context.Context, tx Store) error {
+		if err := tx.CreateEntity(ctx, name, data, result); err != nil {
+			return err
+		}
+		doc, err := toDoc(result)
+		if err != nil {
+			return err
+		}
+		return s.record(ctx, tx, name, OutboxCreate, docID(doc), doc)
+	})
+}
+
+// UpdateEntity persists changes to an entity along with its update event.
+func (s *OutboxStore) UpdateEntity(ctx context.Context, name string, id string, data interface{}, result interface{}) error {
+	if !s.records(name) {
+		return s.Store.UpdateEntity(ctx, name, id, data, result)
+	}
+	return s.atomically(ctx, func(ctx context.Context, tx Store) error {
+		if err := tx.UpdateEntity(ctx, name, id, data, result); err != nil {
+			return err
+		}
+		doc, err := toDoc(result)
+		if err != nil {
+			return err
+		}
+		return s.record(ctx, tx, name, OutboxUpdate, id, doc)
+	})
+}
+
+// DeleteEntity removes an entity along with writing its delete event, which
+// carries the entity as it was before.
+func (s *OutboxStore) DeleteEntity(ctx context.Context, name string, id string) error {
+	if !s.records(name) {
+		return s.Store.DeleteEntity(ctx, name, id)
+	}
+	return s.atomically(ctx, func(ctx context.Context, tx Store) error {
+		before := make(map[string]interface{})
+		if err := tx.GetEntity(ctx, name, id, &before); err != nil {
+			return err
+		}
+		if err := tx.DeleteEntity(ctx, name, id); err != nil {
+			return err
+		}
+		return s.record(ctx, tx, name, OutboxDelete, id, before)
+	})
+}
+
+// WithTransaction runs fn in a transaction of the wrapped store, recording
+// the changes made in it in the same transaction.
+func (s *OutboxStore) WithTransaction(ctx context.Context, fn TxFunc) error {
+	if s.tx {
+		return fn(ctx, s)
+	}
+	return WithTransaction(ctx, s.Store, func(ctx context.Context, tx Store) error {
+		return fn(ctx, &OutboxStore{Forwarder: Forwarder{tx}, Outbox: s.Outbox, Collections: s.Collections, tx: true})
+	})
+}
@@ -0,0 +1,129 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+
+	"goresource/mocks"
+	"goresource/store"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OutboxStore", func() {
+	var (
+		ctrl    *gomock.Controller
+		backend *txStore
+		tx      *mocks.MockStore
+		s       *store.OutboxStore
+		ctx     = context.Background()
+		event   store.OutboxEvent
+	)
+
+	// recordEvent expects an outbox event to be written to the given store.
+	recordEvent := func(m *mocks.MockStore) *gomock.Call {
+		return m.EXPECT().CreateEntity(gomock.Any(), "outbox", gomock.Any(), gomock.Any()).
+			Do(func(_ context.Context, _ string, data interface{}, _ interface{}) {
+				event = data.(store.OutboxEvent)
+			}).Return(nil)
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		tx = mocks.NewMockStore(ctrl)
+		backend = &txStore{MockStore: mocks.NewMockStore(ctrl), tx: tx}
+		s = store.NewOutboxStore(backend, "outbox")
+		event = store.OutboxEvent{}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("writes create events in the transaction creating the entity.", func() {
+		created := map[string]interface{}{"_id": "b1", "name": "go"}
+		gomock.InOrder(
+			tx.EXPECT().CreateEntity(gomock.Any(), "books", gomock.Any(), gomock.Any()).SetArg(3, created).Return(nil),
+			recordEvent(tx),
+		)
+		result := make(map[string]interface{})
+		Expect(s.CreateEntity(ctx, "books", map[string]interface{}{"name": "go"}, &result)).To(Succeed())
+		Expect(result).To(Equal(created))
+		Expect(backend.commits).To(Equal(1))
+		Expect(event.Collection).To(Equal("books"))
+		Expect(event.Action).To(Equal(store.OutboxCreate))
+		Expect(event.EntityID).To(Equal("b1"))
+		Expect(event.Entity).To(Equal(created))
+		Expect(event.Status).To(Equal(store.OutboxPending))
+	})
+
+	It("rolls back changes whose event can't be written.", func() {
+		tx.EXPECT().UpdateEntity(gomock.Any(), "books", "b1", gomock.Any(), gomock.Any()).Return(nil)
+		tx.EXPECT().CreateEntity(gomock.Any(), "outbox", gomock.Any(), gomock.Any()).Return(errors.New("test error"))
+		result := make(map[string]interface{})
+		Expect(s.UpdateEntity(ctx, "books", "b1", map[string]interface{}{}, &result)).To(MatchError("test error"))
+		Expect(backend.commits).To(Equal(0))
+	})
+
+	It("writes delete events with the deleted entity.", func() {
+		tx.EXPECT().GetEntity(gomock.Any(), "books", "b1", gomock.Any()).SetArg(3, map[string]interface{}{"name": "go"}).Return(nil)
+		tx.EXPECT().DeleteEntity(gomock.Any(), "books", "b1").Return(nil)
+		recordEvent(tx)
+		Expect(s.DeleteEntity(ctx, "books", "b1")).To(Succeed())
+		Expect(event.Action).To(Equal(store.OutboxDelete))
+		Expect(event.EntityID).To(Equal("b1"))
+		Expect(event.Entity).To(Equal(map[string]interface{}{"name": "go"}))
+	})
+
+	It("writes events right after changes on stores without transactions.", func() {
+		plain := mocks.NewMockStore(ctrl)
+		s = store.NewOutboxStore(store.NewTracedStore(plain), "outbox")
+		gomock.InOrder(
+			plain.EXPECT().UpdateEntity(gomock.Any(), "books", "b1", gomock.Any(), gomock.Any()).Return(nil),
+			recordEvent(plain),
+		)
+		result := make(map[string]interface{})
+		Expect(s.UpdateEntity(ctx, "books", "b1", map[string]interface{}{}, &result)).To(Succeed())
+		Expect(event.Action).To(Equal(store.OutboxUpdate))
+	})
+
+	It("records changes to the configured collections only.", func() {
+		s.Collections = []string{"books"}
+		backend.EXPECT().DeleteEntity(gomock.Any(), "authors", "a1").Return(nil)
+		backend.EXPECT().DeleteEntity(gomock.Any(), "outbox", "e1").Return(nil)
+		Expect(s.DeleteEntity(ctx, "authors", "a1")).To(Succeed())
+		Expect(s.DeleteEntity(ctx, "outbox", "e1")).To(Succeed())
+		Expect(backend.commits).To(Equal(0))
+	})
+
+	It("records changes made in transactions in the same transaction.", func() {
+		gomock.InOrder(
+			tx.EXPECT().UpdateEntity(gomock.Any(), "books", "b1", gomock.Any(), gomock.Any()).Return(nil),
+			recordEvent(tx),
+			tx.EXPECT().UpdateEntity(gomock.Any(), "books", "b2", gomock.Any(), gomock.Any()).Return(nil),
+			recordEvent(tx),
+		)
+		err := store.WithTransaction(ctx, s, func(ctx context.Context, t store.Store) error {
+			for _, id := range []string{"b1", "b2"} {
+				result := make(map[string]interface{})
+				if err := t.UpdateEntity(ctx, "books", id, map[string]interface{}{}, &result); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		Expect(err).To(BeNil())
+		Expect(backend.commits).To(Equal(1))
+	})
+
+	It("forwards reads to the wrapped store.", func() {
+		backend.EXPECT().ListEntities(gomock.Any(), "books", gomock.Any(), gomock.Any()).
+			SetArg(3, []map[string]interface{}{{"a": 1}, {"a": 2}}).Return(nil)
+		n, err := store.Count(ctx, s, "books", nil)
+		Expect(err).To(BeNil())
+		Expect(n).To(Equal(2))
+	})
+})
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults used by NewRedisCache.
+const (
+	DefaultRedisPoolSize = 8
+	DefaultRedisTimeout  = time.Second
+)
+
+// RedisError is an error replied by a Redis server.
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// RedisCache is a Cache on a server speaking the Redis protocol, such as
+// Redis, Valkey or KeyDB, for caches shared between processes.
+type RedisCache struct {
+	Addr string
+	// Password, if set, authenticates connections.
+	Password string
+	// DB selects the database connections use.
+	DB int
+	// Timeout bounds each command without an earlier context deadline.
+	Timeout time.Duration
+
+	pool chan *redisConn
+}
+
+// redisConn is a connection to a Redis server.
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// NewRedisCache returns a cache on the server at the given address, keeping
+// up to DefaultRedisPoolSize idle connections.
+func NewRedisCache(addr string) *RedisCache {
+	return &RedisCache{Addr: addr, Timeout: DefaultRedisTimeout, pool: make(chan *redisConn, DefaultRedisPoolSize)}
+}
+
+// Get returns the value of the key, if set.
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := c.Do(ctx, "GET", key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected reply %v", reply)
+	}
+	return value, true, nil
+}
+
+// Set sets the value of the key, expiring after ttl if positive.
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := c.Do(ctx, args...)
+	return err
+}
+
+// Delete deletes the given keys.
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	_, err := c.Do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+// Close closes the idle connections.
+func (c *RedisCache) Close() {
+	for {
+		select {
+		case conn := <-c.pool:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// Do sends a command and returns its reply: a string, an int64, a []byte, a
+// []interface{} or nil. Error replies are returned as RedisErrors.
+func (c *RedisCache) Do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(c.deadline(ctx))
+	reply, err := conn.do(args...)
+	var redisErr RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// The connection may be out of sync with the server.
+		conn.Close()
+		return nil, err
+	}
+	select {
+	case c.pool <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+// deadline returns the deadline of a command.
+func (c *RedisCache) deadline(ctx context.Context) time.Time {
+	deadline, ok := ctx.Deadline()
+	if timeout := time.Now().Add(c.Timeout); c.Timeout > 0 && (!ok || timeout.Before(deadline)) {
+		return timeout
+	}
+	return deadline
+}
+
+// conn returns an idle connection, or a new one.
+func (c *RedisCache) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-c.pool:
+		return conn, nil
+	default:
+	}
+	dialer := net.Dialer{Deadline: c.deadline(ctx)}
+	nc, err := dialer.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+	conn.SetDeadline(c.deadline(ctx))
+	if c.Password != "" {
+		if _, err := conn.do("AUTH", c.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if c.DB != 0 {
+		if _, err := conn.do("SELECT", strconv.Itoa(c.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// do writes a command as an array of bulk strings and reads its reply.
+func (conn *redisConn) do(args ...string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(conn, b.String()); err != nil {
+		return nil, err
+	}
+	return readReply(conn.r)
+}
+
+// readReply reads a reply in the Redis serialization protocol.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RedisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: invalid reply %q", line)
+}
@@ -0,0 +1,192 @@
+package store_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"goresource/store"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// redisServer is a fake server speaking enough of the Redis protocol for RedisCache.
+type redisServer struct {
+	net.Listener
+	mu       sync.Mutex
+	values   map[string]string
+	expires  map[string]time.Time
+	commands []string
+}
+
+func newRedisServer() *redisServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).To(BeNil())
+	s := &redisServer{Listener: l, values: make(map[string]string), expires: make(map[string]time.Time)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *redisServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		io.WriteString(conn, s.reply(args))
+	}
+}
+
+// readCommand reads an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func (s *redisServer) reply(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, strings.Join(args, " "))
+	switch args[0] {
+	case "AUTH":
+		if args[1] != "secret" {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		return "+OK\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		value, ok := s.values[args[1]]
+		if exp, set := s.expires[args[1]]; !ok || set && time.Now().After(exp) {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		s.values[args[1]] = args[2]
+		delete(s.expires, args[1])
+		if len(args) == 5 && args[3] == "PX" {
+			ms, _ := strconv.Atoi(args[4])
+			s.expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+		return "+OK\r\n"
+	case "DEL":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := s.values[key]; ok {
+				delete(s.values, key)
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+var _ = Describe("RedisCache", func() {
+	var (
+		server *redisServer
+		cache  *store.RedisCache
+		ctx    = context.Background()
+	)
+
+	BeforeEach(func() {
+		server = newRedisServer()
+		cache = store.NewRedisCache(server.Addr().String())
+	})
+
+	AfterEach(func() {
+		cache.Close()
+		server.Close()
+	})
+
+	It("gets, sets and deletes values.", func() {
+		_, ok, err := cache.Get(ctx, "a")
+		Expect(err).To(BeNil())
+		Expect(ok).To(BeFalse())
+		Expect(cache.Set(ctx, "a", []byte("line\r\nbreak"), 0)).To(Succeed())
+		value, ok, err := cache.Get(ctx, "a")
+		Expect(err).To(BeNil())
+		Expect(ok).To(BeTrue())
+		Expect(string(value)).To(Equal("line\r\nbreak"))
+		Expect(cache.Delete(ctx, "a", "b")).To(Succeed())
+		_, ok, _ = cache.Get(ctx, "a")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("sets values expiring after their ttl.", func() {
+		Expect(cache.Set(ctx, "a", []byte("1"), 5*time.Millisecond)).To(Succeed())
+		Expect(server.commands).To(ContainElement("SET a 1 PX 5"))
+		time.Sleep(10 * time.Millisecond)
+		_, ok, _ := cache.Get(ctx, "a")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("authenticates and selects the database on new connections.", func() {
+		cache.Password, cache.DB = "secret", 2
+		Expect(cache.Set(ctx, "a", []byte("1"), 0)).To(Succeed())
+		Expect(server.commands[:2]).To(Equal([]string{"AUTH secret", "SELECT 2"}))
+		cache.Close()
+
+		cache.Password = "wrong"
+		err := cache.Set(ctx, "a", []byte("1"), 0)
+		Expect(err).To(Equal(store.RedisError("WRONGPASS invalid password")))
+	})
+
+	It("returns error replies and reuses the connection.", func() {
+		_, err := cache.Do(ctx, "FLUSHALL")
+		Expect(err).To(MatchError("redis: ERR unknown command 'FLUSHALL'"))
+		Expect(cache.Set(ctx, "a", []byte("1"), 0)).To(Succeed())
+	})
+
+	It("fails commands to unreachable servers.", func() {
+		server.Close()
+		cache = store.NewRedisCache(server.Addr().String())
+		_, _, err := cache.Get(ctx, "a")
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("backs a CachedStore.", func() {
+		s := store.NewCachedStore(nil, cache)
+		s.Prefix = "test"
+		Expect(s.Stats()).To(Equal(store.CacheStats{}))
+		cache.Set(ctx, "test:books:version:b1", []byte("v1"), 0)
+		cache.Set(ctx, "test:books:entity:b1:v1", []byte(`{"id": "b1"}`), 0)
+		result := make(map[string]interface{})
+		Expect(s.GetEntity(ctx, "books", "b1", &result)).To(Succeed())
+		Expect(result).To(Equal(map[string]interface{}{"id": "b1"}))
+		Expect(s.Stats().Hits).To(Equal(uint64(1)))
+	})
+})
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// ScoreField is the field search results hold their relevance score in.
+const ScoreField = "_score"
+
+// ErrSearchUnsupported is returned searching stores without full text search.
+var ErrSearchUnsupported = errors.New("search not supported")
+
+// Searcher is implemented by stores with full text search.
+type Searcher interface {
+	// Search fetches the entities matching the given text query and filters
+	// into result, a pointer to a slice, most relevant first. Each entity
+	// holds its relevance in ScoreField.
+	Search(ctx context.Context, name string, query string, filters url.Values, result interface{}) error
+}
+
+// Search returns the entities matching the given text query and filters, most
+// relevant first, or ErrSearchUnsupported if neither the store nor the stores
+// it wraps are Searchers.
+func Search(ctx context.Context, s Store, name string, query string, filters url.Values) ([]map[string]interface{}, error) {
+	searcher, ok := searcherOf(s)
+	if !ok {
+		return nil, ErrSearchUnsupported
+	}
+	result := make([]map[string]interface{}, 0)
+	if err := searcher.Search(ctx, name, query, filters, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// searcherOf returns the given store if it is a Searcher, otherwise the first
+// Searcher among the stores it wraps, unwrapped with Unwrap.
+func searcherOf(s Store) (Searcher, bool) {
+	for s != nil {
+		if searcher, ok := s.(Searcher); ok {
+			return searcher, true
+		}
+		w, ok := s.(interface{ Unwrap() Store })
+		if !ok {
+			break
+		}
+		s = w.Unwrap()
+	}
+	return nil, false
+}
+
+// Tokenize splits text into lower case words for indexing and searching.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// NewSliceIterator returns an iterator over already fetched entities.
+func NewSliceIterator(entities []map[string]interface{}) Iterator {
+	return &sliceIterator{items: entities}
+}
@@ -0,0 +1,239 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// ErrInvalidTable is returned for collection names that are not valid table names.
+var ErrInvalidTable = errors.New("invalid table name")
+
+// tableName matches the collection names SQLStore accepts as table names,
+// which are interpolated into its statements.
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLStore is a Store keeping entities as json documents in SQL tables, one
+// per collection with an id and a doc column, created by CreateTable. It works
+// with any database/sql driver. Filters are applied to the documents as
+// fetched, so listing reads the whole table.
+type SQLStore struct {
+	db *sql.DB
+	tx *sql.Tx
+	// Placeholder returns the placeholder of the nth parameter of a statement,
+	// counting from 1. It defaults to "?", use DollarPlaceholder for Postgres.
+	Placeholder func(n int) string
+}
+
+// sqlQuerier is implemented by both databases and transactions.
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// DollarPlaceholder numbers parameters as $1, $2..., as Postgres expects.
+func DollarPlaceholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+// NewSQLStore returns a SQLStore on the given database.
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// CreateTable creates the table of the named collection if it doesn't exist.
+func (s *SQLStore) CreateTable(ctx context.Context, name string) error {
+	return s.exec(ctx, "CREATE TABLE IF NOT EXISTS %s (id VARCHAR(64) PRIMARY KEY, doc TEXT NOT NULL)", name)
+}
+
+// GetEntity fetches the entity with the given id.
+func (s *SQLStore) GetEntity(ctx context.Context, name string, id string, result interface{}) error {
+	query, err := s.query("SELECT doc FROM %s WHERE id = %s", name, 1)
+	if err != nil {
+		return err
+	}
+	var doc string
+	if err := s.querier().QueryRowContext(ctx, query, id).Scan(&doc); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return json.Unmarshal([]byte(doc), result)
+}
+
+// CreateEntity persists a new entity with the given data, rejecting ids that
+// already exist with ErrDuplicate.
+func (s *SQLStore) CreateEntity(ctx context.Context, name string, data interface{}, result interface{}) error {
+	doc, err := toDoc(data)
+	if err != nil {
+		return err
+	}
+	id, doc := entityID(doc)
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	var existing interface{}
+	if err := s.GetEntity(ctx, name, id, &existing); err == nil {
+		return ErrDuplicate
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if err := s.exec(ctx, "INSERT INTO %s (id, doc) VALUES (%s, %s)", name, id, string(encoded)); err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, result)
+}
+
+// ListEntities fetches the entities matching the given filters, ordered by id.
+func (s *SQLStore) ListEntities(ctx context.Context, name string, filters url.Values, result interface{}) error {
+	match, err := Matcher(filters)
+	if err != nil {
+		return err
+	}
+	query, err := s.query("SELECT doc FROM %s ORDER BY id", name, 0)
+	if err != nil {
+		return err
+	}
+	rows, err := s.querier().QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	entities := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		var doc string
+		if err := rows.Scan(&doc); err != nil {
+			return err
+		}
+		entity := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(doc), &entity); err != nil {
+			return err
+		}
+		if match(entity) {
+			entities = append(entities, entity)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return assign(entities, result)
+}
+
+// UpdateEntity replaces the entity with the given id with the given data.
+func (s *SQLStore) UpdateEntity(ctx context.Context, name string, id string, data interface{}, result interface{}) error {
+	doc, err := toDoc(data)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(withID(doc, id))
+	if err != nil {
+		return err
+	}
+	query, err := s.query("UPDATE %s SET doc = %s WHERE id = %s", name, 2)
+	if err != nil {
+		return err
+	}
+	err = affected(s.querier().ExecContext(ctx, query, string(encoded), id))
+	if errors.Is(err, ErrNotFound) {
+		// Some databases count only the rows whose values changed.
+		var existing interface{}
+		err = s.GetEntity(ctx, name, id, &existing)
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, result)
+}
+
+// DeleteEntity removes the entity with the given id.
+func (s *SQLStore) DeleteEntity(ctx context.Context, name string, id string) error {
+	query, err := s.query("DELETE FROM %s WHERE id = %s", name, 1)
+	if err != nil {
+		return err
+	}
+	return affected(s.querier().ExecContext(ctx, query, id))
+}
+
+// WithTransaction runs fn in a database transaction, committed if fn returns
+// nil and rolled back otherwise.
+func (s *SQLStore) WithTransaction(ctx context.Context, fn TxFunc) error {
+	if s.tx != nil {
+		return fn(ctx, s)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(ctx, &SQLStore{db: s.db, tx: tx, Placeholder: s.Placeholder}); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("%w, and rolling back failed: %v", err, rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// Close closes the database. Stores of transactions share the database with
+// the store they were started from, closing them does nothing.
+func (s *SQLStore) Close() {
+	if s.tx == nil {
+		s.db.Close()
+	}
+}
+
+func (s *SQLStore) querier() sqlQuerier {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+// query formats the given statement with the table of the named collection
+// and the placeholders of its params parameters.
+func (s *SQLStore) query(format string, name string, params int) (string, error) {
+	if !tableName.MatchString(name) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidTable, name)
+	}
+	placeholder := s.Placeholder
+	if placeholder == nil {
+		placeholder = func(int) string { return "?" }
+	}
+	args := []interface{}{name}
+	for n := 1; n <= params; n++ {
+		args = append(args, placeholder(n))
+	}
+	return fmt.Sprintf(format, args...), nil
+}
+
+// exec executes the given statement on the table of the named collection,
+// with a parameter for each of the given args.
+func (s *SQLStore) exec(ctx context.Context, format string, name string, args ...interface{}) error {
+	query, err := s.query(format, name, len(args))
+	if err != nil {
+		return err
+	}
+	_, err = s.querier().ExecContext(ctx, query, args...)
+	return err
+}
+
+// affected returns ErrNotFound for statements that changed no rows.
+func affected(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+
+	"goresource/store"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeDB is a database/sql driver understanding the statements of SQLStore,
+// keeping tables of ids to documents in memory. Transactions work on a copy
+// of the tables replacing them on commit.
+type fakeDB struct {
+	mu     sync.Mutex
+	tables map[string]map[string]string
+}
+
+var fakeStatements = map[string]*regexp.Regexp{
+	"create": regexp.MustCompile(`^CREATE TABLE IF NOT EXISTS (\w+) \(id VARCHAR\(64\) PRIMARY KEY, doc TEXT NOT NULL\)$`),
+	"get":    regexp.MustCompile(`^SELECT doc FROM (\w+) WHERE id = (?:\?|\$1)$`),
+	"list":   regexp.MustCompile(`^SELECT doc FROM (\w+) ORDER BY id$`),
+	"insert": regexp.MustCompile(`^INSERT INTO (\w+) \(id, doc\) VALUES \((?:\?|\$1), (?:\?|\$2)\)$`),
+	"update": regexp.MustCompile(`^UPDATE (\w+) SET doc = (?:\?|\$1) WHERE id = (?:\?|\$2)$`),
+	"delete": regexp.MustCompile(`^DELETE FROM (\w+) WHERE id = (?:\?|\$1)$`),
+}
+
+func (db *fakeDB) Open(string) (driver.Conn, error) {
+	return &fakeConn{db: db}, nil
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	return db.Open("")
+}
+
+func (db *fakeDB) Driver() driver.Driver {
+	return db
+}
+
+type fakeConn struct {
+	db     *fakeDB
+	tables map[string]map[string]string
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	for kind, re := range fakeStatements {
+		if m := re.FindStringSubmatch(query); m != nil {
+			return &fakeStmt{conn: c, kind: kind, table: m[1]}, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected statement %q", query)
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.tables = make(map[string]map[string]string)
+	for name, rows := range c.db.tables {
+		c.tables[name] = make(map[string]string)
+		for id, doc := range rows {
+			c.tables[name][id] = doc
+		}
+	}
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.tables, c.tables = c.tables, nil
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.tables = nil
+	return nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	kind  string
+	table string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+// rows returns the rows of the statement's table, locking the database
+// outside transactions until unlock is called.
+func (s *fakeStmt) rows() (rows map[string]string, unlock func(), err error) {
+	tables, unlock := s.conn.tables, func() {}
+	if tables == nil {
+		s.conn.db.mu.Lock()
+		tables, unlock = s.conn.db.tables, s.conn.db.mu.Unlock
+	}
+	if s.kind == "create" && tables[s.table] == nil {
+		tables[s.table] = make(map[string]string)
+	}
+	if rows = tables[s.table]; rows == nil {
+		unlock()
+		return nil, nil, fmt.Errorf("no such table: %s", s.table)
+	}
+	return rows, unlock, nil
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	rows, unlock, err := s.rows()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	var n int64
+	switch s.kind {
+	case "insert":
+		if _, ok := rows[args[0].(string)]; ok {
+			return nil, fmt.Errorf("UNIQUE constraint failed: %s.id", s.table)
+		}
+		rows[args[0].(string)] = args[1].(string)
+		n = 1
+	case "update":
+		if _, ok := rows[args[1].(string)]; ok {
+			rows[args[1].(string)] = args[0].(string)
+			n = 1
+		}
+	case "delete":
+		if _, ok := rows[args[0].(string)]; ok {
+			delete(rows, args[0].(string))
+			n = 1
+		}
+	}
+	return driver.RowsAffected(n), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, unlock, err := s.rows()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	var ids []string
+	if s.kind == "get" {
+		if _, ok := rows[args[0].(string)]; ok {
+			ids = append(ids, args[0].(string))
+		}
+	} else {
+		for id := range rows {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+	}
+	docs := &fakeRows{}
+	for _, id := range ids {
+		docs.docs = append(docs.docs, rows[id])
+	}
+	return docs, nil
+}
+
+type fakeRows struct {
+	docs []string
+}
+
+func (r *fakeRows) Columns() []string { return []string{"doc"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.docs) == 0 {
+		return io.EOF
+	}
+	dest[0], r.docs = r.docs[0], r.docs[1:]
+	return nil
+}
+
+// openFakeDB returns a new empty database of the fake driver.
+func openFakeDB() *sql.DB {
+	db := sql.OpenDB(&fakeDB{tables: make(map[string]map[string]string)})
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+var _ = Describe("SQLStore", func() {
+	ctx := context.Background()
+
+	itStoresEntities(func() store.Store {
+		s := store.NewSQLStore(openFakeDB())
+		Expect(s.CreateTable(ctx, "items")).To(Succeed())
+		return s
+	})
+
+	It("numbers parameters with the given placeholders.", func() {
+		s := store.NewSQLStore(openFakeDB())
+		s.Placeholder = store.DollarPlaceholder
+		Expect(s.CreateTable(ctx, "things")).To(Succeed())
+		var result map[string]interface{}
+		Expect(s.CreateEntity(ctx, "things", map[string]interface{}{"id": "t1"}, &result)).To(Succeed())
+		Expect(s.UpdateEntity(ctx, "things", "t1", map[string]interface{}{"name": "a"}, &result)).To(Succeed())
+		Expect(s.GetEntity(ctx, "things", "t1", &result)).To(Succeed())
+		Expect(result).To(Equal(map[string]interface{}{"id": "t1", "name": "a"}))
+		Expect(s.DeleteEntity(ctx, "things", "t1")).To(Succeed())
+	})
+
+	It("rejects collection names that are not table names.", func() {
+		s := store.NewSQLStore(openFakeDB())
+		var result map[string]interface{}
+		err := s.GetEntity(ctx, "items; DROP TABLE items", "i1", &result)
+		Expect(err).To(MatchError(store.ErrInvalidTable))
+		Expect(s.CreateTable(ctx, "1items")).To(MatchError(store.ErrInvalidTable))
+	})
+})
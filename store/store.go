@@ -1,14 +1,21 @@
 // package store implements a database store for goresource.
 package store
 
-import "net/url"
+import (
+	"context"
+	"errors"
+	"net/url"
+)
+
+// ErrNotFound is returned by stores for entities that don't exist.
+var ErrNotFound = errors.New("not found")
 
 // Store iterface is implemented by database stores.
 type Store interface {
-	GetEntity(name string, id string, result interface{}) error
-	CreateEntity(name string, data interface{}, result interface{}) error
-	ListEntities(name string, filters url.Values, result interface{}) error
-	UpdateEntity(name string, id string, data interface{}, result interface{}) error
-	DeleteEntity(name string, id string) error
+	GetEntity(ctx context.Context, name string, id string, result interface{}) error
+	CreateEntity(ctx context.Context, name string, data interface{}, result interface{}) error
+	ListEntities(ctx context.Context, name string, filters url.Values, result interface{}) error
+	UpdateEntity(ctx context.Context, name string, id string, data interface{}, result interface{}) error
+	DeleteEntity(ctx context.Context, name string, id string) error
 	Close()
 }
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+)
+
+// Iterator iterates over the results of a query, one entity at a time.
+type Iterator interface {
+	// Next decodes the next entity into result, returning false when there
+	// are no more entities or an error occurred.
+	Next(result interface{}) bool
+	// Err returns the error that stopped iteration, if any.
+	Err() error
+	// Close releases the resources held by the iterator.
+	Close() error
+}
+
+// Streamer is implemented by stores that can iterate over query results
+// without loading them all into memory.
+type Streamer interface {
+	StreamEntities(ctx context.Context, name string, filters url.Values) (Iterator, error)
+}
+
+// Stream returns an iterator over all entities matching the given filters.
+// Stores that are not a Streamer have their results listed up front.
+func Stream(ctx context.Context, s Store, name string, filters url.Values) (Iterator, error) {
+	if streamer, ok := s.(Streamer); ok {
+		return streamer.StreamEntities(ctx, name, filters)
+	}
+	result := make([]map[string]interface{}, 0)
+	if err := s.ListEntities(ctx, name, filters, &result); err != nil {
+		return nil, err
+	}
+	return NewSliceIterator(result), nil
+}
+
+// sliceIterator iterates over already listed entities.
+type sliceIterator struct {
+	items []map[string]interface{}
+	err   error
+}
+
+func (it *sliceIterator) Next(result interface{}) bool {
+	if it.err != nil || len(it.items) == 0 {
+		return false
+	}
+	item := it.items[0]
+	it.items = it.items[1:]
+	// Round trip through json to decode into arbitrary result types.
+	data, err := json.Marshal(item)
+	if err == nil {
+		err = json.Unmarshal(data, result)
+	}
+	if err != nil {
+		it.err = err
+		return false
+	}
+	return true
+}
+
+func (it *sliceIterator) Err() error {
+	return it.err
+}
+
+func (it *sliceIterator) Close() error {
+	it.items = nil
+	return it.err
+}
@@ -0,0 +1,67 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"goresource/mocks"
+	"goresource/store"
+
+	"github.com/golang/mock/gomock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stream", func() {
+	var (
+		ctrl    *gomock.Controller
+		backend *mocks.MockStore
+		ctx     = context.Background()
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		backend = mocks.NewMockStore(ctrl)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	Context("given a store that cannot stream", func() {
+		It("iterates over the listed entities.", func() {
+			want := []map[string]interface{}{{"name": "a"}, {"name": "b"}}
+			filters := url.Values{"tag": {"x"}}
+			backend.EXPECT().ListEntities(gomock.Any(), "books", filters, gomock.Any()).SetArg(3, want).Return(nil)
+			it, err := store.Stream(ctx, backend, "books", filters)
+			Expect(err).To(BeNil())
+			var names []string
+			var item struct{ Name string }
+			for it.Next(&item) {
+				names = append(names, item.Name)
+			}
+			Expect(it.Err()).To(BeNil())
+			Expect(it.Close()).To(BeNil())
+			Expect(names).To(Equal([]string{"a", "b"}))
+		})
+		It("passes through errors from the store.", func() {
+			backend.EXPECT().ListEntities(gomock.Any(), "books", nil, gomock.Any()).Return(fmt.Errorf("test error"))
+			it, err := store.Stream(ctx, backend, "books", nil)
+			Expect(err).ToNot(BeNil())
+			Expect(it).To(BeNil())
+		})
+	})
+
+	Context("given a store that can stream", func() {
+		It("uses the store's iterator.", func() {
+			s := store.NewTracedStore(backend)
+			backend.EXPECT().ListEntities(gomock.Any(), "books", nil, gomock.Any()).Return(nil)
+			it, err := store.Stream(ctx, s, "books", nil)
+			Expect(err).To(BeNil())
+			Expect(it.Next(&map[string]interface{}{})).To(BeFalse())
+			Expect(it.Close()).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"context"
+	"net/url"
+	"sort"
+
+	"github.com/rockstardevs/goresource/util"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attribute keys recorded by TracedStore.
+const (
+	AttrCollection  = attribute.Key("goresource.store.collection")
+	AttrEntityID    = attribute.Key("goresource.entity.id")
+	AttrEntityIDs   = attribute.Key("goresource.entity.ids")
+	AttrFilterKeys  = attribute.Key("goresource.filter.keys")
+	AttrResultCount = attribute.Key("goresource.result.count")
+)
+
+// TracedStore is a Store that records an OpenTelemetry span for every
+// operation it delegates to the wrapped Store.
+type TracedStore struct {
+	Forwarder
+	tracer trace.Tracer
+}
+
+// tracedSearcher is a TracedStore wrapping a store with full text search.
+type tracedSearcher struct {
+	*TracedStore
+	searcher Searcher
+}
+
+// NewTracedStore wraps the given store, tracing with the globally registered
+// provider. The returned store is a Searcher only if the given store searches.
+func NewTracedStore(s Store) Store {
+	return newTracedStore(s, otel.Tracer("github.com/rockstardevs/goresource/store"))
+}
+
+func newTracedStore(s Store, tracer trace.Tracer) Store {
+	traced := &TracedStore{Forwarder: Forwarder{s}, tracer: tracer}
+	if searcher, ok := searcherOf(s); ok {
+		return &tracedSearcher{TracedStore: traced, searcher: searcher}
+	}
+	return traced
+}
+
+func (s *TracedStore) start(ctx context.Context, op string, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, AttrCollection.String(name))
+	return s.tracer.Start(ctx, "store."+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func (s *TracedStore) end(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	return err
+}
+
+// FilterKeys returns the sorted keys of the given filters, as recorded on spans.
+func FilterKeys(filters url.Values) []string {
+	keys := make([]string, 0, len(filters))
+	for k := range filters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GetEntity traces fetching a specific entity with the given id.
+func (s *TracedStore) GetEntity(ctx context.Context, name string, id string, result interface{}) error {
+	ctx, span := s.start(ctx, "GetEntity", name, AttrEntityID.String(id))
+	return s.end(span, s.Store.GetEntity(ctx, name, id, result))
+}
+
+// GetEntities traces fetching entities in batch, recording how many were found.
+func (s *TracedStore) GetEntities(ctx context.Context, name string, ids []string, result interface{}) error {
+	ctx, span := s.start(ctx, "GetEntities", name, AttrEntityIDs.StringSlice(ids))
+	err := s.Forwarder.GetEntities(ctx, name, ids, result)
+	if err == nil {
+		span.SetAttributes(AttrResultCount.Int(util.Count(result)))
+	}
+	return s.end(span, err)
+}
+
+// CreateEntity traces persisting a new entity.
+func (s *TracedStore) CreateEntity(ctx context.Context, name string, data interface{}, result interface{}) error {
+	ctx, span := s.start(ctx, "CreateEntity", name)
+	return s.end(span, s.Store.CreateEntity(ctx, name, data, result))
+}
+
+// CreateEntities traces persisting entities in batch, recording how many were created.
+func (s *TracedStore) CreateEntities(ctx context.Context, name string, data []interface{}) (map[int]error, error) {
+	ctx, span := s.start(ctx, "CreateEntities", name)
+	failed, err := CreateEntities(ctx, s.Store, name, data)
+	if err == nil {
+		span.SetAttributes(AttrResultCount.Int(len(data) - len(failed)))
+	}
+	return failed, s.end(span, err)
+}
+
+// ListEntities traces querying entities, recording the filter keys and result count.
+func (s *TracedStore) ListEntities(ctx context.Context, name string, filters url.Values, result interface{}) error {
+	ctx, span := s.start(ctx, "ListEntities", name, AttrFilterKeys.StringSlice(FilterKeys(filters)))
+	err := s.Store.ListEntities(ctx, name, filters, result)
+	if err == nil {
+		span.SetAttributes(AttrResultCount.Int(util.Count(result)))
+	}
+	return s.end(span, err)
+}
+
+// FindEntities traces a sorted, limited query, recording the filter keys and result count.
+func (s *TracedStore) FindEntities(ctx context.Context, name string, filters url.Values, opts FindOptions, result interface{}) error {
+	ctx, span := s.start(ctx, "FindEntities", name, AttrFilterKeys.StringSlice(FilterKeys(filters)))
+	err := s.Forwarder.FindEntities(ctx, name, filters, opts, result)
+	if err == nil {
+		span.SetAttributes(AttrResultCount.Int(util.Count(result)))
+	}
+	return s.end(span, err)
+}
+
+// CountEntities traces counting entities, recording the filter keys and count.
+func (s *TracedStore) CountEntities(ctx context.Context, name string, filters url.Values) (int, error) {
+	ctx, span := s.start(ctx, "CountEntities", name, AttrFilterKeys.StringSlice(FilterKeys(filters)))
+	n, err := s.Forwarder.CountEntities(ctx, name, filters)
+	if err == nil {
+		span.SetAttributes(AttrResultCount.Int(n))
+	}
+	return n, s.end(span, err)
+}
+
+// Aggregate traces an aggregation, recording the filter keys and number of groups.
+func (s *TracedStore) Aggregate(ctx context.Context, name string, filters url.Values, agg Aggregation, result interface{}) error {
+	ctx, span := s.start(ctx, "Aggregate", name, AttrFilterKeys.StringSlice(FilterKeys(filters)))
+	err := s.Forwarder.Aggregate(ctx, name, filters, agg, result)
+	if err == nil {
+		span.SetAttributes(AttrResultCount.Int(util.Count(result)))
+	}
+	return s.end(span, err)
+}
+
+// EnsureIndexes traces creating indexes, forwarding to stores with indexes.
+func (s *TracedStore) EnsureIndexes(ctx context.Context, name string, indexes []Index) error {
+	ctx, span := s.start(ctx, "EnsureIndexes", name)
+	return s.end(span, s.Forwarder.EnsureIndexes(ctx, name, indexes))
+}
+
+// WithTransaction traces a transaction, tracing the operations in it as its children.
+func (s *TracedStore) WithTransaction(ctx context.Context, fn TxFunc) error {
+	ctx, span := s.tracer.Start(ctx, "store.Transaction", trace.WithSpanKind(trace.SpanKindClient))
+	err := WithTransaction(ctx, s.Store, func(ctx context.Context, tx Store) error {
+		return fn(ctx, newTracedStore(tx, s.tracer))
+	})
+	return s.end(span, err)
+}
+
+// Search traces a full text search, recording the filter keys and result count.
+func (s *tracedSearcher) Search(ctx context.Context, name string, query string, filters url.Values, result interface{}) error {
+	ctx, span := s.start(ctx, "Search", name, AttrFilterKeys.StringSlice(FilterKeys(filters)))
+	err := s.searcher.Search(ctx, name, query, filters, result)
+	if err == nil {
+		span.SetAttributes(AttrResultCount.Int(util.Count(result)))
+	}
+	return s.end(span, err)
+}
+
+// UpdateEntity traces updating a specific entity with the given id.
+func (s *TracedStore) UpdateEntity(ctx context.Context, name string, id string, data interface{}, result interface{}) error {
+	ctx, span := s.start(ctx, "UpdateEntity", name, AttrEntityID.String(id))
+	return s.end(span, s.Store.UpdateEntity(ctx, name, id, data, result))
+}
+
+// DeleteEntity traces removing a specific entity with the given id.
+func (s *TracedStore) DeleteEntity(ctx context.Context, name string, id string) error {
+	ctx, span := s.start(ctx, "DeleteEntity", name, AttrEntityID.String(id))
+	return s.end(span, s.Store.DeleteEntity(ctx, name, id))
+}
+
+// StreamEntities traces opening and iterating over a stream of entities. The
+// span ends when the returned iterator is closed.
+func (s *TracedStore) StreamEntities(ctx context.Context, name string, filters url.Values) (Iterator, error) {
+	ctx, span := s.start(ctx, "StreamEntities", name, AttrFilterKeys.StringSlice(FilterKeys(filters)))
+	it, err := s.Forwarder.StreamEntities(ctx, name, filters)
+	if err != nil {
+		return nil, s.end(span, err)
+	}
+	return &tracedIterator{Iterator: it, store: s, span: span}, nil
+}
+
+// tracedIterator counts the entities iterated over and ends its span on Close.
+type tracedIterator struct {
+	Iterator
+	store *TracedStore
+	span  trace.Span
+	count int
+}
+
+func (it *tracedIterator) Next(result interface{}) bool {
+	if it.Iterator.Next(result) {
+		it.count++
+		return true
+	}
+	return false
+}
+
+func (it *tracedIterator) Close() error {
+	err := it.Iterator.Close()
+	if err == nil {
+		err = it.Iterator.Err()
+	}
+	it.span.SetAttributes(AttrResultCount.Int(it.count))
+	return it.store.end(it.span, err)
+}
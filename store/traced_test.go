@@ -0,0 +1,154 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"goresource/mocks"
+	"goresource/store"
+
+	"github.com/golang/mock/gomock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TracedStore", func() {
+	var (
+		ctrl     *gomock.Controller
+		backend  *mocks.MockStore
+		recorder *tracetest.SpanRecorder
+		s        store.Store
+		ctx      = context.Background()
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		backend = mocks.NewMockStore(ctrl)
+		recorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		s = store.NewTracedStore(backend)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("records a span for GetEntity with the entity id.", func() {
+		backend.EXPECT().GetEntity(gomock.Any(), "books", "fakeid", gomock.Any()).Return(nil)
+		Expect(s.GetEntity(ctx, "books", "fakeid", nil)).To(BeNil())
+		spans := recorder.Ended()
+		Expect(len(spans)).To(Equal(1))
+		Expect(spans[0].Name()).To(Equal("store.GetEntity"))
+		Expect(spans[0].Attributes()).To(ContainElement(store.AttrEntityID.String("fakeid")))
+		Expect(spans[0].Attributes()).To(ContainElement(store.AttrCollection.String("books")))
+	})
+
+	It("records filter keys and result count for ListEntities.", func() {
+		want := []map[string]interface{}{{"a": 1}, {"b": 2}}
+		backend.EXPECT().ListEntities(gomock.Any(), "books", gomock.Any(), gomock.Any()).SetArg(3, want).Return(nil)
+		var result []map[string]interface{}
+		err := s.ListEntities(ctx, "books", url.Values{"tag": {"a"}, "name~": {"b"}}, &result)
+		Expect(err).To(BeNil())
+		spans := recorder.Ended()
+		Expect(len(spans)).To(Equal(1))
+		Expect(spans[0].Attributes()).To(ContainElement(store.AttrFilterKeys.StringSlice([]string{"name~", "tag"})))
+		Expect(spans[0].Attributes()).To(ContainElement(attribute.Int("goresource.result.count", 2)))
+	})
+
+	It("records ids and result count for GetEntities.", func() {
+		backend.EXPECT().GetEntity(gomock.Any(), "books", "a", gomock.Any()).SetArg(3, map[string]interface{}{"id": "a"}).Return(nil)
+		backend.EXPECT().GetEntity(gomock.Any(), "books", "b", gomock.Any()).Return(store.ErrNotFound)
+		var result []map[string]interface{}
+		Expect(s.(store.BatchGetter).GetEntities(ctx, "books", []string{"a", "b"}, &result)).To(Succeed())
+		Expect(result).To(Equal([]map[string]interface{}{{"id": "a"}}))
+		spans := recorder.Ended()
+		Expect(spans[len(spans)-1].Name()).To(Equal("store.GetEntities"))
+		Expect(spans[len(spans)-1].Attributes()).To(ContainElement(store.AttrEntityIDs.StringSlice([]string{"a", "b"})))
+		Expect(spans[len(spans)-1].Attributes()).To(ContainElement(store.AttrResultCount.Int(1)))
+	})
+
+	It("records a span for Search, forwarding to searching stores.", func() {
+		backend.EXPECT().ListEntities(gomock.Any(), "books", url.Values{}, gomock.Any()).
+			SetArg(3, []map[string]interface{}{{"id": "a", "title": "go"}}).Return(nil)
+		backend.EXPECT().GetEntity(gomock.Any(), "books", "a", gomock.Any()).SetArg(3, map[string]interface{}{"id": "a", "title": "go"}).Return(nil)
+		indexed := store.NewIndexedStore(backend, nil)
+		Expect(indexed.Rebuild(ctx, "books")).To(Succeed())
+		result, err := store.Search(ctx, store.NewTracedStore(indexed), "books", "go", nil)
+		Expect(err).To(BeNil())
+		Expect(len(result)).To(Equal(1))
+		spans := recorder.Ended()
+		Expect(spans[len(spans)-1].Name()).To(Equal("store.Search"))
+		Expect(spans[len(spans)-1].Attributes()).To(ContainElement(store.AttrResultCount.Int(1)))
+
+		_, err = store.Search(ctx, s, "books", "go", nil)
+		Expect(err).To(Equal(store.ErrSearchUnsupported))
+	})
+
+	It("records spans for CountEntities and Aggregate, falling back for other stores.", func() {
+		list := []map[string]interface{}{{"tag": "a"}, {"tag": "b"}, {"tag": "a"}}
+		backend.EXPECT().ListEntities(gomock.Any(), "books", gomock.Any(), gomock.Any()).SetArg(3, list).Return(nil).Times(2)
+		n, err := store.Count(ctx, s, "books", url.Values{"tag": {"a"}})
+		Expect(err).To(BeNil())
+		Expect(n).To(Equal(3))
+		rows, err := store.Aggregate(ctx, s, "books", nil, store.Aggregation{
+			GroupBy: []string{"tag"}, Metrics: []store.Metric{{Op: store.OpCount}}})
+		Expect(err).To(BeNil())
+		Expect(rows).To(Equal([]map[string]interface{}{{"tag": "a", "count": 2}, {"tag": "b", "count": 1}}))
+		spans := recorder.Ended()
+		Expect(len(spans)).To(Equal(2))
+		Expect(spans[0].Name()).To(Equal("store.CountEntities"))
+		Expect(spans[0].Attributes()).To(ContainElement(store.AttrResultCount.Int(3)))
+		Expect(spans[1].Name()).To(Equal("store.Aggregate"))
+		Expect(spans[1].Attributes()).To(ContainElement(store.AttrResultCount.Int(2)))
+	})
+
+	It("records a span for EnsureIndexes.", func() {
+		Expect(s.(store.Indexer).EnsureIndexes(ctx, "books", []store.Index{{Key: []string{"a"}}})).To(Succeed())
+		spans := recorder.Ended()
+		Expect(len(spans)).To(Equal(1))
+		Expect(spans[0].Name()).To(Equal("store.EnsureIndexes"))
+	})
+
+	It("passes through and records errors.", func() {
+		backend.EXPECT().DeleteEntity(gomock.Any(), "books", "fakeid").Return(fmt.Errorf("test error"))
+		err := s.DeleteEntity(ctx, "books", "fakeid")
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(Equal("test error"))
+		spans := recorder.Ended()
+		Expect(len(spans)).To(Equal(1))
+		Expect(spans[0].Status().Code).To(Equal(codes.Error))
+	})
+
+	It("records a span for StreamEntities ending when the iterator is closed.", func() {
+		want := []map[string]interface{}{{"a": 1}, {"b": 2}, {"c": 3}}
+		backend.EXPECT().ListEntities(gomock.Any(), "books", gomock.Any(), gomock.Any()).SetArg(3, want).Return(nil)
+		it, err := s.(store.Streamer).StreamEntities(ctx, "books", url.Values{"tag": {"a"}})
+		Expect(err).To(BeNil())
+		item := make(map[string]interface{})
+		for it.Next(&item) {
+		}
+		Expect(recorder.Ended()).To(BeEmpty())
+		Expect(it.Close()).To(BeNil())
+		spans := recorder.Ended()
+		Expect(len(spans)).To(Equal(1))
+		Expect(spans[0].Name()).To(Equal("store.StreamEntities"))
+		Expect(spans[0].Attributes()).To(ContainElement(attribute.Int("goresource.result.count", 3)))
+	})
+
+	It("parents store spans on the span in the given context.", func() {
+		backend.EXPECT().CreateEntity(gomock.Any(), "books", gomock.Any(), gomock.Any()).Return(nil)
+		parentCtx, parent := otel.Tracer("test").Start(ctx, "parent")
+		Expect(s.CreateEntity(parentCtx, "books", map[string]string{}, nil)).To(BeNil())
+		parent.End()
+		spans := recorder.Ended()
+		Expect(len(spans)).To(Equal(2))
+		Expect(spans[0].Parent().SpanID()).To(Equal(parent.SpanContext().SpanID()))
+	})
+})
@@ -0,0 +1,30 @@
+package store
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrTransactionsUnsupported is returned running transactions on stores without them.
+var ErrTransactionsUnsupported = errors.New("transactions not supported")
+
+// TxFunc runs operations in a transaction on the given store, committed if it
+// returns nil and rolled back otherwise.
+type TxFunc func(ctx context.Context, tx Store) error
+
+// Transactor is implemented by stores that can run operations atomically.
+type Transactor interface {
+	// WithTransaction runs fn in a transaction. Stores may run fn more than
+	// once to retry transient errors, so it should have no other side effects.
+	// Calling WithTransaction on tx runs fn in the same transaction.
+	WithTransaction(ctx context.Context, fn TxFunc) error
+}
+
+// WithTransaction runs fn in a transaction on the given store, or returns
+// ErrTransactionsUnsupported if the store isn't a Transactor.
+func WithTransaction(ctx context.Context, s Store, fn TxFunc) error {
+	if t, ok := s.(Transactor); ok {
+		return t.WithTransaction(ctx, fn)
+	}
+	return ErrTransactionsUnsupported
+}
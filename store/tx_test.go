@@ -0,0 +1,89 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+
+	"goresource/mocks"
+	"goresource/store"
+
+	"github.com/golang/mock/gomock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// txStore is a store running transactions on a separate store, counting commits.
+type txStore struct {
+	*mocks.MockStore
+	tx      store.Store
+	commits int
+}
+
+func (s *txStore) WithTransaction(ctx context.Context, fn store.TxFunc) error {
+	if err := fn(ctx, s.tx); err != nil {
+		return err
+	}
+	s.commits++
+	return nil
+}
+
+var _ = Describe("WithTransaction", func() {
+	var (
+		ctrl    *gomock.Controller
+		backend *txStore
+		tx      *mocks.MockStore
+		ctx     = context.Background()
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		tx = mocks.NewMockStore(ctrl)
+		backend = &txStore{MockStore: mocks.NewMockStore(ctrl), tx: tx}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("is unsupported by stores without transactions.", func() {
+		err := store.WithTransaction(ctx, mocks.NewMockStore(ctrl), func(context.Context, store.Store) error {
+			Fail("ran without a transaction")
+			return nil
+		})
+		Expect(err).To(Equal(store.ErrTransactionsUnsupported))
+	})
+
+	It("runs the function with the transaction's store.", func() {
+		tx.EXPECT().DeleteEntity(gomock.Any(), "books", "a").Return(nil)
+		err := store.WithTransaction(ctx, backend, func(ctx context.Context, s store.Store) error {
+			return s.DeleteEntity(ctx, "books", "a")
+		})
+		Expect(err).To(BeNil())
+		Expect(backend.commits).To(Equal(1))
+	})
+
+	It("traces transactions and the operations in them.", func() {
+		recorder := tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		tx.EXPECT().DeleteEntity(gomock.Any(), "books", "a").Return(nil)
+		err := store.WithTransaction(ctx, store.NewTracedStore(backend), func(ctx context.Context, s store.Store) error {
+			if err := s.DeleteEntity(ctx, "books", "a"); err != nil {
+				return err
+			}
+			return fmt.Errorf("test error")
+		})
+		Expect(err).To(MatchError("test error"))
+		Expect(backend.commits).To(Equal(0))
+		spans := recorder.Ended()
+		Expect(len(spans)).To(Equal(2))
+		Expect(spans[0].Name()).To(Equal("store.DeleteEntity"))
+		Expect(spans[1].Name()).To(Equal("store.Transaction"))
+		Expect(spans[0].Parent().SpanID()).To(Equal(spans[1].SpanContext().SpanID()))
+		Expect(spans[1].Status().Code).To(Equal(codes.Error))
+	})
+})
@@ -0,0 +1,89 @@
+package goresource
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rockstardevs/goresource/codec"
+	"github.com/rockstardevs/goresource/store"
+)
+
+// streamFlushInterval is the number of entities written between flushes of a
+// streamed response.
+const streamFlushInterval = 100
+
+// streamCodec returns the stream codec negotiated for a list request, if the
+// client asked for one and the manager can stream entities.
+func (r Resource) streamCodec(req *http.Request) (codec.StreamCodec, bool) {
+	if _, ok := r.manager.(StreamManager); !ok || mux.Vars(req)["id"] != "" {
+		return nil, false
+	}
+	offered := append(append([]string{}, r.Formats()...), codec.NDJSONType, codec.CSVType)
+	mediaType, ok := codec.NegotiateType(req.Header.Get("Accept"), offered)
+	if !ok {
+		return nil, false
+	}
+	c, ok := codec.Streams[mediaType]
+	return c, ok
+}
+
+// stream writes all entities matching the request's query row by row, flushing
+// periodically so memory use is bounded regardless of the number of entities.
+// Csv columns are taken from the first entity, see codec.CSV.
+func (r Resource) stream(rw http.ResponseWriter, req *http.Request, c codec.StreamCodec) {
+	query := r.scopeQuery(req, req.URL.Query())
+	ctx, span := r.startSpan(req.Context(), "StreamEntities", AttrFilterKeys.StringSlice(store.FilterKeys(query)))
+	it, err := r.manager.(StreamManager).StreamEntities(ctx, query)
+	if err != nil {
+		endSpan(span, err)
+		writeError(rw, err.Error(), errorStatus(err))
+		return
+	}
+	rw.Header().Set("Content-Type", c.ContentType())
+	rw.WriteHeader(http.StatusOK)
+	var (
+		writer = c.NewWriter(rw)
+		count  int
+	)
+	for {
+		item := make(map[string]interface{})
+		if !it.Next(&item) {
+			break
+		}
+		if err = writer.Write(item); err != nil {
+			break
+		}
+		count++
+		if count%streamFlushInterval == 0 {
+			if err = writer.Flush(); err != nil {
+				break
+			}
+			flush(rw)
+		}
+	}
+	if err == nil {
+		err = it.Err()
+	}
+	if closeErr := it.Close(); err == nil {
+		err = closeErr
+	}
+	if flushErr := writer.Flush(); err == nil {
+		err = flushErr
+	}
+	flush(rw)
+	span.SetAttributes(AttrResultCount.Int(count))
+	endSpan(span, err)
+	if err != nil {
+		// Headers are already sent, so the error can only be logged.
+		if sw, ok := rw.(*statusWriter); ok {
+			sw.err = err.Error()
+		}
+	}
+}
+
+// flush sends any buffered response data to the client.
+func flush(rw http.ResponseWriter) {
+	if f, ok := rw.(http.Flusher); ok {
+		f.Flush()
+	}
+}
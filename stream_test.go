@@ -0,0 +1,109 @@
+package goresource_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"goresource"
+	"goresource/codec"
+	"goresource/mocks"
+	"goresource/store"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// streamManager is a ResourceManager that streams entities from a store.
+type streamManager struct {
+	*mocks.MockResourceManager
+	store store.Store
+}
+
+func (m streamManager) StreamEntities(ctx context.Context, query url.Values) (store.Iterator, error) {
+	return store.Stream(ctx, m.store, "test", query)
+}
+
+var _ = Describe("Resource streaming", func() {
+	var (
+		ctrl    *gomock.Controller
+		manager *mocks.MockResourceManager
+		backend *mocks.MockStore
+		router  *mux.Router
+		rw      *httptest.ResponseRecorder
+		items   = []map[string]interface{}{{"id": "a", "name": "foo"}, {"id": "b", "name": "bar"}}
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		manager = mocks.NewMockResourceManager(ctrl)
+		backend = mocks.NewMockStore(ctrl)
+		router = mux.NewRouter().PathPrefix("/api").Subrouter()
+		rw = httptest.NewRecorder()
+		manager.EXPECT().GetName().AnyTimes().Return("test")
+		goresource.NewResource(streamManager{manager, backend}, router)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("streams lists as ndjson.", func() {
+		req, _ := http.NewRequest("GET", "/api/test?tag=x", nil)
+		req.Header.Set("Accept", codec.NDJSONType)
+		backend.EXPECT().ListEntities(gomock.Any(), "test", url.Values{"tag": {"x"}}, gomock.Any()).SetArg(3, items).Return(nil)
+		router.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Header().Get("Content-Type")).To(Equal(codec.NDJSONType))
+		Expect(rw.Body.String()).To(Equal("{\"id\":\"a\",\"name\":\"foo\"}\n{\"id\":\"b\",\"name\":\"bar\"}\n"))
+		Expect(rw.Flushed).To(BeTrue())
+	})
+
+	It("streams lists as csv.", func() {
+		req, _ := http.NewRequest("GET", "/api/test", nil)
+		req.Header.Set("Accept", "text/csv")
+		backend.EXPECT().ListEntities(gomock.Any(), "test", gomock.Any(), gomock.Any()).SetArg(3, items).Return(nil)
+		router.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Header().Get("Content-Type")).To(Equal(codec.CSVType))
+		Expect(rw.Body.String()).To(Equal("id,name\na,foo\nb,bar\n"))
+	})
+
+	It("responds with an error if the stream cannot be opened.", func() {
+		req, _ := http.NewRequest("GET", "/api/test", nil)
+		req.Header.Set("Accept", codec.NDJSONType)
+		backend.EXPECT().ListEntities(gomock.Any(), "test", gomock.Any(), gomock.Any()).Return(fmt.Errorf("test error"))
+		router.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusInternalServerError))
+		Expect(rw.Body.String()).To(Equal("test error\n"))
+	})
+
+	It("responds with the status of the error opening the stream.", func() {
+		req, _ := http.NewRequest("GET", "/api/test?bad=1", nil)
+		req.Header.Set("Accept", codec.NDJSONType)
+		backend.EXPECT().ListEntities(gomock.Any(), "test", gomock.Any(), gomock.Any()).
+			Return(fmt.Errorf("%w: bad filter", goresource.ErrInvalidQuery))
+		router.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("does not stream single entities.", func() {
+		req, _ := http.NewRequest("GET", "/api/test/fakeid", nil)
+		req.Header.Set("Accept", codec.NDJSONType)
+		router.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusNotAcceptable))
+	})
+
+	It("responds with json by default.", func() {
+		req, _ := http.NewRequest("GET", "/api/test", nil)
+		manager.EXPECT().ListEntities(gomock.Any(), gomock.Any()).Return(items, nil)
+		router.ServeHTTP(rw, req)
+		Expect(rw.Code).To(Equal(http.StatusOK))
+		Expect(rw.Header().Get("Content-Type")).To(Equal(codec.JSONType))
+	})
+})
@@ -0,0 +1,101 @@
+package goresource
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/rockstardevs/goresource/store"
+	"github.com/rockstardevs/goresource/util"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation name used for all spans created by goresource.
+const TracerName = "github.com/rockstardevs/goresource"
+
+// Span attribute keys recorded by Resource.
+const (
+	AttrResource    = attribute.Key("goresource.resource")
+	AttrEntityID    = attribute.Key("goresource.entity.id")
+	AttrFilterKeys  = attribute.Key("goresource.filter.keys")
+	AttrResultCount = attribute.Key("goresource.result.count")
+	AttrHTTPMethod  = attribute.Key("http.request.method")
+	AttrHTTPStatus  = attribute.Key("http.response.status_code")
+)
+
+// propagator extracts W3C traceparent/tracestate headers from incoming requests.
+var propagator = propagation.TraceContext{}
+
+// tracer returns the goresource tracer from the globally registered provider.
+func tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// startRequestSpan starts the server span for an incoming request, continuing
+// any trace propagated by the caller.
+func (r Resource) startRequestSpan(req *http.Request, id string) (context.Context, trace.Span) {
+	ctx := propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+	return tracer().Start(ctx, r.manager.GetName()+" "+req.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			AttrResource.String(r.manager.GetName()),
+			AttrEntityID.String(id),
+			AttrHTTPMethod.String(req.Method),
+			AttrFilterKeys.StringSlice(store.FilterKeys(req.URL.Query())),
+		))
+}
+
+// startSpan starts an internal span named after the given manager operation.
+func (r Resource) startSpan(ctx context.Context, op string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, AttrResource.String(r.manager.GetName()))
+	return tracer().Start(ctx, r.manager.GetName()+"."+op, trace.WithAttributes(attrs...))
+}
+
+// endSpan records the outcome of an operation on the given span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// resultCount returns a span attribute with the number of entities in result.
+func resultCount(result interface{}) attribute.KeyValue {
+	return AttrResultCount.Int(util.Count(result))
+}
+
+// statusWriter is a http.ResponseWriter that remembers the status code and
+// any error message written.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	err    string
+}
+
+// WriteHeader records the status code and passes it through.
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Hijack passes through to the underlying writer, for websocket upgrades.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	w.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// Flush passes through to the underlying writer, if it supports flushing.
+func (w *statusWriter) Flush() {
+	flush(w.ResponseWriter)
+}
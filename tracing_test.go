@@ -0,0 +1,88 @@
+package goresource_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"goresource"
+	"goresource/mocks"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resource tracing", func() {
+	var (
+		ctrl     *gomock.Controller
+		manager  *mocks.MockResourceManager
+		router   *mux.Router
+		rw       *httptest.ResponseRecorder
+		recorder *tracetest.SpanRecorder
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		manager = mocks.NewMockResourceManager(ctrl)
+		router = mux.NewRouter().PathPrefix("/api").Subrouter()
+		rw = httptest.NewRecorder()
+		recorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		manager.EXPECT().GetName().AnyTimes().Return("test")
+		goresource.NewResource(manager, router)
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("records a request span and a child span for the manager call.", func() {
+		req, _ := http.NewRequest("GET", "/api/test/fakeid", nil)
+		manager.EXPECT().GetEntity(gomock.Any(), "fakeid", gomock.Any()).Return("fake-entity", nil)
+		router.ServeHTTP(rw, req)
+		spans := recorder.Ended()
+		Expect(len(spans)).To(Equal(2))
+		Expect(spans[0].Name()).To(Equal("test.GetEntity"))
+		Expect(spans[1].Name()).To(Equal("test GET"))
+		Expect(spans[0].Parent().SpanID()).To(Equal(spans[1].SpanContext().SpanID()))
+		Expect(spans[1].Attributes()).To(ContainElement(goresource.AttrEntityID.String("fakeid")))
+		Expect(spans[1].Attributes()).To(ContainElement(goresource.AttrHTTPStatus.Int(http.StatusOK)))
+	})
+
+	It("records filter keys and the result count for lists.", func() {
+		req, _ := http.NewRequest("GET", "/api/test?tag=a&name~=b", nil)
+		manager.EXPECT().ListEntities(gomock.Any(), gomock.Any()).Return([]interface{}{"a", "b", "c"}, nil)
+		router.ServeHTTP(rw, req)
+		spans := recorder.Ended()
+		Expect(len(spans)).To(Equal(2))
+		Expect(spans[0].Attributes()).To(ContainElement(goresource.AttrFilterKeys.StringSlice([]string{"name~", "tag"})))
+		Expect(spans[0].Attributes()).To(ContainElement(goresource.AttrResultCount.Int(3)))
+	})
+
+	It("continues a trace propagated via the traceparent header.", func() {
+		req, _ := http.NewRequest("DELETE", "/api/test/fakeid", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		manager.EXPECT().DeleteEntity(gomock.Any(), "fakeid", gomock.Any()).Return(nil)
+		router.ServeHTTP(rw, req)
+		spans := recorder.Ended()
+		Expect(len(spans)).To(Equal(2))
+		Expect(spans[1].SpanContext().TraceID().String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+		Expect(spans[1].Parent().SpanID().String()).To(Equal("00f067aa0ba902b7"))
+	})
+
+	It("marks the request span as failed on server errors.", func() {
+		req, _ := http.NewRequest("GET", "/api/test/fakeid", nil)
+		manager.EXPECT().GetEntity(gomock.Any(), "fakeid", gomock.Any()).Return(nil, http.ErrAbortHandler)
+		router.ServeHTTP(rw, req)
+		spans := recorder.Ended()
+		Expect(len(spans)).To(Equal(2))
+		Expect(spans[0].Status().Code).To(Equal(codes.Error))
+		Expect(spans[1].Status().Code).To(Equal(codes.Error))
+	})
+})
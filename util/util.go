@@ -4,6 +4,7 @@ package util
 import (
 	"encoding/json"
 	"net/http"
+	"reflect"
 )
 
 // WriteJSON marshals the given data into json and writes it to the response stream.
@@ -17,3 +18,24 @@ func WriteJSON(data interface{}, rw http.ResponseWriter) {
 	rw.WriteHeader(http.StatusOK)
 	rw.Write(jsonBytes)
 }
+
+// Count returns the number of entities held by the given result. Pointers are
+// followed, slices and arrays report their length, nil counts as zero and any
+// other value, including a map, counts as a single entity.
+func Count(result interface{}) int {
+	if result == nil {
+		return 0
+	}
+	v := reflect.ValueOf(result)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return 0
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		return v.Len()
+	}
+	return 1
+}
@@ -110,4 +110,27 @@ var _ = Describe("Util", func() {
 		})
 	})
 
+	Describe("Count", func() {
+		It("counts nil as zero", func() {
+			Expect(util.Count(nil)).To(Equal(0))
+		})
+		It("counts a nil pointer as zero", func() {
+			var items *[]string
+			Expect(util.Count(items)).To(Equal(0))
+		})
+		It("counts the elements of a slice", func() {
+			Expect(util.Count([]string{"a", "b"})).To(Equal(2))
+		})
+		It("follows pointers to slices", func() {
+			items := []map[string]interface{}{{"a": 1}, {"b": 2}, {"c": 3}}
+			Expect(util.Count(&items)).To(Equal(3))
+		})
+		It("counts a map as a single entity", func() {
+			Expect(util.Count(map[string]interface{}{"a": 1, "b": 2})).To(Equal(1))
+		})
+		It("counts a struct as a single entity", func() {
+			Expect(util.Count(struct{ Name string }{"foo"})).To(Equal(1))
+		})
+	})
+
 })
@@ -0,0 +1,296 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/rockstardevs/goresource/store"
+)
+
+// Headers sent with each delivery.
+const (
+	// SignatureHeader holds the delivery's signature, as returned by Sign.
+	SignatureHeader = "X-Webhook-Signature"
+	// TimestampHeader holds the unix time the delivery was signed at.
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Defaults used by NewDispatcher.
+const (
+	DefaultMaxAttempts  = 8
+	DefaultBatchSize    = 100
+	DefaultPollInterval = time.Second
+	DefaultTimeout      = 10 * time.Second
+)
+
+// maxBackoff caps the delay between attempts.
+const maxBackoff = time.Hour
+
+// Sign returns the signature of a delivery's body signed at the given unix
+// timestamp: sha256= followed by the hex encoded HMAC-SHA256 of the timestamp,
+// a dot and the body, keyed by the subscription's secret.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the headers of a delivery carry a valid signature of
+// its body, for receivers. Receivers should also reject old timestamps.
+func Verify(secret string, header http.Header, body []byte) bool {
+	expected := Sign(secret, header.Get(TimestampHeader), body)
+	return hmac.Equal([]byte(expected), []byte(header.Get(SignatureHeader)))
+}
+
+// Backoff returns the delay before retrying a delivery after the given number
+// of failed attempts, doubling from a second up to an hour.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	if attempts > 12 {
+		return maxBackoff
+	}
+	if d := time.Second << (attempts - 1); d < maxBackoff {
+		return d
+	}
+	return maxBackoff
+}
+
+// Dispatcher queues deliveries of outbox events to matching subscriptions in
+// a store collection and attempts them until delivered, so pending deliveries
+// survive restarts. Only one dispatcher should process a collection of
+// deliveries, concurrent dispatchers may deliver events more than once.
+type Dispatcher struct {
+	Store store.Store
+	// Subscriptions and Deliveries name the collections of subscriptions and deliveries.
+	Subscriptions string
+	Deliveries    string
+	// Secrets names the collection of subscriptions' secrets, see Manager.
+	Secrets string
+	Client  *http.Client
+	// MaxAttempts is the number of attempts after which a delivery fails.
+	MaxAttempts int
+	// Backoff returns the delay before the next attempt after the given number of failures.
+	Backoff func(attempts int) time.Duration
+	// BatchSize is the maximum number of deliveries attempted by each call to Process.
+	BatchSize int
+	// PollInterval is the interval between attempts of due deliveries by Run.
+	PollInterval time.Duration
+	Logger       *slog.Logger
+}
+
+// NewDispatcher returns a Dispatcher for the named collections of
+// subscriptions and deliveries in the given store.
+func NewDispatcher(s store.Store, subscriptions, deliveries string) *Dispatcher {
+	return &Dispatcher{
+		Store:         s,
+		Subscriptions: subscriptions,
+		Deliveries:    deliveries,
+		Secrets:       subscriptions + SecretsSuffix,
+		Client:        &http.Client{Timeout: DefaultTimeout},
+		MaxAttempts:   DefaultMaxAttempts,
+		BatchSize:     DefaultBatchSize,
+		Backoff:       Backoff,
+		PollInterval:  DefaultPollInterval,
+		Logger:        slog.Default(),
+	}
+}
+
+// Publish queues a delivery of the given outbox event to each matching
+// subscription, so a Dispatcher is an outbox.Publisher. Relaying the outbox
+// of a store.OutboxStore to it queues deliveries of every change written,
+// even if the process exits before they are queued, since the relay publishes
+// events until they are queued. Events published again are delivered again,
+// receivers should deduplicate them by id.
+func (d *Dispatcher) Publish(ctx context.Context, e store.OutboxEvent) error {
+	var subs []map[string]interface{}
+	if err := d.Store.ListEntities(ctx, d.Subscriptions, url.Values{"resource": {e.Collection}}, &subs); err != nil {
+		return err
+	}
+	e.Status = ""
+	for _, doc := range subs {
+		var sub Subscription
+		if err := decode(doc, &sub); err != nil {
+			return err
+		}
+		if !sub.Matches(e) {
+			continue
+		}
+		delivery := Delivery{
+			Subscription: sub.ID,
+			Event:        e,
+			Status:       StatusPending,
+			Attempts:     []Attempt{},
+			NextAttempt:  time.Now().UTC(),
+		}
+		result := make(map[string]interface{})
+		if err := d.Store.CreateEntity(ctx, d.Deliveries, delivery, &result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Process attempts up to BatchSize pending deliveries that are due, oldest
+// first, recording each attempt. It returns the number of deliveries attempted.
+func (d *Dispatcher) Process(ctx context.Context) (int, error) {
+	due, err := d.due(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i, delivery := range due {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		d.attempt(ctx, &delivery)
+		id := delivery.ID
+		delivery.ID = ""
+		result := make(map[string]interface{})
+		if err := d.Store.UpdateEntity(ctx, d.Deliveries, id, delivery, &result); err != nil {
+			return i, err
+		}
+	}
+	return len(due), nil
+}
+
+// due returns the pending deliveries due for an attempt, up to BatchSize and
+// in the order of their next attempt, served by the status and next attempt
+// index on stores that can sort and limit queries.
+func (d *Dispatcher) due(ctx context.Context) ([]Delivery, error) {
+	filters := url.Values{"status": {StatusPending}}
+	docs, err := store.Find(ctx, d.Store, d.Deliveries, filters,
+		store.FindOptions{Sort: []string{"nextAttempt"}, Limit: d.BatchSize})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var due []Delivery
+	for _, doc := range docs {
+		var delivery Delivery
+		if err := decode(doc, &delivery); err != nil {
+			return nil, err
+		}
+		// Later deliveries aren't due either.
+		if delivery.NextAttempt.After(now) {
+			break
+		}
+		due = append(due, delivery)
+	}
+	return due, nil
+}
+
+// attempt sends the given delivery, recording the attempt and its outcome.
+func (d *Dispatcher) attempt(ctx context.Context, delivery *Delivery) {
+	var (
+		sub   Subscription
+		start = time.Now()
+		a     = Attempt{Time: start.UTC()}
+	)
+	err := get(ctx, d.Store, d.Subscriptions, delivery.Subscription, &sub)
+	if err == nil {
+		var (
+			s     secret
+			found bool
+		)
+		// Subscriptions without a secret get unsigned deliveries.
+		s, found, err = findSecret(ctx, d.Store, d.Secrets, sub.ID)
+		if found {
+			sub.Secret = s.Secret
+		}
+	}
+	if err == nil {
+		a.StatusCode, err = d.send(ctx, sub, delivery)
+	}
+	a.Duration = time.Since(start).Milliseconds()
+	delivery.Attempts = append(delivery.Attempts, a)
+	switch {
+	case err == nil:
+		delivery.Status = StatusDelivered
+		return
+	case errors.Is(err, store.ErrNotFound):
+		err = errors.New("subscription removed")
+		delivery.Status = StatusFailed
+	case len(delivery.Attempts) >= d.MaxAttempts:
+		delivery.Status = StatusFailed
+	default:
+		delivery.NextAttempt = time.Now().UTC().Add(d.Backoff(len(delivery.Attempts)))
+	}
+	delivery.Attempts[len(delivery.Attempts)-1].Error = err.Error()
+}
+
+// send posts the delivery's event to the subscription's url, returning the
+// response status and an error unless it is successful. Deliveries are only
+// signed if the subscription has a secret, never with an empty key.
+func (d *Dispatcher) send(ctx context.Context, sub Subscription, delivery *Delivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event.Action)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	if sub.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign(sub.Secret, timestamp, body))
+	}
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Run attempts due deliveries every PollInterval until the context is done,
+// first indexing deliveries by status and next attempt on stores with indexes.
+// Deliveries are queued by an outbox.Relay publishing to the dispatcher.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	index := store.Index{Key: []string{"status", "nextAttempt"}}
+	if err := store.EnsureIndexes(ctx, d.Store, d.Deliveries, []store.Index{index}); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// Keep attempting while full batches are due.
+			for {
+				n, err := d.Process(ctx)
+				if err != nil && ctx.Err() == nil {
+					d.Logger.ErrorContext(ctx, "error processing webhook deliveries", "error", err.Error())
+				}
+				if err != nil || n == 0 || n < d.BatchSize {
+					break
+				}
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
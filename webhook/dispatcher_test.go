@@ -0,0 +1,217 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"goresource"
+	"goresource/outbox"
+	storepkg "goresource/store"
+	"goresource/webhook"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Signatures", func() {
+	It("verify deliveries signed with the same secret.", func() {
+		body := []byte(`{"id": 1}`)
+		header := http.Header{}
+		header.Set(webhook.TimestampHeader, "1700000000")
+		header.Set(webhook.SignatureHeader, webhook.Sign("secret", "1700000000", body))
+		Expect(header.Get(webhook.SignatureHeader)).To(HavePrefix("sha256="))
+		Expect(webhook.Verify("secret", header, body)).To(BeTrue())
+		Expect(webhook.Verify("other", header, body)).To(BeFalse())
+		Expect(webhook.Verify("secret", header, []byte(`{"id": 2}`))).To(BeFalse())
+		header.Set(webhook.TimestampHeader, "1700000001")
+		Expect(webhook.Verify("secret", header, body)).To(BeFalse())
+	})
+})
+
+var _ = Describe("Backoff", func() {
+	It("doubles from a second up to an hour.", func() {
+		Expect(webhook.Backoff(1)).To(Equal(time.Second))
+		Expect(webhook.Backoff(2)).To(Equal(2 * time.Second))
+		Expect(webhook.Backoff(5)).To(Equal(16 * time.Second))
+		Expect(webhook.Backoff(13)).To(Equal(time.Hour))
+		Expect(webhook.Backoff(100)).To(Equal(time.Hour))
+	})
+})
+
+var _ = Describe("Dispatcher", func() {
+	var (
+		store      *memStore
+		dispatcher *webhook.Dispatcher
+		receiver   *httptest.Server
+		mu         sync.Mutex
+		received   []*http.Request
+		bodies     [][]byte
+		status     int
+		ctx        = context.Background()
+	)
+
+	// subscribe creates a subscription to the receiver.
+	subscribe := func(sub webhook.Subscription) string {
+		sub.URL = receiver.URL
+		result, err := webhook.NewManager("webhooks", store).CreateEntity(ctx, &sub, nil)
+		Expect(err).To(BeNil())
+		return result.(map[string]interface{})["id"].(string)
+	}
+
+	// deliveries returns the recorded deliveries.
+	deliveries := func() []webhook.Delivery {
+		var result []webhook.Delivery
+		Expect(store.ListEntities(ctx, "deliveries", nil, &result)).To(Succeed())
+		return result
+	}
+
+	// count returns the number of requests received.
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received)
+	}
+
+	event := storepkg.OutboxEvent{ID: "e7", Collection: "books", Action: goresource.AuditCreate, EntityID: "b1",
+		Entity: map[string]interface{}{"id": "b1", "genre": "tech"}}
+
+	BeforeEach(func() {
+		store = newMemStore()
+		received, bodies, status = nil, nil, http.StatusOK
+		receiver = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, req)
+			bodies = append(bodies, body)
+			rw.WriteHeader(status)
+		}))
+		dispatcher = webhook.NewDispatcher(store, "webhooks", "deliveries")
+		dispatcher.Backoff = func(int) time.Duration { return 0 }
+	})
+
+	AfterEach(func() {
+		receiver.Close()
+	})
+
+	It("delivers signed events to matching subscriptions.", func() {
+		id := subscribe(webhook.Subscription{Resource: "books", Filter: "genre=tech", Secret: "s3cret"})
+		subscribe(webhook.Subscription{Resource: "books", Events: []string{goresource.AuditDelete}})
+		subscribe(webhook.Subscription{Resource: "authors"})
+		Expect(dispatcher.Publish(ctx, event)).To(Succeed())
+		Expect(deliveries()).To(HaveLen(1))
+
+		n, err := dispatcher.Process(ctx)
+		Expect(err).To(BeNil())
+		Expect(n).To(Equal(1))
+		Expect(received).To(HaveLen(1))
+		req := received[0]
+		Expect(req.Header.Get(webhook.EventHeader)).To(Equal(goresource.AuditCreate))
+		Expect(webhook.Verify("s3cret", req.Header, bodies[0])).To(BeTrue())
+		var sent storepkg.OutboxEvent
+		Expect(json.Unmarshal(bodies[0], &sent)).To(Succeed())
+		Expect(sent.ID).To(Equal("e7"))
+		Expect(sent.EntityID).To(Equal("b1"))
+
+		delivery := deliveries()[0]
+		Expect(delivery.Subscription).To(Equal(id))
+		Expect(req.Header.Get(webhook.DeliveryHeader)).To(Equal(delivery.ID))
+		Expect(delivery.Status).To(Equal(webhook.StatusDelivered))
+		Expect(delivery.Attempts).To(HaveLen(1))
+		Expect(delivery.Attempts[0].StatusCode).To(Equal(http.StatusOK))
+
+		n, _ = dispatcher.Process(ctx)
+		Expect(n).To(Equal(0))
+	})
+
+	It("retries failed deliveries after the backoff.", func() {
+		subscribe(webhook.Subscription{Resource: "books"})
+		Expect(dispatcher.Publish(ctx, event)).To(Succeed())
+		status = http.StatusServiceUnavailable
+		dispatcher.Process(ctx)
+		delivery := deliveries()[0]
+		Expect(delivery.Status).To(Equal(webhook.StatusPending))
+		Expect(delivery.Attempts[0].StatusCode).To(Equal(http.StatusServiceUnavailable))
+		Expect(delivery.Attempts[0].Error).To(Equal("unexpected status 503"))
+
+		dispatcher.Backoff = func(int) time.Duration { return time.Hour }
+		dispatcher.Process(ctx)
+		Expect(deliveries()[0].Attempts).To(HaveLen(2))
+		n, _ := dispatcher.Process(ctx)
+		Expect(n).To(Equal(0))
+		Expect(deliveries()[0].NextAttempt).To(BeTemporally("~", time.Now().Add(time.Hour), time.Minute))
+	})
+
+	It("fails deliveries after the maximum attempts.", func() {
+		subscribe(webhook.Subscription{Resource: "books"})
+		Expect(dispatcher.Publish(ctx, event)).To(Succeed())
+		status = http.StatusInternalServerError
+		dispatcher.MaxAttempts = 3
+		for i := 0; i < 5; i++ {
+			dispatcher.Process(ctx)
+		}
+		Expect(count()).To(Equal(3))
+		Expect(deliveries()[0].Status).To(Equal(webhook.StatusFailed))
+	})
+
+	It("fails deliveries to removed subscriptions.", func() {
+		id := subscribe(webhook.Subscription{Resource: "books"})
+		Expect(dispatcher.Publish(ctx, event)).To(Succeed())
+		Expect(store.DeleteEntity(ctx, "webhooks", id)).To(Succeed())
+		dispatcher.Process(ctx)
+		Expect(count()).To(Equal(0))
+		delivery := deliveries()[0]
+		Expect(delivery.Status).To(Equal(webhook.StatusFailed))
+		Expect(delivery.Attempts[0].Error).To(Equal("subscription removed"))
+	})
+
+	It("sends unsigned deliveries to subscriptions without a secret.", func() {
+		subscribe(webhook.Subscription{Resource: "books"})
+		Expect(dispatcher.Publish(ctx, event)).To(Succeed())
+		dispatcher.Process(ctx)
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].Header).NotTo(HaveKey(webhook.SignatureHeader))
+		Expect(received[0].Header).NotTo(HaveKey(webhook.TimestampHeader))
+		Expect(webhook.Verify("", received[0].Header, bodies[0])).To(BeFalse())
+	})
+
+	It("attempts due deliveries in batches.", func() {
+		subscribe(webhook.Subscription{Resource: "books"})
+		for i := 0; i < 3; i++ {
+			Expect(dispatcher.Publish(ctx, event)).To(Succeed())
+		}
+		dispatcher.BatchSize = 2
+		n, err := dispatcher.Process(ctx)
+		Expect(err).To(BeNil())
+		Expect(n).To(Equal(2))
+		n, _ = dispatcher.Process(ctx)
+		Expect(n).To(Equal(1))
+		Expect(count()).To(Equal(3))
+	})
+
+	It("delivers the events relayed from an outbox.", func() {
+		subscribe(webhook.Subscription{Resource: "books"})
+		s := storepkg.NewOutboxStore(store, storepkg.DefaultOutbox)
+		Expect(s.CreateEntity(ctx, "books", map[string]interface{}{"genre": "tech"}, &map[string]interface{}{})).To(Succeed())
+		relay := outbox.NewRelay(store, storepkg.DefaultOutbox, dispatcher)
+		Expect(relay.Process(ctx)).To(Equal(1))
+		Expect(relay.Process(ctx)).To(Equal(0))
+
+		dispatcher.PollInterval = 10 * time.Millisecond
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan error)
+		go func() { done <- dispatcher.Run(ctx) }()
+		Eventually(count).Should(Equal(1))
+		cancel()
+		Eventually(done).Should(Receive(Equal(context.Canceled)))
+		var sent storepkg.OutboxEvent
+		Expect(json.Unmarshal(bodies[0], &sent)).To(Succeed())
+		Expect(sent.Collection).To(Equal("books"))
+		Expect(sent.Action).To(Equal(storepkg.OutboxCreate))
+	})
+})
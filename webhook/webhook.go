@@ -0,0 +1,404 @@
+// Package webhook delivers the changes written to the outbox of a
+// store.OutboxStore as signed http callbacks to subscribed urls, retrying
+// failed deliveries. Subscriptions and deliveries are themselves entities
+// served as resources.
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rockstardevs/goresource"
+	"github.com/rockstardevs/goresource/store"
+)
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// ErrReadOnly is returned for requests changing deliveries, which are only
+// recorded by the Dispatcher.
+var ErrReadOnly = fmt.Errorf("%w: deliveries are read only", goresource.ErrInvalidQuery)
+
+// Subscription subscribes a url to changes to the entities of a resource.
+type Subscription struct {
+	ID  string `json:"id,omitempty" bson:"_id,omitempty"`
+	URL string `json:"url" bson:"url"`
+	// Resource names the resource whose changes are delivered, matched against
+	// the collection of outbox events, which DefaultManagers name after the
+	// resource.
+	Resource string `json:"resource" bson:"resource"`
+	// Events are the actions delivered, goresource.AuditCreate, AuditUpdate
+	// or AuditDelete, all of them if empty.
+	Events []string `json:"events,omitempty" bson:"events,omitempty"`
+	// Filter, if set, limits deliveries to changes to entities matching it, in
+	// the query syntax of list requests, for example genre=tech&title~=^go.
+	Filter string `json:"filter,omitempty" bson:"filter,omitempty"`
+	// Secret signs deliveries, which are sent unsigned to subscriptions
+	// without one. It is stored apart from the subscription by Manager and
+	// never returned once set.
+	Secret string `json:"secret,omitempty" bson:"secret,omitempty"`
+}
+
+// HasId reports whether this subscription has an id.
+func (s Subscription) HasId() bool {
+	return s.ID != ""
+}
+
+// GetId returns the subscription's id.
+func (s Subscription) GetId() string {
+	return s.ID
+}
+
+// Validate checks the subscription's url, events and filter.
+func (s Subscription) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q", s.URL)
+	}
+	if s.Resource == "" {
+		return errors.New("resource is required")
+	}
+	for _, e := range s.Events {
+		switch e {
+		case goresource.AuditCreate, goresource.AuditUpdate, goresource.AuditDelete:
+		default:
+			return fmt.Errorf("invalid event %q", e)
+		}
+	}
+	_, err = s.matcher()
+	return err
+}
+
+// Matches reports whether the given event should be delivered to this subscription.
+func (s Subscription) Matches(e store.OutboxEvent) bool {
+	if e.Collection != s.Resource {
+		return false
+	}
+	if len(s.Events) > 0 && !contains(s.Events, e.Action) {
+		return false
+	}
+	match, err := s.matcher()
+	return err == nil && match(e.Entity)
+}
+
+// matcher returns a function matching entities against the subscription's filter.
+func (s Subscription) matcher() (func(map[string]interface{}) bool, error) {
+	filters, err := url.ParseQuery(s.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	return store.Matcher(filters)
+}
+
+// Attempt records a single attempt to deliver an event.
+type Attempt struct {
+	Time time.Time `json:"time" bson:"time"`
+	// StatusCode is the status the receiver responded with, if any.
+	StatusCode int    `json:"statusCode,omitempty" bson:"statusCode,omitempty"`
+	Error      string `json:"error,omitempty" bson:"error,omitempty"`
+	// Duration is the time taken to respond, in milliseconds.
+	Duration int64 `json:"duration" bson:"duration"`
+}
+
+// Delivery is an event queued for delivery to a subscription, with its attempts so far.
+type Delivery struct {
+	ID           string            `json:"id,omitempty" bson:"_id,omitempty"`
+	Subscription string            `json:"subscription" bson:"subscription"`
+	Event        store.OutboxEvent `json:"event" bson:"event"`
+	Status       string            `json:"status" bson:"status"`
+	Attempts     []Attempt         `json:"attempts" bson:"attempts"`
+	// NextAttempt is when a pending delivery is attempted next.
+	NextAttempt time.Time `json:"nextAttempt" bson:"nextAttempt"`
+}
+
+// HasId reports whether this delivery has an id.
+func (d Delivery) HasId() bool {
+	return d.ID != ""
+}
+
+// GetId returns the delivery's id.
+func (d Delivery) GetId() string {
+	return d.ID
+}
+
+// SecretsSuffix is appended to the name of a collection of subscriptions to
+// name the collection holding their secrets.
+const SecretsSuffix = "_secrets"
+
+// ErrSecretQuery is returned for queries filtering or aggregating by secret.
+var ErrSecretQuery = fmt.Errorf("%w: secrets cannot be queried", goresource.ErrInvalidQuery)
+
+// secret holds the secret of a subscription, stored apart from subscriptions
+// so that no read of the subscriptions collection can return it.
+type secret struct {
+	ID           string `json:"id,omitempty" bson:"_id,omitempty"`
+	Subscription string `json:"subscription" bson:"subscription"`
+	Secret       string `json:"secret" bson:"secret"`
+}
+
+// Manager is a ResourceManager for Subscriptions. It validates subscriptions
+// and stores their secrets in a separate collection, so they are never
+// returned once set, and rejects queries by secret.
+type Manager struct {
+	goresource.DefaultManager
+	// Secrets names the collection secrets are stored in.
+	Secrets string
+}
+
+// NewManager returns a Manager storing subscriptions in the named collection
+// of the given store, and their secrets in the collection named with SecretsSuffix.
+func NewManager(name string, s store.Store) *Manager {
+	return &Manager{DefaultManager: goresource.NewDefaultManager(name, s), Secrets: name + SecretsSuffix}
+}
+
+// New returns an empty Subscription.
+func (m *Manager) New() goresource.Entity {
+	return &Subscription{}
+}
+
+// ParseJSON decodes and validates a Subscription.
+func (m *Manager) ParseJSON(data io.ReadCloser) (goresource.Entity, error) {
+	sub := &Subscription{}
+	if err := json.NewDecoder(data).Decode(sub); err != nil {
+		return nil, err
+	}
+	if err := sub.Validate(); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// ListEntities fetches the subscriptions matching the query's filters.
+func (m *Manager) ListEntities(ctx context.Context, query url.Values) (interface{}, error) {
+	if err := checkQuery(query); err != nil {
+		return nil, err
+	}
+	return m.DefaultManager.ListEntities(ctx, query)
+}
+
+// StreamEntities returns an iterator over the subscriptions matching the query's filters.
+func (m *Manager) StreamEntities(ctx context.Context, query url.Values) (store.Iterator, error) {
+	if err := checkQuery(query); err != nil {
+		return nil, err
+	}
+	return m.DefaultManager.StreamEntities(ctx, query)
+}
+
+// CountEntities returns the number of subscriptions matching the query's filters.
+func (m *Manager) CountEntities(ctx context.Context, query url.Values) (int, error) {
+	if err := checkQuery(query); err != nil {
+		return 0, err
+	}
+	return m.DefaultManager.CountEntities(ctx, query)
+}
+
+// AggregateEntities returns grouped metrics over the subscriptions matching the query's filters.
+func (m *Manager) AggregateEntities(ctx context.Context, query url.Values) (interface{}, error) {
+	if err := checkQuery(query); err != nil {
+		return nil, err
+	}
+	return m.DefaultManager.AggregateEntities(ctx, query)
+}
+
+// EnsureIndexes creates the manager's indexes, and a unique index on the
+// subscription of secrets so each subscription has a single secret.
+func (m *Manager) EnsureIndexes(ctx context.Context) error {
+	if err := m.DefaultManager.EnsureIndexes(ctx); err != nil {
+		return err
+	}
+	index := store.Index{Key: []string{"subscription"}, Unique: true}
+	return store.EnsureIndexes(ctx, m.Store, m.Secrets, []store.Index{index})
+}
+
+// CreateEntity persists the given subscription, and its secret apart from it.
+func (m *Manager) CreateEntity(ctx context.Context, e goresource.Entity, query url.Values) (interface{}, error) {
+	key := takeSecret(e)
+	result, err := m.DefaultManager.CreateEntity(ctx, e, query)
+	if err != nil || key == "" {
+		return result, err
+	}
+	var created Subscription
+	if err := decode(toMap(result), &created); err != nil {
+		return nil, err
+	}
+	if err := setSecret(ctx, m.Store, m.Secrets, created.ID, key); err != nil {
+		if derr := m.DefaultManager.DeleteEntity(ctx, created.ID, query); derr != nil {
+			return nil, fmt.Errorf("%w, and removing subscription %s without its secret failed: %v", err, created.ID, derr)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// UpdateEntity persists changes to the subscription with the given id,
+// keeping its secret unless a new one is given.
+func (m *Manager) UpdateEntity(ctx context.Context, id string, e goresource.Entity, query url.Values) (interface{}, error) {
+	key := takeSecret(e)
+	result, err := m.DefaultManager.UpdateEntity(ctx, id, e, query)
+	if err != nil || key == "" {
+		return result, err
+	}
+	if err := setSecret(ctx, m.Store, m.Secrets, id, key); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteEntity removes the subscription with the given id and its secret.
+func (m *Manager) DeleteEntity(ctx context.Context, id string, query url.Values) error {
+	if err := m.DefaultManager.DeleteEntity(ctx, id, query); err != nil {
+		return err
+	}
+	s, found, err := findSecret(ctx, m.Store, m.Secrets, id)
+	if err != nil || !found {
+		return err
+	}
+	return m.Store.DeleteEntity(ctx, m.Secrets, s.ID)
+}
+
+// takeSecret removes the secret from the given subscription, returning it.
+func takeSecret(e goresource.Entity) string {
+	sub, ok := e.(*Subscription)
+	if !ok {
+		return ""
+	}
+	key := sub.Secret
+	sub.Secret = ""
+	return key
+}
+
+// checkQuery rejects queries filtering, grouping or computing metrics by secret.
+func checkQuery(query url.Values) error {
+	for k, values := range query {
+		fields := []string{strings.TrimSuffix(k, "~")}
+		if k == goresource.GroupParam || k == goresource.MetricParam {
+			fields = nil
+			for _, v := range values {
+				for _, item := range strings.Split(v, ",") {
+					parts := strings.SplitN(strings.TrimSpace(item), ":", 2)
+					fields = append(fields, parts[len(parts)-1])
+				}
+			}
+		}
+		for _, f := range fields {
+			if f == "secret" {
+				return ErrSecretQuery
+			}
+		}
+	}
+	return nil
+}
+
+// findSecret fetches the secret of the subscription with the given id.
+func findSecret(ctx context.Context, s store.Store, secrets, id string) (secret, bool, error) {
+	var docs []map[string]interface{}
+	if err := s.ListEntities(ctx, secrets, url.Values{"subscription": {id}}, &docs); err != nil || len(docs) == 0 {
+		return secret{}, false, err
+	}
+	var result secret
+	err := decode(docs[0], &result)
+	return result, err == nil, err
+}
+
+// setSecret stores the secret of the subscription with the given id,
+// replacing its current secret if any. Concurrent writes of a new secret are
+// resolved by the unique index on subscription, see Manager.EnsureIndexes:
+// the write that loses the race updates the secret stored by the other.
+func setSecret(ctx context.Context, s store.Store, secrets, id, key string) error {
+	current, found, err := findSecret(ctx, s, secrets, id)
+	if err != nil {
+		return err
+	}
+	result := make(map[string]interface{})
+	if !found {
+		err = s.CreateEntity(ctx, secrets, secret{Subscription: id, Secret: key}, &result)
+		if !errors.Is(err, store.ErrDuplicate) {
+			return err
+		}
+		if current, found, err = findSecret(ctx, s, secrets, id); err != nil {
+			return err
+		}
+		if !found {
+			return store.ErrNotFound
+		}
+	}
+	return s.UpdateEntity(ctx, secrets, current.ID, secret{Subscription: id, Secret: key}, &result)
+}
+
+// DeliveryManager is a read only ResourceManager for the Deliveries recorded
+// by a Dispatcher, for inspecting their attempts.
+type DeliveryManager struct {
+	goresource.DefaultManager
+}
+
+// NewDeliveryManager returns a DeliveryManager for the deliveries in the named
+// collection of the given store.
+func NewDeliveryManager(name string, s store.Store) *DeliveryManager {
+	return &DeliveryManager{goresource.NewDefaultManager(name, s)}
+}
+
+// New returns an empty Delivery.
+func (m *DeliveryManager) New() goresource.Entity {
+	return &Delivery{}
+}
+
+// ParseJSON rejects every delivery, since deliveries are read only.
+func (m *DeliveryManager) ParseJSON(io.ReadCloser) (goresource.Entity, error) {
+	return nil, ErrReadOnly
+}
+
+// DeleteEntity returns ErrReadOnly.
+func (m *DeliveryManager) DeleteEntity(context.Context, string, url.Values) error {
+	return ErrReadOnly
+}
+
+// get fetches the entity with the given id into v, through a map so stored
+// ids of any type decode as strings.
+func get(ctx context.Context, s store.Store, name, id string, v interface{}) error {
+	result := make(map[string]interface{})
+	if err := s.GetEntity(ctx, name, id, &result); err != nil {
+		return err
+	}
+	return decode(result, v)
+}
+
+// decode converts a stored entity into v, round tripping through json.
+func decode(doc map[string]interface{}, v interface{}) error {
+	if _, ok := doc["id"]; !ok {
+		doc["id"] = doc["_id"]
+	}
+	delete(doc, "_id")
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// toMap converts the given value into a map of its json fields.
+func toMap(v interface{}) map[string]interface{} {
+	doc := make(map[string]interface{})
+	if data, err := json.Marshal(v); err == nil {
+		json.Unmarshal(data, &doc)
+	}
+	return doc
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"goresource/store"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestWebhook(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Webhook Suite")
+}
+
+// memStore is an in-memory store of json documents, enforcing unique indexes
+// on a single field.
+type memStore struct {
+	mu     sync.Mutex
+	last   int
+	docs   map[string]map[string]map[string]interface{}
+	unique map[string][]string
+}
+
+func newMemStore() *memStore {
+	return &memStore{docs: make(map[string]map[string]map[string]interface{}), unique: make(map[string][]string)}
+}
+
+func (s *memStore) EnsureIndexes(_ context.Context, name string, indexes []store.Index) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, index := range indexes {
+		if index.Unique {
+			s.unique[name] = append(s.unique[name], index.Key[0])
+		}
+	}
+	return nil
+}
+
+// convert copies from into to through json.
+func convert(from, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, to)
+}
+
+func (s *memStore) GetEntity(_ context.Context, name string, id string, result interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, ok := s.docs[name][id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	return convert(doc, result)
+}
+
+func (s *memStore) CreateEntity(_ context.Context, name string, data interface{}, result interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc := make(map[string]interface{})
+	if err := convert(data, &doc); err != nil {
+		return err
+	}
+	for _, field := range s.unique[name] {
+		for _, other := range s.docs[name] {
+			if other[field] == doc[field] {
+				return store.ErrDuplicate
+			}
+		}
+	}
+	s.last++
+	doc["id"] = strconv.Itoa(s.last)
+	if s.docs[name] == nil {
+		s.docs[name] = make(map[string]map[string]interface{})
+	}
+	s.docs[name][doc["id"].(string)] = doc
+	return convert(doc, result)
+}
+
+func (s *memStore) ListEntities(_ context.Context, name string, filters url.Values, result interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	match, err := store.Matcher(filters)
+	if err != nil {
+		return err
+	}
+	docs := make([]map[string]interface{}, 0)
+	for i := 1; i <= s.last; i++ {
+		if doc, ok := s.docs[name][strconv.Itoa(i)]; ok && match(doc) {
+			docs = append(docs, doc)
+		}
+	}
+	return convert(docs, result)
+}
+
+func (s *memStore) UpdateEntity(_ context.Context, name string, id string, data interface{}, result interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.docs[name][id]; !ok {
+		return store.ErrNotFound
+	}
+	doc := make(map[string]interface{})
+	if err := convert(data, &doc); err != nil {
+		return err
+	}
+	doc["id"] = id
+	s.docs[name][id] = doc
+	return convert(doc, result)
+}
+
+func (s *memStore) DeleteEntity(_ context.Context, name string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.docs[name][id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.docs[name], id)
+	return nil
+}
+
+func (s *memStore) Close() {}
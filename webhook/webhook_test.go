@@ -0,0 +1,186 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"goresource"
+	"goresource/codec"
+	storepkg "goresource/store"
+	"goresource/webhook"
+
+	"github.com/gorilla/mux"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Subscription", func() {
+	sub := webhook.Subscription{URL: "https://example.com/hook", Resource: "books"}
+
+	It("validates its url, resource, events and filter.", func() {
+		Expect(sub.Validate()).To(Succeed())
+		invalid := []webhook.Subscription{
+			{URL: "example.com/hook", Resource: "books"},
+			{URL: "ftp://example.com", Resource: "books"},
+			{URL: "https://example.com/hook"},
+			{URL: "https://example.com/hook", Resource: "books", Events: []string{"read"}},
+			{URL: "https://example.com/hook", Resource: "books", Filter: "name~=("},
+			{URL: "https://example.com/hook", Resource: "books", Filter: "%"},
+		}
+		for _, s := range invalid {
+			Expect(s.Validate()).NotTo(Succeed())
+		}
+	})
+
+	It("matches events by resource, action and filter.", func() {
+		event := storepkg.OutboxEvent{Collection: "books", Action: goresource.AuditCreate,
+			Entity: map[string]interface{}{"genre": "tech"}}
+		Expect(sub.Matches(event)).To(BeTrue())
+		Expect(sub.Matches(storepkg.OutboxEvent{Collection: "authors", Action: goresource.AuditCreate})).To(BeFalse())
+
+		s := sub
+		s.Events = []string{goresource.AuditUpdate, goresource.AuditDelete}
+		Expect(s.Matches(event)).To(BeFalse())
+		s.Events = nil
+		s.Filter = "genre=tech"
+		Expect(s.Matches(event)).To(BeTrue())
+		s.Filter = "genre=fiction"
+		Expect(s.Matches(event)).To(BeFalse())
+	})
+})
+
+var _ = Describe("Manager", func() {
+	var (
+		store  *memStore
+		router *mux.Router
+	)
+
+	// do serves a request with the given json body, returning the response and its decoded body.
+	do := func(method, target, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		result := make(map[string]interface{})
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return w, result
+	}
+
+	// secretOf returns the stored secret of the subscription with the given id.
+	secretOf := func(id string) string {
+		for _, doc := range store.docs["webhooks"+webhook.SecretsSuffix] {
+			if doc["subscription"] == id {
+				return doc["secret"].(string)
+			}
+		}
+		return ""
+	}
+
+	BeforeEach(func() {
+		store = newMemStore()
+		router = mux.NewRouter()
+		goresource.NewResource(webhook.NewManager("webhooks", store), router)
+		goresource.NewResource(webhook.NewDeliveryManager("deliveries", store), router)
+	})
+
+	It("serves subscriptions without their secrets.", func() {
+		w, created := do("POST", "/webhooks", `{"url": "https://example.com/hook", "resource": "books", "secret": "s3cret"}`)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(created).NotTo(HaveKey("secret"))
+		id := created["id"].(string)
+		Expect(store.docs["webhooks"][id]).NotTo(HaveKey("secret"))
+		Expect(secretOf(id)).To(Equal("s3cret"))
+
+		_, got := do("GET", "/webhooks/"+id, "")
+		Expect(got["url"]).To(Equal("https://example.com/hook"))
+		Expect(got).NotTo(HaveKey("secret"))
+		w, _ = do("GET", "/webhooks", "")
+		Expect(w.Body.String()).NotTo(ContainSubstring("s3cret"))
+	})
+
+	It("keeps the secret of subscriptions updated without one.", func() {
+		_, created := do("POST", "/webhooks", `{"url": "https://example.com/hook", "resource": "books", "secret": "s3cret"}`)
+		id := created["id"].(string)
+		w, _ := do("PUT", "/webhooks/"+id, `{"url": "https://example.com/v2", "resource": "books"}`)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(store.docs["webhooks"][id]["url"]).To(Equal("https://example.com/v2"))
+		Expect(secretOf(id)).To(Equal("s3cret"))
+		do("PUT", "/webhooks/"+id, `{"url": "https://example.com/v2", "resource": "books", "secret": "rotated"}`)
+		Expect(secretOf(id)).To(Equal("rotated"))
+		Expect(store.docs["webhooks"+webhook.SecretsSuffix]).To(HaveLen(1))
+	})
+
+	It("keeps a single secret per subscription written concurrently.", func() {
+		manager := webhook.NewManager("webhooks", store)
+		Expect(manager.EnsureIndexes(context.Background())).To(Succeed())
+		_, created := do("POST", "/webhooks", `{"url": "https://example.com/hook", "resource": "books"}`)
+		id := created["id"].(string)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				sub := &webhook.Subscription{URL: "https://example.com/hook", Resource: "books", Secret: strconv.Itoa(i)}
+				_, err := manager.UpdateEntity(context.Background(), id, sub, nil)
+				Expect(err).To(BeNil())
+			}(i)
+		}
+		wg.Wait()
+		Expect(store.docs["webhooks"+webhook.SecretsSuffix]).To(HaveLen(1))
+	})
+
+	It("never streams or aggregates secrets.", func() {
+		do("POST", "/webhooks", `{"url": "https://example.com/hook", "resource": "books", "secret": "topsecret"}`)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/webhooks", nil)
+		req.Header.Set("Accept", codec.NDJSONType)
+		router.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(ContainSubstring("example.com/hook"))
+		Expect(w.Body.String()).NotTo(ContainSubstring("topsecret"))
+
+		w, _ = do("GET", "/webhooks/aggregate?group=resource", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).NotTo(ContainSubstring("topsecret"))
+		for _, target := range []string{
+			"/webhooks/aggregate?group=secret",
+			"/webhooks/aggregate?group=resource&metric=max:secret",
+			"/webhooks?secret=topsecret",
+			"/webhooks/count?secret~=^top",
+		} {
+			w, _ = do("GET", target, "")
+			Expect(w.Code).To(Equal(http.StatusBadRequest), target)
+			Expect(w.Body.String()).NotTo(ContainSubstring("topsecret"))
+		}
+	})
+
+	It("removes the secret of deleted subscriptions.", func() {
+		_, created := do("POST", "/webhooks", `{"url": "https://example.com/hook", "resource": "books", "secret": "s3cret"}`)
+		w, _ := do("DELETE", "/webhooks/"+created["id"].(string), "")
+		Expect(w.Code).To(Equal(http.StatusNoContent))
+		Expect(store.docs["webhooks"+webhook.SecretsSuffix]).To(BeEmpty())
+	})
+
+	It("rejects invalid subscriptions.", func() {
+		w, _ := do("POST", "/webhooks", `{"url": "example.com", "resource": "books"}`)
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("serves deliveries read only.", func() {
+		w, _ := do("POST", "/deliveries", `{"subscription": "1"}`)
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		store.CreateEntity(context.Background(), "deliveries", webhook.Delivery{Subscription: "1", Status: webhook.StatusPending}, &map[string]interface{}{})
+		w, got := do("GET", "/deliveries/1", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(got["status"]).To(Equal(webhook.StatusPending))
+		w, _ = do("DELETE", "/deliveries/1", "")
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+	})
+})
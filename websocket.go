@@ -0,0 +1,231 @@
+package goresource
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rockstardevs/goresource/store"
+)
+
+// Subscription message types. Clients send subscribe and unsubscribe
+// messages, the server responds with subscribed, unsubscribed or error
+// messages and pushes event messages.
+const (
+	MessageSubscribe    = "subscribe"
+	MessageUnsubscribe  = "unsubscribe"
+	MessageSubscribed   = "subscribed"
+	MessageUnsubscribed = "unsubscribed"
+	MessageEvent        = "event"
+	MessageError        = "error"
+)
+
+// SubscriptionMessage is a message exchanged over a resource's websocket.
+type SubscriptionMessage struct {
+	Type string `json:"type"`
+	// ID identifies the subscription, chosen by the client.
+	ID string `json:"id,omitempty"`
+	// IDs, if set, subscribe to changes to the entities with these ids only.
+	IDs []string `json:"ids,omitempty"`
+	// Filter, if set, subscribes to changes to entities matching it, in the
+	// query syntax of list requests, for example genre=tech&title~=^go.
+	Filter string       `json:"filter,omitempty"`
+	Event  *ChangeEvent `json:"event,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// SubscriptionAuthorizer decides whether the client that opened the given
+// websocket request may make the given subscription, returning an error to
+// refuse it.
+type SubscriptionAuthorizer func(req *http.Request, sub SubscriptionMessage) error
+
+// WithSubscriptionAuthorizer authorizes each websocket subscription with the
+// given function, by default all subscriptions are allowed.
+func WithSubscriptionAuthorizer(authorize SubscriptionAuthorizer) Option {
+	return func(r *Resource) {
+		r.authorize = authorize
+	}
+}
+
+// upgrader upgrades websocket requests, accepting same origin requests only.
+var upgrader = websocket.Upgrader{}
+
+// maxSubscriptionMessage is the size of the largest message read from
+// websocket clients, which are disconnected if they send larger ones.
+const maxSubscriptionMessage = 64 * 1024
+
+// subscription is an active websocket subscription.
+type subscription struct {
+	ids   map[string]bool
+	match func(map[string]interface{}) bool
+}
+
+// socket is a websocket connection to a resource, serializing its writes.
+type socket struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (s *socket) send(msg SubscriptionMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return s.conn.WriteJSON(msg)
+}
+
+func (s *socket) ping() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+}
+
+// WebSocket is the http handler for websocket subscriptions to changes to this
+// resource's entities, published to its change feed. Clients subscribe to ids
+// or filters with SubscriptionMessages and are pushed an event message per
+// subscription matching each change.
+func (r Resource) WebSocket(rw http.ResponseWriter, req *http.Request) {
+	if r.changes == nil {
+		writeError(rw, "Method Not Supported", http.StatusNotImplemented)
+		return
+	}
+	conn, err := upgrader.Upgrade(rw, req, nil)
+	if err != nil {
+		// The upgrader has already responded with the error.
+		return
+	}
+	defer conn.Close()
+	feed := r.changes.Listen(r.manager.GetName())
+	defer feed.Close()
+	var (
+		ws     = &socket{conn: conn}
+		mu     sync.Mutex
+		subs   = make(map[string]subscription)
+		closed = make(chan struct{})
+	)
+	heartbeat := r.changes.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeat
+	}
+	// Clients neither answering pings nor sending messages for two heartbeats
+	// are disconnected.
+	alive := func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+	}
+	alive("")
+	conn.SetReadLimit(maxSubscriptionMessage)
+	conn.SetPongHandler(alive)
+	go func() {
+		defer close(closed)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			alive("")
+			var (
+				msg   SubscriptionMessage
+				reply SubscriptionMessage
+			)
+			if err := json.Unmarshal(data, &msg); err != nil {
+				reply = SubscriptionMessage{Type: MessageError, Error: err.Error()}
+			} else {
+				reply = r.handleMessage(req, msg, func(update func(map[string]subscription)) {
+					mu.Lock()
+					defer mu.Unlock()
+					update(subs)
+				})
+			}
+			if ws.send(reply) != nil {
+				return
+			}
+		}
+	}()
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-feed.C:
+			if !ok {
+				// Fell behind the feed, the client should resubscribe.
+				ws.mu.Lock()
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber fell behind"), time.Now().Add(time.Second))
+				ws.mu.Unlock()
+				return
+			}
+			if err := r.push(ws, e, &mu, subs); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if ws.ping() != nil {
+				return
+			}
+		case <-closed:
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// handleMessage applies a client's message to its subscriptions, returning the reply.
+func (r Resource) handleMessage(req *http.Request, msg SubscriptionMessage, update func(func(map[string]subscription))) SubscriptionMessage {
+	fail := func(err error) SubscriptionMessage {
+		return SubscriptionMessage{Type: MessageError, ID: msg.ID, Error: err.Error()}
+	}
+	if msg.ID == "" {
+		return fail(fmt.Errorf("%s message without an id", msg.Type))
+	}
+	switch msg.Type {
+	case MessageSubscribe:
+		query, err := url.ParseQuery(msg.Filter)
+		if err != nil {
+			return fail(err)
+		}
+		match, err := store.Matcher(filters(r.scopeQuery(req, query)))
+		if err != nil {
+			return fail(err)
+		}
+		if r.authorize != nil {
+			if err := r.authorize(req, msg); err != nil {
+				return fail(err)
+			}
+		}
+		sub := subscription{match: match}
+		if len(msg.IDs) > 0 {
+			sub.ids = make(map[string]bool)
+			for _, id := range msg.IDs {
+				sub.ids[id] = true
+			}
+		}
+		update(func(subs map[string]subscription) { subs[msg.ID] = sub })
+		return SubscriptionMessage{Type: MessageSubscribed, ID: msg.ID}
+	case MessageUnsubscribe:
+		update(func(subs map[string]subscription) { delete(subs, msg.ID) })
+		return SubscriptionMessage{Type: MessageUnsubscribed, ID: msg.ID}
+	}
+	return fail(fmt.Errorf("unknown message type %q", msg.Type))
+}
+
+// push sends the given event to each matching subscription.
+func (r Resource) push(ws *socket, e ChangeEvent, mu *sync.Mutex, subs map[string]subscription) error {
+	fields := toFields(e.Entity)
+	var matched []string
+	mu.Lock()
+	for id, sub := range subs {
+		if (sub.ids == nil || sub.ids[e.EntityID]) && sub.match(fields) {
+			matched = append(matched, id)
+		}
+	}
+	mu.Unlock()
+	for _, id := range matched {
+		if err := ws.send(SubscriptionMessage{Type: MessageEvent, ID: id, Event: &e}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package goresource_test
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"goresource"
+	"goresource/mocks"
+	storepkg "goresource/store"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WebSocket", func() {
+	var (
+		ctrl   *gomock.Controller
+		store  *mocks.MockStore
+		feed   *goresource.ChangeFeed
+		server *httptest.Server
+		conn   *websocket.Conn
+	)
+
+	// serve serves the books resource with the given options and connects to its websocket.
+	serve := func(opts ...goresource.Option) {
+		router := mux.NewRouter()
+		opts = append(opts, goresource.WithChangeFeed(feed))
+		goresource.NewResource(bookManager{goresource.NewDefaultManager("books", store)}, router, opts...)
+		server = httptest.NewServer(router)
+		var err error
+		conn, _, err = websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/books/ws", nil)
+		Expect(err).To(BeNil())
+	}
+
+	// send sends the given message and returns the reply.
+	send := func(msg goresource.SubscriptionMessage) goresource.SubscriptionMessage {
+		Expect(conn.WriteJSON(msg)).To(Succeed())
+		var reply goresource.SubscriptionMessage
+		Expect(conn.ReadJSON(&reply)).To(Succeed())
+		return reply
+	}
+
+	// receive returns the next message pushed to the connection.
+	receive := func() goresource.SubscriptionMessage {
+		var msg goresource.SubscriptionMessage
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		Expect(conn.ReadJSON(&msg)).To(Succeed())
+		return msg
+	}
+
+	// publish publishes an update to the book with the given id and name.
+	publish := func(id, name string) {
+		feed.Publish(goresource.ChangeEvent{Resource: "books", Action: goresource.AuditUpdate, EntityID: id,
+			Entity: map[string]interface{}{"id": id, "name": name}})
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStore(ctrl)
+		feed = goresource.NewChangeFeed(10)
+		server, conn = nil, nil
+	})
+
+	AfterEach(func() {
+		if conn != nil {
+			conn.Close()
+		}
+		if server != nil {
+			server.Close()
+		}
+		ctrl.Finish()
+	})
+
+	It("pushes changes made through the resource to subscriptions.", func() {
+		serve()
+		Expect(send(goresource.SubscriptionMessage{Type: goresource.MessageSubscribe, ID: "all"})).To(Equal(
+			goresource.SubscriptionMessage{Type: goresource.MessageSubscribed, ID: "all"}))
+		store.EXPECT().CreateEntity(gomock.Any(), "books", gomock.Any(), gomock.Any()).
+			SetArg(3, map[string]interface{}{"id": "b1", "name": "a"}).Return(nil)
+		post, err := http.Post(server.URL+"/books", "application/json", strings.NewReader(`{"name": "a"}`))
+		Expect(err).To(BeNil())
+		Expect(post.StatusCode).To(Equal(http.StatusOK))
+
+		msg := receive()
+		Expect(msg.Type).To(Equal(goresource.MessageEvent))
+		Expect(msg.ID).To(Equal("all"))
+		Expect(msg.Event.Action).To(Equal(goresource.AuditCreate))
+		Expect(msg.Event.EntityID).To(Equal("b1"))
+	})
+
+	It("pushes changes to the subscribed ids and filters only.", func() {
+		serve()
+		send(goresource.SubscriptionMessage{Type: goresource.MessageSubscribe, ID: "one", IDs: []string{"2"}})
+		send(goresource.SubscriptionMessage{Type: goresource.MessageSubscribe, ID: "go", Filter: "name~=^go"})
+		publish("1", "rust")
+		publish("2", "rust")
+		publish("3", "gophers")
+
+		msg := receive()
+		Expect(msg.ID).To(Equal("one"))
+		Expect(msg.Event.EntityID).To(Equal("2"))
+		msg = receive()
+		Expect(msg.ID).To(Equal("go"))
+		Expect(msg.Event.EntityID).To(Equal("3"))
+	})
+
+	It("stops pushing changes after unsubscribing.", func() {
+		serve()
+		send(goresource.SubscriptionMessage{Type: goresource.MessageSubscribe, ID: "one", IDs: []string{"1"}})
+		send(goresource.SubscriptionMessage{Type: goresource.MessageSubscribe, ID: "two", IDs: []string{"2"}})
+		Expect(send(goresource.SubscriptionMessage{Type: goresource.MessageUnsubscribe, ID: "one"})).To(Equal(
+			goresource.SubscriptionMessage{Type: goresource.MessageUnsubscribed, ID: "one"}))
+		publish("1", "a")
+		publish("2", "b")
+		Expect(receive().ID).To(Equal("two"))
+	})
+
+	It("authorizes subscriptions per connection.", func() {
+		serve(goresource.WithSubscriptionAuthorizer(func(req *http.Request, sub goresource.SubscriptionMessage) error {
+			Expect(req.URL.Path).To(Equal("/books/ws"))
+			if len(sub.IDs) == 0 {
+				return errors.New("ids required")
+			}
+			return nil
+		}))
+		Expect(send(goresource.SubscriptionMessage{Type: goresource.MessageSubscribe, ID: "all"})).To(Equal(
+			goresource.SubscriptionMessage{Type: goresource.MessageError, ID: "all", Error: "ids required"}))
+		Expect(send(goresource.SubscriptionMessage{Type: goresource.MessageSubscribe, ID: "one", IDs: []string{"1"}}).Type).
+			To(Equal(goresource.MessageSubscribed))
+	})
+
+	It("replies with errors to invalid messages.", func() {
+		serve()
+		Expect(send(goresource.SubscriptionMessage{Type: goresource.MessageSubscribe, ID: "bad", Filter: "name~=("}).Type).
+			To(Equal(goresource.MessageError))
+		Expect(send(goresource.SubscriptionMessage{Type: goresource.MessageSubscribe}).Type).
+			To(Equal(goresource.MessageError))
+		Expect(send(goresource.SubscriptionMessage{Type: "publish", ID: "x"}).Error).
+			To(Equal(`unknown message type "publish"`))
+		Expect(conn.WriteMessage(websocket.TextMessage, []byte("{"))).To(Succeed())
+		Expect(receive().Type).To(Equal(goresource.MessageError))
+	})
+
+	It("disconnects clients sending overly large messages.", func() {
+		serve()
+		Expect(conn.WriteMessage(websocket.TextMessage, []byte(strings.Repeat(" ", 65*1024)))).To(Succeed())
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, _, err := conn.ReadMessage()
+		Expect(websocket.IsCloseError(err, websocket.CloseMessageTooBig)).To(BeTrue())
+	})
+
+	It("disconnects clients not answering pings.", func() {
+		feed.Heartbeat = 10 * time.Millisecond
+		serve()
+		// Pings are only answered while reading, so the client stops answering.
+		time.Sleep(50 * time.Millisecond)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var err error
+		for err == nil {
+			_, _, err = conn.ReadMessage()
+		}
+		// The server closed the connection, before the client's read deadline.
+		var netErr net.Error
+		Expect(errors.As(err, &netErr) && netErr.Timeout()).To(BeFalse())
+	})
+
+	It("is not served without a change feed.", func() {
+		router := mux.NewRouter()
+		goresource.NewResource(bookManager{goresource.NewDefaultManager("books", store)}, router)
+		store.EXPECT().GetEntity(gomock.Any(), "books", "ws", gomock.Any()).Return(storepkg.ErrNotFound)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/books/ws", nil))
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+	})
+})